@@ -7,14 +7,18 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	httpserver "citizenapp/backend/internal/httpgin"
+	"citizenapp/backend/internal/mail"
+	"citizenapp/backend/internal/realtime"
 	"citizenapp/backend/internal/repository"
 	"citizenapp/backend/internal/service"
 	_ "github.com/jackc/pgx/v5/stdlib"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func main() {
@@ -32,21 +36,108 @@ func main() {
 		log.Fatalf("cannot reach database: %v", err)
 	}
 
-	// 2.- Extraemos la clave JWT requerida para firmar tokens.
+	// 2.- Cargamos las llaves de firma; JWT_SECRET sólo es obligatorio sin llaves asimétricas.
 	jwtSecret := strings.TrimSpace(os.Getenv("JWT_SECRET"))
-	if jwtSecret == "" {
-		log.Fatal("JWT_SECRET environment variable is required")
+	keyRing := keyRingFromEnv()
+	if jwtSecret == "" && keyRing == nil {
+		log.Fatal("JWT_SIGNING_KEY or JWT_SECRET environment variable is required")
 	}
 
 	// 3.- Inicializamos los servicios concurrentes requeridos por el API.
 	userRepo := repository.NewPostgresUserRepository(db)
 	reportRepo := repository.NewPostgresReportRepository(db)
-	authService := service.NewAuthService(userRepo, 4, 8*time.Hour, []byte(jwtSecret))
+	accessTTL := durationFromEnv("ACCESS_TOKEN_TTL", 15*time.Minute)
+	refreshTTL := durationFromEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+	refreshStore := repository.NewPostgresRefreshTokenStore(db)
+	revocationStore := repository.NewPostgresRevocationStore(db)
+	loginAttempts := repository.NewPostgresLoginAttemptStore(db)
+	lockoutPolicy := service.LockoutPolicy{
+		AccountThreshold: intFromEnv("LOGIN_LOCKOUT_THRESHOLD", 5),
+		IPThreshold:      intFromEnv("LOGIN_IP_LOCKOUT_THRESHOLD", 20),
+		BaseDelay:        durationFromEnv("LOGIN_LOCKOUT_BASE", time.Minute),
+		MaxDelay:         durationFromEnv("LOGIN_LOCKOUT_MAX", time.Hour),
+		Window:           durationFromEnv("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+	}
+	sessionStore := repository.NewPostgresSessionStore(db)
+	// El hub se comparte para que revocar una sesión también cierre sus WebSockets.
+	hub := realtime.NewHub(8, 8, 4096, 128)
+	revocations := service.NewRevocationCache(revocationStore, durationFromEnv("REVOCATION_CACHE_TTL", 30*time.Second))
+	authOpts := []service.AuthOption{
+		service.WithRefreshTokens(refreshStore, refreshTTL),
+		service.WithRevocation(revocations),
+		service.WithAccountStatusCache(durationFromEnv("ACCOUNT_STATUS_CACHE_TTL", 30*time.Second)),
+		service.WithOneTimeTokens(repository.NewPostgresOneTimeTokenStore(db)),
+		service.WithMailer(mailerFromEnv()),
+		service.WithPasswordReset(durationFromEnv("RESET_TOKEN_TTL", 30*time.Minute), os.Getenv("RESET_URL")),
+		service.WithInvitations(durationFromEnv("INVITE_TOKEN_TTL", 72*time.Hour), os.Getenv("INVITE_URL")),
+		service.WithSocialLogin(repository.NewPostgresIdentityRepository(db), socialProvidersFromEnv()...),
+		service.WithLoginLockout(loginAttempts, lockoutPolicy),
+		service.WithEmailVerification(durationFromEnv("VERIFY_TOKEN_TTL", 48*time.Hour), os.Getenv("VERIFY_URL")),
+		service.WithUnverifiedPermissions(permissionsFromEnv("UNVERIFIED_PERMISSIONS", "reports:submit")...),
+		service.WithMFA(repository.NewPostgresMFARepository(db), os.Getenv("MFA_ISSUER"), rolesFromEnv("MFA_REQUIRED_ROLES", "supervisor,admin")...),
+		service.WithPasswordHasher(passwordHasherFromEnv()),
+		service.WithPasswordPolicy(passwordPolicyFromEnv()),
+		service.WithSessions(sessionStore),
+		service.WithSessionListener(hub),
+		service.WithAPIKeys(repository.NewPostgresAPIKeyStore(db)),
+		service.WithAnonymousAccess(durationFromEnv("ANONYMOUS_TOKEN_TTL", time.Hour), intFromEnv("ANONYMOUS_TOKENS_PER_IP", 20)),
+	}
+	if keyRing != nil {
+		authOpts = append(authOpts, service.WithSigningKeys(keyRing))
+	}
+	authService := service.NewAuthService(userRepo, 4, accessTTL, []byte(jwtSecret), authOpts...)
+	go purgeExpired("revoked tokens", revocationStore.PurgeExpired, 0)
+	go purgeExpired("login attempts", loginAttempts.PurgeExpired, lockoutPolicy.Window)
+	go purgeExpired("sessions", sessionStore.PurgeExpired, refreshTTL)
 	catalogService := service.NewCatalogService(2)
-	reportService := service.NewReportService(reportRepo, 4, 4)
+	folioPrefix := strings.TrimSpace(os.Getenv("FOLIO_PREFIX"))
+	if folioPrefix == "" {
+		folioPrefix = "F"
+	}
+	folios, err := service.NewSequenceFolioGenerator(repository.NewPostgresFolioSequence(db), folioPrefix)
+	if err != nil {
+		log.Fatalf("invalid %s: %q", "FOLIO_PREFIX", folioPrefix)
+	}
+	workflow := service.DefaultWorkflow()
+	if path := strings.TrimSpace(os.Getenv("WORKFLOW_FILE")); path != "" {
+		loaded, err := service.LoadWorkflow(path)
+		if err != nil {
+			log.Fatalf("cannot load workflow: %v", err)
+		}
+		workflow = loaded
+	}
+	reportOpts := []service.ReportOption{
+		service.WithWorkflow(workflow),
+		service.WithDepartments(repository.NewPostgresDepartmentStore(db)),
+		service.WithStaffNotes(repository.NewPostgresNoteStore(db), authService, durationFromEnv("NOTE_EDIT_WINDOW", 15*time.Minute)),
+		service.WithMessages(repository.NewPostgresMessageStore(db)),
+	}
+	if path := strings.TrimSpace(os.Getenv("SLA_FILE")); path != "" {
+		sla, err := service.LoadSLA(path, workflow)
+		if err != nil {
+			log.Fatalf("cannot load sla: %v", err)
+		}
+		reportOpts = append(reportOpts, service.WithSLA(sla, repository.NewPostgresSLAStore(db), authService))
+	}
+	reportService := service.NewReportService(reportRepo, folios, 4, 4, reportOpts...)
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+	go reportService.RunSLAScheduler(schedulerCtx, durationFromEnv("SLA_CHECK_INTERVAL", 5*time.Minute))
 
 	// 4.- Construimos el enrutador HTTP basado en los servicios previos.
-	srv := httpserver.New(authService, catalogService, reportService)
+	for _, email := range strings.Split(os.Getenv("BOOTSTRAP_ADMIN_EMAILS"), ",") {
+		if strings.TrimSpace(email) == "" {
+			continue
+		}
+		if err := authService.AssignRole(context.Background(), email, service.RoleAdmin); err != nil {
+			log.Printf("cannot bootstrap admin %s: %v", strings.TrimSpace(email), err)
+		}
+	}
+	serverOpts := []httpserver.Option{httpserver.WithRealtimeHub(hub)}
+	if proxies := strings.TrimSpace(os.Getenv("TRUSTED_PROXIES")); proxies != "" {
+		serverOpts = append(serverOpts, httpserver.WithTrustedProxies(strings.Split(proxies, ",")...))
+	}
+	srv := httpserver.New(authService, catalogService, reportService, serverOpts...)
 	handler := srv.Router()
 
 	// 5.- Configuramos el servidor tomando el puerto del entorno si existe.
@@ -83,3 +174,228 @@ func main() {
 		log.Printf("realtime shutdown error: %v", err)
 	}
 }
+
+// 7.- durationFromEnv interpreta duraciones opcionales como "15m" o "720h".
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback
+	}
+	value, err := time.ParseDuration(raw)
+	if err != nil || value <= 0 {
+		log.Fatalf("invalid %s: %q", key, raw)
+	}
+	return value
+}
+
+// 7.1.- intFromEnv interpreta enteros positivos opcionales.
+func intFromEnv(key string, fallback int) int {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value <= 0 {
+		log.Fatalf("invalid %s: %q", key, raw)
+	}
+	return value
+}
+
+// 7.2.- permissionsFromEnv lee una lista de permisos separados por coma y rechaza los desconocidos.
+func permissionsFromEnv(key, fallback string) []service.Permission {
+	raw, ok := os.LookupEnv(key)
+	if !ok {
+		raw = fallback
+	}
+	var perms []service.Permission
+	for _, name := range strings.Split(raw, ",") {
+		perm := service.Permission(strings.TrimSpace(name))
+		if perm == "" {
+			continue
+		}
+		// El rol admin acumula todos los permisos, así que sirve como catálogo.
+		if !service.RoleHasPermission(service.RoleAdmin, perm) {
+			log.Fatalf("invalid %s: unknown permission %q", key, perm)
+		}
+		perms = append(perms, perm)
+	}
+	return perms
+}
+
+// 7.3.- rolesFromEnv lee una lista de roles separados por coma y rechaza los desconocidos.
+func rolesFromEnv(key, fallback string) []string {
+	raw, ok := os.LookupEnv(key)
+	if !ok {
+		raw = fallback
+	}
+	var roles []string
+	for _, name := range strings.Split(raw, ",") {
+		role := strings.TrimSpace(strings.ToLower(name))
+		if role == "" {
+			continue
+		}
+		if !service.ValidRole(role) {
+			log.Fatalf("invalid %s: unknown role %q", key, role)
+		}
+		roles = append(roles, role)
+	}
+	return roles
+}
+
+// 7.4.- passwordHasherFromEnv elige el esquema de los hashes nuevos; los anteriores se migran al iniciar sesión.
+func passwordHasherFromEnv() service.PasswordHasher {
+	switch scheme := strings.TrimSpace(strings.ToLower(os.Getenv("PASSWORD_HASH"))); scheme {
+	case "", "argon2id":
+		defaults := service.DefaultArgon2Params()
+		threads := intFromEnv("ARGON2_THREADS", int(defaults.Parallelism))
+		if threads > 255 {
+			log.Fatalf("invalid ARGON2_THREADS: %d", threads)
+		}
+		return service.NewArgon2idHasher(service.Argon2Params{
+			Memory:      uint32(intFromEnv("ARGON2_MEMORY_KIB", int(defaults.Memory))),
+			Iterations:  uint32(intFromEnv("ARGON2_ITERATIONS", int(defaults.Iterations))),
+			Parallelism: uint8(threads),
+		})
+	case "bcrypt":
+		cost := intFromEnv("BCRYPT_COST", bcrypt.DefaultCost)
+		if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			log.Fatalf("invalid BCRYPT_COST: %d", cost)
+		}
+		return service.NewBcryptHasher(cost)
+	default:
+		log.Fatalf("invalid PASSWORD_HASH: %q", scheme)
+		return nil
+	}
+}
+
+// 7.5.- passwordPolicyFromEnv suma las palabras del municipio y la lista de filtraciones a la política base.
+func passwordPolicyFromEnv() service.PasswordPolicy {
+	policy := service.DefaultPasswordPolicy()
+	policy.MinLength = intFromEnv("PASSWORD_MIN_LENGTH", policy.MinLength)
+	policy.MaxLength = intFromEnv("PASSWORD_MAX_LENGTH", policy.MaxLength)
+	if path := strings.TrimSpace(os.Getenv("PASSWORD_DENYLIST_FILE")); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("cannot read password denylist: %v", err)
+		}
+		for _, word := range strings.Split(string(raw), "\n") {
+			if word = strings.TrimSpace(word); word != "" && !strings.HasPrefix(word, "#") {
+				policy.Denylist = append(policy.Denylist, word)
+			}
+		}
+	}
+	dir := strings.TrimSpace(os.Getenv("BREACHED_PASSWORDS_DIR"))
+	path := strings.TrimSpace(os.Getenv("BREACHED_PASSWORDS_FILE"))
+	if dir != "" && path != "" {
+		log.Fatal("set only one of BREACHED_PASSWORDS_DIR or BREACHED_PASSWORDS_FILE")
+	}
+	if dir != "" {
+		ranges, err := service.OpenBreachedPasswordRanges(dir)
+		if err != nil {
+			log.Fatalf("cannot open breached password ranges: %v", err)
+		}
+		log.Printf("checking breached passwords against range files in %s", dir)
+		policy.Breached = ranges
+	}
+	if path != "" {
+		list, err := service.LoadBreachedPasswordList(path)
+		if err != nil {
+			log.Fatalf("cannot load breached passwords: %v", err)
+		}
+		log.Printf("loaded %d breached password hashes", list.Len())
+		policy.Breached = list
+	}
+	return policy
+}
+
+// 8.- purgeExpired limpia cada hora las filas vencidas hace más de retention.
+func purgeExpired(name string, purge func(context.Context, time.Time) error, retention time.Duration) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := purge(ctx, time.Now().Add(-retention)); err != nil {
+			log.Printf("cannot purge %s: %v", name, err)
+		}
+		cancel()
+	}
+}
+
+// 9.- mailerFromEnv elige SMTP si está configurado, luego archivos; la memoria exige MAIL_MEMORY=true.
+func mailerFromEnv() service.Mailer {
+	from := strings.TrimSpace(os.Getenv("MAIL_FROM"))
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+	if addr := strings.TrimSpace(os.Getenv("SMTP_ADDR")); addr != "" {
+		return mail.NewSMTPMailer(mail.SMTPConfig{
+			Addr:     addr,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		})
+	}
+	if dir := strings.TrimSpace(os.Getenv("MAIL_DIR")); dir != "" {
+		mailer, err := mail.NewFileMailer(dir, from)
+		if err != nil {
+			log.Fatalf("cannot prepare mail dir: %v", err)
+		}
+		return mailer
+	}
+	// La bandeja en memoria guarda tokens vigentes sin límite; sólo se acepta en desarrollo y de forma explícita.
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("MAIL_MEMORY")), "true") {
+		log.Print("MAIL_MEMORY=true; outgoing mail is kept in memory only")
+		return mail.NewMemoryMailer()
+	}
+	log.Fatal("SMTP_ADDR or MAIL_DIR environment variable is required (set MAIL_MEMORY=true for local development)")
+	return nil
+}
+
+// 10.- socialProvidersFromEnv carga los proveedores OpenID Connect declarados en SOCIAL_PROVIDERS_FILE.
+func socialProvidersFromEnv() []*service.SocialProvider {
+	path := strings.TrimSpace(os.Getenv("SOCIAL_PROVIDERS_FILE"))
+	if path == "" {
+		return nil
+	}
+	configs, err := service.LoadSocialProviders(path)
+	if err != nil {
+		log.Fatalf("cannot load social providers: %v", err)
+	}
+	providers := make([]*service.SocialProvider, 0, len(configs))
+	for _, cfg := range configs {
+		provider, err := service.NewSocialProvider(cfg, nil)
+		if err != nil {
+			log.Fatalf("invalid social provider: %v", err)
+		}
+		providers = append(providers, provider)
+	}
+	return providers
+}
+
+// 11.- keyRingFromEnv arma el anillo con JWT_SIGNING_KEY activa y JWT_VERIFICATION_KEYS para rotación.
+func keyRingFromEnv() *service.KeyRing {
+	path := strings.TrimSpace(os.Getenv("JWT_SIGNING_KEY"))
+	if path == "" {
+		return nil
+	}
+	active, err := service.LoadSigningKey(path)
+	if err != nil {
+		log.Fatalf("cannot load signing key: %v", err)
+	}
+	var verification []*service.SigningKey
+	for _, extra := range strings.Split(os.Getenv("JWT_VERIFICATION_KEYS"), ",") {
+		if strings.TrimSpace(extra) == "" {
+			continue
+		}
+		key, err := service.LoadSigningKey(strings.TrimSpace(extra))
+		if err != nil {
+			log.Fatalf("cannot load verification key: %v", err)
+		}
+		verification = append(verification, key)
+	}
+	ring, err := service.NewKeyRing(active, verification...)
+	if err != nil {
+		log.Fatalf("invalid signing keys: %v", err)
+	}
+	return ring
+}
@@ -1,6 +1,9 @@
 package dto
 
-import "strings"
+import (
+	"strings"
+	"time"
+)
 
 // 1.- Package dto centraliza los contratos de entrada para el gateway HTTP.
 
@@ -15,6 +18,123 @@ type RecoverRequest struct {
 	Email string `json:"email" validate:"required,email"`
 }
 
+// 3.1.- RefreshRequest transporta el token opaco que se rotará en /auth/refresh.
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required,min=16,max=256"`
+}
+
+// 3.2.- LogoutRequest permite revocar también la familia del token de renovación.
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"omitempty,max=256"`
+}
+
+// 3.3.- ResetPasswordRequest canjea el token recibido por correo por una nueva contraseña.
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required,min=16,max=256"`
+	Password string `json:"password" validate:"required,min=8"`
+}
+
+// 3.4.- SocialLoginRequest acepta el ID token del proveedor o un código de autorización.
+type SocialLoginRequest struct {
+	IDToken      string `json:"idToken" validate:"required_without=Code,omitempty,max=8192"`
+	Code         string `json:"code" validate:"required_without=IDToken,omitempty,max=2048"`
+	CodeVerifier string `json:"codeVerifier" validate:"omitempty,max=128"`
+	RedirectURI  string `json:"redirectUri" validate:"omitempty,url"`
+	Nonce        string `json:"nonce" validate:"omitempty,max=256"`
+}
+
+// 3.5.- VerifyEmailRequest confirma el correo con el token enviado al registrarse.
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required,min=16,max=256"`
+}
+
+// 3.6.- MFAChallengeRequest presenta el reto devuelto por el login para inscribirse.
+type MFAChallengeRequest struct {
+	MFAToken string `json:"mfaToken" validate:"required,max=4096"`
+}
+
+// 3.7.- MFAVerifyRequest completa el login con un código TOTP o de recuperación.
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfaToken" validate:"required,max=4096"`
+	Code     string `json:"code" validate:"required,min=6,max=16"`
+}
+
+// 3.8.- MFACodeRequest confirma la inscripción con el primer código generado.
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// 3.9.- UpdateProfileRequest modifica sólo los campos presentes; una cadena vacía borra el valor.
+type UpdateProfileRequest struct {
+	DisplayName   *string                         `json:"displayName" validate:"omitempty,max=80"`
+	Phone         *string                         `json:"phone" validate:"omitempty,phone_digits"`
+	Notifications *NotificationPreferencesRequest `json:"notifications"`
+}
+
+// 3.10.- NotificationPreferencesRequest exige los tres canales para no apagar alguno por omisión.
+type NotificationPreferencesRequest struct {
+	Email *bool `json:"email" validate:"required"`
+	Push  *bool `json:"push" validate:"required"`
+	SMS   *bool `json:"sms" validate:"required"`
+}
+
+// 3.11.- ChangePasswordRequest confirma la contraseña actual antes de reemplazarla.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" validate:"required,max=256"`
+	NewPassword     string `json:"newPassword" validate:"required,min=8"`
+}
+
+// 3.11.1.- DeleteAccountRequest confirma el borrado con la contraseña; las cuentas sin contraseña envían un objeto vacío.
+type DeleteAccountRequest struct {
+	CurrentPassword string `json:"currentPassword" validate:"max=256"`
+}
+
+// 3.12.- PushRegistrationRequest asocia el token de notificaciones del dispositivo a la sesión actual.
+type PushRegistrationRequest struct {
+	Provider string `json:"provider" validate:"required,oneof=fcm apns"`
+	Token    string `json:"token" validate:"required,min=1,max=4096"`
+}
+
+// 3.13.- InviteUserRequest crea una cuenta de personal que se configura desde el enlace enviado por correo.
+type InviteUserRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,oneof=citizen operator supervisor admin"`
+}
+
+// 3.14.- ChangeRoleRequest asigna un rol del catálogo a otra cuenta.
+type ChangeRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=citizen operator supervisor admin"`
+}
+
+// 3.15.- AcceptInvitationRequest canjea el token de invitación por la primera contraseña.
+type AcceptInvitationRequest struct {
+	Token    string `json:"token" validate:"required,min=16,max=256"`
+	Password string `json:"password" validate:"required,min=8"`
+}
+
+// 3.16.- CreateAPIKeyRequest define los alcances, la cuota por minuto y la vigencia opcional de una clave.
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,min=1,max=80"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=reports:read reports:write"`
+	RateLimit int        `json:"rateLimit" validate:"omitempty,min=1,max=10000"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// 3.17.- AnonymousTokenRequest identifica la instalación con un valor aleatorio que genera la app.
+type AnonymousTokenRequest struct {
+	InstallID string `json:"installId" validate:"required,min=32,max=128"`
+}
+
+// 3.18.- ClaimReportsRequest presenta el identificador de instalación con el que se enviaron los reportes anónimos.
+type ClaimReportsRequest struct {
+	InstallID string `json:"installId" validate:"required,min=32,max=128"`
+}
+
+// 3.19.- NameRequest da de alta una dependencia o una cuadrilla; ambas sólo requieren nombre.
+type NameRequest struct {
+	Name string `json:"name" validate:"required,min=1,max=120"`
+}
+
 // 4.- ReportSubmissionRequest agrupa la información de un reporte ciudadano.
 type ReportSubmissionRequest struct {
 	IncidentTypeID string   `json:"incidentTypeId" validate:"required,min=1"`
@@ -27,6 +147,23 @@ type ReportSubmissionRequest struct {
 	EvidenceURLs   []string `json:"evidenceUrls" validate:"omitempty,dive,uri"`
 }
 
+// 4.1.- AnonymousReportSubmissionRequest acepta el mismo reporte con contacto opcional para quien no tiene cuenta.
+type AnonymousReportSubmissionRequest struct {
+	IncidentTypeID string   `json:"incidentTypeId" validate:"required,min=1"`
+	Description    string   `json:"description" validate:"required,max=2000"`
+	ContactEmail   string   `json:"contactEmail" validate:"omitempty,email"`
+	ContactPhone   string   `json:"contactPhone" validate:"omitempty,phone_digits"`
+	Latitude       float64  `json:"latitude" validate:"required,gte=-90,lte=90"`
+	Longitude      float64  `json:"longitude" validate:"required,gte=-180,lte=180"`
+	Address        string   `json:"address" validate:"required,min=1,max=250"`
+	EvidenceURLs   []string `json:"evidenceUrls" validate:"omitempty,dive,uri"`
+}
+
+// ToPayload reutiliza la conversión del reporte autenticado; ambos tipos comparten campos.
+func (r AnonymousReportSubmissionRequest) ToPayload() map[string]any {
+	return ReportSubmissionRequest(r).ToPayload()
+}
+
 // 5.- ToPayload transforma la solicitud en un mapa compatible con el servicio existente.
 func (r ReportSubmissionRequest) ToPayload() map[string]any {
 	payload := map[string]any{
@@ -53,7 +190,32 @@ func (r ReportSubmissionRequest) ToPayload() map[string]any {
 	return payload
 }
 
-// 6.- ReportStatusUpdateRequest encapsula el cuerpo aceptado por PATCH /reports/{id}.
+// 6.- ReportStatusUpdateRequest encapsula el cuerpo aceptado por PATCH /reports/{id}; el flujo configurado valida el estatus.
 type ReportStatusUpdateRequest struct {
-	Status string `json:"status" validate:"required,oneof=en_revision en_proceso resuelto critico"`
+	Status string `json:"status" validate:"required,min=1,max=64"`
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+// 7.- ReportNoteRequest describe la actualización pública agregada a la bitácora.
+type ReportNoteRequest struct {
+	Note string `json:"note" validate:"required,min=1,max=1000"`
+}
+
+// 7.0.1.- StaffNoteRequest crea o edita una nota interna; las menciones son correos del personal.
+type StaffNoteRequest struct {
+	Body        string   `json:"body" validate:"required,min=1,max=4000"`
+	Mentions    []string `json:"mentions" validate:"omitempty,max=10,dive,email"`
+	Attachments []string `json:"attachments" validate:"omitempty,max=10,dive,uri"`
+}
+
+// 7.0.2.- ReportMessageRequest envía un mensaje a la conversación pública del reporte.
+type ReportMessageRequest struct {
+	Body string `json:"body" validate:"required,min=1,max=2000"`
+}
+
+// 7.1.- ReportAssignmentRequest indica la dependencia, la cuadrilla o ambas; el motivo es obligatorio al reasignar.
+type ReportAssignmentRequest struct {
+	DepartmentID string `json:"departmentId" validate:"required_without=CrewID,omitempty,max=64"`
+	CrewID       string `json:"crewId" validate:"omitempty,max=64"`
+	Reason       string `json:"reason" validate:"max=500"`
 }
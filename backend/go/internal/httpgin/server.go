@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
@@ -25,10 +26,32 @@ type Server struct {
 	realtimeHub    *realtime.Hub
 	upgrader       websocket.Upgrader
 	engine         *gin.Engine
+	trustedProxies []string
+}
+
+// Option personaliza dependencias opcionales del servidor.
+type Option func(*Server)
+
+// WithTrustedProxies indica qué proxies pueden fijar X-Forwarded-For; sin ellos se usa la IP de la conexión.
+func WithTrustedProxies(proxies ...string) Option {
+	return func(s *Server) {
+		for _, proxy := range proxies {
+			if proxy = strings.TrimSpace(proxy); proxy != "" {
+				s.trustedProxies = append(s.trustedProxies, proxy)
+			}
+		}
+	}
+}
+
+// WithRealtimeHub comparte el hub con el servicio de autenticación para cortar conexiones de sesiones revocadas.
+func WithRealtimeHub(hub *realtime.Hub) Option {
+	return func(s *Server) {
+		s.realtimeHub = hub
+	}
 }
 
 // 2.- New construye el servidor, configura Gin y prepara las rutas.
-func New(auth *service.AuthService, catalog *service.CatalogService, reports *service.ReportService) *Server {
+func New(auth *service.AuthService, catalog *service.CatalogService, reports *service.ReportService, opts ...Option) *Server {
 	if gin.Mode() == gin.DebugMode {
 		gin.SetMode(gin.ReleaseMode)
 	}
@@ -37,18 +60,26 @@ func New(auth *service.AuthService, catalog *service.CatalogService, reports *se
 	engine.NoRoute(func(c *gin.Context) {
 		writeError(c, http.StatusNotFound, "not found")
 	})
-	hub := realtime.NewHub(8, 8, 4096, 128)
 	srv := &Server{
 		authService:    auth,
 		catalogService: catalog,
 		reportService:  reports,
-		realtimeHub:    hub,
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
 		},
 		engine: engine,
 	}
+	for _, opt := range opts {
+		opt(srv)
+	}
+	if srv.realtimeHub == nil {
+		srv.realtimeHub = realtime.NewHub(8, 8, 4096, 128)
+	}
+	// Confiar en cualquier X-Forwarded-For permitiría evadir el bloqueo por IP.
+	if err := engine.SetTrustedProxies(srv.trustedProxies); err != nil {
+		panic("invalid trusted proxies: " + err.Error())
+	}
 	engine.GET("/metrics", gin.WrapH(observability.PrometheusHandler()))
 	srv.registerRoutes()
 	return srv
@@ -73,38 +104,168 @@ func (s *Server) registerRoutes() {
 	s.registerEndpoint(api, "/auth/register", map[string]gin.HandlerFunc{
 		http.MethodPost: s.handleAuthRegister,
 	})
+	s.registerEndpoint(api, "/auth/refresh", map[string]gin.HandlerFunc{
+		http.MethodPost: s.handleAuthRefresh,
+	})
 	s.registerEndpoint(api, "/auth/recover", map[string]gin.HandlerFunc{
 		http.MethodPost: s.handleAuthRecover,
 	})
+	s.registerEndpoint(api, "/auth/reset", map[string]gin.HandlerFunc{
+		http.MethodPost: s.handleAuthReset,
+	})
+	s.registerEndpoint(api, "/auth/verify", map[string]gin.HandlerFunc{
+		http.MethodPost: s.handleAuthVerify,
+	})
+	s.registerEndpoint(api, "/auth/invitations/accept", map[string]gin.HandlerFunc{
+		http.MethodPost: s.handleInvitationAccept,
+	})
+	s.registerEndpoint(api, "/auth/anonymous", map[string]gin.HandlerFunc{
+		http.MethodPost: s.handleAnonymousToken,
+	})
+	s.registerEndpoint(api, "/auth/mfa/verify", map[string]gin.HandlerFunc{
+		http.MethodPost: s.handleMFAVerify,
+	})
+	s.registerEndpoint(api, "/auth/mfa/enroll", map[string]gin.HandlerFunc{
+		http.MethodPost: s.handleMFAChallengeEnroll,
+	})
 	s.registerEndpoint(api, "/auth/social/:provider", map[string]gin.HandlerFunc{
 		http.MethodPost: s.handleAuthSocial,
 	})
 	s.registerEndpoint(api, "/catalog/incident-types", map[string]gin.HandlerFunc{
 		http.MethodGet: s.handleCatalog,
 	})
+	s.registerEndpoint(api, "/catalog/workflow", map[string]gin.HandlerFunc{
+		http.MethodGet: s.handleWorkflow,
+	})
 	protected := api.Group("")
 	protected.Use(s.requireAuth())
-	s.registerEndpoint(protected, "/reports", map[string]gin.HandlerFunc{
-		http.MethodGet:  s.handleReportList,
-		http.MethodPost: s.handleReportSubmit,
+	s.registerEndpoint(protected, "/auth/logout", map[string]gin.HandlerFunc{
+		http.MethodPost: s.handleAuthLogout,
+	})
+	s.registerEndpoint(protected, "/auth/verify/resend", map[string]gin.HandlerFunc{
+		http.MethodPost: s.handleAuthVerifyResend,
+	})
+	s.registerEndpoint(protected, "/me", map[string]gin.HandlerFunc{
+		http.MethodGet:    s.handleProfile,
+		http.MethodPatch:  s.handleProfileUpdate,
+		http.MethodDelete: s.handleAccountDelete,
+	})
+	s.registerEndpoint(protected, "/me/password", map[string]gin.HandlerFunc{
+		http.MethodPost: s.handlePasswordChange,
+	})
+	s.registerEndpoint(protected, "/me/sessions", map[string]gin.HandlerFunc{
+		http.MethodGet: s.handleSessionList,
+	})
+	s.registerEndpoint(protected, "/me/sessions/:id", map[string]gin.HandlerFunc{
+		http.MethodDelete: s.handleSessionRevoke,
+	})
+	s.registerEndpoint(protected, "/me/push", map[string]gin.HandlerFunc{
+		http.MethodPut:    s.handlePushRegister,
+		http.MethodDelete: s.handlePushUnregister,
+	})
+	s.registerEndpoint(protected, "/me/reports", map[string]gin.HandlerFunc{
+		http.MethodGet: s.authorize(service.PermReportsRead, s.handleMyReports),
+	})
+	s.registerEndpoint(protected, "/me/reports/claim", map[string]gin.HandlerFunc{
+		http.MethodPost: s.handleReportClaim,
+	})
+	s.registerEndpoint(protected, "/me/mfa/enroll", map[string]gin.HandlerFunc{
+		http.MethodPost: s.handleMFAEnroll,
+	})
+	s.registerEndpoint(protected, "/me/mfa/confirm", map[string]gin.HandlerFunc{
+		http.MethodPost: s.handleMFAConfirm,
+	})
+	// Las rutas de reportes también aceptan claves de API limitadas a sus alcances.
+	integrations := api.Group("")
+	integrations.Use(s.requireAuthOrAPIKey())
+	s.registerEndpoint(integrations, "/reports", map[string]gin.HandlerFunc{
+		http.MethodGet:  s.authorize(service.PermReportsRead, s.handleReportList),
+		http.MethodPost: s.authorize(service.PermReportsSubmit, s.handleReportSubmit),
+	})
+	s.registerEndpoint(integrations, "/reports/:id", map[string]gin.HandlerFunc{
+		http.MethodGet:    s.authorize(service.PermReportsRead, s.handleReportGet),
+		http.MethodPatch:  s.authorize(service.PermReportsUpdate, s.handleReportUpdate),
+		http.MethodDelete: s.authorize(service.PermReportsDelete, s.handleReportDelete),
+	})
+	s.registerEndpoint(integrations, "/reports/:id/events", map[string]gin.HandlerFunc{
+		http.MethodGet:  s.authorize(service.PermReportsEvents, s.handleReportEvents),
+		http.MethodPost: s.authorize(service.PermReportsUpdate, s.handleReportNote),
 	})
-	s.registerEndpoint(protected, "/reports/:id", map[string]gin.HandlerFunc{
-		http.MethodGet:    s.handleReportGet,
-		http.MethodPatch:  s.handleReportUpdate,
-		http.MethodDelete: s.handleReportDelete,
+	s.registerEndpoint(protected, "/reports/:id/notes", map[string]gin.HandlerFunc{
+		http.MethodGet:  s.authorize(service.PermReportsNotes, s.handleStaffNotes),
+		http.MethodPost: s.authorize(service.PermReportsNotes, s.handleStaffNoteCreate),
+	})
+	s.registerEndpoint(protected, "/reports/:id/notes/:noteId", map[string]gin.HandlerFunc{
+		http.MethodPatch: s.authorize(service.PermReportsNotes, s.handleStaffNoteEdit),
+	})
+	s.registerEndpoint(protected, "/reports/:id/messages", map[string]gin.HandlerFunc{
+		http.MethodGet:  s.authorize(service.PermReportsRead, s.handleReportMessages),
+		http.MethodPost: s.authorize(service.PermReportsRead, s.handleReportMessageCreate),
+	})
+	s.registerEndpoint(protected, "/reports/:id/assignment", map[string]gin.HandlerFunc{
+		http.MethodPost: s.authorize(service.PermReportsAssign, s.handleReportAssign),
 	})
 	s.registerEndpoint(api, "/folios/:folio", map[string]gin.HandlerFunc{
 		http.MethodGet: s.handleFolioLookup,
 	})
 	s.registerEndpoint(protected, "/admin/dashboard/metrics", map[string]gin.HandlerFunc{
-		http.MethodGet: s.handleAdminMetrics,
+		http.MethodGet: s.authorize(service.PermAdminMetrics, s.handleAdminMetrics),
+	})
+	s.registerEndpoint(protected, "/admin/users", map[string]gin.HandlerFunc{
+		http.MethodGet:  s.authorize(service.PermUsersManage, s.handleUserList),
+		http.MethodPost: s.authorize(service.PermUsersManage, s.handleUserInvite),
+	})
+	s.registerEndpoint(protected, "/admin/users/:email/role", map[string]gin.HandlerFunc{
+		http.MethodPut: s.authorize(service.PermUsersManage, s.handleUserRole),
+	})
+	s.registerEndpoint(protected, "/admin/users/:email/disabled", map[string]gin.HandlerFunc{
+		http.MethodPut:    s.authorize(service.PermUsersManage, s.handleUserDisable),
+		http.MethodDelete: s.authorize(service.PermUsersManage, s.handleUserEnable),
+	})
+	s.registerEndpoint(protected, "/departments", map[string]gin.HandlerFunc{
+		http.MethodGet:  s.authorize(service.PermReportsAssign, s.handleDepartmentList),
+		http.MethodPost: s.authorize(service.PermDepartmentsManage, s.handleDepartmentCreate),
+	})
+	s.registerEndpoint(protected, "/departments/:id/crews", map[string]gin.HandlerFunc{
+		http.MethodPost: s.authorize(service.PermDepartmentsManage, s.handleCrewCreate),
+	})
+	s.registerEndpoint(protected, "/admin/api-keys", map[string]gin.HandlerFunc{
+		http.MethodGet:  s.authorize(service.PermAPIKeysManage, s.handleAPIKeyList),
+		http.MethodPost: s.authorize(service.PermAPIKeysManage, s.handleAPIKeyCreate),
+	})
+	s.registerEndpoint(protected, "/admin/api-keys/:id", map[string]gin.HandlerFunc{
+		http.MethodDelete: s.authorize(service.PermAPIKeysManage, s.handleAPIKeyRevoke),
+	})
+	s.registerEndpoint(protected, "/admin/users/:email/sessions", map[string]gin.HandlerFunc{
+		http.MethodDelete: s.authorize(service.PermUsersManage, s.handleRevokeSessions),
+	})
+	s.registerEndpoint(protected, "/admin/users/:email/lockout", map[string]gin.HandlerFunc{
+		http.MethodDelete: s.authorize(service.PermUsersManage, s.handleUnlockAccount),
+	})
+	s.registerEndpoint(&s.engine.RouterGroup, "/.well-known/jwks.json", map[string]gin.HandlerFunc{
+		http.MethodGet: s.handleJWKS,
 	})
 	s.engine.Handle(http.MethodGet, "/ws", func(c *gin.Context) {
 		if c.Request.Method != http.MethodGet {
 			writeError(c, http.StatusMethodNotAllowed, "method not allowed")
 			return
 		}
-		s.handleWebSocket(c)
+		// Los canales de cuadrilla y dependencia son del personal; la difusión general sigue abierta.
+		staffChannels := len(c.QueryArray("crew")) > 0 || len(c.QueryArray("department")) > 0
+		if !staffChannels && c.GetHeader("Authorization") == "" {
+			s.handleWebSocket(c)
+			return
+		}
+		// Con token, la conexión también abre el canal privado de la cuenta para los mensajes.
+		s.requireAuth()(c)
+		if c.IsAborted() {
+			return
+		}
+		if !staffChannels {
+			s.handleWebSocket(c)
+			return
+		}
+		s.authorize(service.PermReportsEvents, s.handleWebSocket)(c)
 	})
 }
 
@@ -129,17 +290,101 @@ func (s *Server) requireAuth() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
-		subject, err := s.authService.ValidateToken(strings.TrimSpace(parts[1]))
+		principal, err := s.authService.ValidateToken(c.Request.Context(), strings.TrimSpace(parts[1]))
+		if err != nil {
+			status := http.StatusServiceUnavailable
+			if errors.Is(err, service.ErrInvalidToken) || errors.Is(err, service.ErrTokenRevoked) || errors.Is(err, service.ErrAccountDisabled) {
+				status = http.StatusUnauthorized
+			}
+			writeError(c, status, err.Error())
+			c.Abort()
+			return
+		}
+		setPrincipal(c, principal)
+		c.Next()
+	}
+}
+
+// 7.0.1.- requireAuthOrAPIKey acepta X-API-Key en las rutas abiertas a integraciones y, sin ella, exige Bearer.
+func (s *Server) requireAuthOrAPIKey() gin.HandlerFunc {
+	bearer := s.requireAuth()
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-API-Key")
+		if key == "" {
+			bearer(c)
+			return
+		}
+		principal, err := s.authService.AuthenticateAPIKey(c.Request.Context(), key)
 		if err != nil {
-			writeError(c, http.StatusUnauthorized, err.Error())
+			var limited *service.RateLimitError
+			switch {
+			case errors.As(err, &limited):
+				c.Header("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+				writeError(c, http.StatusTooManyRequests, service.ErrRateLimited.Error())
+			case errors.Is(err, service.ErrInvalidAPIKey):
+				writeError(c, http.StatusUnauthorized, err.Error())
+			default:
+				writeError(c, http.StatusServiceUnavailable, err.Error())
+			}
 			c.Abort()
 			return
 		}
-		c.Set("auth.subject", subject)
+		setPrincipal(c, principal)
 		c.Next()
 	}
 }
 
+// setPrincipal deja la identidad en el contexto con las claves que leen los handlers.
+func setPrincipal(c *gin.Context, principal service.Principal) {
+	c.Set("auth.subject", principal.Subject)
+	c.Set("auth.role", principal.Role)
+	c.Set("auth.principal", principal)
+}
+
+// 7.1.- authorize envuelve un handler y responde 403 si el rol o los alcances de la clave no conceden el permiso, o falta verificar el correo.
+func (s *Server) authorize(perm service.Permission, handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := s.authService.Authorize(principalFrom(c), perm); err != nil {
+			message := "forbidden: missing permission " + string(perm)
+			if principalFrom(c).APIKeyID != "" {
+				message = "forbidden: api key scopes do not grant " + string(perm)
+			}
+			if errors.Is(err, service.ErrEmailUnverified) {
+				message = "forbidden: " + err.Error()
+			}
+			writeError(c, http.StatusForbidden, message)
+			return
+		}
+		handler(c)
+	}
+}
+
+// 7.2.- principalFrom recupera la identidad que requireAuth dejó en el contexto.
+func principalFrom(c *gin.Context) service.Principal {
+	principal, _ := c.Get("auth.principal")
+	value, _ := principal.(service.Principal)
+	return value
+}
+
+// 7.3.- clientInfo describe el origen de la petición; la app declara el dispositivo con cabeceras opcionales.
+func clientInfo(c *gin.Context) service.ClientInfo {
+	return service.ClientInfo{
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		DeviceName: truncateHeader(c.GetHeader("X-Device-Name"), 80),
+		Platform:   strings.ToLower(truncateHeader(c.GetHeader("X-Device-Platform"), 32)),
+	}
+}
+
+// truncateHeader recorta valores declarados por el cliente antes de persistirlos.
+func truncateHeader(value string, limit int) string {
+	value = strings.TrimSpace(value)
+	if runes := []rune(value); len(runes) > limit {
+		return string(runes[:limit])
+	}
+	return value
+}
+
 // 8.- handleAuthLogin verifica credenciales y responde con token JWT.
 func (s *Server) handleAuthLogin(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
@@ -148,8 +393,12 @@ func (s *Server) handleAuthLogin(c *gin.Context) {
 	if ok := decodeAndValidate(c, &body); !ok {
 		return
 	}
+	ctx = service.WithClientInfo(ctx, clientInfo(c))
 	resp, err := s.authService.Authenticate(ctx, body.Email, body.Password)
 	if err != nil {
+		if writeLoginInterruption(c, err) {
+			return
+		}
 		status := http.StatusGatewayTimeout
 		if errors.Is(err, service.ErrInvalidCredentials) {
 			status = http.StatusUnauthorized
@@ -160,6 +409,38 @@ func (s *Server) handleAuthLogin(c *gin.Context) {
 	writeJSON(c, http.StatusOK, resp)
 }
 
+// 8.1.- writeLoginInterruption responde 202 con el reto MFA, 429 con Retry-After si hay bloqueo o 403 si la cuenta está suspendida.
+func writeLoginInterruption(c *gin.Context, err error) bool {
+	var (
+		challenge *service.MFAChallengeError
+		locked    *service.LockoutError
+	)
+	switch {
+	case errors.Is(err, service.ErrAccountDisabled):
+		writeError(c, http.StatusForbidden, err.Error())
+		return true
+	case errors.As(err, &challenge):
+		writeJSON(c, http.StatusAccepted, challenge.Challenge)
+		return true
+	case errors.As(err, &locked):
+		// Retry-After se redondea hacia arriba para no invitar a reintentar antes de tiempo.
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		writeError(c, http.StatusTooManyRequests, service.ErrLoginLocked.Error())
+		return true
+	}
+	return false
+}
+
+// 8.2.- writePasswordPolicyError responde 400 con cada regla incumplida en details.
+func writePasswordPolicyError(c *gin.Context, err error) bool {
+	var policy *service.PasswordPolicyError
+	if !errors.As(err, &policy) {
+		return false
+	}
+	writeErrorDetails(c, http.StatusBadRequest, service.ErrWeakPassword.Error(), policy.Violations)
+	return true
+}
+
 // 9.- handleAuthRegister crea un usuario y retorna el token inicial.
 func (s *Server) handleAuthRegister(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
@@ -168,8 +449,12 @@ func (s *Server) handleAuthRegister(c *gin.Context) {
 	if ok := decodeAndValidate(c, &body); !ok {
 		return
 	}
+	ctx = service.WithClientInfo(ctx, clientInfo(c))
 	resp, err := s.authService.Register(ctx, body.Email, body.Password)
 	if err != nil {
+		if writePasswordPolicyError(c, err) {
+			return
+		}
 		status := http.StatusGatewayTimeout
 		switch {
 		case errors.Is(err, service.ErrInvalidCredentials):
@@ -183,7 +468,48 @@ func (s *Server) handleAuthRegister(c *gin.Context) {
 	writeJSON(c, http.StatusCreated, resp)
 }
 
-// 10.- handleAuthRecover confirma la existencia y simula el envío de correo.
+// 9.1.- handleAuthRefresh rota el token de renovación y emite un nuevo par de tokens.
+func (s *Server) handleAuthRefresh(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	var body dto.RefreshRequest
+	if ok := decodeAndValidate(c, &body); !ok {
+		return
+	}
+	ctx = service.WithClientInfo(ctx, clientInfo(c))
+	resp, err := s.authService.Refresh(ctx, body.RefreshToken)
+	if err != nil {
+		if writeLoginInterruption(c, err) {
+			return
+		}
+		status := http.StatusGatewayTimeout
+		if errors.Is(err, service.ErrInvalidRefreshToken) || errors.Is(err, service.ErrRefreshTokenReused) {
+			status = http.StatusUnauthorized
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// 9.2.- handleAuthLogout revoca el token presentado y opcionalmente su token de renovación.
+func (s *Server) handleAuthLogout(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	var body dto.LogoutRequest
+	if c.Request.ContentLength != 0 {
+		if ok := decodeAndValidate(c, &body); !ok {
+			return
+		}
+	}
+	if err := s.authService.Logout(ctx, principalFrom(c), body.RefreshToken); err != nil {
+		writeError(c, http.StatusGatewayTimeout, err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// 10.- handleAuthRecover responde 202 exista o no la cuenta para evitar enumeración.
 func (s *Server) handleAuthRecover(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
 	defer cancel()
@@ -202,120 +528,165 @@ func (s *Server) handleAuthRecover(c *gin.Context) {
 	c.Status(http.StatusAccepted)
 }
 
-// 11.- handleAuthSocial valida el proveedor y responde con token federado.
-func (s *Server) handleAuthSocial(c *gin.Context) {
+// 10.1.- handleAuthReset fija la nueva contraseña con un token de restablecimiento.
+func (s *Server) handleAuthReset(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
 	defer cancel()
-	provider := c.Param("provider")
-	resp, err := s.authService.SocialAuthenticate(ctx, provider)
-	if err != nil {
+	var body dto.ResetPasswordRequest
+	if ok := decodeAndValidate(c, &body); !ok {
+		return
+	}
+	if err := s.authService.ResetPassword(ctx, body.Token, body.Password); err != nil {
+		if writePasswordPolicyError(c, err) {
+			return
+		}
 		status := http.StatusGatewayTimeout
-		if errors.Is(err, service.ErrUnsupportedLogin) {
+		if errors.Is(err, service.ErrInvalidOneTimeToken) || errors.Is(err, service.ErrInvalidCredentials) {
 			status = http.StatusBadRequest
 		}
 		writeError(c, status, err.Error())
 		return
 	}
-	writeJSON(c, http.StatusOK, resp)
+	c.Status(http.StatusNoContent)
 }
 
-// 12.- handleCatalog delega al servicio para obtener los tipos de incidentes.
-func (s *Server) handleCatalog(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+// 10.2.- handleAuthVerify confirma el correo con el token enviado al registrarse.
+func (s *Server) handleAuthVerify(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
 	defer cancel()
-	catalog, err := s.catalogService.Fetch(ctx)
-	if err != nil {
-		writeError(c, http.StatusGatewayTimeout, err.Error())
+	var body dto.VerifyEmailRequest
+	if ok := decodeAndValidate(c, &body); !ok {
 		return
 	}
-	writeJSON(c, http.StatusOK, catalog)
+	if err := s.authService.VerifyEmail(ctx, body.Token); err != nil {
+		status := http.StatusGatewayTimeout
+		if errors.Is(err, service.ErrInvalidOneTimeToken) {
+			status = http.StatusBadRequest
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
 }
 
-// 13.- handleReportList atiende las solicitudes paginadas del panel.
-func (s *Server) handleReportList(c *gin.Context) {
+// 10.3.- handleAuthVerifyResend reenvía el correo de confirmación a la cuenta autenticada.
+func (s *Server) handleAuthVerifyResend(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
 	defer cancel()
-	page := parseQueryInt(c.Query("page"), 0)
-	pageSize := parseQueryInt(c.Query("pageSize"), 20)
-	status := c.Query("status")
-	reports, err := s.reportService.List(ctx, page, pageSize, status)
-	if err != nil {
-		statusCode := http.StatusGatewayTimeout
-		if errors.Is(err, service.ErrInvalidStatus) {
-			statusCode = http.StatusBadRequest
+	if err := s.authService.ResendVerification(ctx, principalFrom(c).Subject); err != nil {
+		var limited *service.RateLimitError
+		if errors.As(err, &limited) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+			writeError(c, http.StatusTooManyRequests, service.ErrRateLimited.Error())
+			return
 		}
-		writeError(c, statusCode, err.Error())
+		writeError(c, http.StatusGatewayTimeout, err.Error())
 		return
 	}
-	writeJSON(c, http.StatusOK, reports)
+	c.Status(http.StatusAccepted)
 }
 
-// 14.- handleReportSubmit recibe el reporte ciudadano y notifica al hub.
-func (s *Server) handleReportSubmit(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+// 10.4.- handleProfile devuelve el perfil de la cuenta autenticada.
+func (s *Server) handleProfile(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	profile, err := s.authService.Profile(ctx, principalFrom(c).Subject)
+	writeProfile(c, profile, err)
+}
+
+// 10.5.- handleProfileUpdate aplica los cambios parciales de nombre, teléfono y preferencias.
+func (s *Server) handleProfileUpdate(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
 	defer cancel()
-	var payload dto.ReportSubmissionRequest
-	if ok := decodeAndValidate(c, &payload); !ok {
+	var body dto.UpdateProfileRequest
+	if ok := decodeAndValidate(c, &body); !ok {
 		return
 	}
-	report, err := s.reportService.Submit(ctx, payload.ToPayload())
-	if err != nil {
-		writeError(c, http.StatusGatewayTimeout, err.Error())
-		return
+	update := service.ProfileUpdate{DisplayName: body.DisplayName, Phone: body.Phone}
+	if prefs := body.Notifications; prefs != nil {
+		update.Notifications = &service.NotificationPreferences{Email: *prefs.Email, Push: *prefs.Push, SMS: *prefs.SMS}
 	}
-	_ = s.realtimeHub.BroadcastReport(report)
-	writeJSON(c, http.StatusCreated, report)
+	profile, err := s.authService.UpdateProfile(ctx, principalFrom(c).Subject, update)
+	writeProfile(c, profile, err)
 }
 
-// 15.- handleReportGet devuelve el detalle puntual del reporte.
-func (s *Server) handleReportGet(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
-	defer cancel()
-	id := c.Param("id")
-	report, err := s.reportService.Get(ctx, id)
+// 10.6.- writeProfile comparte el mapeo de errores de lectura y edición del perfil.
+func writeProfile(c *gin.Context, profile service.Profile, err error) {
 	if err != nil {
 		status := http.StatusGatewayTimeout
-		if errors.Is(err, service.ErrReportNotFound) {
+		if errors.Is(err, service.ErrUserNotFound) {
 			status = http.StatusNotFound
 		}
 		writeError(c, status, err.Error())
 		return
 	}
-	writeJSON(c, http.StatusOK, report)
+	writeJSON(c, http.StatusOK, profile)
 }
 
-// 16.- handleReportUpdate permite cambiar el estatus del reporte.
-func (s *Server) handleReportUpdate(c *gin.Context) {
+// 10.7.- handlePasswordChange verifica la contraseña actual y cierra todas las sesiones al cambiarla.
+func (s *Server) handlePasswordChange(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
 	defer cancel()
-	id := c.Param("id")
-	var body dto.ReportStatusUpdateRequest
+	var body dto.ChangePasswordRequest
 	if ok := decodeAndValidate(c, &body); !ok {
 		return
 	}
-	report, err := s.reportService.UpdateStatus(ctx, id, body.Status)
-	if err != nil {
+	ctx = service.WithClientInfo(ctx, clientInfo(c))
+	if err := s.authService.ChangePassword(ctx, principalFrom(c).Subject, body.CurrentPassword, body.NewPassword); err != nil {
+		if writePasswordPolicyError(c, err) || writeLoginInterruption(c, err) {
+			return
+		}
 		status := http.StatusGatewayTimeout
 		switch {
-		case errors.Is(err, service.ErrInvalidStatus):
+		case errors.Is(err, service.ErrInvalidCredentials), errors.Is(err, service.ErrPasswordUnchanged):
 			status = http.StatusBadRequest
-		case errors.Is(err, service.ErrReportNotFound):
+		case errors.Is(err, service.ErrUserNotFound):
 			status = http.StatusNotFound
 		}
 		writeError(c, status, err.Error())
 		return
 	}
-	writeJSON(c, http.StatusOK, report)
+	c.Status(http.StatusNoContent)
 }
 
-// 17.- handleReportDelete elimina definitivamente el registro.
-func (s *Server) handleReportDelete(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+// 10.8.- handleAccountDelete confirma la identidad, anonimiza los reportes de la cuenta y después la elimina.
+func (s *Server) handleAccountDelete(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
-	id := c.Param("id")
-	if err := s.reportService.Delete(ctx, id); err != nil {
+	var body dto.DeleteAccountRequest
+	if ok := decodeAndValidate(c, &body); !ok {
+		return
+	}
+	principal := principalFrom(c)
+	ctx = service.WithClientInfo(ctx, clientInfo(c))
+	// Un token robado no basta para borrar la cuenta; nada se anonimiza sin la confirmación.
+	if err := s.authService.ConfirmAccountDeletion(ctx, principal, body.CurrentPassword); err != nil {
+		if writeLoginInterruption(c, err) {
+			return
+		}
 		status := http.StatusGatewayTimeout
-		if errors.Is(err, service.ErrReportNotFound) {
+		switch {
+		case errors.Is(err, service.ErrInvalidCredentials):
+			status = http.StatusBadRequest
+		case errors.Is(err, service.ErrRecentLoginRequired):
+			status = http.StatusUnauthorized
+		case errors.Is(err, service.ErrForbidden):
+			status = http.StatusForbidden
+		case errors.Is(err, service.ErrUserNotFound):
+			status = http.StatusNotFound
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	subject := principal.Subject
+	// Anonimizar primero permite reintentar si el borrado de la cuenta falla.
+	if _, err := s.reportService.AnonymizeReporter(ctx, subject); err != nil {
+		writeError(c, http.StatusGatewayTimeout, err.Error())
+		return
+	}
+	if err := s.authService.DeleteAccount(ctx, subject); err != nil {
+		status := http.StatusGatewayTimeout
+		if errors.Is(err, service.ErrUserNotFound) {
 			status = http.StatusNotFound
 		}
 		writeError(c, status, err.Error())
@@ -324,56 +695,921 @@ func (s *Server) handleReportDelete(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
-// 18.- handleFolioLookup reutiliza el servicio para mostrar el seguimiento.
-func (s *Server) handleFolioLookup(c *gin.Context) {
+// 10.9.- handleSessionList muestra los dispositivos con sesión vigente y marca el actual.
+func (s *Server) handleSessionList(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
 	defer cancel()
-	folioID := c.Param("folio")
-	if strings.TrimSpace(folioID) == "" {
-		writeError(c, http.StatusBadRequest, "missing folio id")
+	sessions, err := s.authService.Sessions(ctx, principalFrom(c))
+	if err != nil {
+		writeError(c, http.StatusGatewayTimeout, err.Error())
 		return
 	}
-	status, err := s.reportService.Lookup(ctx, folioID)
-	if err != nil {
-		statusCode := http.StatusGatewayTimeout
-		if errors.Is(err, service.ErrReportNotFound) {
-			statusCode = http.StatusNotFound
+	writeJSON(c, http.StatusOK, sessions)
+}
+
+// 10.10.- handleSessionRevoke cierra otro dispositivo o el actual; la respuesta no distingue sesiones ajenas.
+func (s *Server) handleSessionRevoke(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	if err := s.authService.RevokeSession(ctx, principalFrom(c), c.Param("id")); err != nil {
+		status := http.StatusGatewayTimeout
+		if errors.Is(err, service.ErrSessionNotFound) {
+			status = http.StatusNotFound
 		}
-		writeError(c, statusCode, err.Error())
+		writeError(c, status, err.Error())
 		return
 	}
-	writeJSON(c, http.StatusOK, status)
+	c.Status(http.StatusNoContent)
 }
 
-// 19.- handleAdminMetrics calcula los totales requeridos por el dashboard.
-func (s *Server) handleAdminMetrics(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+// 10.11.- handlePushRegister guarda el token de notificaciones del dispositivo de la sesión actual.
+func (s *Server) handlePushRegister(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
 	defer cancel()
-	metrics, err := s.reportService.DashboardMetrics(ctx)
-	if err != nil {
-		writeError(c, http.StatusGatewayTimeout, err.Error())
+	var body dto.PushRegistrationRequest
+	if ok := decodeAndValidate(c, &body); !ok {
 		return
 	}
-	writeJSON(c, http.StatusOK, metrics)
+	if err := s.authService.RegisterPush(ctx, principalFrom(c), body.Provider, body.Token); err != nil {
+		writePushError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
 }
 
-// 20.- handleWebSocket conserva la actualización en tiempo real.
-func (s *Server) handleWebSocket(c *gin.Context) {
-	conn, err := s.upgrader.Upgrade(c.Writer, c.Request, nil)
-	if err != nil {
-		writeError(c, http.StatusBadRequest, "websocket upgrade failed")
+// 10.12.- handlePushUnregister deja de enviar notificaciones al dispositivo actual.
+func (s *Server) handlePushUnregister(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	if err := s.authService.UnregisterPush(ctx, principalFrom(c)); err != nil {
+		writePushError(c, err)
 		return
 	}
-	client := s.realtimeHub.Register(conn)
-	go client.Run(context.Background())
+	c.Status(http.StatusNoContent)
 }
 
-// 21.- Shutdown sincroniza el cierre del hub con el servidor HTTP.
-func (s *Server) Shutdown(ctx context.Context) error {
-	return s.realtimeHub.Shutdown(ctx)
+// 10.13.- writePushError mapea los errores comunes del registro push.
+func writePushError(c *gin.Context, err error) {
+	status := http.StatusGatewayTimeout
+	switch {
+	case errors.Is(err, service.ErrSessionRequired), errors.Is(err, service.ErrInvalidPushProvider):
+		status = http.StatusBadRequest
+	case errors.Is(err, service.ErrSessionNotFound):
+		status = http.StatusNotFound
+	}
+	writeError(c, status, err.Error())
 }
 
-// 22.- writeJSON homologa la serialización JSON y cabeceras comunes.
+// 10.14.- handleInvitationAccept fija la primera contraseña de una cuenta invitada y responde como un login.
+func (s *Server) handleInvitationAccept(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	var body dto.AcceptInvitationRequest
+	if ok := decodeAndValidate(c, &body); !ok {
+		return
+	}
+	ctx = service.WithClientInfo(ctx, clientInfo(c))
+	resp, err := s.authService.AcceptInvitation(ctx, body.Token, body.Password)
+	if err != nil {
+		if writePasswordPolicyError(c, err) || writeLoginInterruption(c, err) {
+			return
+		}
+		status := http.StatusGatewayTimeout
+		if errors.Is(err, service.ErrInvalidOneTimeToken) || errors.Is(err, service.ErrInvalidCredentials) {
+			status = http.StatusBadRequest
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// 10.15.- handleAnonymousToken emite el token limitado para reportar sin cuenta.
+func (s *Server) handleAnonymousToken(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	var body dto.AnonymousTokenRequest
+	if ok := decodeAndValidate(c, &body); !ok {
+		return
+	}
+	ctx = service.WithClientInfo(ctx, clientInfo(c))
+	resp, err := s.authService.AnonymousToken(ctx, body.InstallID)
+	if err != nil {
+		var limited *service.RateLimitError
+		status := http.StatusGatewayTimeout
+		switch {
+		case errors.As(err, &limited):
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+			writeError(c, http.StatusTooManyRequests, service.ErrRateLimited.Error())
+			return
+		case errors.Is(err, service.ErrInvalidInstallID):
+			status = http.StatusBadRequest
+		case errors.Is(err, service.ErrAnonymousDisabled):
+			status = http.StatusForbidden
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// 10.16.- handleReportClaim vincula a la cuenta los reportes enviados antes desde la misma instalación.
+func (s *Server) handleReportClaim(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	var body dto.ClaimReportsRequest
+	if ok := decodeAndValidate(c, &body); !ok {
+		return
+	}
+	result, err := s.reportService.ClaimAnonymousReports(ctx, principalFrom(c), body.InstallID)
+	if err != nil {
+		status := http.StatusGatewayTimeout
+		switch {
+		case errors.Is(err, service.ErrForbidden):
+			status = http.StatusForbidden
+		case errors.Is(err, service.ErrInvalidInstallID):
+			status = http.StatusBadRequest
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	writeJSON(c, http.StatusOK, result)
+}
+
+// 11.- handleAuthSocial verifica la credencial del proveedor y responde con tokens de la cuenta vinculada.
+func (s *Server) handleAuthSocial(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	var body dto.SocialLoginRequest
+	if ok := decodeAndValidate(c, &body); !ok {
+		return
+	}
+	credential := service.SocialCredential{
+		IDToken:      body.IDToken,
+		Code:         body.Code,
+		CodeVerifier: body.CodeVerifier,
+		RedirectURI:  body.RedirectURI,
+		Nonce:        body.Nonce,
+	}
+	ctx = service.WithClientInfo(ctx, clientInfo(c))
+	resp, err := s.authService.SocialAuthenticate(ctx, c.Param("provider"), credential)
+	if err != nil {
+		if writeLoginInterruption(c, err) {
+			return
+		}
+		status := http.StatusGatewayTimeout
+		switch {
+		case errors.Is(err, service.ErrUnsupportedLogin):
+			status = http.StatusBadRequest
+		case errors.Is(err, service.ErrInvalidIdentityToken):
+			status = http.StatusUnauthorized
+		case errors.Is(err, service.ErrIdentityEmailUnverified):
+			status = http.StatusForbidden
+		case errors.Is(err, service.ErrIdentityAccountUnverified):
+			status = http.StatusConflict
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// 11.1.- handleMFAVerify completa el login con el reto y el segundo factor.
+func (s *Server) handleMFAVerify(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	var body dto.MFAVerifyRequest
+	if ok := decodeAndValidate(c, &body); !ok {
+		return
+	}
+	ctx = service.WithClientInfo(ctx, clientInfo(c))
+	resp, err := s.authService.VerifyMFA(ctx, body.MFAToken, body.Code)
+	if err != nil {
+		if writeLoginInterruption(c, err) {
+			return
+		}
+		status := http.StatusGatewayTimeout
+		if errors.Is(err, service.ErrInvalidMFAChallenge) || errors.Is(err, service.ErrInvalidMFACode) {
+			status = http.StatusUnauthorized
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// 11.2.- handleMFAChallengeEnroll entrega el secreto TOTP a quien su rol obliga a inscribirse.
+func (s *Server) handleMFAChallengeEnroll(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	var body dto.MFAChallengeRequest
+	if ok := decodeAndValidate(c, &body); !ok {
+		return
+	}
+	setup, err := s.authService.EnrollMFAWithChallenge(ctx, body.MFAToken)
+	writeMFASetup(c, setup, err)
+}
+
+// 11.3.- handleMFAEnroll inicia la inscripción TOTP de la cuenta autenticada.
+func (s *Server) handleMFAEnroll(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	setup, err := s.authService.EnrollMFA(ctx, principalFrom(c).Subject)
+	writeMFASetup(c, setup, err)
+}
+
+// 11.4.- writeMFASetup mapea los errores comunes de ambos caminos de inscripción.
+func writeMFASetup(c *gin.Context, setup service.MFASetup, err error) {
+	if err != nil {
+		status := http.StatusGatewayTimeout
+		switch {
+		case errors.Is(err, service.ErrInvalidMFAChallenge):
+			status = http.StatusUnauthorized
+		case errors.Is(err, service.ErrMFAAlreadyEnrolled):
+			status = http.StatusConflict
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	writeJSON(c, http.StatusOK, setup)
+}
+
+// 11.5.- handleMFAConfirm activa el secreto con el primer código y devuelve los códigos de recuperación.
+func (s *Server) handleMFAConfirm(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	var body dto.MFACodeRequest
+	if ok := decodeAndValidate(c, &body); !ok {
+		return
+	}
+	codes, err := s.authService.ConfirmMFA(ctx, principalFrom(c).Subject, body.Code)
+	if err != nil {
+		status := http.StatusGatewayTimeout
+		switch {
+		case errors.Is(err, service.ErrInvalidMFACode):
+			status = http.StatusBadRequest
+		case errors.Is(err, service.ErrMFANotEnrolled):
+			status = http.StatusNotFound
+		case errors.Is(err, service.ErrMFAAlreadyEnrolled):
+			status = http.StatusConflict
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	writeJSON(c, http.StatusOK, struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}{codes})
+}
+
+// 11.6.- handleJWKS publica las llaves públicas para que otros servicios validen los tokens.
+func (s *Server) handleJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	writeJSON(c, http.StatusOK, s.authService.JWKS())
+}
+
+// 12.- handleCatalog delega al servicio para obtener los tipos de incidentes.
+func (s *Server) handleCatalog(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
+	catalog, err := s.catalogService.Fetch(ctx)
+	if err != nil {
+		writeError(c, http.StatusGatewayTimeout, err.Error())
+		return
+	}
+	writeJSON(c, http.StatusOK, catalog)
+}
+
+// 12.1.- handleWorkflow publica los estatus y transiciones configurados.
+func (s *Server) handleWorkflow(c *gin.Context) {
+	writeJSON(c, http.StatusOK, s.reportService.Workflow())
+}
+
+// 13.- handleReportList atiende las solicitudes paginadas del panel; sin reports:read_all sólo lista los propios.
+func (s *Server) handleReportList(c *gin.Context) {
+	principal := principalFrom(c)
+	filter := reportFilterFrom(c)
+	if !principal.Can(service.PermReportsReadAll) {
+		filter.Reporter = principal.Subject
+	}
+	s.writeReportList(c, filter)
+}
+
+// 13.1.- handleMyReports lista los reportes enviados por la cuenta autenticada, sin importar su rol.
+func (s *Server) handleMyReports(c *gin.Context) {
+	filter := reportFilterFrom(c)
+	filter.Reporter = principalFrom(c).Subject
+	s.writeReportList(c, filter)
+}
+
+// 13.2.- reportFilterFrom lee la paginación, el estatus y la asignación de la consulta.
+func reportFilterFrom(c *gin.Context) service.ReportFilter {
+	return service.ReportFilter{
+		Status:       c.Query("status"),
+		DepartmentID: c.Query("departmentId"),
+		CrewID:       c.Query("crewId"),
+		Page:         parseQueryInt(c.Query("page"), 0),
+		PageSize:     parseQueryInt(c.Query("pageSize"), 20),
+	}
+}
+
+// 13.3.- writeReportList consulta el listado y oculta el contacto a quien no es personal.
+func (s *Server) writeReportList(c *gin.Context, filter service.ReportFilter) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	reports, err := s.reportService.List(ctx, filter)
+	if err != nil {
+		statusCode := http.StatusGatewayTimeout
+		if errors.Is(err, service.ErrInvalidStatus) {
+			statusCode = http.StatusBadRequest
+		}
+		writeError(c, statusCode, err.Error())
+		return
+	}
+	if !s.canViewContact(c) {
+		for i := range reports.Items {
+			reports.Items[i] = reports.Items[i].WithoutContact()
+		}
+	}
+	writeJSON(c, http.StatusOK, reports)
+}
+
+// 14.- handleReportSubmit recibe el reporte ciudadano y notifica al hub.
+func (s *Server) handleReportSubmit(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	var payload map[string]any
+	// Quien reporta sin cuenta puede omitir el contacto; el resto de las reglas es igual.
+	if principalFrom(c).Role == service.RoleAnonymous {
+		var body dto.AnonymousReportSubmissionRequest
+		if ok := decodeAndValidate(c, &body); !ok {
+			return
+		}
+		payload = body.ToPayload()
+	} else {
+		var body dto.ReportSubmissionRequest
+		if ok := decodeAndValidate(c, &body); !ok {
+			return
+		}
+		payload = body.ToPayload()
+	}
+	report, err := s.reportService.Submit(ctx, c.GetString("auth.subject"), payload)
+	if err != nil {
+		writeError(c, http.StatusGatewayTimeout, err.Error())
+		return
+	}
+	_ = s.realtimeHub.BroadcastReport(report.WithoutContact())
+	writeJSON(c, http.StatusCreated, report)
+}
+
+// 15.- handleReportGet devuelve el detalle puntual del reporte; un reporte ajeno responde 404 para no revelar que existe.
+func (s *Server) handleReportGet(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	id := c.Param("id")
+	report, err := s.reportService.Get(ctx, id)
+	if err != nil {
+		status := http.StatusGatewayTimeout
+		if errors.Is(err, service.ErrReportNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	if !report.VisibleTo(principalFrom(c)) {
+		writeError(c, http.StatusNotFound, service.ErrReportNotFound.Error())
+		return
+	}
+	if !s.canViewContact(c) {
+		report = report.WithoutContact()
+	}
+	writeJSON(c, http.StatusOK, report)
+}
+
+// 16.- handleReportUpdate permite cambiar el estatus del reporte.
+func (s *Server) handleReportUpdate(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	id := c.Param("id")
+	var body dto.ReportStatusUpdateRequest
+	if ok := decodeAndValidate(c, &body); !ok {
+		return
+	}
+	change := service.StatusChange{Status: body.Status, Actor: c.GetString("auth.subject"), Reason: body.Reason}
+	report, err := s.reportService.UpdateStatus(ctx, id, change)
+	if err != nil {
+		status := http.StatusGatewayTimeout
+		switch {
+		case errors.Is(err, service.ErrInvalidStatus):
+			status = http.StatusBadRequest
+		case errors.Is(err, service.ErrInvalidTransition), errors.Is(err, service.ErrStatusConflict):
+			status = http.StatusConflict
+		case errors.Is(err, service.ErrReportNotFound):
+			status = http.StatusNotFound
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	if !s.canViewContact(c) {
+		report = report.WithoutContact()
+	}
+	writeJSON(c, http.StatusOK, report)
+}
+
+// 17.- handleReportDelete elimina definitivamente el registro.
+func (s *Server) handleReportDelete(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	id := c.Param("id")
+	if err := s.reportService.Delete(ctx, id); err != nil {
+		status := http.StatusGatewayTimeout
+		if errors.Is(err, service.ErrReportNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// 17.1.- handleReportEvents devuelve la bitácora completa con actores y motivos.
+func (s *Server) handleReportEvents(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	events, err := s.reportService.Events(ctx, c.Param("id"))
+	if err != nil {
+		status := http.StatusGatewayTimeout
+		if errors.Is(err, service.ErrReportNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	writeJSON(c, http.StatusOK, events)
+}
+
+// 17.2.- handleReportNote agrega una actualización visible en el seguimiento del folio.
+func (s *Server) handleReportNote(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	var body dto.ReportNoteRequest
+	if ok := decodeAndValidate(c, &body); !ok {
+		return
+	}
+	event, err := s.reportService.AddNote(ctx, c.Param("id"), c.GetString("auth.subject"), body.Note)
+	if err != nil {
+		status := http.StatusGatewayTimeout
+		if errors.Is(err, service.ErrReportNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	writeJSON(c, http.StatusCreated, event)
+}
+
+// 17.2.1.- handleStaffNotes lista las notas internas; no forman parte del seguimiento del folio.
+func (s *Server) handleStaffNotes(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	notes, err := s.reportService.Notes(ctx, c.Param("id"))
+	if err != nil {
+		writeError(c, staffNoteErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(c, http.StatusOK, notes)
+}
+
+// 17.2.2.- handleStaffNoteCreate agrega una nota interna y avisa a las personas mencionadas.
+func (s *Server) handleStaffNoteCreate(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	var body dto.StaffNoteRequest
+	if ok := decodeAndValidate(c, &body); !ok {
+		return
+	}
+	note, err := s.reportService.AddStaffNote(ctx, c.Param("id"), c.GetString("auth.subject"), service.StaffNoteInput{
+		Body:        body.Body,
+		Mentions:    body.Mentions,
+		Attachments: body.Attachments,
+	})
+	if err != nil {
+		writeError(c, staffNoteErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(c, http.StatusCreated, note)
+}
+
+// 17.2.3.- handleStaffNoteEdit reemplaza el contenido de la nota si quien edita es el autor y sigue en plazo.
+func (s *Server) handleStaffNoteEdit(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	var body dto.StaffNoteRequest
+	if ok := decodeAndValidate(c, &body); !ok {
+		return
+	}
+	note, err := s.reportService.EditStaffNote(ctx, c.Param("id"), c.Param("noteId"), c.GetString("auth.subject"), service.StaffNoteInput{
+		Body:        body.Body,
+		Mentions:    body.Mentions,
+		Attachments: body.Attachments,
+	})
+	if err != nil {
+		writeError(c, staffNoteErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(c, http.StatusOK, note)
+}
+
+// staffNoteErrorStatus comparte el mapeo de errores entre las rutas de notas internas.
+func staffNoteErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrReportNotFound), errors.Is(err, service.ErrNoteNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidMention):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrNoteNotAuthor):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrNoteEditWindowClosed):
+		return http.StatusConflict
+	default:
+		return http.StatusGatewayTimeout
+	}
+}
+
+// 17.2.4.- handleReportMessages devuelve la conversación con quien reportó y la marca como leída para quien consulta.
+func (s *Server) handleReportMessages(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	messages, err := s.reportService.Messages(ctx, c.Param("id"), principalFrom(c))
+	if err != nil {
+		writeError(c, reportMessageErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(c, http.StatusOK, messages)
+}
+
+// 17.2.5.- handleReportMessageCreate guarda el mensaje y lo entrega en vivo sólo a sus destinatarios.
+func (s *Server) handleReportMessageCreate(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	var body dto.ReportMessageRequest
+	if ok := decodeAndValidate(c, &body); !ok {
+		return
+	}
+	message, err := s.reportService.SendMessage(ctx, c.Param("id"), principalFrom(c), body.Body)
+	if err != nil {
+		writeError(c, reportMessageErrorStatus(err), err.Error())
+		return
+	}
+	_ = s.realtimeHub.PublishMessage(message)
+	if message.Sender == service.MessageSenderCitizen {
+		message = message.ForCitizen()
+	}
+	writeJSON(c, http.StatusCreated, message)
+}
+
+// reportMessageErrorStatus comparte el mapeo de errores entre las rutas de mensajes.
+func reportMessageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrEmptyMessage):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrReportNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrReportHasNoReporter):
+		return http.StatusConflict
+	default:
+		return http.StatusGatewayTimeout
+	}
+}
+
+// 17.3.- handleReportAssign canaliza el reporte y avisa por tiempo real a la cuadrilla o dependencia.
+func (s *Server) handleReportAssign(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	var body dto.ReportAssignmentRequest
+	if ok := decodeAndValidate(c, &body); !ok {
+		return
+	}
+	report, err := s.reportService.Assign(ctx, c.Param("id"), service.AssignmentRequest{
+		DepartmentID: body.DepartmentID,
+		CrewID:       body.CrewID,
+		Actor:        c.GetString("auth.subject"),
+		Reason:       body.Reason,
+	})
+	if err != nil {
+		status := http.StatusGatewayTimeout
+		switch {
+		case errors.Is(err, service.ErrReportNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, service.ErrDepartmentNotFound), errors.Is(err, service.ErrCrewNotFound),
+			errors.Is(err, service.ErrCrewDepartmentMismatch), errors.Is(err, service.ErrAssignmentReasonRequired):
+			status = http.StatusBadRequest
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	_ = s.realtimeHub.PublishAssignment(report.WithoutContact())
+	if !s.canViewContact(c) {
+		report = report.WithoutContact()
+	}
+	writeJSON(c, http.StatusOK, report)
+}
+
+// 17.4.- handleDepartmentList devuelve las dependencias con sus cuadrillas.
+func (s *Server) handleDepartmentList(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	departments, err := s.reportService.Departments(ctx)
+	if err != nil {
+		writeError(c, http.StatusGatewayTimeout, err.Error())
+		return
+	}
+	writeJSON(c, http.StatusOK, departments)
+}
+
+// 17.5.- handleDepartmentCreate da de alta una dependencia.
+func (s *Server) handleDepartmentCreate(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	var body dto.NameRequest
+	if ok := decodeAndValidate(c, &body); !ok {
+		return
+	}
+	department, err := s.reportService.CreateDepartment(ctx, c.GetString("auth.subject"), body.Name)
+	if err != nil {
+		status := http.StatusGatewayTimeout
+		if errors.Is(err, service.ErrDepartmentExists) {
+			status = http.StatusConflict
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	writeJSON(c, http.StatusCreated, department)
+}
+
+// 17.6.- handleCrewCreate agrega una cuadrilla a la dependencia indicada.
+func (s *Server) handleCrewCreate(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	var body dto.NameRequest
+	if ok := decodeAndValidate(c, &body); !ok {
+		return
+	}
+	crew, err := s.reportService.CreateCrew(ctx, c.GetString("auth.subject"), c.Param("id"), body.Name)
+	if err != nil {
+		status := http.StatusGatewayTimeout
+		switch {
+		case errors.Is(err, service.ErrDepartmentNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, service.ErrCrewExists):
+			status = http.StatusConflict
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	writeJSON(c, http.StatusCreated, crew)
+}
+
+// 18.- handleFolioLookup reutiliza el servicio para mostrar el seguimiento.
+func (s *Server) handleFolioLookup(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	folioID := c.Param("folio")
+	if strings.TrimSpace(folioID) == "" {
+		writeError(c, http.StatusBadRequest, "missing folio id")
+		return
+	}
+	status, err := s.reportService.Lookup(ctx, folioID)
+	if err != nil {
+		statusCode := http.StatusGatewayTimeout
+		switch {
+		case errors.Is(err, service.ErrInvalidFolio):
+			statusCode = http.StatusBadRequest
+		case errors.Is(err, service.ErrReportNotFound):
+			statusCode = http.StatusNotFound
+		}
+		writeError(c, statusCode, err.Error())
+		return
+	}
+	writeJSON(c, http.StatusOK, status)
+}
+
+// 19.- handleAdminMetrics calcula los totales requeridos por el dashboard.
+func (s *Server) handleAdminMetrics(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
+	metrics, err := s.reportService.DashboardMetrics(ctx)
+	if err != nil {
+		writeError(c, http.StatusGatewayTimeout, err.Error())
+		return
+	}
+	writeJSON(c, http.StatusOK, metrics)
+}
+
+// 19.1.- handleRevokeSessions cierra todas las sesiones de la cuenta indicada.
+func (s *Server) handleRevokeSessions(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	if err := s.authService.RevokeSessions(ctx, c.Param("email")); err != nil {
+		status := http.StatusGatewayTimeout
+		if errors.Is(err, service.ErrUserNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// 19.2.- handleUnlockAccount borra el bloqueo por intentos fallidos de la cuenta indicada.
+func (s *Server) handleUnlockAccount(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	if err := s.authService.UnlockAccount(ctx, c.Param("email")); err != nil {
+		status := http.StatusGatewayTimeout
+		if errors.Is(err, service.ErrUserNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// 19.3.- handleUserList pagina las cuentas con búsqueda por correo o nombre y filtros de rol y estado.
+func (s *Server) handleUserList(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	users, err := s.authService.ListUsers(ctx, service.UserQuery{
+		Search:   c.Query("search"),
+		Role:     c.Query("role"),
+		Status:   c.Query("status"),
+		Page:     parseQueryInt(c.Query("page"), 0),
+		PageSize: parseQueryInt(c.Query("pageSize"), 20),
+	})
+	if err != nil {
+		status := http.StatusGatewayTimeout
+		if errors.Is(err, service.ErrInvalidRole) || errors.Is(err, service.ErrInvalidUserStatus) {
+			status = http.StatusBadRequest
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	writeJSON(c, http.StatusOK, users)
+}
+
+// 19.4.- handleUserInvite crea la cuenta con su rol y envía el enlace para configurar la contraseña.
+func (s *Server) handleUserInvite(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	var body dto.InviteUserRequest
+	if ok := decodeAndValidate(c, &body); !ok {
+		return
+	}
+	user, err := s.authService.InviteUser(ctx, principalFrom(c), body.Email, body.Role)
+	if err != nil {
+		status := http.StatusGatewayTimeout
+		switch {
+		case errors.Is(err, service.ErrInvalidRole), errors.Is(err, service.ErrInvalidCredentials):
+			status = http.StatusBadRequest
+		case errors.Is(err, service.ErrEmailConflict):
+			status = http.StatusConflict
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	writeJSON(c, http.StatusCreated, user)
+}
+
+// 19.5.- handleUserRole cambia el rol de otra cuenta.
+func (s *Server) handleUserRole(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	var body dto.ChangeRoleRequest
+	if ok := decodeAndValidate(c, &body); !ok {
+		return
+	}
+	err := s.authService.ChangeRole(ctx, principalFrom(c), c.Param("email"), body.Role)
+	writeUserAdminResult(c, err)
+}
+
+// 19.6.- handleUserDisable suspende la cuenta y revoca sus sesiones.
+func (s *Server) handleUserDisable(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	writeUserAdminResult(c, s.authService.DisableUser(ctx, principalFrom(c), c.Param("email")))
+}
+
+// 19.7.- handleUserEnable reactiva una cuenta suspendida.
+func (s *Server) handleUserEnable(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	writeUserAdminResult(c, s.authService.EnableUser(ctx, principalFrom(c), c.Param("email")))
+}
+
+// 19.8.- writeUserAdminResult mapea los errores comunes de los cambios administrativos.
+func writeUserAdminResult(c *gin.Context, err error) {
+	if err == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	status := http.StatusGatewayTimeout
+	switch {
+	case errors.Is(err, service.ErrInvalidRole):
+		status = http.StatusBadRequest
+	case errors.Is(err, service.ErrUserNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrSelfManagement):
+		status = http.StatusConflict
+	}
+	writeError(c, status, err.Error())
+}
+
+// 19.9.- handleAPIKeyList lista las claves vigentes sin su valor.
+func (s *Server) handleAPIKeyList(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	keys, err := s.authService.APIKeys(ctx)
+	if err != nil {
+		writeError(c, http.StatusGatewayTimeout, err.Error())
+		return
+	}
+	writeJSON(c, http.StatusOK, keys)
+}
+
+// 19.10.- handleAPIKeyCreate emite una clave; su valor sólo aparece en esta respuesta.
+func (s *Server) handleAPIKeyCreate(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	var body dto.CreateAPIKeyRequest
+	if ok := decodeAndValidate(c, &body); !ok {
+		return
+	}
+	spec := service.NewAPIKey{Name: body.Name, Scopes: body.Scopes, RateLimit: body.RateLimit}
+	if body.ExpiresAt != nil {
+		spec.ExpiresAt = *body.ExpiresAt
+	}
+	key, err := s.authService.CreateAPIKey(ctx, principalFrom(c), spec)
+	if err != nil {
+		status := http.StatusGatewayTimeout
+		if errors.Is(err, service.ErrInvalidAPIKeyScope) || errors.Is(err, service.ErrInvalidAPIKeyExpiry) {
+			status = http.StatusBadRequest
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	writeJSON(c, http.StatusCreated, key)
+}
+
+// 19.11.- handleAPIKeyRevoke invalida la clave indicada de inmediato.
+func (s *Server) handleAPIKeyRevoke(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	if err := s.authService.RevokeAPIKey(ctx, principalFrom(c), c.Param("id")); err != nil {
+		status := http.StatusGatewayTimeout
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(c, status, err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// 20.- handleWebSocket conserva la actualización en tiempo real y suscribe los canales pedidos en la consulta.
+func (s *Server) handleWebSocket(c *gin.Context) {
+	var channels []string
+	for _, crewID := range c.QueryArray("crew") {
+		channels = append(channels, realtime.CrewChannel(crewID))
+	}
+	for _, departmentID := range c.QueryArray("department") {
+		channels = append(channels, realtime.DepartmentChannel(departmentID))
+	}
+	conn, err := s.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, "websocket upgrade failed")
+		return
+	}
+	var client *realtime.Client
+	// Una conexión autenticada recibe su canal privado sólo mientras su token siga vigente.
+	if principal := principalFrom(c); principal.Subject != "" && principal.APIKeyID == "" {
+		client = s.realtimeHub.RegisterUser(conn, principal, channels...)
+	} else {
+		client = s.realtimeHub.Register(conn, channels...)
+	}
+	go client.Run(context.Background())
+}
+
+// 21.- Shutdown sincroniza el cierre del hub con el servidor HTTP.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.realtimeHub.Shutdown(ctx)
+}
+
+// canViewContact indica si el rol autenticado puede consultar datos de contacto.
+func (s *Server) canViewContact(c *gin.Context) bool {
+	return principalFrom(c).Can(service.PermReportsContact)
+}
+
+// 22.- writeJSON homologa la serialización JSON y cabeceras comunes.
 func writeJSON(c *gin.Context, status int, payload any) {
 	c.Header("Content-Type", "application/json")
 	c.Status(status)
@@ -384,11 +1620,17 @@ func writeJSON(c *gin.Context, status int, payload any) {
 
 // 23.- writeError devuelve el esquema de ErrorResponse definido en OpenAPI.
 func writeError(c *gin.Context, status int, message string) {
+	writeErrorDetails(c, status, message, nil)
+}
+
+// 23.1.- writeErrorDetails agrega el contexto legible por máquina del campo details.
+func writeErrorDetails(c *gin.Context, status int, message string, details any) {
 	type errorResponse struct {
 		Code    int    `json:"code"`
 		Message string `json:"message"`
+		Details any    `json:"details,omitempty"`
 	}
-	payload := errorResponse{Code: status, Message: message}
+	payload := errorResponse{Code: status, Message: message, Details: details}
 	c.AbortWithStatusJSON(status, payload)
 }
 
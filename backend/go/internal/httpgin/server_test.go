@@ -3,15 +3,25 @@ package httpgin
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"citizenapp/backend/internal/mail"
+	"citizenapp/backend/internal/realtime"
 	"citizenapp/backend/internal/service"
 	"github.com/gin-gonic/gin"
 )
@@ -19,31 +29,140 @@ import (
 // 1.- inMemoryUserRepository simula la base de datos para autenticación.
 type inMemoryUserRepository struct {
 	mu    sync.RWMutex
-	users map[string]string
+	users map[string]service.User
 }
 
 func newInMemoryUserRepository() *inMemoryUserRepository {
-	return &inMemoryUserRepository{users: make(map[string]string)}
+	return &inMemoryUserRepository{users: make(map[string]service.User)}
 }
 
-func (r *inMemoryUserRepository) Create(_ context.Context, email, passwordHash string) error {
+func (r *inMemoryUserRepository) Create(_ context.Context, user service.User) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if _, exists := r.users[email]; exists {
+	if _, exists := r.users[user.Email]; exists {
 		return service.ErrEmailConflict
 	}
-	r.users[email] = passwordHash
+	r.users[user.Email] = user
 	return nil
 }
 
-func (r *inMemoryUserRepository) PasswordHash(_ context.Context, email string) (string, error) {
+func (r *inMemoryUserRepository) FindByEmail(_ context.Context, email string) (service.User, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	hash, ok := r.users[email]
+	user, ok := r.users[email]
 	if !ok {
-		return "", service.ErrInvalidCredentials
+		return service.User{}, service.ErrUserNotFound
 	}
-	return hash, nil
+	return user, nil
+}
+
+func (r *inMemoryUserRepository) UpdateRole(_ context.Context, email, role string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[email]
+	if !ok {
+		return service.ErrUserNotFound
+	}
+	user.Role = role
+	r.users[email] = user
+	return nil
+}
+
+func (r *inMemoryUserRepository) UpdatePassword(_ context.Context, email, passwordHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[email]
+	if !ok {
+		return service.ErrUserNotFound
+	}
+	user.PasswordHash = passwordHash
+	r.users[email] = user
+	return nil
+}
+
+func (r *inMemoryUserRepository) UpgradePasswordHash(_ context.Context, email, currentHash, newHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[email]
+	if !ok || user.PasswordHash != currentHash {
+		return nil
+	}
+	user.PasswordHash = newHash
+	r.users[email] = user
+	return nil
+}
+
+func (r *inMemoryUserRepository) UpdateProfile(_ context.Context, user service.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.users[user.Email]
+	if !ok {
+		return service.ErrUserNotFound
+	}
+	stored.DisplayName, stored.Phone, stored.Notifications = user.DisplayName, user.Phone, user.Notifications
+	r.users[user.Email] = stored
+	return nil
+}
+
+func (r *inMemoryUserRepository) Delete(_ context.Context, email string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[email]; !ok {
+		return service.ErrUserNotFound
+	}
+	delete(r.users, email)
+	return nil
+}
+
+func (r *inMemoryUserRepository) MarkVerified(_ context.Context, email string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[email]
+	if !ok {
+		return service.ErrUserNotFound
+	}
+	if !user.Verified() {
+		user.VerifiedAt = at
+	}
+	r.users[email] = user
+	return nil
+}
+
+func (r *inMemoryUserRepository) List(_ context.Context, query service.UserQuery) ([]service.User, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	search := strings.ToLower(query.Search)
+	var matched []service.User
+	for _, user := range r.users {
+		if search != "" && !strings.Contains(user.Email, search) && !strings.Contains(strings.ToLower(user.DisplayName), search) {
+			continue
+		}
+		if (query.Role != "" && user.Role != query.Role) || (query.Status != "" && user.Status() != query.Status) {
+			continue
+		}
+		matched = append(matched, user)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].CreatedAt.After(matched[j].CreatedAt)
+		}
+		return matched[i].Email < matched[j].Email
+	})
+	start := min(query.Page*query.PageSize, len(matched))
+	end := min(start+query.PageSize, len(matched))
+	return append([]service.User{}, matched[start:end]...), len(matched), nil
+}
+
+func (r *inMemoryUserRepository) SetDisabled(_ context.Context, email string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[email]
+	if !ok {
+		return service.ErrUserNotFound
+	}
+	user.DisabledAt = at
+	r.users[email] = user
+	return nil
 }
 
 func (r *inMemoryUserRepository) Exists(_ context.Context, email string) (bool, error) {
@@ -57,16 +176,19 @@ func (r *inMemoryUserRepository) Exists(_ context.Context, email string) (bool,
 type inMemoryReportRepository struct {
 	mu      sync.RWMutex
 	records map[string]service.Report
+	events  map[string][]service.ReportEvent
+	nextID  int64
 }
 
 func newInMemoryReportRepository() *inMemoryReportRepository {
-	return &inMemoryReportRepository{records: make(map[string]service.Report)}
+	return &inMemoryReportRepository{records: make(map[string]service.Report), events: make(map[string][]service.ReportEvent)}
 }
 
-func (r *inMemoryReportRepository) Create(_ context.Context, report service.Report) (service.Report, error) {
+func (r *inMemoryReportRepository) Create(_ context.Context, report service.Report, event service.ReportEvent) (service.Report, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	r.records[report.ID] = report
+	r.appendLocked(event)
 	return report, nil
 }
 
@@ -77,294 +199,1900 @@ func (r *inMemoryReportRepository) FindByID(_ context.Context, id string) (servi
 	if !ok {
 		return service.Report{}, service.ErrReportNotFound
 	}
-	return report, nil
-}
+	return report, nil
+}
+
+func (r *inMemoryReportRepository) List(_ context.Context, filter service.ReportFilter) ([]service.Report, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	items := make([]service.Report, 0, len(r.records))
+	for _, report := range r.records {
+		if filter.Status != "" && report.Status != filter.Status {
+			continue
+		}
+		if filter.Reporter != "" && report.Reporter != filter.Reporter {
+			continue
+		}
+		if (filter.DepartmentID != "" || filter.CrewID != "") && report.Assignment == nil {
+			continue
+		}
+		if filter.DepartmentID != "" && report.Assignment.DepartmentID != filter.DepartmentID {
+			continue
+		}
+		if filter.CrewID != "" && report.Assignment.CrewID != filter.CrewID {
+			continue
+		}
+		items = append(items, report)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].CreatedAt.After(items[j].CreatedAt)
+	})
+	total := len(items)
+	start := filter.Page * filter.PageSize
+	if start > total {
+		start = total
+	}
+	end := start + filter.PageSize
+	if end > total {
+		end = total
+	}
+	return items[start:end], total, nil
+}
+
+func (r *inMemoryReportRepository) Delete(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.records[id]; !ok {
+		return service.ErrReportNotFound
+	}
+	delete(r.records, id)
+	delete(r.events, id)
+	return nil
+}
+
+func (r *inMemoryReportRepository) AppendEvent(_ context.Context, event service.ReportEvent) (service.ReportEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.records[event.ReportID]; !ok {
+		return service.ReportEvent{}, service.ErrReportNotFound
+	}
+	return r.appendLocked(event), nil
+}
+
+func (r *inMemoryReportRepository) Events(_ context.Context, reportID string) ([]service.ReportEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]service.ReportEvent(nil), r.events[reportID]...), nil
+}
+
+func (r *inMemoryReportRepository) appendLocked(event service.ReportEvent) service.ReportEvent {
+	r.nextID++
+	event.ID = r.nextID
+	r.events[event.ReportID] = append(r.events[event.ReportID], event)
+	return event
+}
+
+func (r *inMemoryReportRepository) UpdateStatus(_ context.Context, fromStatus string, event service.ReportEvent) (service.Report, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report, ok := r.records[event.ReportID]
+	if !ok {
+		return service.Report{}, service.ErrReportNotFound
+	}
+	if report.Status != fromStatus {
+		return service.Report{}, service.ErrStatusConflict
+	}
+	report.Status = event.Status
+	report.StatusChangedAt = event.CreatedAt
+	r.records[event.ReportID] = report
+	r.appendLocked(event)
+	return report, nil
+}
+
+func (r *inMemoryReportRepository) Assign(_ context.Context, assignment service.Assignment, event service.ReportEvent) (service.Report, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report, ok := r.records[event.ReportID]
+	if !ok {
+		return service.Report{}, service.ErrReportNotFound
+	}
+	report.Assignment = &assignment
+	r.records[event.ReportID] = report
+	r.appendLocked(event)
+	return report, nil
+}
+
+func (r *inMemoryReportRepository) StatusCounts(_ context.Context) (map[string]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	counts := make(map[string]int)
+	for _, report := range r.records {
+		counts[report.Status]++
+	}
+	return counts, nil
+}
+
+func (r *inMemoryReportRepository) AnonymizeReporter(_ context.Context, email, actor string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := 0
+	for id, report := range r.records {
+		submitted := false
+		for i, event := range r.events[id] {
+			if event.Actor == email {
+				submitted = submitted || event.Kind == service.ReportEventSubmitted
+				r.events[id][i].Actor = actor
+			}
+		}
+		if submitted || report.ContactEmail == email || report.Reporter == email {
+			report.ContactEmail, report.ContactPhone, report.Reporter = "", "", ""
+			r.records[id] = report
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *inMemoryReportRepository) ClaimReports(_ context.Context, anonymousActor, email string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := 0
+	for id, report := range r.records {
+		for i, event := range r.events[id] {
+			if event.Actor != anonymousActor {
+				continue
+			}
+			r.events[id][i].Actor = email
+			if event.Kind == service.ReportEventSubmitted {
+				report.Anonymous, report.Reporter = false, email
+				r.records[id] = report
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+// memoryFolioSequence entrega consecutivos en memoria para las pruebas.
+type memoryFolioSequence struct {
+	next atomic.Int64
+}
+
+func (m *memoryFolioSequence) NextValue(context.Context) (int64, error) {
+	return m.next.Add(1), nil
+}
+
+// inMemoryRefreshStore guarda los tokens de renovación con consumo único.
+type inMemoryRefreshStore struct {
+	mu      sync.Mutex
+	tokens  map[string]service.RefreshToken
+	used    map[string]bool
+	revoked map[string]bool
+}
+
+func newInMemoryRefreshStore() *inMemoryRefreshStore {
+	return &inMemoryRefreshStore{tokens: make(map[string]service.RefreshToken), used: make(map[string]bool), revoked: make(map[string]bool)}
+}
+
+func (r *inMemoryRefreshStore) Create(_ context.Context, token service.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[token.TokenHash] = token
+	return nil
+}
+
+func (r *inMemoryRefreshStore) Consume(_ context.Context, tokenHash string, now time.Time) (service.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[tokenHash]
+	if !ok {
+		return service.RefreshToken{}, service.ErrInvalidRefreshToken
+	}
+	if r.used[tokenHash] {
+		return token, service.ErrRefreshTokenReused
+	}
+	if r.revoked[token.FamilyID] || !token.ExpiresAt.After(now) {
+		return service.RefreshToken{}, service.ErrInvalidRefreshToken
+	}
+	r.used[tokenHash] = true
+	return token, nil
+}
+
+func (r *inMemoryRefreshStore) RevokeFamily(_ context.Context, familyID string, _ time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revoked[familyID] = true
+	return nil
+}
+
+func (r *inMemoryRefreshStore) RevokeSubject(_ context.Context, subject string, _ time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.Subject == subject {
+			r.revoked[token.FamilyID] = true
+		}
+	}
+	return nil
+}
+
+// inMemoryRevocationStore mantiene la lista de denegación sin base de datos.
+type inMemoryRevocationStore struct {
+	mu       sync.Mutex
+	tokens   map[string]time.Time
+	subjects map[string]time.Time
+}
+
+func newInMemoryRevocationStore() *inMemoryRevocationStore {
+	return &inMemoryRevocationStore{tokens: make(map[string]time.Time), subjects: make(map[string]time.Time)}
+}
+
+func (r *inMemoryRevocationStore) RevokeToken(_ context.Context, jti string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[jti] = expiresAt
+	return nil
+}
+
+func (r *inMemoryRevocationStore) IsTokenRevoked(_ context.Context, jti string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.tokens[jti]
+	return ok, nil
+}
+
+func (r *inMemoryRevocationStore) RevokeSubject(_ context.Context, subject string, cutoff, _ time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subjects[subject] = cutoff
+	return nil
+}
+
+func (r *inMemoryRevocationStore) SubjectCutoff(_ context.Context, subject string, _ time.Time) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.subjects[subject], nil
+}
+
+// inMemoryOneTimeTokenStore conserva los tokens enviados por correo.
+type inMemoryOneTimeTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]service.OneTimeToken
+	used   map[string]bool
+}
+
+func newInMemoryOneTimeTokenStore() *inMemoryOneTimeTokenStore {
+	return &inMemoryOneTimeTokenStore{tokens: make(map[string]service.OneTimeToken), used: make(map[string]bool)}
+}
+
+func (r *inMemoryOneTimeTokenStore) Create(_ context.Context, token service.OneTimeToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[token.TokenHash] = token
+	return nil
+}
+
+func (r *inMemoryOneTimeTokenStore) Consume(_ context.Context, purpose, tokenHash string, now time.Time) (service.OneTimeToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[tokenHash]
+	if !ok || token.Purpose != purpose || r.used[tokenHash] || !token.ExpiresAt.After(now) {
+		return service.OneTimeToken{}, service.ErrInvalidOneTimeToken
+	}
+	r.used[tokenHash] = true
+	return token, nil
+}
+
+func (r *inMemoryOneTimeTokenStore) InvalidateSubject(_ context.Context, purpose, subject string, _ time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for hash, token := range r.tokens {
+		if token.Purpose == purpose && token.Subject == subject {
+			r.used[hash] = true
+		}
+	}
+	return nil
+}
+
+// inMemoryLoginAttemptStore lleva los contadores de bloqueo sin ventana deslizante.
+type inMemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]service.LoginAttempt
+}
+
+func newInMemoryLoginAttemptStore() *inMemoryLoginAttemptStore {
+	return &inMemoryLoginAttemptStore{attempts: make(map[string]service.LoginAttempt)}
+}
+
+func (r *inMemoryLoginAttemptStore) Lookup(_ context.Context, key string) (service.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.attempts[key], nil
+}
+
+func (r *inMemoryLoginAttemptStore) RecordFailure(_ context.Context, key string, now, _ time.Time) (service.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempt := r.attempts[key]
+	attempt.Key = key
+	attempt.Failures++
+	attempt.LastFailure = now
+	r.attempts[key] = attempt
+	return attempt, nil
+}
+
+func (r *inMemoryLoginAttemptStore) Lock(_ context.Context, key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempt := r.attempts[key]
+	attempt.LockedUntil = until
+	r.attempts[key] = attempt
+	return nil
+}
+
+func (r *inMemoryLoginAttemptStore) Reset(_ context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.attempts, key)
+	return nil
+}
+
+// inMemoryMFARepository guarda inscripciones TOTP sin persistencia.
+type inMemoryMFARepository struct {
+	mu       sync.Mutex
+	enrolled map[string]service.MFAEnrollment
+	recovery map[string]bool
+}
+
+func newInMemoryMFARepository() *inMemoryMFARepository {
+	return &inMemoryMFARepository{enrolled: make(map[string]service.MFAEnrollment), recovery: make(map[string]bool)}
+}
+
+func (r *inMemoryMFARepository) FindMFA(_ context.Context, subject string) (service.MFAEnrollment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	enrollment, ok := r.enrolled[subject]
+	if !ok {
+		return service.MFAEnrollment{}, service.ErrMFANotEnrolled
+	}
+	return enrollment, nil
+}
+
+func (r *inMemoryMFARepository) SaveMFA(_ context.Context, enrollment service.MFAEnrollment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.enrolled[enrollment.Subject].Confirmed() {
+		return service.ErrMFAAlreadyEnrolled
+	}
+	r.enrolled[enrollment.Subject] = enrollment
+	return nil
+}
+
+func (r *inMemoryMFARepository) ConfirmMFA(_ context.Context, subject string, at time.Time, hashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	enrollment := r.enrolled[subject]
+	enrollment.ConfirmedAt = at
+	r.enrolled[subject] = enrollment
+	for _, hash := range hashes {
+		r.recovery[subject+"|"+hash] = false
+	}
+	return nil
+}
+
+func (r *inMemoryMFARepository) UseTOTPStep(_ context.Context, subject string, step int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	enrollment := r.enrolled[subject]
+	if step <= enrollment.LastStep {
+		return service.ErrInvalidMFACode
+	}
+	enrollment.LastStep = step
+	r.enrolled[subject] = enrollment
+	return nil
+}
+
+func (r *inMemoryMFARepository) ConsumeRecoveryCode(_ context.Context, subject, codeHash string, _ time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	used, ok := r.recovery[subject+"|"+codeHash]
+	if !ok || used {
+		return service.ErrInvalidMFACode
+	}
+	r.recovery[subject+"|"+codeHash] = true
+	return nil
+}
+
+// currentTOTP calcula el código vigente como lo haría la aplicación autenticadora.
+func currentTOTP(t *testing.T, secret string) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatalf("cannot decode secret: %v", err)
+	}
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(time.Now().Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+// inMemorySessionStore conserva las sesiones por dispositivo y sus registros push.
+type inMemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]service.Session
+	push     map[string]service.PushRegistration
+}
+
+func newInMemorySessionStore() *inMemorySessionStore {
+	return &inMemorySessionStore{sessions: make(map[string]service.Session), push: make(map[string]service.PushRegistration)}
+}
+
+func (r *inMemorySessionStore) CreateSession(_ context.Context, session service.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[session.ID] = session
+	return nil
+}
+
+func (r *inMemorySessionStore) TouchSession(_ context.Context, id string, at time.Time, _ string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok || !session.RevokedAt.IsZero() {
+		return service.ErrSessionNotFound
+	}
+	session.LastSeenAt = at
+	r.sessions[id] = session
+	return nil
+}
+
+func (r *inMemorySessionStore) FindSession(_ context.Context, id string) (service.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok {
+		return service.Session{}, service.ErrSessionNotFound
+	}
+	return session, nil
+}
+
+func (r *inMemorySessionStore) ListSessions(_ context.Context, subject string, activeSince time.Time) ([]service.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sessions := make([]service.Session, 0)
+	for _, session := range r.sessions {
+		if session.Subject == subject && session.RevokedAt.IsZero() && session.LastSeenAt.After(activeSince) {
+			sessions = append(sessions, session)
+		}
+	}
+	// Igual que el store de Postgres: actividad reciente primero; el alta desempata para un orden estable.
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastSeenAt.Equal(sessions[j].LastSeenAt) {
+			return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+		}
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+	return sessions, nil
+}
+
+func (r *inMemorySessionStore) RevokeSession(_ context.Context, id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if session, ok := r.sessions[id]; ok && session.RevokedAt.IsZero() {
+		session.RevokedAt = at
+		r.sessions[id] = session
+	}
+	delete(r.push, id)
+	return nil
+}
+
+func (r *inMemorySessionStore) RevokeSubjectSessions(ctx context.Context, subject string, at time.Time) error {
+	r.mu.Lock()
+	var ids []string
+	for id, session := range r.sessions {
+		if session.Subject == subject {
+			ids = append(ids, id)
+		}
+	}
+	r.mu.Unlock()
+	for _, id := range ids {
+		_ = r.RevokeSession(ctx, id, at)
+	}
+	return nil
+}
+
+func (r *inMemorySessionStore) SavePushRegistration(_ context.Context, registration service.PushRegistration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, existing := range r.push {
+		if existing.Token == registration.Token {
+			delete(r.push, id)
+		}
+	}
+	r.push[registration.SessionID] = registration
+	return nil
+}
+
+func (r *inMemorySessionStore) DeletePushRegistration(_ context.Context, sessionID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.push, sessionID)
+	return nil
+}
+
+func (r *inMemorySessionStore) PushRegistrations(_ context.Context, subject string) ([]service.PushRegistration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var registrations []service.PushRegistration
+	for _, registration := range r.push {
+		if registration.Subject == subject {
+			registrations = append(registrations, registration)
+		}
+	}
+	return registrations, nil
+}
+
+// inMemoryAPIKeyStore guarda las claves por ID y las busca por hash.
+type inMemoryAPIKeyStore struct {
+	mu   sync.Mutex
+	keys map[string]service.APIKey
+}
+
+func newInMemoryAPIKeyStore() *inMemoryAPIKeyStore {
+	return &inMemoryAPIKeyStore{keys: make(map[string]service.APIKey)}
+}
+
+func (r *inMemoryAPIKeyStore) CreateAPIKey(_ context.Context, key service.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys[key.ID] = key
+	return nil
+}
+
+func (r *inMemoryAPIKeyStore) FindAPIKeyByHash(_ context.Context, hash string) (service.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range r.keys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+	return service.APIKey{}, service.ErrAPIKeyNotFound
+}
+
+func (r *inMemoryAPIKeyStore) ListAPIKeys(_ context.Context) ([]service.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := make([]service.APIKey, 0)
+	for _, key := range r.keys {
+		if key.RevokedAt.IsZero() {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (r *inMemoryAPIKeyStore) RevokeAPIKey(_ context.Context, id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key, ok := r.keys[id]
+	if !ok || !key.RevokedAt.IsZero() {
+		return service.ErrAPIKeyNotFound
+	}
+	key.RevokedAt = at
+	r.keys[id] = key
+	return nil
+}
+
+func (r *inMemoryAPIKeyStore) TouchAPIKey(_ context.Context, id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := r.keys[id]
+	key.LastUsedAt = &at
+	r.keys[id] = key
+	return nil
+}
+
+// inMemoryDepartmentStore guarda dependencias y cuadrillas con nombres únicos sin distinguir mayúsculas.
+type inMemoryDepartmentStore struct {
+	mu          sync.Mutex
+	departments map[string]service.Department
+	crews       map[string]service.Crew
+}
+
+func newInMemoryDepartmentStore() *inMemoryDepartmentStore {
+	return &inMemoryDepartmentStore{departments: make(map[string]service.Department), crews: make(map[string]service.Crew)}
+}
+
+func (r *inMemoryDepartmentStore) CreateDepartment(_ context.Context, department service.Department) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.departments {
+		if strings.EqualFold(existing.Name, department.Name) {
+			return service.ErrDepartmentExists
+		}
+	}
+	r.departments[department.ID] = department
+	return nil
+}
+
+func (r *inMemoryDepartmentStore) CreateCrew(_ context.Context, crew service.Crew) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.crews {
+		if existing.DepartmentID == crew.DepartmentID && strings.EqualFold(existing.Name, crew.Name) {
+			return service.ErrCrewExists
+		}
+	}
+	r.crews[crew.ID] = crew
+	return nil
+}
+
+func (r *inMemoryDepartmentStore) Departments(_ context.Context) ([]service.Department, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	departments := make([]service.Department, 0, len(r.departments))
+	for _, department := range r.departments {
+		department.Crews = []service.Crew{}
+		for _, crew := range r.crews {
+			if crew.DepartmentID == department.ID {
+				department.Crews = append(department.Crews, crew)
+			}
+		}
+		departments = append(departments, department)
+	}
+	sort.Slice(departments, func(i, j int) bool { return departments[i].Name < departments[j].Name })
+	return departments, nil
+}
+
+func (r *inMemoryDepartmentStore) FindDepartment(_ context.Context, id string) (service.Department, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	department, ok := r.departments[id]
+	if !ok {
+		return service.Department{}, service.ErrDepartmentNotFound
+	}
+	return department, nil
+}
+
+func (r *inMemoryDepartmentStore) FindCrew(_ context.Context, id string) (service.Crew, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	crew, ok := r.crews[id]
+	if !ok {
+		return service.Crew{}, service.ErrCrewNotFound
+	}
+	return crew, nil
+}
+
+// inMemoryNoteStore guarda las notas internas en orden de creación.
+type inMemoryNoteStore struct {
+	mu    sync.Mutex
+	notes []service.StaffNote
+}
+
+func newInMemoryNoteStore() *inMemoryNoteStore {
+	return &inMemoryNoteStore{}
+}
+
+func (r *inMemoryNoteStore) CreateNote(_ context.Context, note service.StaffNote) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notes = append(r.notes, note)
+	return nil
+}
+
+func (r *inMemoryNoteStore) Notes(_ context.Context, reportID string) ([]service.StaffNote, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	notes := make([]service.StaffNote, 0)
+	for _, note := range r.notes {
+		if note.ReportID == reportID {
+			notes = append(notes, note)
+		}
+	}
+	return notes, nil
+}
+
+func (r *inMemoryNoteStore) FindNote(_ context.Context, reportID, id string) (service.StaffNote, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, note := range r.notes {
+		if note.ReportID == reportID && note.ID == id {
+			return note, nil
+		}
+	}
+	return service.StaffNote{}, service.ErrNoteNotFound
+}
+
+func (r *inMemoryNoteStore) UpdateNote(_ context.Context, note service.StaffNote) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, existing := range r.notes {
+		if existing.ReportID == note.ReportID && existing.ID == note.ID {
+			r.notes[i] = note
+			return nil
+		}
+	}
+	return service.ErrNoteNotFound
+}
+
+// inMemoryMessageStore guarda la conversación de cada reporte en orden de envío.
+type inMemoryMessageStore struct {
+	mu       sync.Mutex
+	messages []service.ReportMessage
+}
+
+func newInMemoryMessageStore() *inMemoryMessageStore {
+	return &inMemoryMessageStore{}
+}
+
+func (r *inMemoryMessageStore) CreateMessage(_ context.Context, message service.ReportMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, message)
+	return nil
+}
+
+func (r *inMemoryMessageStore) Messages(_ context.Context, reportID string) ([]service.ReportMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	messages := make([]service.ReportMessage, 0)
+	for _, message := range r.messages {
+		if message.ReportID == reportID {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+func (r *inMemoryMessageStore) MarkRead(_ context.Context, reportID, sender string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, message := range r.messages {
+		if message.ReportID == reportID && message.Sender == sender && message.ReadAt == nil {
+			read := at
+			r.messages[i].ReadAt = &read
+		}
+	}
+	return nil
+}
+
+func (r *inMemoryMessageStore) UnreadCount(_ context.Context, reportID, sender string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := 0
+	for _, message := range r.messages {
+		if message.ReportID == reportID && message.Sender == sender && message.ReadAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// 3.- buildServer centraliza la creación del servidor de pruebas.
+func buildServer(t *testing.T, opts ...Option) *Server {
+	t.Helper()
+	return buildServerWithAuth(t, nil, opts...)
+}
+
+// buildServerWithAuth permite habilitar dependencias opcionales del servicio de autenticación.
+func buildServerWithAuth(t *testing.T, authOpts []service.AuthOption, opts ...Option) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	authRepo := newInMemoryUserRepository()
+	reportRepo := newInMemoryReportRepository()
+	hub := realtime.NewHub(8, 8, 4096, 128)
+	authOpts = append([]service.AuthOption{
+		service.WithRefreshTokens(newInMemoryRefreshStore(), time.Hour),
+		service.WithRevocation(newInMemoryRevocationStore()),
+		service.WithSessions(newInMemorySessionStore()),
+		service.WithSessionListener(hub),
+	}, authOpts...)
+	authSvc := service.NewAuthService(authRepo, 2, time.Minute, []byte("integration-secret"), authOpts...)
+	catalogSvc := service.NewCatalogService(1)
+	folios, err := service.NewSequenceFolioGenerator(&memoryFolioSequence{}, "F")
+	if err != nil {
+		t.Fatalf("NewSequenceFolioGenerator returned error: %v", err)
+	}
+	reportSvc := service.NewReportService(reportRepo, folios, 2, 2,
+		service.WithDepartments(newInMemoryDepartmentStore()),
+		service.WithStaffNotes(newInMemoryNoteStore(), authSvc, 0),
+		service.WithMessages(newInMemoryMessageStore()))
+	srv := New(authSvc, catalogSvc, reportSvc, append([]Option{WithRealtimeHub(hub)}, opts...)...)
+	t.Cleanup(func() {
+		_ = srv.Shutdown(context.Background())
+	})
+	return srv
+}
+
+func TestOpenAPIEndpointsHappyPath(t *testing.T) {
+	// 4.- Iniciamos el servidor y registramos un usuario nuevo.
+	srv := buildServer(t)
+	registerBody := map[string]string{
+		"email":    "citizen@example.com",
+		"password": "ClaveSegura1",
+	}
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/register", registerBody, http.StatusCreated, nil)
+	if err := srv.authService.AssignRole(context.Background(), registerBody["email"], service.RoleAdmin); err != nil {
+		t.Fatalf("cannot promote admin: %v", err)
+	}
+
+	// 5.- Validamos el login tradicional.
+	var loginResponse service.AuthResponse
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/login", registerBody, http.StatusOK, &loginResponse)
+	if loginResponse.Token == "" {
+		t.Fatalf("expected non-empty token from login")
+	}
+	authHeader := withAuth(loginResponse.Token)
+
+	// 6.- Recuperación de contraseña responde 202 sin revelar la cuenta.
+	recoverBody := map[string]string{"email": registerBody["email"]}
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/recover", recoverBody, http.StatusAccepted, nil)
+
+	// 7.- Autenticación social exige credencial y un proveedor configurado.
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/social/google", map[string]string{}, http.StatusBadRequest, nil)
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/social/google", map[string]string{"idToken": "header.payload.signature"}, http.StatusBadRequest, nil)
+
+	// 8.- El catálogo debe responder con tipos disponibles.
+	var catalog []service.IncidentType
+	performRequest(t, srv, http.MethodGet, "/api/v1/catalog/incident-types", nil, http.StatusOK, &catalog)
+	if len(catalog) == 0 {
+		t.Fatalf("expected at least one incident type")
+	}
+
+	// 9.- Capturamos el hub para verificar el broadcast posterior.
+	client := srv.realtimeHub.Register(&captureConn{})
+
+	// 10.- Ingresamos un reporte completo.
+	reportBody := map[string]any{
+		"incidentTypeId": "pothole",
+		"description":    "Bache profundo",
+		"contactEmail":   registerBody["email"],
+		"contactPhone":   "5512345678",
+		"latitude":       19.4,
+		"longitude":      -99.1,
+		"address":        "Centro, CDMX",
+	}
+	var created service.Report
+	performJSON(t, srv, http.MethodPost, "/api/v1/reports", reportBody, http.StatusCreated, &created, authHeader)
+	if created.ID == "" {
+		t.Fatalf("expected generated report identifier")
+	}
+
+	// 11.- Confirmamos la notificación WebSocket del nuevo reporte.
+	select {
+	case msg := <-client.Messages():
+		var payload map[string]any
+		if err := json.Unmarshal(msg, &payload); err != nil {
+			t.Fatalf("cannot decode broadcast: %v", err)
+		}
+		if payload["type"] != "report.created" {
+			t.Fatalf("unexpected broadcast type: %v", payload["type"])
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected websocket broadcast after submission")
+	}
+
+	// 12.- Listamos los reportes administrativos.
+	var list service.PaginatedReports
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports?page=0&pageSize=10", nil, http.StatusOK, &list, authHeader)
+	if len(list.Items) == 0 {
+		t.Fatalf("expected paginated items in list endpoint")
+	}
+
+	// 13.- Leemos el reporte directo y comprobamos el ID.
+	var fetched service.Report
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports/"+created.ID, nil, http.StatusOK, &fetched, authHeader)
+	if fetched.ID != created.ID {
+		t.Fatalf("expected fetched report ID %s, got %s", created.ID, fetched.ID)
+	}
+
+	// 14.- Actualizamos el estatus a resuelto.
+	updateBody := map[string]string{"status": "resuelto"}
+	performJSON(t, srv, http.MethodPatch, "/api/v1/reports/"+created.ID, updateBody, http.StatusOK, &fetched, authHeader)
+	if fetched.Status != "resuelto" {
+		t.Fatalf("expected updated status resuelto, got %s", fetched.Status)
+	}
+
+	// 14.1.- Una transición no declarada en el flujo responde 409.
+	performJSON(t, srv, http.MethodPatch, "/api/v1/reports/"+created.ID, map[string]string{"status": "critico"}, http.StatusConflict, nil, authHeader)
+
+	// 15.- El dashboard debe reflejar el conteo de resueltos.
+	var metrics service.AdminDashboardMetrics
+	performRequest(t, srv, http.MethodGet, "/api/v1/admin/dashboard/metrics", nil, http.StatusOK, &metrics, authHeader)
+	if metrics.ResolvedReports == 0 {
+		t.Fatalf("expected resolved reports metric to be positive")
+	}
+
+	// 16.- Consultamos el folio directo para validar seguimiento.
+	var folio service.FolioStatus
+	performRequest(t, srv, http.MethodGet, "/api/v1/folios/"+created.ID, nil, http.StatusOK, &folio)
+	if folio.Folio != created.ID {
+		t.Fatalf("expected folio %s, got %s", created.ID, folio.Folio)
+	}
+	if len(folio.History) != 2 || folio.History[1].Status != "resuelto" {
+		t.Fatalf("expected submitted and status entries in history, got %+v", folio.History)
+	}
+
+	// 17.- Eliminamos el reporte y comprobamos la ausencia posterior.
+	performRequest(t, srv, http.MethodDelete, "/api/v1/reports/"+created.ID, nil, http.StatusNoContent, nil, authHeader)
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports/"+created.ID, nil, http.StatusNotFound, nil, authHeader)
+}
+
+func TestMethodEnforcementRemainsActive(t *testing.T) {
+	// 18.- Un GET sobre login debe seguir devolviendo 405.
+	srv := buildServer(t)
+	performRequest(t, srv, http.MethodGet, "/api/v1/auth/login", nil, http.StatusMethodNotAllowed, nil)
+}
+
+func TestProtectedEndpointsRequireToken(t *testing.T) {
+	// 19.- Las rutas protegidas deben rechazar la ausencia de token.
+	srv := buildServer(t)
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports", nil, http.StatusUnauthorized, nil)
+
+	// 20.- Un token inválido también regresa 401.
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports", nil, http.StatusUnauthorized, nil, withAuth("malformed"))
+}
+
+func TestReportSubmissionValidationRejectsMalformedPayload(t *testing.T) {
+	// 21.- El validador debe prevenir que cargas incompletas lleguen al servicio.
+	srv := buildServer(t)
+	credentials := map[string]string{
+		"email":    "validator@example.com",
+		"password": "ClaveSegura1",
+	}
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/register", credentials, http.StatusCreated, nil)
+	var token service.AuthResponse
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/login", credentials, http.StatusOK, &token)
+	invalidReport := map[string]any{
+		"incidentTypeId": "",
+		"description":    "",
+		"contactEmail":   "no-es-correo",
+		"contactPhone":   "123",
+		"latitude":       120.0,
+		"longitude":      200.0,
+		"address":        "",
+	}
+	performJSON(t, srv, http.MethodPost, "/api/v1/reports", invalidReport, http.StatusBadRequest, nil, withAuth(token.Token))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	reports, err := srv.reportService.List(ctx, service.ReportFilter{PageSize: 10})
+	if err != nil {
+		t.Fatalf("unexpected list error: %v", err)
+	}
+	if len(reports.Items) != 0 {
+		t.Fatalf("expected zero reports after invalid submission, got %d", len(reports.Items))
+	}
+}
+
+func TestMetricsEndpointReportsObservability(t *testing.T) {
+	// 22.- El endpoint /metrics debe exponer las métricas clave instrumentadas.
+	srv := buildServer(t)
+	creds := map[string]string{
+		"email":    "metrics@example.com",
+		"password": "ClaveSegura1",
+	}
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/register", creds, http.StatusCreated, nil)
+	var login service.AuthResponse
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/login", creds, http.StatusOK, &login)
+	submission := map[string]any{
+		"incidentTypeId": "flood",
+		"description":    "Avenida inundada",
+		"contactEmail":   creds["email"],
+		"contactPhone":   "5587654321",
+		"latitude":       19.43,
+		"longitude":      -99.13,
+		"address":        "Centro",
+	}
+	performJSON(t, srv, http.MethodPost, "/api/v1/reports", submission, http.StatusCreated, nil, withAuth(login.Token))
+	time.Sleep(150 * time.Millisecond)
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rr := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 from metrics endpoint, got %d", rr.Code)
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, "citizenapp_report_submit_queue_depth") {
+		t.Fatalf("expected submit queue metric in body, got %s", body)
+	}
+	if !strings.Contains(body, "citizenapp_http_request_duration_seconds_bucket") {
+		t.Fatalf("expected http duration histogram in body, got %s", body)
+	}
+	if !strings.Contains(body, "citizenapp_realtime_broadcast_latency_seconds_bucket") {
+		t.Fatalf("expected broadcast latency histogram in body, got %s", body)
+	}
+}
+
+func TestFolioLookupRejectsMistypedFolio(t *testing.T) {
+	// 1.- Un folio con dígito verificador incorrecto no debe llegar al repositorio.
+	srv := buildServer(t)
+	performRequest(t, srv, http.MethodGet, "/api/v1/folios/F-2026-0000001-0", nil, http.StatusBadRequest, nil)
+	performRequest(t, srv, http.MethodGet, "/api/v1/folios/no-es-folio", nil, http.StatusBadRequest, nil)
+}
+
+func TestReportContactVisibleOnlyToStaff(t *testing.T) {
+	// 1.- Registramos a un ciudadano y a un integrante del personal autorizado.
+	srv := buildServer(t)
+	citizen := map[string]string{"email": "vecino@example.com", "password": "ClaveSegura1"}
+	citizenToken := loginWithRole(t, srv, citizen["email"], service.RoleCitizen)
+	staffToken := loginWithRole(t, srv, "operador@example.com", service.RoleOperator)
+
+	// 2.- El ciudadano envía un reporte con contacto, dirección y evidencia.
+	submission := map[string]any{
+		"incidentTypeId": "pothole",
+		"description":    "Bache frente a la escuela",
+		"contactEmail":   citizen["email"],
+		"contactPhone":   "5512345678",
+		"latitude":       19.4,
+		"longitude":      -99.1,
+		"address":        "Calle 5 de Mayo 20",
+		"evidenceUrls":   []string{"https://cdn.example.com/bache.jpg"},
+	}
+	var created service.Report
+	performJSON(t, srv, http.MethodPost, "/api/v1/reports", submission, http.StatusCreated, &created, withAuth(citizenToken.Token))
+
+	// 3.- Sólo el personal recibe los datos de contacto en el detalle.
+	var public, internal service.Report
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports/"+created.ID, nil, http.StatusOK, &public, withAuth(citizenToken.Token))
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports/"+created.ID, nil, http.StatusOK, &internal, withAuth(staffToken.Token))
+	if public.ContactEmail != "" || public.ContactPhone != "" {
+		t.Fatalf("expected contact data to be hidden, got %+v", public)
+	}
+	if public.Address != "Calle 5 de Mayo 20" || len(public.EvidenceURLs) != 1 {
+		t.Fatalf("expected address and evidence in public view, got %+v", public)
+	}
+	if internal.ContactEmail != citizen["email"] || internal.ContactPhone != "5512345678" {
+		t.Fatalf("expected contact data for staff, got %+v", internal)
+	}
+}
+
+func TestStaffEndpointsRejectCitizens(t *testing.T) {
+	// 1.- Un ciudadano autenticado puede reportar pero no administrar reportes.
+	srv := buildServer(t)
+	citizen := loginWithRole(t, srv, "vecino@example.com", service.RoleCitizen)
+	submission := map[string]any{
+		"incidentTypeId": "trash",
+		"description":    "Basura en la esquina",
+		"contactEmail":   "vecino@example.com",
+		"contactPhone":   "5512345678",
+		"latitude":       19.4,
+		"longitude":      -99.1,
+		"address":        "Esquina Norte",
+	}
+	var created service.Report
+	performJSON(t, srv, http.MethodPost, "/api/v1/reports", submission, http.StatusCreated, &created, withAuth(citizen.Token))
+
+	// 2.- Las rutas de personal responden 403 para el rol ciudadano.
+	performJSON(t, srv, http.MethodPatch, "/api/v1/reports/"+created.ID, map[string]string{"status": "en_proceso"}, http.StatusForbidden, nil, withAuth(citizen.Token))
+	performRequest(t, srv, http.MethodDelete, "/api/v1/reports/"+created.ID, nil, http.StatusForbidden, nil, withAuth(citizen.Token))
+	performRequest(t, srv, http.MethodGet, "/api/v1/admin/dashboard/metrics", nil, http.StatusForbidden, nil, withAuth(citizen.Token))
+
+	// 3.- Un operador puede cambiar el estatus pero no eliminar.
+	operator := loginWithRole(t, srv, "operador@example.com", service.RoleOperator)
+	performJSON(t, srv, http.MethodPatch, "/api/v1/reports/"+created.ID, map[string]string{"status": "en_proceso"}, http.StatusOK, nil, withAuth(operator.Token))
+	performRequest(t, srv, http.MethodDelete, "/api/v1/reports/"+created.ID, nil, http.StatusForbidden, nil, withAuth(operator.Token))
+}
+
+func TestRefreshEndpointRotatesAndDetectsReuse(t *testing.T) {
+	// 1.- El login entrega un token de renovación junto con el de acceso.
+	srv := buildServer(t)
+	session := loginWithRole(t, srv, "vecino@example.com", service.RoleCitizen)
+	if session.RefreshToken == "" || session.RefreshExpiresAt == nil {
+		t.Fatalf("expected refresh token in login response, got %+v", session)
+	}
+
+	// 2.- La renovación rota el token y el nuevo acceso funciona en rutas protegidas.
+	var rotated service.AuthResponse
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/refresh", map[string]string{"refreshToken": session.RefreshToken}, http.StatusOK, &rotated)
+	if rotated.RefreshToken == "" || rotated.RefreshToken == session.RefreshToken {
+		t.Fatalf("expected rotated refresh token, got %+v", rotated)
+	}
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports", nil, http.StatusOK, nil, withAuth(rotated.Token))
+
+	// 3.- Reutilizar el token previo responde 401 e invalida también el más reciente.
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/refresh", map[string]string{"refreshToken": session.RefreshToken}, http.StatusUnauthorized, nil)
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/refresh", map[string]string{"refreshToken": rotated.RefreshToken}, http.StatusUnauthorized, nil)
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/refresh", map[string]string{}, http.StatusBadRequest, nil)
+}
+
+func TestLogoutAndAdminRevocation(t *testing.T) {
+	// 1.- El logout invalida el token de acceso y el de renovación enviados.
+	srv := buildServer(t)
+	citizen := loginWithRole(t, srv, "vecino@example.com", service.RoleCitizen)
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/logout", map[string]string{"refreshToken": citizen.RefreshToken}, http.StatusNoContent, nil, withAuth(citizen.Token))
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports", nil, http.StatusUnauthorized, nil, withAuth(citizen.Token))
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/refresh", map[string]string{"refreshToken": citizen.RefreshToken}, http.StatusUnauthorized, nil)
+
+	// 2.- Sólo un administrador puede cerrar todas las sesiones de otra cuenta.
+	operator := loginWithRole(t, srv, "operador@example.com", service.RoleOperator)
+	performRequest(t, srv, http.MethodDelete, "/api/v1/admin/users/operador@example.com/sessions", nil, http.StatusForbidden, nil, withAuth(operator.Token))
+	admin := loginWithRole(t, srv, "admin@example.com", service.RoleAdmin)
+	performRequest(t, srv, http.MethodDelete, "/api/v1/admin/users/nadie@example.com/sessions", nil, http.StatusNotFound, nil, withAuth(admin.Token))
+	performRequest(t, srv, http.MethodDelete, "/api/v1/admin/users/operador@example.com/sessions", nil, http.StatusNoContent, nil, withAuth(admin.Token))
+
+	// 3.- Las sesiones del operador quedan revocadas y la del administrador sigue activa.
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports", nil, http.StatusUnauthorized, nil, withAuth(operator.Token))
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/refresh", map[string]string{"refreshToken": operator.RefreshToken}, http.StatusUnauthorized, nil)
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports", nil, http.StatusOK, nil, withAuth(admin.Token))
+}
+
+func TestPasswordResetDoesNotEnumerateAccounts(t *testing.T) {
+	// 1.- Cuentas existentes e inexistentes reciben el mismo 202.
+	mailer := mail.NewMemoryMailer()
+	srv := buildServerWithAuth(t, []service.AuthOption{
+		service.WithOneTimeTokens(newInMemoryOneTimeTokenStore()),
+		service.WithMailer(mailer),
+		service.WithPasswordReset(time.Hour, "https://app.example.com/reset"),
+	})
+	session := loginWithRole(t, srv, "vecino@example.com", service.RoleCitizen)
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/recover", map[string]string{"email": "nadie@example.com"}, http.StatusAccepted, nil)
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/recover", map[string]string{"email": "vecino@example.com"}, http.StatusAccepted, nil)
+
+	// 2.- Sólo la cuenta real recibe el correo con el enlace.
+	var message service.MailMessage
+	select {
+	case message = <-mailer.Messages():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timeout waiting for reset mail")
+	}
+	if message.To != "vecino@example.com" || len(mailer.Sent()) != 1 {
+		t.Fatalf("unexpected outbox: %+v", mailer.Sent())
+	}
+	_, link, _ := strings.Cut(message.Body, "?token=")
+	token := strings.Fields(link)[0]
+
+	// 3.- El token fija la nueva contraseña una sola vez y cierra la sesión anterior.
+	reset := map[string]string{"token": token, "password": "ClaveNueva99"}
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/reset", reset, http.StatusNoContent, nil)
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/reset", reset, http.StatusBadRequest, nil)
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports", nil, http.StatusUnauthorized, nil, withAuth(session.Token))
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/login", map[string]string{"email": "vecino@example.com", "password": "ClaveNueva99"}, http.StatusOK, nil)
+}
+
+// loginWithRole registra la cuenta, le asigna el rol y devuelve un token vigente.
+func TestJWKSEndpointPublishesSigningKeys(t *testing.T) {
+	// 1.- Configuramos el servidor con una llave Ed25519 en lugar del secreto HS256.
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	key, err := service.NewSigningKey(private)
+	if err != nil {
+		t.Fatalf("NewSigningKey returned error: %v", err)
+	}
+	ring, err := service.NewKeyRing(key)
+	if err != nil {
+		t.Fatalf("NewKeyRing returned error: %v", err)
+	}
+	srv := buildServerWithAuth(t, []service.AuthOption{service.WithSigningKeys(ring)})
+
+	// 2.- El JWKS es público y lista la llave activa con su kid.
+	var jwks service.JSONWebKeySet
+	performRequest(t, srv, http.MethodGet, "/.well-known/jwks.json", nil, http.StatusOK, &jwks)
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != key.ID || jwks.Keys[0].Alg != "EdDSA" {
+		t.Fatalf("unexpected jwks: %+v", jwks)
+	}
+	performRequest(t, srv, http.MethodPost, "/.well-known/jwks.json", nil, http.StatusMethodNotAllowed, nil)
+
+	// 3.- Los tokens firmados con la llave publicada se aceptan en rutas protegidas.
+	citizen := loginWithRole(t, srv, "vecino@example.com", service.RoleCitizen)
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports", nil, http.StatusOK, nil, withAuth(citizen.Token))
+}
+
+func TestLoginLockoutReturnsRetryAfter(t *testing.T) {
+	// 1.- Tras dos fallos la cuenta queda bloqueada y el login responde 429 con Retry-After.
+	srv := buildServerWithAuth(t, []service.AuthOption{
+		service.WithLoginLockout(newInMemoryLoginAttemptStore(), service.LockoutPolicy{AccountThreshold: 2, IPThreshold: 50}),
+	})
+	creds := map[string]string{"email": "vecino@example.com", "password": "ClaveSegura1"}
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/register", creds, http.StatusCreated, nil)
+	wrong := map[string]string{"email": creds["email"], "password": "ClaveErronea1"}
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/login", wrong, http.StatusUnauthorized, nil)
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/login", wrong, http.StatusUnauthorized, nil)
+	payload, _ := json.Marshal(creds)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "60" {
+		t.Fatalf("expected 429 with Retry-After 60, got %d %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+
+	// 2.- Un administrador desbloquea la cuenta y el siguiente intento correcto funciona.
+	admin := loginWithRole(t, srv, "admin@example.com", service.RoleAdmin)
+	performRequest(t, srv, http.MethodDelete, "/api/v1/admin/users/nadie@example.com/lockout", nil, http.StatusNotFound, nil, withAuth(admin.Token))
+	performRequest(t, srv, http.MethodDelete, "/api/v1/admin/users/vecino@example.com/lockout", nil, http.StatusNoContent, nil, withAuth(admin.Token))
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/login", creds, http.StatusOK, nil)
+
+	// 3.- Los rechazos quedan registrados en Prometheus por motivo.
+	metrics := httptest.NewRecorder()
+	srv.Router().ServeHTTP(metrics, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, series := range []string{`citizenapp_auth_login_failures_total{reason="locked"}`, `citizenapp_auth_login_lockouts_total{scope="account"}`} {
+		if !strings.Contains(metrics.Body.String(), series) {
+			t.Fatalf("expected %s in metrics output", series)
+		}
+	}
+}
+
+func TestEmailVerificationGatesUnverifiedAccounts(t *testing.T) {
+	// 1.- Las cuentas nuevas sólo pueden enviar reportes hasta confirmar el correo.
+	mailer := mail.NewMemoryMailer()
+	srv := buildServerWithAuth(t, []service.AuthOption{
+		service.WithOneTimeTokens(newInMemoryOneTimeTokenStore()),
+		service.WithMailer(mailer),
+		service.WithEmailVerification(time.Hour, "https://app.example.com/verify"),
+		service.WithUnverifiedPermissions(service.PermReportsSubmit),
+	})
+	creds := map[string]string{"email": "vecino@example.com", "password": "ClaveSegura1"}
+	var session service.AuthResponse
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/register", creds, http.StatusCreated, &session)
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports", nil, http.StatusForbidden, nil, withAuth(session.Token))
+
+	// 2.- El reenvío invalida el primer enlace y uno inmediato responde 429 con Retry-After.
+	waitToken := func() string {
+		t.Helper()
+		select {
+		case message := <-mailer.Messages():
+			_, link, _ := strings.Cut(message.Body, "?token=")
+			return strings.Fields(link)[0]
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for verification mail")
+		}
+		return ""
+	}
+	first := waitToken()
+	performRequest(t, srv, http.MethodPost, "/api/v1/auth/verify/resend", nil, http.StatusAccepted, nil, withAuth(session.Token))
+	second := waitToken()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/verify/resend", nil)
+	withAuth(session.Token)(req)
+	rr := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "60" {
+		t.Fatalf("expected 429 with Retry-After 60, got %d %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/verify", map[string]string{"token": first}, http.StatusBadRequest, nil)
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/verify", map[string]string{"token": second}, http.StatusNoContent, nil)
+
+	// 3.- Tras renovar el token la cuenta verificada recupera todos sus permisos.
+	var refreshed service.AuthResponse
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/refresh", map[string]string{"refreshToken": session.RefreshToken}, http.StatusOK, &refreshed)
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports", nil, http.StatusOK, nil, withAuth(refreshed.Token))
+}
+
+func TestMFALoginRequiresSecondFactor(t *testing.T) {
+	// 1.- Un administrador sin MFA recibe 202 con un reto de inscripción.
+	srv := buildServerWithAuth(t, []service.AuthOption{
+		service.WithMFA(newInMemoryMFARepository(), "Municipio", service.RoleAdmin),
+	})
+	creds := map[string]string{"email": "admin@example.com", "password": "ClaveSegura1"}
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/register", creds, http.StatusCreated, nil)
+	if err := srv.authService.AssignRole(context.Background(), creds["email"], service.RoleAdmin); err != nil {
+		t.Fatalf("AssignRole returned error: %v", err)
+	}
+	var challenge service.MFAChallenge
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/login", creds, http.StatusAccepted, &challenge)
+	if !challenge.EnrollmentRequired || challenge.Token == "" {
+		t.Fatalf("unexpected challenge: %+v", challenge)
+	}
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports", nil, http.StatusUnauthorized, nil, withAuth(challenge.Token))
+
+	// 2.- Con el reto obtiene el secreto y el primer código le entrega tokens y códigos de recuperación.
+	var setup service.MFASetup
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/mfa/enroll", map[string]string{"mfaToken": challenge.Token}, http.StatusOK, &setup)
+	if !strings.HasPrefix(setup.URI, "otpauth://totp/") {
+		t.Fatalf("unexpected setup: %+v", setup)
+	}
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/mfa/verify", map[string]string{"mfaToken": challenge.Token, "code": "000000"}, http.StatusUnauthorized, nil)
+	var session service.AuthResponse
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/mfa/verify", map[string]string{"mfaToken": challenge.Token, "code": currentTOTP(t, setup.Secret)}, http.StatusOK, &session)
+	if len(session.RecoveryCodes) == 0 || session.RefreshToken == "" {
+		t.Fatalf("expected tokens and recovery codes, got %+v", session)
+	}
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports", nil, http.StatusOK, nil, withAuth(session.Token))
+	performRequest(t, srv, http.MethodPost, "/api/v1/me/mfa/enroll", nil, http.StatusConflict, nil, withAuth(session.Token))
+
+	// 3.- Los ciudadanos pueden inscribirse desde /me/mfa y a partir de ahí reciben el reto.
+	citizen := loginWithRole(t, srv, "vecino@example.com", service.RoleCitizen)
+	performRequest(t, srv, http.MethodPost, "/api/v1/me/mfa/enroll", nil, http.StatusOK, &setup, withAuth(citizen.Token))
+	performJSON(t, srv, http.MethodPost, "/api/v1/me/mfa/confirm", map[string]string{"code": "12345"}, http.StatusBadRequest, nil, withAuth(citizen.Token))
+	performJSON(t, srv, http.MethodPost, "/api/v1/me/mfa/confirm", map[string]string{"code": currentTOTP(t, setup.Secret)}, http.StatusOK, nil, withAuth(citizen.Token))
+	var second service.MFAChallenge
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/login", map[string]string{"email": "vecino@example.com", "password": "ClaveSegura1"}, http.StatusAccepted, &second)
+	if second.EnrollmentRequired {
+		t.Fatalf("expected verification challenge, got %+v", second)
+	}
+}
+
+func TestRegisterReturnsPasswordPolicyViolations(t *testing.T) {
+	// 1.- La política rechaza la contraseña y details enumera cada regla incumplida.
+	srv := buildServerWithAuth(t, []service.AuthOption{
+		service.WithPasswordPolicy(service.PasswordPolicy{MinLength: 12, Denylist: []string{"municipio"}}),
+	})
+	var failure struct {
+		Code    int                         `json:"code"`
+		Message string                      `json:"message"`
+		Details []service.PasswordViolation `json:"details"`
+	}
+	creds := map[string]string{"email": "vecino@example.com", "password": "Municip10"}
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/register", creds, http.StatusBadRequest, &failure)
+	if len(failure.Details) != 2 || failure.Details[0].Rule != service.PasswordRuleMinLength || failure.Details[1].Rule != service.PasswordRuleDenylist {
+		t.Fatalf("unexpected violations: %+v", failure)
+	}
+
+	// 2.- Una contraseña que cumple la política registra la cuenta.
+	creds["password"] = "caballo-bateria-grapa"
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/register", creds, http.StatusCreated, nil)
+}
+
+func TestAccountEndpointsManageProfileAndDeletion(t *testing.T) {
+	// 1.- El ciudadano consulta y edita su perfil.
+	srv := buildServer(t)
+	citizen := loginWithRole(t, srv, "vecina@example.com", service.RoleCitizen)
+	staff := loginWithRole(t, srv, "operador@example.com", service.RoleOperator)
+	var profile service.Profile
+	performRequest(t, srv, http.MethodGet, "/api/v1/me", nil, http.StatusOK, &profile, withAuth(citizen.Token))
+	if profile.Email != "vecina@example.com" || !profile.Notifications.Email {
+		t.Fatalf("unexpected profile: %+v", profile)
+	}
+	update := map[string]any{"displayName": "Ana", "notifications": map[string]bool{"email": false, "push": true, "sms": false}}
+	performJSON(t, srv, http.MethodPatch, "/api/v1/me", update, http.StatusOK, &profile, withAuth(citizen.Token))
+	if profile.DisplayName != "Ana" || profile.Notifications.Email {
+		t.Fatalf("unexpected profile after update: %+v", profile)
+	}
+	performJSON(t, srv, http.MethodPatch, "/api/v1/me", map[string]any{"phone": "12"}, http.StatusBadRequest, nil, withAuth(citizen.Token))
+	performJSON(t, srv, http.MethodPatch, "/api/v1/me", map[string]any{"notifications": map[string]bool{"email": true}}, http.StatusBadRequest, nil, withAuth(citizen.Token))
+
+	// 2.- Cambiar la contraseña exige la actual.
+	performJSON(t, srv, http.MethodPost, "/api/v1/me/password", map[string]string{"currentPassword": "Incorrecta1", "newPassword": "ClaveNueva1"}, http.StatusBadRequest, nil, withAuth(citizen.Token))
+
+	// 3.- Al borrar la cuenta, el reporte queda sin contacto y la bitácora sin su correo.
+	submission := map[string]any{
+		"incidentTypeId": "pothole",
+		"description":    "Bache en la avenida",
+		"contactEmail":   "vecina@example.com",
+		"contactPhone":   "5512345678",
+		"latitude":       19.4,
+		"longitude":      -99.1,
+		"address":        "Avenida Juárez 10",
+	}
+	var created service.Report
+	performJSON(t, srv, http.MethodPost, "/api/v1/reports", submission, http.StatusCreated, &created, withAuth(citizen.Token))
+	performRequest(t, srv, http.MethodDelete, "/api/v1/me", nil, http.StatusBadRequest, nil, withAuth(citizen.Token))
+	performJSON(t, srv, http.MethodDelete, "/api/v1/me", map[string]string{"currentPassword": "Incorrecta1"}, http.StatusBadRequest, nil, withAuth(citizen.Token))
+	var untouched service.Report
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports/"+created.ID, nil, http.StatusOK, &untouched, withAuth(staff.Token))
+	if untouched.ContactEmail != "vecina@example.com" {
+		t.Fatalf("expected report untouched after a rejected deletion, got %+v", untouched)
+	}
+	performJSON(t, srv, http.MethodDelete, "/api/v1/me", map[string]string{"currentPassword": "ClaveSegura1"}, http.StatusNoContent, nil, withAuth(citizen.Token))
+	var report service.Report
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports/"+created.ID, nil, http.StatusOK, &report, withAuth(staff.Token))
+	if report.ContactEmail != "" || report.ContactPhone != "" || report.Description != "Bache en la avenida" {
+		t.Fatalf("expected anonymized report, got %+v", report)
+	}
+	var events []service.ReportEvent
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports/"+created.ID+"/events", nil, http.StatusOK, &events, withAuth(staff.Token))
+	if len(events) == 0 || events[0].Actor != service.DeletedActor {
+		t.Fatalf("expected anonymized actor, got %+v", events)
+	}
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/login", map[string]string{"email": "vecina@example.com", "password": "ClaveSegura1"}, http.StatusUnauthorized, nil)
+}
+
+func TestSessionEndpointsListAndRevokeDevices(t *testing.T) {
+	// 1.- Un segundo inicio de sesión declara el dispositivo con cabeceras.
+	srv := buildServer(t)
+	first := loginWithRole(t, srv, "vecina@example.com", service.RoleCitizen)
+	creds := map[string]string{"email": "vecina@example.com", "password": "ClaveSegura1"}
+	var tablet service.AuthResponse
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/login", creds, http.StatusOK, &tablet, func(r *http.Request) {
+		r.Header.Set("X-Device-Name", "Galaxy Tab")
+		r.Header.Set("X-Device-Platform", "Android")
+	})
+
+	// 2.- La lista incluye el registro, el primer login y la tableta marcada como actual.
+	var sessions []service.Session
+	performRequest(t, srv, http.MethodGet, "/api/v1/me/sessions", nil, http.StatusOK, &sessions, withAuth(tablet.Token))
+	// Copiamos la sesión: el slice se vuelve a decodificar más adelante.
+	var current *service.Session
+	for _, session := range sessions {
+		if session.Current {
+			current = &session
+		}
+	}
+	if len(sessions) != 3 || current == nil || current.DeviceName != "Galaxy Tab" || current.Platform != "android" {
+		t.Fatalf("unexpected sessions %+v", sessions)
+	}
+
+	// 3.- El registro push valida el proveedor y se asocia a la sesión actual.
+	performJSON(t, srv, http.MethodPut, "/api/v1/me/push", map[string]string{"provider": "sms", "token": "abc"}, http.StatusBadRequest, nil, withAuth(tablet.Token))
+	performJSON(t, srv, http.MethodPut, "/api/v1/me/push", map[string]string{"provider": "fcm", "token": "abc"}, http.StatusNoContent, nil, withAuth(tablet.Token))
+	if registrations, _ := srv.authService.PushRegistrations(context.Background(), "vecina@example.com"); len(registrations) != 1 || registrations[0].SessionID != current.ID {
+		t.Fatalf("unexpected push registrations %+v", registrations)
+	}
+
+	// 4.- Cerrar otra sesión invalida su token; las sesiones ajenas responden 404.
+	principal, err := srv.authService.ValidateToken(context.Background(), first.Token)
+	if err != nil {
+		t.Fatalf("ValidateToken returned error: %v", err)
+	}
+	target := principal.SessionID
+	tabletPrincipal, err := srv.authService.ValidateToken(context.Background(), tablet.Token)
+	if err != nil {
+		t.Fatalf("ValidateToken returned error: %v", err)
+	}
+	firstSocket := srv.realtimeHub.RegisterUser(&captureConn{}, principal)
+	tabletSocket := srv.realtimeHub.RegisterUser(&captureConn{}, tabletPrincipal)
+	stranger := loginWithRole(t, srv, "otra@example.com", service.RoleCitizen)
+	performRequest(t, srv, http.MethodDelete, "/api/v1/me/sessions/"+target, nil, http.StatusNotFound, nil, withAuth(stranger.Token))
+	performRequest(t, srv, http.MethodDelete, "/api/v1/me/sessions/"+target, nil, http.StatusNoContent, nil, withAuth(tablet.Token))
+	performRequest(t, srv, http.MethodDelete, "/api/v1/me/sessions/"+target, nil, http.StatusNotFound, nil, withAuth(tablet.Token))
+	performRequest(t, srv, http.MethodGet, "/api/v1/me", nil, http.StatusUnauthorized, nil, withAuth(first.Token))
+	waitForClose(t, firstSocket)
+	select {
+	case _, open := <-tabletSocket.Messages():
+		if !open {
+			t.Fatalf("expected the tablet socket to stay open")
+		}
+	default:
+	}
+	performRequest(t, srv, http.MethodGet, "/api/v1/me/sessions", nil, http.StatusOK, &sessions, withAuth(tablet.Token))
+	if len(sessions) != 2 {
+		t.Fatalf("expected two sessions after revocation, got %+v", sessions)
+	}
 
-func (r *inMemoryReportRepository) List(_ context.Context, page, pageSize int, status string) ([]service.Report, int, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	items := make([]service.Report, 0, len(r.records))
-	for _, report := range r.records {
-		if status != "" && report.Status != status {
-			continue
-		}
-		items = append(items, report)
+	// 5.- Cerrar la sesión actual retira el registro push y revoca el propio token.
+	performRequest(t, srv, http.MethodDelete, "/api/v1/me/sessions/"+current.ID, nil, http.StatusNoContent, nil, withAuth(tablet.Token))
+	performRequest(t, srv, http.MethodGet, "/api/v1/me/sessions", nil, http.StatusUnauthorized, nil, withAuth(tablet.Token))
+	waitForClose(t, tabletSocket)
+	if registrations, _ := srv.authService.PushRegistrations(context.Background(), "vecina@example.com"); len(registrations) != 0 {
+		t.Fatalf("expected push registration to be removed, got %+v", registrations)
 	}
-	sort.Slice(items, func(i, j int) bool {
-		return items[i].CreatedAt.After(items[j].CreatedAt)
+}
+
+func TestAdminUserManagementEndpoints(t *testing.T) {
+	// 1.- El administrador invita a un operador y lo encuentra como pendiente en el listado.
+	mailer := mail.NewMemoryMailer()
+	srv := buildServerWithAuth(t, []service.AuthOption{
+		service.WithOneTimeTokens(newInMemoryOneTimeTokenStore()),
+		service.WithMailer(mailer),
+		service.WithInvitations(time.Hour, "https://panel.example.com/invite"),
 	})
-	total := len(items)
-	start := page * pageSize
-	if start > total {
-		start = total
+	admin := loginWithRole(t, srv, "admin@example.com", service.RoleAdmin)
+	citizen := loginWithRole(t, srv, "vecino@example.com", service.RoleCitizen)
+	performJSON(t, srv, http.MethodPost, "/api/v1/admin/users", map[string]string{"email": "operador@example.com", "role": "operator"}, http.StatusForbidden, nil, withAuth(citizen.Token))
+	performJSON(t, srv, http.MethodPost, "/api/v1/admin/users", map[string]string{"email": "operador@example.com", "role": "root"}, http.StatusBadRequest, nil, withAuth(admin.Token))
+	var invited service.UserSummary
+	performJSON(t, srv, http.MethodPost, "/api/v1/admin/users", map[string]string{"email": "operador@example.com", "role": "operator"}, http.StatusCreated, &invited, withAuth(admin.Token))
+	performJSON(t, srv, http.MethodPost, "/api/v1/admin/users", map[string]string{"email": "vecino@example.com", "role": "operator"}, http.StatusConflict, nil, withAuth(admin.Token))
+	var page service.PaginatedUsers
+	performRequest(t, srv, http.MethodGet, "/api/v1/admin/users?status=invited", nil, http.StatusOK, &page, withAuth(admin.Token))
+	if page.TotalCount != 1 || page.Items[0].Email != "operador@example.com" || invited.Status != service.UserStatusInvited {
+		t.Fatalf("unexpected invited listing %+v", page)
 	}
-	end := start + pageSize
-	if end > total {
-		end = total
+	performRequest(t, srv, http.MethodGet, "/api/v1/admin/users?status=unknown", nil, http.StatusBadRequest, nil, withAuth(admin.Token))
+
+	// 2.- El enlace del correo fija la contraseña y devuelve tokens con el rol del operador.
+	var message service.MailMessage
+	select {
+	case message = <-mailer.Messages():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timeout waiting for invitation mail")
 	}
-	return items[start:end], total, nil
+	_, link, _ := strings.Cut(message.Body, "?token=")
+	accept := map[string]string{"token": strings.Fields(link)[0], "password": "ClaveOperador1"}
+	var operator service.AuthResponse
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/invitations/accept", accept, http.StatusOK, &operator)
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/invitations/accept", accept, http.StatusBadRequest, nil)
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports/missing/events", nil, http.StatusNotFound, nil, withAuth(operator.Token))
+
+	// 3.- El rol se cambia para otras cuentas, nunca para la propia.
+	performJSON(t, srv, http.MethodPut, "/api/v1/admin/users/vecino@example.com/role", map[string]string{"role": "supervisor"}, http.StatusNoContent, nil, withAuth(admin.Token))
+	performJSON(t, srv, http.MethodPut, "/api/v1/admin/users/admin@example.com/role", map[string]string{"role": "citizen"}, http.StatusConflict, nil, withAuth(admin.Token))
+	performJSON(t, srv, http.MethodPut, "/api/v1/admin/users/nadie@example.com/role", map[string]string{"role": "citizen"}, http.StatusNotFound, nil, withAuth(admin.Token))
+
+	// 4.- Suspender rechaza el token vigente y el login; reactivar lo permite de nuevo.
+	creds := map[string]string{"email": "operador@example.com", "password": "ClaveOperador1"}
+	performRequest(t, srv, http.MethodPut, "/api/v1/admin/users/operador@example.com/disabled", nil, http.StatusNoContent, nil, withAuth(admin.Token))
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports", nil, http.StatusUnauthorized, nil, withAuth(operator.Token))
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/login", creds, http.StatusForbidden, nil)
+	performRequest(t, srv, http.MethodDelete, "/api/v1/admin/users/operador@example.com/disabled", nil, http.StatusNoContent, nil, withAuth(admin.Token))
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/login", creds, http.StatusOK, nil)
 }
 
-func (r *inMemoryReportRepository) Delete(_ context.Context, id string) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	if _, ok := r.records[id]; !ok {
-		return service.ErrReportNotFound
+func TestAPIKeyEndpointsAuthenticateIntegrations(t *testing.T) {
+	// 1.- Sólo el administrador crea claves; el valor se devuelve una vez.
+	srv := buildServerWithAuth(t, []service.AuthOption{service.WithAPIKeys(newInMemoryAPIKeyStore())})
+	admin := loginWithRole(t, srv, "admin@example.com", service.RoleAdmin)
+	supervisor := loginWithRole(t, srv, "supervisor@example.com", service.RoleSupervisor)
+	request := map[string]any{"name": "Obras públicas", "scopes": []string{"reports:read", "reports:write"}, "rateLimit": 2}
+	performJSON(t, srv, http.MethodPost, "/api/v1/admin/api-keys", request, http.StatusForbidden, nil, withAuth(supervisor.Token))
+	performJSON(t, srv, http.MethodPost, "/api/v1/admin/api-keys", map[string]any{"name": "x", "scopes": []string{"users:manage"}}, http.StatusBadRequest, nil, withAuth(admin.Token))
+	var created service.CreatedAPIKey
+	performJSON(t, srv, http.MethodPost, "/api/v1/admin/api-keys", request, http.StatusCreated, &created, withAuth(admin.Token))
+	var readOnly service.CreatedAPIKey
+	performJSON(t, srv, http.MethodPost, "/api/v1/admin/api-keys", map[string]any{"name": "Datos abiertos", "scopes": []string{"reports:read"}}, http.StatusCreated, &readOnly, withAuth(admin.Token))
+	var keys []service.APIKey
+	performRequest(t, srv, http.MethodGet, "/api/v1/admin/api-keys", nil, http.StatusOK, &keys, withAuth(admin.Token))
+	if len(keys) != 2 || created.Key == "" || readOnly.RateLimit != 60 {
+		t.Fatalf("unexpected keys %+v", keys)
 	}
-	delete(r.records, id)
-	return nil
-}
 
-func (r *inMemoryReportRepository) Lookup(ctx context.Context, id string) (service.FolioStatus, error) {
-	report, err := r.FindByID(ctx, id)
-	if err != nil {
-		return service.FolioStatus{}, err
+	// 2.- La clave de escritura registra reportes con su identidad y sin acceso a rutas personales.
+	submission := map[string]any{
+		"incidentTypeId": "lighting",
+		"description":    "Luminaria apagada reportada por cuadrilla",
+		"contactEmail":   "obras@example.com",
+		"contactPhone":   "5512345678",
+		"latitude":       19.4,
+		"longitude":      -99.1,
+		"address":        "Calle 5 de Mayo 20",
 	}
-	return service.FolioStatus{
-		Folio:      report.ID,
-		Status:     report.Status,
-		LastUpdate: time.Now(),
-		History:    []string{"Reporte recibido", "Asignado a cuadrilla"},
-	}, nil
-}
+	var report service.Report
+	performJSON(t, srv, http.MethodPost, "/api/v1/reports", submission, http.StatusCreated, &report, withAPIKey(created.Key))
+	performRequest(t, srv, http.MethodGet, "/api/v1/me", nil, http.StatusUnauthorized, nil, withAPIKey(created.Key))
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports/"+report.ID+"/events", nil, http.StatusForbidden, nil, withAPIKey(created.Key))
 
-func (r *inMemoryReportRepository) UpdateStatusWithMetrics(ctx context.Context, id, status string) (service.Report, service.AdminDashboardMetrics, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	report, ok := r.records[id]
-	if !ok {
-		return service.Report{}, service.AdminDashboardMetrics{}, service.ErrReportNotFound
+	// 3.- La clave de lectura consulta sin datos de contacto y no puede escribir.
+	var fetched service.Report
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports/"+report.ID, nil, http.StatusOK, &fetched, withAPIKey(readOnly.Key))
+	if fetched.ContactEmail != "" {
+		t.Fatalf("expected contact data to be hidden from api keys, got %+v", fetched)
 	}
-	report.Status = status
-	r.records[id] = report
-	metrics := r.metricsLocked()
-	return report, metrics, nil
-}
+	performJSON(t, srv, http.MethodPost, "/api/v1/reports", submission, http.StatusForbidden, nil, withAPIKey(readOnly.Key))
 
-func (r *inMemoryReportRepository) Metrics(_ context.Context) (service.AdminDashboardMetrics, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	return r.metricsLocked(), nil
+	// 4.- Al agotar la cuota se responde 429 con Retry-After; una clave revocada responde 401.
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/reports", nil)
+	withAPIKey(created.Key)(req)
+	srv.Engine().ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected rate limited response, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	performRequest(t, srv, http.MethodDelete, "/api/v1/admin/api-keys/"+readOnly.ID, nil, http.StatusNoContent, nil, withAuth(admin.Token))
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports", nil, http.StatusUnauthorized, nil, withAPIKey(readOnly.Key))
+	performRequest(t, srv, http.MethodDelete, "/api/v1/admin/api-keys/"+readOnly.ID, nil, http.StatusNotFound, nil, withAuth(admin.Token))
 }
 
-func (r *inMemoryReportRepository) metricsLocked() service.AdminDashboardMetrics {
-	metrics := service.AdminDashboardMetrics{}
-	for _, report := range r.records {
-		switch report.Status {
-		case "en_revision":
-			metrics.PendingReports++
-		case "resuelto":
-			metrics.ResolvedReports++
-		case "critico":
-			metrics.CriticalIncidents++
-		}
+func TestAnonymousReportsCanBeClaimedAfterRegistering(t *testing.T) {
+	// 1.- La app obtiene un token anónimo y reporta sin datos de contacto.
+	srv := buildServerWithAuth(t, []service.AuthOption{service.WithAnonymousAccess(time.Hour, 10)})
+	install := map[string]string{"installId": "3f6c1a2e-9b7d-4e8f-a1c2-5d6e7f8a9b0c"}
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/anonymous", map[string]string{"installId": "corto"}, http.StatusBadRequest, nil)
+	var anonymous service.AuthResponse
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/anonymous", install, http.StatusOK, &anonymous)
+	submission := map[string]any{
+		"incidentTypeId": "lighting",
+		"description":    "Luminaria apagada",
+		"latitude":       19.4,
+		"longitude":      -99.1,
+		"address":        "Calle Madero 3",
 	}
-	return metrics
-}
+	var report service.Report
+	performJSON(t, srv, http.MethodPost, "/api/v1/reports", submission, http.StatusCreated, &report, withAuth(anonymous.Token))
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports", nil, http.StatusForbidden, nil, withAuth(anonymous.Token))
+	performJSON(t, srv, http.MethodPost, "/api/v1/me/reports/claim", install, http.StatusForbidden, nil, withAuth(anonymous.Token))
+	performJSON(t, srv, http.MethodDelete, "/api/v1/me", map[string]string{}, http.StatusForbidden, nil, withAuth(anonymous.Token))
 
-// 3.- buildServer centraliza la creación del servidor de pruebas.
-func buildServer(t *testing.T) *Server {
-	t.Helper()
-	gin.SetMode(gin.TestMode)
-	authRepo := newInMemoryUserRepository()
-	reportRepo := newInMemoryReportRepository()
-	authSvc := service.NewAuthService(authRepo, 2, time.Minute, []byte("integration-secret"))
-	catalogSvc := service.NewCatalogService(1)
-	reportSvc := service.NewReportService(reportRepo, 2, 2)
-	srv := New(authSvc, catalogSvc, reportSvc)
-	t.Cleanup(func() {
-		_ = srv.Shutdown(context.Background())
-	})
-	return srv
+	// 2.- Una cuenta sigue obligada a dar contacto; el operador ve el reporte marcado como anónimo.
+	citizen := loginWithRole(t, srv, "vecina@example.com", service.RoleCitizen)
+	performJSON(t, srv, http.MethodPost, "/api/v1/reports", submission, http.StatusBadRequest, nil, withAuth(citizen.Token))
+	operator := loginWithRole(t, srv, "operador@example.com", service.RoleOperator)
+	var fetched service.Report
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports/"+report.ID, nil, http.StatusOK, &fetched, withAuth(operator.Token))
+	if !fetched.Anonymous {
+		t.Fatalf("expected anonymous flag, got %+v", fetched)
+	}
+
+	// 3.- Al registrarse, la ciudadana reclama el folio con el mismo identificador de instalación.
+	var result service.ClaimResult
+	performJSON(t, srv, http.MethodPost, "/api/v1/me/reports/claim", install, http.StatusOK, &result, withAuth(citizen.Token))
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports/"+report.ID, nil, http.StatusOK, &fetched, withAuth(operator.Token))
+	if result.Claimed != 1 || fetched.Anonymous {
+		t.Fatalf("expected claimed report, got %+v %+v", result, fetched)
+	}
+	var events []service.ReportEvent
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports/"+report.ID+"/events", nil, http.StatusOK, &events, withAuth(operator.Token))
+	if len(events) == 0 || events[0].Actor != "vecina@example.com" {
+		t.Fatalf("expected claimed submitter in events, got %+v", events)
+	}
 }
 
-func TestOpenAPIEndpointsHappyPath(t *testing.T) {
-	// 4.- Iniciamos el servidor y registramos un usuario nuevo.
+func TestCitizensOnlySeeTheirOwnReports(t *testing.T) {
+	// 1.- Dos ciudadanos reportan; el operador conserva la vista de toda la ciudad.
 	srv := buildServer(t)
-	registerBody := map[string]string{
-		"email":    "citizen@example.com",
-		"password": "ClaveSegura1",
+	ana := loginWithRole(t, srv, "ana@example.com", service.RoleCitizen)
+	luis := loginWithRole(t, srv, "luis@example.com", service.RoleCitizen)
+	operator := loginWithRole(t, srv, "operador@example.com", service.RoleOperator)
+	submission := map[string]any{
+		"incidentTypeId": "pothole",
+		"description":    "Bache en la avenida",
+		"contactEmail":   "ana@example.com",
+		"contactPhone":   "5512345678",
+		"latitude":       19.4,
+		"longitude":      -99.1,
+		"address":        "Av. Reforma 100",
 	}
-	performJSON(t, srv, http.MethodPost, "/api/v1/auth/register", registerBody, http.StatusCreated, nil)
+	var own, other service.Report
+	performJSON(t, srv, http.MethodPost, "/api/v1/reports", submission, http.StatusCreated, &own, withAuth(ana.Token))
+	submission["contactEmail"] = "luis@example.com"
+	performJSON(t, srv, http.MethodPost, "/api/v1/reports", submission, http.StatusCreated, &other, withAuth(luis.Token))
 
-	// 5.- Validamos el login tradicional.
-	var loginResponse service.AuthResponse
-	performJSON(t, srv, http.MethodPost, "/api/v1/auth/login", registerBody, http.StatusOK, &loginResponse)
-	if loginResponse.Token == "" {
-		t.Fatalf("expected non-empty token from login")
+	// 2.- Mis reportes y el listado general del ciudadano sólo incluyen los suyos, sin datos de contacto.
+	var mine, scoped, all service.PaginatedReports
+	performRequest(t, srv, http.MethodGet, "/api/v1/me/reports?status=en_revision", nil, http.StatusOK, &mine, withAuth(ana.Token))
+	if mine.TotalCount != 1 || mine.Items[0].ID != own.ID || mine.Items[0].ContactEmail != "" {
+		t.Fatalf("unexpected own reports %+v", mine)
+	}
+	performRequest(t, srv, http.MethodGet, "/api/v1/me/reports?status=inexistente", nil, http.StatusBadRequest, nil, withAuth(ana.Token))
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports", nil, http.StatusOK, &scoped, withAuth(ana.Token))
+	if scoped.TotalCount != 1 || scoped.Items[0].ID != own.ID {
+		t.Fatalf("expected citizen listing to be scoped, got %+v", scoped)
+	}
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports", nil, http.StatusOK, &all, withAuth(operator.Token))
+	if all.TotalCount != 2 {
+		t.Fatalf("expected staff to list every report, got %+v", all)
 	}
-	authHeader := withAuth(loginResponse.Token)
-
-	// 6.- Recuperación de contraseña debe confirmar la cuenta.
-	recoverBody := map[string]string{"email": registerBody["email"]}
-	performJSON(t, srv, http.MethodPost, "/api/v1/auth/recover", recoverBody, http.StatusAccepted, nil)
 
-	// 7.- Autenticación social valida proveedores soportados.
-	performJSON(t, srv, http.MethodPost, "/api/v1/auth/social/google", map[string]string{}, http.StatusOK, nil)
+	// 3.- El detalle de un reporte ajeno responde 404 para el ciudadano pero no para el personal.
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports/"+other.ID, nil, http.StatusNotFound, nil, withAuth(ana.Token))
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports/"+own.ID, nil, http.StatusOK, nil, withAuth(ana.Token))
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports/"+other.ID, nil, http.StatusOK, nil, withAuth(operator.Token))
+	performRequest(t, srv, http.MethodGet, "/api/v1/me/reports", nil, http.StatusUnauthorized, nil)
+}
 
-	// 8.- El catálogo debe responder con tipos disponibles.
-	var catalog []service.IncidentType
-	performRequest(t, srv, http.MethodGet, "/api/v1/catalog/incident-types", nil, http.StatusOK, &catalog)
-	if len(catalog) == 0 {
-		t.Fatalf("expected at least one incident type")
+func TestAssignmentNotifiesTheCrewChannel(t *testing.T) {
+	// 1.- El administrador da de alta una dependencia con su cuadrilla; el operador puede consultarlas.
+	srv := buildServer(t)
+	admin := loginWithRole(t, srv, "admin@example.com", service.RoleAdmin)
+	operator := loginWithRole(t, srv, "operador@example.com", service.RoleOperator)
+	citizen := loginWithRole(t, srv, "vecino@example.com", service.RoleCitizen)
+	var department service.Department
+	performJSON(t, srv, http.MethodPost, "/api/v1/departments", map[string]string{"name": "Obras Públicas"}, http.StatusCreated, &department, withAuth(admin.Token))
+	performJSON(t, srv, http.MethodPost, "/api/v1/departments", map[string]string{"name": "obras públicas"}, http.StatusConflict, nil, withAuth(admin.Token))
+	performJSON(t, srv, http.MethodPost, "/api/v1/departments", map[string]string{"name": "Parques"}, http.StatusForbidden, nil, withAuth(operator.Token))
+	var crew service.Crew
+	performJSON(t, srv, http.MethodPost, "/api/v1/departments/"+department.ID+"/crews", map[string]string{"name": "Bacheo Norte"}, http.StatusCreated, &crew, withAuth(admin.Token))
+	performJSON(t, srv, http.MethodPost, "/api/v1/departments/desconocida/crews", map[string]string{"name": "Bacheo Sur"}, http.StatusNotFound, nil, withAuth(admin.Token))
+	var departments []service.Department
+	performRequest(t, srv, http.MethodGet, "/api/v1/departments", nil, http.StatusOK, &departments, withAuth(operator.Token))
+	if len(departments) != 1 || len(departments[0].Crews) != 1 || departments[0].Crews[0].ID != crew.ID {
+		t.Fatalf("unexpected departments %+v", departments)
 	}
 
-	// 9.- Capturamos el hub para verificar el broadcast posterior.
-	client := srv.realtimeHub.Register(&captureConn{})
+	// 2.- Sólo el personal puede escuchar el canal de la cuadrilla.
+	performRequest(t, srv, http.MethodGet, "/ws?crew="+crew.ID, nil, http.StatusUnauthorized, nil)
+	performRequest(t, srv, http.MethodGet, "/ws?crew="+crew.ID, nil, http.StatusForbidden, nil, withAuth(citizen.Token))
+	crewClient := srv.realtimeHub.Register(&captureConn{}, realtime.CrewChannel(crew.ID))
 
-	// 10.- Ingresamos un reporte completo.
-	reportBody := map[string]any{
+	// 3.- El operador asigna el reporte; el ciudadano no puede hacerlo.
+	submission := map[string]any{
 		"incidentTypeId": "pothole",
 		"description":    "Bache profundo",
-		"contactEmail":   registerBody["email"],
+		"contactEmail":   "vecino@example.com",
 		"contactPhone":   "5512345678",
 		"latitude":       19.4,
 		"longitude":      -99.1,
-		"address":        "Centro, CDMX",
+		"address":        "Av. Central 12",
 	}
-	var created service.Report
-	performJSON(t, srv, http.MethodPost, "/api/v1/reports", reportBody, http.StatusCreated, &created, authHeader)
-	if created.ID == "" {
-		t.Fatalf("expected generated report identifier")
+	var report service.Report
+	performJSON(t, srv, http.MethodPost, "/api/v1/reports", submission, http.StatusCreated, &report, withAuth(citizen.Token))
+	assignment := map[string]string{"crewId": crew.ID}
+	performJSON(t, srv, http.MethodPost, "/api/v1/reports/"+report.ID+"/assignment", assignment, http.StatusForbidden, nil, withAuth(citizen.Token))
+	performJSON(t, srv, http.MethodPost, "/api/v1/reports/"+report.ID+"/assignment", map[string]string{}, http.StatusBadRequest, nil, withAuth(operator.Token))
+	var assigned service.Report
+	performJSON(t, srv, http.MethodPost, "/api/v1/reports/"+report.ID+"/assignment", assignment, http.StatusOK, &assigned, withAuth(operator.Token))
+	if assigned.Assignment == nil || assigned.Assignment.DepartmentID != department.ID {
+		t.Fatalf("unexpected assignment %+v", assigned.Assignment)
 	}
-
-	// 11.- Confirmamos la notificación WebSocket del nuevo reporte.
-	select {
-	case msg := <-client.Messages():
-		var payload map[string]any
-		if err := json.Unmarshal(msg, &payload); err != nil {
-			t.Fatalf("cannot decode broadcast: %v", err)
-		}
-		if payload["type"] != "report.created" {
-			t.Fatalf("unexpected broadcast type: %v", payload["type"])
+	for {
+		select {
+		case msg := <-crewClient.Messages():
+			var payload map[string]any
+			if err := json.Unmarshal(msg, &payload); err != nil {
+				t.Fatalf("cannot decode notification: %v", err)
+			}
+			if payload["type"] != "report.assigned" {
+				continue
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected crew notification after assignment")
 		}
-	case <-time.After(2 * time.Second):
-		t.Fatalf("expected websocket broadcast after submission")
+		break
 	}
 
-	// 12.- Listamos los reportes administrativos.
+	// 4.- Reasignar exige motivo y el listado filtra por cuadrilla.
+	performJSON(t, srv, http.MethodPost, "/api/v1/reports/"+report.ID+"/assignment", assignment, http.StatusBadRequest, nil, withAuth(operator.Token))
+	assignment["reason"] = "Cambio de turno"
+	performJSON(t, srv, http.MethodPost, "/api/v1/reports/"+report.ID+"/assignment", assignment, http.StatusOK, nil, withAuth(operator.Token))
 	var list service.PaginatedReports
-	performRequest(t, srv, http.MethodGet, "/api/v1/reports?page=0&pageSize=10", nil, http.StatusOK, &list, authHeader)
-	if len(list.Items) == 0 {
-		t.Fatalf("expected paginated items in list endpoint")
+	performRequest(t, srv, http.MethodGet, "/api/v1/reports?crewId="+crew.ID, nil, http.StatusOK, &list, withAuth(operator.Token))
+	if list.TotalCount != 1 {
+		t.Fatalf("expected one report for the crew, got %+v", list)
 	}
-
-	// 13.- Leemos el reporte directo y comprobamos el ID.
-	var fetched service.Report
-	performRequest(t, srv, http.MethodGet, "/api/v1/reports/"+created.ID, nil, http.StatusOK, &fetched, authHeader)
-	if fetched.ID != created.ID {
-		t.Fatalf("expected fetched report ID %s, got %s", created.ID, fetched.ID)
+	var status service.FolioStatus
+	performRequest(t, srv, http.MethodGet, "/api/v1/folios/"+report.ID, nil, http.StatusOK, &status)
+	if len(status.History) != 3 || status.History[1].Description != "Asignado a cuadrilla" {
+		t.Fatalf("unexpected folio history %+v", status.History)
 	}
+}
 
-	// 14.- Actualizamos el estatus a resuelto.
-	updateBody := map[string]string{"status": "resuelto"}
-	performJSON(t, srv, http.MethodPatch, "/api/v1/reports/"+created.ID, updateBody, http.StatusOK, &fetched, authHeader)
-	if fetched.Status != "resuelto" {
-		t.Fatalf("expected updated status resuelto, got %s", fetched.Status)
+func TestStaffNotesStayOutOfTheFolioHistory(t *testing.T) {
+	// 1.- Un operador escribe una nota con mención a una supervisora y un adjunto.
+	srv := buildServer(t)
+	citizen := loginWithRole(t, srv, "vecino@example.com", service.RoleCitizen)
+	operator := loginWithRole(t, srv, "operador@example.com", service.RoleOperator)
+	supervisor := loginWithRole(t, srv, "supervisora@example.com", service.RoleSupervisor)
+	submission := map[string]any{
+		"incidentTypeId": "lighting",
+		"description":    "Luminaria apagada",
+		"contactEmail":   "vecino@example.com",
+		"contactPhone":   "5512345678",
+		"latitude":       19.4,
+		"longitude":      -99.1,
+		"address":        "Parque Central",
 	}
-
-	// 15.- El dashboard debe reflejar el conteo de resueltos.
-	var metrics service.AdminDashboardMetrics
-	performRequest(t, srv, http.MethodGet, "/api/v1/admin/dashboard/metrics", nil, http.StatusOK, &metrics, authHeader)
-	if metrics.ResolvedReports == 0 {
-		t.Fatalf("expected resolved reports metric to be positive")
+	var report service.Report
+	performJSON(t, srv, http.MethodPost, "/api/v1/reports", submission, http.StatusCreated, &report, withAuth(citizen.Token))
+	notesPath := "/api/v1/reports/" + report.ID + "/notes"
+	body := map[string]any{
+		"body":        "Revisar con la cuadrilla nocturna",
+		"mentions":    []string{"supervisora@example.com"},
+		"attachments": []string{"https://cdn.example.com/poste.jpg"},
 	}
-
-	// 16.- Consultamos el folio directo para validar seguimiento.
-	var folio service.FolioStatus
-	performRequest(t, srv, http.MethodGet, "/api/v1/folios/"+created.ID, nil, http.StatusOK, &folio)
-	if folio.Folio != created.ID {
-		t.Fatalf("expected folio %s, got %s", created.ID, folio.Folio)
+	var note service.StaffNote
+	performJSON(t, srv, http.MethodPost, notesPath, body, http.StatusCreated, &note, withAuth(operator.Token))
+	if note.Author != "operador@example.com" || len(note.Mentions) != 1 || len(note.Attachments) != 1 || note.EditableUntil.IsZero() {
+		t.Fatalf("unexpected note %+v", note)
 	}
 
-	// 17.- Eliminamos el reporte y comprobamos la ausencia posterior.
-	performRequest(t, srv, http.MethodDelete, "/api/v1/reports/"+created.ID, nil, http.StatusNoContent, nil, authHeader)
-	performRequest(t, srv, http.MethodGet, "/api/v1/reports/"+created.ID, nil, http.StatusNotFound, nil, authHeader)
-}
-
-func TestMethodEnforcementRemainsActive(t *testing.T) {
-	// 18.- Un GET sobre login debe seguir devolviendo 405.
-	srv := buildServer(t)
-	performRequest(t, srv, http.MethodGet, "/api/v1/auth/login", nil, http.StatusMethodNotAllowed, nil)
-}
+	// 2.- Mencionar a un ciudadano se rechaza y el ciudadano no puede leer ni escribir notas.
+	performJSON(t, srv, http.MethodPost, notesPath, map[string]any{"body": "Hola", "mentions": []string{"vecino@example.com"}}, http.StatusBadRequest, nil, withAuth(operator.Token))
+	performRequest(t, srv, http.MethodGet, notesPath, nil, http.StatusForbidden, nil, withAuth(citizen.Token))
+	performJSON(t, srv, http.MethodPost, notesPath, map[string]any{"body": "Hola"}, http.StatusForbidden, nil, withAuth(citizen.Token))
 
-func TestProtectedEndpointsRequireToken(t *testing.T) {
-	// 19.- Las rutas protegidas deben rechazar la ausencia de token.
-	srv := buildServer(t)
-	performRequest(t, srv, http.MethodGet, "/api/v1/reports", nil, http.StatusUnauthorized, nil)
+	// 3.- Sólo el autor edita su nota; el resto del personal la lee.
+	edit := map[string]any{"body": "Revisar con la cuadrilla nocturna el jueves"}
+	performJSON(t, srv, http.MethodPatch, notesPath+"/"+note.ID, edit, http.StatusForbidden, nil, withAuth(supervisor.Token))
+	var edited service.StaffNote
+	performJSON(t, srv, http.MethodPatch, notesPath+"/"+note.ID, edit, http.StatusOK, &edited, withAuth(operator.Token))
+	if edited.EditedAt == nil || edited.Body != edit["body"] {
+		t.Fatalf("unexpected edited note %+v", edited)
+	}
+	var notes []service.StaffNote
+	performRequest(t, srv, http.MethodGet, notesPath, nil, http.StatusOK, &notes, withAuth(supervisor.Token))
+	if len(notes) != 1 || notes[0].Body != edit["body"] {
+		t.Fatalf("unexpected notes %+v", notes)
+	}
 
-	// 20.- Un token inválido también regresa 401.
-	performRequest(t, srv, http.MethodGet, "/api/v1/reports", nil, http.StatusUnauthorized, nil, withAuth("malformed"))
+	// 4.- El seguimiento público del folio no incluye la nota.
+	var status service.FolioStatus
+	performRequest(t, srv, http.MethodGet, "/api/v1/folios/"+report.ID, nil, http.StatusOK, &status)
+	if len(status.History) != 1 || strings.Contains(fmt.Sprint(status.History), "nocturna") {
+		t.Fatalf("expected staff notes to stay out of the folio history, got %+v", status.History)
+	}
 }
 
-func TestReportSubmissionValidationRejectsMalformedPayload(t *testing.T) {
-	// 21.- El validador debe prevenir que cargas incompletas lleguen al servicio.
+func TestReportMessagesReachOnlyTheConversationParticipants(t *testing.T) {
+	// 1.- El ciudadano reporta; cada cuenta escucha su canal privado y un vecino ajeno también.
 	srv := buildServer(t)
-	credentials := map[string]string{
-		"email":    "validator@example.com",
-		"password": "ClaveSegura1",
+	citizen := loginWithRole(t, srv, "vecino@example.com", service.RoleCitizen)
+	neighbour := loginWithRole(t, srv, "vecina@example.com", service.RoleCitizen)
+	operator := loginWithRole(t, srv, "operador@example.com", service.RoleOperator)
+	submission := map[string]any{
+		"incidentTypeId": "trash",
+		"description":    "Basura acumulada",
+		"contactEmail":   "vecino@example.com",
+		"contactPhone":   "5512345678",
+		"latitude":       19.4,
+		"longitude":      -99.1,
+		"address":        "Calle Olmo",
 	}
-	performJSON(t, srv, http.MethodPost, "/api/v1/auth/register", credentials, http.StatusCreated, nil)
-	var token service.AuthResponse
-	performJSON(t, srv, http.MethodPost, "/api/v1/auth/login", credentials, http.StatusOK, &token)
-	invalidReport := map[string]any{
-		"incidentTypeId": "",
-		"description":    "",
-		"contactEmail":   "no-es-correo",
-		"contactPhone":   "123",
-		"latitude":       120.0,
-		"longitude":      200.0,
-		"address":        "",
+	var report service.Report
+	performJSON(t, srv, http.MethodPost, "/api/v1/reports", submission, http.StatusCreated, &report, withAuth(citizen.Token))
+	messagesPath := "/api/v1/reports/" + report.ID + "/messages"
+	performRequest(t, srv, http.MethodGet, "/ws", nil, http.StatusUnauthorized, nil, withAuth("token-invalido"))
+	citizenClient := srv.realtimeHub.Register(&captureConn{}, realtime.UserChannel("vecino@example.com"))
+	neighbourClient := srv.realtimeHub.Register(&captureConn{}, realtime.UserChannel("vecina@example.com"))
+	operatorClient := srv.realtimeHub.Register(&captureConn{}, realtime.UserChannel("operador@example.com"))
+
+	// 2.- El operador pregunta; sólo quien reportó lo recibe y sin ver quién del personal escribió.
+	performJSON(t, srv, http.MethodPost, messagesPath, map[string]string{"body": ""}, http.StatusBadRequest, nil, withAuth(operator.Token))
+	performJSON(t, srv, http.MethodPost, messagesPath, map[string]string{"body": "   "}, http.StatusBadRequest, nil, withAuth(operator.Token))
+	var question service.ReportMessage
+	performJSON(t, srv, http.MethodPost, messagesPath, map[string]string{"body": "¿Cuál es el número exterior?"}, http.StatusCreated, &question, withAuth(operator.Token))
+	if question.Sender != service.MessageSenderStaff || question.Author != "operador@example.com" {
+		t.Fatalf("unexpected message %+v", question)
 	}
-	performJSON(t, srv, http.MethodPost, "/api/v1/reports", invalidReport, http.StatusBadRequest, nil, withAuth(token.Token))
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	reports, err := srv.reportService.List(ctx, 0, 10, "")
-	if err != nil {
-		t.Fatalf("unexpected list error: %v", err)
+	delivered := waitForMessage(t, citizenClient, "report.message")
+	if payload := delivered["payload"].(map[string]any); payload["body"] != question.Body || payload["author"] != nil {
+		t.Fatalf("unexpected delivery %+v", delivered)
 	}
-	if len(reports.Items) != 0 {
-		t.Fatalf("expected zero reports after invalid submission, got %d", len(reports.Items))
+	if err := srv.realtimeHub.Broadcast([]byte(`{"type":"ping"}`)); err != nil {
+		t.Fatalf("broadcast failed: %v", err)
+	}
+	// La difusión del reporte puede llegar antes del ping; sólo importa que el mensaje no llegue.
+	for received := waitForMessage(t, neighbourClient, ""); received["type"] != "ping"; received = waitForMessage(t, neighbourClient, "") {
+		if received["type"] == "report.message" {
+			t.Fatalf("expected the neighbour to miss the message, got %+v", received)
+		}
 	}
-}
 
-func TestMetricsEndpointReportsObservability(t *testing.T) {
-	// 22.- El endpoint /metrics debe exponer las métricas clave instrumentadas.
-	srv := buildServer(t)
-	creds := map[string]string{
-		"email":    "metrics@example.com",
-		"password": "ClaveSegura1",
+	// 3.- El folio avisa del mensaje pendiente hasta que el ciudadano abre la conversación.
+	var status service.FolioStatus
+	performRequest(t, srv, http.MethodGet, "/api/v1/folios/"+report.ID, nil, http.StatusOK, &status)
+	if status.UnreadMessages != 1 {
+		t.Fatalf("expected one unread message, got %+v", status)
 	}
-	performJSON(t, srv, http.MethodPost, "/api/v1/auth/register", creds, http.StatusCreated, nil)
-	var login service.AuthResponse
-	performJSON(t, srv, http.MethodPost, "/api/v1/auth/login", creds, http.StatusOK, &login)
-	submission := map[string]any{
-		"incidentTypeId": "flood",
-		"description":    "Avenida inundada",
-		"contactEmail":   creds["email"],
-		"contactPhone":   "5587654321",
-		"latitude":       19.43,
-		"longitude":      -99.13,
-		"address":        "Centro",
+	performRequest(t, srv, http.MethodGet, messagesPath, nil, http.StatusNotFound, nil, withAuth(neighbour.Token))
+	var conversation []service.ReportMessage
+	performRequest(t, srv, http.MethodGet, messagesPath, nil, http.StatusOK, &conversation, withAuth(citizen.Token))
+	if len(conversation) != 1 || conversation[0].ReadAt == nil || conversation[0].Author != "" {
+		t.Fatalf("unexpected conversation %+v", conversation)
 	}
-	performJSON(t, srv, http.MethodPost, "/api/v1/reports", submission, http.StatusCreated, nil, withAuth(login.Token))
-	time.Sleep(150 * time.Millisecond)
-	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
-	rr := httptest.NewRecorder()
-	srv.Router().ServeHTTP(rr, req)
-	if rr.Code != http.StatusOK {
-		t.Fatalf("expected 200 from metrics endpoint, got %d", rr.Code)
+	performRequest(t, srv, http.MethodGet, "/api/v1/folios/"+report.ID, nil, http.StatusOK, &status)
+	if status.UnreadMessages != 0 {
+		t.Fatalf("expected the message to be read, got %+v", status)
 	}
-	body := rr.Body.String()
-	if !strings.Contains(body, "citizenapp_report_submit_queue_depth") {
-		t.Fatalf("expected submit queue metric in body, got %s", body)
+
+	// 4.- La respuesta del ciudadano llega al operador que preguntó y el vecino no puede escribir.
+	performJSON(t, srv, http.MethodPost, messagesPath, map[string]string{"body": "Hola"}, http.StatusNotFound, nil, withAuth(neighbour.Token))
+	performJSON(t, srv, http.MethodPost, messagesPath, map[string]string{"body": "Es el 42"}, http.StatusCreated, nil, withAuth(citizen.Token))
+	reply := waitForMessage(t, operatorClient, "report.message")
+	if payload := reply["payload"].(map[string]any); payload["body"] != "Es el 42" || payload["sender"] != service.MessageSenderCitizen {
+		t.Fatalf("unexpected reply %+v", reply)
 	}
-	if !strings.Contains(body, "citizenapp_http_request_duration_seconds_bucket") {
-		t.Fatalf("expected http duration histogram in body, got %s", body)
+}
+
+// waitForMessage devuelve el primer mensaje del cliente con el tipo indicado; vacío acepta cualquiera.
+func waitForMessage(t *testing.T, client *realtime.Client, kind string) map[string]any {
+	t.Helper()
+	for {
+		select {
+		case msg := <-client.Messages():
+			var payload map[string]any
+			if err := json.Unmarshal(msg, &payload); err != nil {
+				t.Fatalf("cannot decode notification: %v", err)
+			}
+			if kind == "" || payload["type"] == kind {
+				return payload
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for %q notification", kind)
+		}
 	}
-	if !strings.Contains(body, "citizenapp_realtime_broadcast_latency_seconds_bucket") {
-		t.Fatalf("expected broadcast latency histogram in body, got %s", body)
+}
+
+// waitForClose descarta los mensajes pendientes hasta que el hub cierra la conexión.
+func waitForClose(t *testing.T, client *realtime.Client) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case _, open := <-client.Messages():
+			if !open {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timeout waiting for the connection to close")
+		}
+	}
+}
+
+func loginWithRole(t *testing.T, srv *Server, email, role string) service.AuthResponse {
+	t.Helper()
+	creds := map[string]string{"email": email, "password": "ClaveSegura1"}
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/register", creds, http.StatusCreated, nil)
+	if err := srv.authService.AssignRole(context.Background(), email, role); err != nil {
+		t.Fatalf("cannot assign role %s: %v", role, err)
 	}
+	var token service.AuthResponse
+	performJSON(t, srv, http.MethodPost, "/api/v1/auth/login", creds, http.StatusOK, &token)
+	return token
 }
 
 // 23.- performJSON ayuda a serializar cuerpos y decodificar respuestas.
@@ -393,7 +2121,7 @@ func performRequest(t *testing.T, srv *Server, method, path string, body *bytes.
 func performWithBody(t *testing.T, srv *Server, method, path string, reader *bytes.Reader, expected int, target any, opts ...func(*http.Request)) {
 	t.Helper()
 	req := httptest.NewRequest(method, path, reader)
-	if method == http.MethodPost || method == http.MethodPatch {
+	if method == http.MethodPost || method == http.MethodPatch || method == http.MethodPut {
 		req.Header.Set("Content-Type", "application/json")
 	}
 	for _, opt := range opts {
@@ -418,6 +2146,13 @@ func withAuth(token string) func(*http.Request) {
 	}
 }
 
+// 26.1.- withAPIKey autentica la petición como una integración.
+func withAPIKey(key string) func(*http.Request) {
+	return func(r *http.Request) {
+		r.Header.Set("X-API-Key", key)
+	}
+}
+
 // 27.- captureConn implementa la interfaz WebSocket mínima para pruebas.
 type captureConn struct{}
 
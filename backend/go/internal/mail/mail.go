@@ -0,0 +1,138 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"citizenapp/backend/internal/service"
+)
+
+// 1.- SMTPConfig agrupa los datos del servidor de correo saliente.
+type SMTPConfig struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+// 2.- SMTPMailer implementa service.Mailer usando net/smtp con STARTTLS cuando el servidor lo ofrece.
+type SMTPMailer struct {
+	cfg  SMTPConfig
+	auth smtp.Auth
+}
+
+// 3.- NewSMTPMailer valida la dirección y el remitente requeridos.
+func NewSMTPMailer(cfg SMTPConfig) *SMTPMailer {
+	if strings.TrimSpace(cfg.Addr) == "" || strings.TrimSpace(cfg.From) == "" {
+		panic("smtp address and sender are required")
+	}
+	m := &SMTPMailer{cfg: cfg}
+	if cfg.Username != "" {
+		host, _, err := net.SplitHostPort(cfg.Addr)
+		if err != nil {
+			host = cfg.Addr
+		}
+		m.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, host)
+	}
+	return m
+}
+
+// 4.- Send entrega el mensaje; net/smtp no acepta contexto, así que sólo se verifica antes de conectar.
+func (m *SMTPMailer) Send(ctx context.Context, message service.MailMessage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data := Format(m.cfg.From, message, time.Now())
+	if err := smtp.SendMail(m.cfg.Addr, m.auth, m.cfg.From, []string{message.To}, data); err != nil {
+		return fmt.Errorf("smtp send: %w", err)
+	}
+	return nil
+}
+
+// 5.- FileMailer escribe cada correo como archivo .eml para inspeccionarlo en desarrollo.
+type FileMailer struct {
+	dir  string
+	from string
+	seq  atomic.Uint64
+}
+
+// 6.- NewFileMailer crea el directorio de salida si no existe.
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create mail dir: %w", err)
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+// 7.- Send guarda el mensaje con un nombre ordenable por fecha.
+func (m *FileMailer) Send(ctx context.Context, message service.MailMessage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	now := time.Now()
+	name := fmt.Sprintf("%s-%04d.eml", now.UTC().Format("20060102T150405"), m.seq.Add(1))
+	return os.WriteFile(filepath.Join(m.dir, name), Format(m.from, message, now), 0o600)
+}
+
+// 8.- MemoryMailer conserva los correos en memoria para pruebas y ejecución local.
+type MemoryMailer struct {
+	mu     sync.Mutex
+	sent   []service.MailMessage
+	notify chan service.MailMessage
+}
+
+// 9.- NewMemoryMailer prepara el buffer de notificaciones para las pruebas.
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{notify: make(chan service.MailMessage, 64)}
+}
+
+// 10.- Send registra el mensaje y lo publica en el canal sin bloquear.
+func (m *MemoryMailer) Send(ctx context.Context, message service.MailMessage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.sent = append(m.sent, message)
+	m.mu.Unlock()
+	select {
+	case m.notify <- message:
+	default:
+	}
+	return nil
+}
+
+// 11.- Sent devuelve una copia de los correos entregados.
+func (m *MemoryMailer) Sent() []service.MailMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]service.MailMessage(nil), m.sent...)
+}
+
+// 12.- Messages expone un canal de solo lectura útil para esperar envíos asíncronos.
+func (m *MemoryMailer) Messages() <-chan service.MailMessage {
+	return m.notify
+}
+
+// 13.- Format construye un mensaje RFC 5322 en texto plano UTF-8.
+func Format(from string, message service.MailMessage, now time.Time) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", message.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	buf.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+	return buf.Bytes()
+}
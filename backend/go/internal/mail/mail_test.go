@@ -0,0 +1,48 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"citizenapp/backend/internal/service"
+)
+
+func TestFormatEncodesHeadersAndBody(t *testing.T) {
+	// 1.- Generamos un correo con acentos en el asunto y saltos de línea en el cuerpo.
+	message := service.MailMessage{To: "vecino@example.com", Subject: "Restablece tu contraseña", Body: "Hola\nAdiós"}
+	data := string(Format("no-reply@example.com", message, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)))
+
+	// 2.- El asunto va codificado y el cuerpo usa CRLF.
+	if !strings.Contains(data, "Subject: =?utf-8?q?Restablece_tu_contrase=C3=B1a?=\r\n") {
+		t.Fatalf("unexpected subject header in %q", data)
+	}
+	if !strings.Contains(data, "To: vecino@example.com\r\n") || !strings.HasSuffix(data, "\r\n\r\nHola\r\nAdiós") {
+		t.Fatalf("unexpected message %q", data)
+	}
+}
+
+func TestFileMailerWritesOneFilePerMessage(t *testing.T) {
+	// 1.- Enviamos dos correos a un directorio temporal.
+	dir := t.TempDir()
+	mailer, err := NewFileMailer(dir, "no-reply@example.com")
+	if err != nil {
+		t.Fatalf("NewFileMailer returned error: %v", err)
+	}
+	for _, to := range []string{"a@example.com", "b@example.com"} {
+		if err := mailer.Send(context.Background(), service.MailMessage{To: to, Subject: "Hola", Body: "Cuerpo"}); err != nil {
+			t.Fatalf("Send returned error: %v", err)
+		}
+	}
+
+	// 2.- Cada mensaje queda en su propio archivo .eml.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("cannot read dir: %v", err)
+	}
+	if len(entries) != 2 || !strings.HasSuffix(entries[0].Name(), ".eml") {
+		t.Fatalf("unexpected files: %v", entries)
+	}
+}
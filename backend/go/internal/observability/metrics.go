@@ -22,6 +22,10 @@ var (
 	broadcastLatency prometheus.Histogram
 	// 5.- httpRequestDuration captura la latencia de cada petición HTTP.
 	httpRequestDuration *prometheus.HistogramVec
+	// 5.1.- loginFailures cuenta los inicios de sesión rechazados por motivo.
+	loginFailures *prometheus.CounterVec
+	// 5.2.- loginLockouts cuenta los bloqueos temporales aplicados por alcance.
+	loginLockouts *prometheus.CounterVec
 )
 
 // 6.- EnsureMetrics inicializa y registra los recolectores personalizados.
@@ -56,7 +60,19 @@ func EnsureMetrics(reg prometheus.Registerer) {
 			Help:      "Latency histogram for HTTP endpoints.",
 			Buckets:   prometheus.DefBuckets,
 		}, []string{"method", "path", "status"})
-		reg.MustRegister(submitQueueDepth, lookupQueueDepth, broadcastLatency, httpRequestDuration)
+		loginFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "citizenapp",
+			Subsystem: "auth",
+			Name:      "login_failures_total",
+			Help:      "Rejected login attempts by reason.",
+		}, []string{"reason"})
+		loginLockouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "citizenapp",
+			Subsystem: "auth",
+			Name:      "login_lockouts_total",
+			Help:      "Temporary login lockouts applied per account or IP.",
+		}, []string{"scope"})
+		reg.MustRegister(submitQueueDepth, lookupQueueDepth, broadcastLatency, httpRequestDuration, loginFailures, loginLockouts)
 	})
 }
 
@@ -90,6 +106,20 @@ func ObserveHTTPRequestDuration(method, path string, status int, duration time.D
 	labels.Observe(duration.Seconds())
 }
 
+// 10.1.- IncLoginFailure suma un intento rechazado ("invalid_credentials" o "locked").
+func IncLoginFailure(reason string) {
+	if loginFailures != nil {
+		loginFailures.WithLabelValues(reason).Inc()
+	}
+}
+
+// 10.2.- IncLoginLockout suma un bloqueo aplicado a una cuenta o a una IP.
+func IncLoginLockout(scope string) {
+	if loginLockouts != nil {
+		loginLockouts.WithLabelValues(scope).Inc()
+	}
+}
+
 // 11.- GinMetricsMiddleware mide automáticamente la latencia de cada solicitud.
 func GinMetricsMiddleware() gin.HandlerFunc {
 	EnsureMetrics(nil)
@@ -29,7 +29,7 @@ type Conn interface {
 type Hub struct {
 	shards            []clientShard
 	shardMask         uint64
-	broadcastQueue    chan []byte
+	broadcastQueue    chan hubMessage
 	workerGroup       sync.WaitGroup
 	stopOnce          sync.Once
 	nextID            atomic.Uint64
@@ -51,6 +51,32 @@ type Client struct {
 	hub       *Hub
 	outbound  chan []byte
 	closeOnce sync.Once
+	// channels son los canales privados a los que se suscribió al conectarse.
+	channels map[string]struct{}
+	// subject y sessionID identifican a la cuenta autenticada; expiry cierra la conexión al vencer su token.
+	subject   string
+	sessionID string
+	expiry    *time.Timer
+}
+
+// 3.1.- hubMessage dirige el mensaje a un canal; vacío significa a todos los clientes.
+type hubMessage struct {
+	channel string
+	data    []byte
+}
+
+// 3.2.- CrewChannel y DepartmentChannel nombran los canales que reciben las asignaciones.
+func CrewChannel(crewID string) string {
+	return "crew:" + crewID
+}
+
+func DepartmentChannel(departmentID string) string {
+	return "department:" + departmentID
+}
+
+// 3.3.- UserChannel es el canal privado de una cuenta; sólo lo abre una conexión autenticada como ella.
+func UserChannel(subject string) string {
+	return "user:" + subject
 }
 
 const (
@@ -81,7 +107,7 @@ func NewHub(shardPower, workerCount, queueSize, clientBuffer int) *Hub {
 	h := &Hub{
 		shards:            shards,
 		shardMask:         uint64(shardCount - 1),
-		broadcastQueue:    make(chan []byte, queueSize),
+		broadcastQueue:    make(chan hubMessage, queueSize),
 		clientBuffer:      clientBuffer,
 		pongWait:          defaultPongWait,
 		pingInterval:      defaultPingInterval,
@@ -94,25 +120,56 @@ func NewHub(shardPower, workerCount, queueSize, clientBuffer int) *Hub {
 	return h
 }
 
-// 5.- Register asigna un identificador incremental y agrega al cliente a su partición.
-func (h *Hub) Register(conn Conn) *Client {
+// 5.- Register asigna un identificador incremental y agrega al cliente a su partición con sus canales.
+func (h *Hub) Register(conn Conn, channels ...string) *Client {
+	client := h.newClient(conn, channels)
+	shard := h.shardFor(client.id)
+	shard.mu.Lock()
+	shard.clients[client.id] = client
+	shard.mu.Unlock()
+	return client
+}
+
+// 5.1.- RegisterUser suscribe además el canal privado de la cuenta y cierra la conexión cuando vence el token.
+func (h *Hub) RegisterUser(conn Conn, principal service.Principal, channels ...string) *Client {
+	client := h.newClient(conn, channels)
+	client.channels[UserChannel(principal.Subject)] = struct{}{}
+	client.subject = principal.Subject
+	client.sessionID = principal.SessionID
+	shard := h.shardFor(client.id)
+	shard.mu.Lock()
+	shard.clients[client.id] = client
+	// El temporizador se arma bajo el candado: close sólo lo lee después de sacar al cliente de su partición.
+	client.expiry = time.AfterFunc(time.Until(principal.ExpiresAt), func() {
+		h.unregister(client.id)
+	})
+	shard.mu.Unlock()
+	return client
+}
+
+func (h *Hub) newClient(conn Conn, channels []string) *Client {
 	client := &Client{
 		id:       h.nextID.Add(1),
 		conn:     conn,
 		hub:      h,
 		outbound: make(chan []byte, h.clientBuffer),
+		channels: make(map[string]struct{}, len(channels)+1),
+	}
+	for _, channel := range channels {
+		client.channels[channel] = struct{}{}
 	}
-	shard := h.shardFor(client.id)
-	shard.mu.Lock()
-	shard.clients[client.id] = client
-	shard.mu.Unlock()
 	return client
 }
 
 // 6.- Broadcast encola el mensaje para procesamiento paralelo sin bloquear al caller.
 func (h *Hub) Broadcast(message []byte) error {
+	return h.Publish("", message)
+}
+
+// 6.1.- Publish encola el mensaje sólo para los clientes suscritos al canal.
+func (h *Hub) Publish(channel string, message []byte) error {
 	select {
-	case h.broadcastQueue <- message:
+	case h.broadcastQueue <- hubMessage{channel: channel, data: message}:
 		return nil
 	default:
 		return errors.New("broadcast queue full")
@@ -132,6 +189,46 @@ func (h *Hub) BroadcastReport(report service.Report) error {
 	return h.Broadcast(data)
 }
 
+// 7.1.- PublishAssignment avisa a la cuadrilla asignada o, si aún no hay cuadrilla, a la dependencia.
+func (h *Hub) PublishAssignment(report service.Report) error {
+	if report.Assignment == nil {
+		return nil
+	}
+	channel := DepartmentChannel(report.Assignment.DepartmentID)
+	if report.Assignment.CrewID != "" {
+		channel = CrewChannel(report.Assignment.CrewID)
+	}
+	data, err := json.Marshal(map[string]any{
+		"type":    "report.assigned",
+		"payload": report,
+	})
+	if err != nil {
+		return err
+	}
+	return h.Publish(channel, data)
+}
+
+// 7.2.- PublishMessage entrega el mensaje sólo a los canales de sus destinatarios; el ciudadano no ve quién del personal escribió.
+func (h *Hub) PublishMessage(message service.ReportMessage) error {
+	payload := message
+	if message.Sender == service.MessageSenderStaff {
+		payload = message.ForCitizen()
+	}
+	data, err := json.Marshal(map[string]any{
+		"type":    "report.message",
+		"payload": payload,
+	})
+	if err != nil {
+		return err
+	}
+	for _, subject := range message.Recipients {
+		if err := h.Publish(UserChannel(subject), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // 8.- Shutdown detiene a los workers y cierra cada conexión de forma ordenada.
 func (h *Hub) Shutdown(ctx context.Context) error {
 	h.stopOnce.Do(func() {
@@ -175,7 +272,9 @@ func (h *Hub) broadcastWorker() {
 			shard := &h.shards[i]
 			shard.mu.RLock()
 			for _, client := range shard.clients {
-				client.enqueue(message)
+				if message.channel == "" || client.subscribed(message.channel) {
+					client.enqueue(message.data)
+				}
 			}
 			shard.mu.RUnlock()
 		}
@@ -199,6 +298,12 @@ func (c *Client) enqueue(message []byte) {
 	}
 }
 
+// 11.1.- subscribed indica si el cliente pidió el canal; el conjunto no cambia tras registrarse.
+func (c *Client) subscribed(channel string) bool {
+	_, ok := c.channels[channel]
+	return ok
+}
+
 // 12.- Run inicia los ciclos de lectura y escritura para la conexión WebSocket.
 func (c *Client) Run(ctx context.Context) {
 	ctx, cancel := context.WithCancel(ctx)
@@ -259,6 +364,9 @@ func (c *Client) Messages() <-chan []byte {
 // 16.- close garantiza el cierre único de los recursos asociados al cliente.
 func (c *Client) close() {
 	c.closeOnce.Do(func() {
+		if c.expiry != nil {
+			c.expiry.Stop()
+		}
 		close(c.outbound)
 		_ = c.conn.Close()
 	})
@@ -277,3 +385,38 @@ func (h *Hub) unregister(id uint64) {
 		client.close()
 	}
 }
+
+// 18.- DisconnectSession cierra las conexiones abiertas con tokens de la sesión revocada.
+func (h *Hub) DisconnectSession(sessionID string) {
+	if sessionID == "" {
+		return
+	}
+	h.disconnect(func(c *Client) bool { return c.sessionID == sessionID })
+}
+
+// 19.- DisconnectSubject cierra todas las conexiones autenticadas como la cuenta indicada.
+func (h *Hub) DisconnectSubject(subject string) {
+	if subject == "" {
+		return
+	}
+	h.disconnect(func(c *Client) bool { return c.subject == subject })
+}
+
+// disconnect saca de cada partición a los clientes que cumplen la condición y los cierra fuera del candado.
+func (h *Hub) disconnect(match func(*Client) bool) {
+	for i := range h.shards {
+		shard := &h.shards[i]
+		var closing []*Client
+		shard.mu.Lock()
+		for id, client := range shard.clients {
+			if match(client) {
+				delete(shard.clients, id)
+				closing = append(closing, client)
+			}
+		}
+		shard.mu.Unlock()
+		for _, client := range closing {
+			client.close()
+		}
+	}
+}
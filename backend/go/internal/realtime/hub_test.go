@@ -3,6 +3,7 @@ package realtime
 import (
 	"context"
 	"errors"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -87,3 +88,135 @@ func TestBroadcastQueueBackpressure(t *testing.T) {
 		t.Fatalf("expected backpressure error when queue is full")
 	}
 }
+
+func TestPublishAssignmentReachesOnlyTheCrewChannel(t *testing.T) {
+	// 7.- Un cliente escucha a la cuadrilla, otro a su dependencia y otro sólo la difusión general.
+	hub := NewHub(2, 2, 8, 2)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = hub.Shutdown(ctx)
+	})
+	crew := hub.Register(&mockConn{}, CrewChannel("c1"))
+	department := hub.Register(&mockConn{}, DepartmentChannel("d1"))
+	public := hub.Register(&mockConn{})
+
+	// 8.- La asignación a la cuadrilla sólo llega a su canal.
+	report := service.Report{ID: "F-0002", Assignment: &service.Assignment{DepartmentID: "d1", CrewID: "c1"}}
+	if err := hub.PublishAssignment(report); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+	select {
+	case msg := <-crew.Messages():
+		if !strings.Contains(string(msg), `"report.assigned"`) {
+			t.Fatalf("unexpected assignment payload %s", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timeout waiting for crew notification")
+	}
+
+	// 9.- Una difusión general posterior es lo primero que reciben los demás clientes.
+	if err := hub.BroadcastReport(service.Report{ID: "F-0003"}); err != nil {
+		t.Fatalf("broadcast failed: %v", err)
+	}
+	for _, client := range []*Client{department, public} {
+		select {
+		case msg := <-client.Messages():
+			if !strings.Contains(string(msg), `"report.created"`) {
+				t.Fatalf("unexpected message for unsubscribed client: %s", msg)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for broadcast")
+		}
+	}
+}
+
+func TestPublishMessageReachesOnlyTheRecipients(t *testing.T) {
+	// 10.- El ciudadano destinatario y otra cuenta escuchan sus canales privados.
+	hub := NewHub(2, 2, 8, 2)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = hub.Shutdown(ctx)
+	})
+	recipient := hub.Register(&mockConn{}, UserChannel("vecino@example.com"))
+	other := hub.Register(&mockConn{}, UserChannel("vecina@example.com"))
+
+	// 11.- El mensaje del personal llega sin el correo de quien lo escribió.
+	message := service.ReportMessage{
+		ReportID:   "F-0004",
+		Sender:     service.MessageSenderStaff,
+		Author:     "operador@example.com",
+		Body:       "¿Número exterior?",
+		Recipients: []string{"vecino@example.com"},
+	}
+	if err := hub.PublishMessage(message); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+	select {
+	case msg := <-recipient.Messages():
+		if !strings.Contains(string(msg), `"report.message"`) || strings.Contains(string(msg), "operador@example.com") {
+			t.Fatalf("unexpected message payload %s", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timeout waiting for message")
+	}
+
+	// 12.- La otra cuenta recibe primero la difusión posterior.
+	if err := hub.BroadcastReport(service.Report{ID: "F-0005"}); err != nil {
+		t.Fatalf("broadcast failed: %v", err)
+	}
+	select {
+	case msg := <-other.Messages():
+		if !strings.Contains(string(msg), `"report.created"`) {
+			t.Fatalf("unexpected message for another account: %s", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timeout waiting for broadcast")
+	}
+}
+
+func TestUserConnectionsCloseOnRevocationAndExpiry(t *testing.T) {
+	// 13.- Dos dispositivos de la misma cuenta, otra cuenta con un token por vencer y un tablero público.
+	hub := NewHub(2, 2, 8, 2)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = hub.Shutdown(ctx)
+	})
+	valid := time.Now().Add(time.Hour)
+	phoneConn, tabletConn, expiringConn, publicConn := &mockConn{}, &mockConn{}, &mockConn{}, &mockConn{}
+	phone := hub.RegisterUser(phoneConn, service.Principal{Subject: "vecina@example.com", SessionID: "s1", ExpiresAt: valid})
+	tablet := hub.RegisterUser(tabletConn, service.Principal{Subject: "vecina@example.com", SessionID: "s2", ExpiresAt: valid})
+	expiring := hub.RegisterUser(expiringConn, service.Principal{Subject: "vecino@example.com", SessionID: "s3", ExpiresAt: time.Now().Add(50 * time.Millisecond)})
+	hub.Register(publicConn)
+	if !phone.subscribed(UserChannel("vecina@example.com")) {
+		t.Fatalf("expected the user channel to be subscribed")
+	}
+
+	// 14.- Revocar una sesión sólo cierra su conexión; revocar la cuenta cierra las demás.
+	hub.DisconnectSession("s1")
+	if !phoneConn.closed.Load() || tabletConn.closed.Load() {
+		t.Fatalf("expected only the revoked session to close")
+	}
+	hub.DisconnectSubject("vecina@example.com")
+	if !tabletConn.closed.Load() {
+		t.Fatalf("expected every connection of the subject to close")
+	}
+	if _, open := <-tablet.Messages(); open {
+		t.Fatalf("expected the outbound buffer to be closed")
+	}
+
+	// 15.- La conexión cuyo token vence se cierra sola; el tablero público sigue abierto.
+	select {
+	case _, open := <-expiring.Messages():
+		if open {
+			t.Fatalf("unexpected message before expiry")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timeout waiting for the expired connection to close")
+	}
+	if !expiringConn.closed.Load() || publicConn.closed.Load() {
+		t.Fatalf("expected only the expired connection to close")
+	}
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"citizenapp/backend/internal/service"
+)
+
+// 1.- PostgresAPIKeyStore implementa service.APIKeyStore guardando sólo el hash de cada clave.
+type PostgresAPIKeyStore struct {
+	db *sql.DB
+}
+
+// 2.- NewPostgresAPIKeyStore valida la conexión inyectada.
+func NewPostgresAPIKeyStore(db *sql.DB) *PostgresAPIKeyStore {
+	if db == nil {
+		panic("postgres db is required")
+	}
+	return &PostgresAPIKeyStore{db: db}
+}
+
+const apiKeyColumns = "id, name, prefix, key_hash, scopes, rate_limit, created_by, created_at, expires_at, last_used_at, revoked_at"
+
+// 3.- CreateAPIKey registra la clave con sus alcances serializados en JSONB.
+func (s *PostgresAPIKeyStore) CreateAPIKey(ctx context.Context, key service.APIKey) error {
+	const query = `
+                INSERT INTO api_keys (id, name, prefix, key_hash, scopes, rate_limit, created_by, created_at, expires_at)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        `
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return fmt.Errorf("encode api key scopes: %w", err)
+	}
+	var expiresAt sql.NullTime
+	if key.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: *key.ExpiresAt, Valid: true}
+	}
+	_, err = s.db.ExecContext(ctx, query,
+		key.ID, key.Name, key.Prefix, key.Hash, string(scopes), key.RateLimit, key.CreatedBy, key.CreatedAt, expiresAt,
+	)
+	return err
+}
+
+// 4.- FindAPIKeyByHash devuelve la clave aunque esté revocada o vencida; el servicio decide.
+func (s *PostgresAPIKeyStore) FindAPIKeyByHash(ctx context.Context, hash string) (service.APIKey, error) {
+	key, err := scanAPIKey(s.db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = $1", hash))
+	if err == sql.ErrNoRows {
+		return service.APIKey{}, service.ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+// 5.- ListAPIKeys omite las claves revocadas.
+func (s *PostgresAPIKeyStore) ListAPIKeys(ctx context.Context) ([]service.APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE revoked_at IS NULL ORDER BY created_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := make([]service.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// 6.- RevokeAPIKey marca la clave; revocar dos veces responde como no encontrada.
+func (s *PostgresAPIKeyStore) RevokeAPIKey(ctx context.Context, id string, at time.Time) error {
+	result, err := s.db.ExecContext(ctx, "UPDATE api_keys SET revoked_at = $2 WHERE id = $1 AND revoked_at IS NULL", id, at)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return service.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// 7.- TouchAPIKey guarda el último uso.
+func (s *PostgresAPIKeyStore) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = $2 WHERE id = $1", id, at)
+	return err
+}
+
+func scanAPIKey(row rowScanner) (service.APIKey, error) {
+	var (
+		key        service.APIKey
+		scopes     []byte
+		expiresAt  sql.NullTime
+		lastUsedAt sql.NullTime
+		revokedAt  sql.NullTime
+	)
+	err := row.Scan(
+		&key.ID, &key.Name, &key.Prefix, &key.Hash, &scopes, &key.RateLimit, &key.CreatedBy, &key.CreatedAt,
+		&expiresAt, &lastUsedAt, &revokedAt,
+	)
+	if err != nil {
+		return service.APIKey{}, err
+	}
+	if err := json.Unmarshal(scopes, &key.Scopes); err != nil {
+		return service.APIKey{}, fmt.Errorf("decode api key scopes: %w", err)
+	}
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = revokedAt.Time
+	}
+	return key, nil
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"citizenapp/backend/internal/service"
+)
+
+// 1.- PostgresDepartmentStore implementa service.DepartmentStore sobre las tablas departments y crews.
+type PostgresDepartmentStore struct {
+	db *sql.DB
+}
+
+// 2.- NewPostgresDepartmentStore valida la conexión inyectada.
+func NewPostgresDepartmentStore(db *sql.DB) *PostgresDepartmentStore {
+	if db == nil {
+		panic("postgres db is required")
+	}
+	return &PostgresDepartmentStore{db: db}
+}
+
+// 3.- CreateDepartment inserta la dependencia; un nombre repetido responde ErrDepartmentExists.
+func (s *PostgresDepartmentStore) CreateDepartment(ctx context.Context, department service.Department) error {
+	const query = `
+                INSERT INTO departments (id, name, created_at)
+                VALUES ($1, $2, $3)
+                ON CONFLICT DO NOTHING
+        `
+	return insertOnce(ctx, s.db, service.ErrDepartmentExists, query, department.ID, department.Name, department.CreatedAt)
+}
+
+// 4.- CreateCrew inserta la cuadrilla; el nombre es único dentro de su dependencia.
+func (s *PostgresDepartmentStore) CreateCrew(ctx context.Context, crew service.Crew) error {
+	const query = `
+                INSERT INTO crews (id, department_id, name, created_at)
+                VALUES ($1, $2, $3, $4)
+                ON CONFLICT DO NOTHING
+        `
+	return insertOnce(ctx, s.db, service.ErrCrewExists, query, crew.ID, crew.DepartmentID, crew.Name, crew.CreatedAt)
+}
+
+// 5.- Departments arma el catálogo completo con dos consultas en lugar de una por dependencia.
+func (s *PostgresDepartmentStore) Departments(ctx context.Context) ([]service.Department, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, name, created_at FROM departments ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	departments := make([]service.Department, 0)
+	index := make(map[string]int)
+	for rows.Next() {
+		department := service.Department{Crews: []service.Crew{}}
+		if err := rows.Scan(&department.ID, &department.Name, &department.CreatedAt); err != nil {
+			return nil, err
+		}
+		index[department.ID] = len(departments)
+		departments = append(departments, department)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	crewRows, err := s.db.QueryContext(ctx, "SELECT id, department_id, name, created_at FROM crews ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer crewRows.Close()
+	for crewRows.Next() {
+		var crew service.Crew
+		if err := crewRows.Scan(&crew.ID, &crew.DepartmentID, &crew.Name, &crew.CreatedAt); err != nil {
+			return nil, err
+		}
+		if i, ok := index[crew.DepartmentID]; ok {
+			departments[i].Crews = append(departments[i].Crews, crew)
+		}
+	}
+	return departments, crewRows.Err()
+}
+
+// 6.- FindDepartment devuelve la dependencia sin sus cuadrillas o ErrDepartmentNotFound.
+func (s *PostgresDepartmentStore) FindDepartment(ctx context.Context, id string) (service.Department, error) {
+	department := service.Department{Crews: []service.Crew{}}
+	err := s.db.QueryRowContext(ctx, "SELECT id, name, created_at FROM departments WHERE id = $1", id).
+		Scan(&department.ID, &department.Name, &department.CreatedAt)
+	if err == sql.ErrNoRows {
+		return service.Department{}, service.ErrDepartmentNotFound
+	}
+	return department, err
+}
+
+// 7.- FindCrew devuelve la cuadrilla con su dependencia o ErrCrewNotFound.
+func (s *PostgresDepartmentStore) FindCrew(ctx context.Context, id string) (service.Crew, error) {
+	var crew service.Crew
+	err := s.db.QueryRowContext(ctx, "SELECT id, department_id, name, created_at FROM crews WHERE id = $1", id).
+		Scan(&crew.ID, &crew.DepartmentID, &crew.Name, &crew.CreatedAt)
+	if err == sql.ErrNoRows {
+		return service.Crew{}, service.ErrCrewNotFound
+	}
+	return crew, err
+}
+
+// insertOnce ejecuta un INSERT ... ON CONFLICT DO NOTHING y traduce la fila omitida al error indicado.
+func insertOnce(ctx context.Context, db *sql.DB, conflict error, query string, args ...any) error {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return conflict
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+// 1.- PostgresFolioSequence implementa service.FolioSequence con una secuencia nativa.
+type PostgresFolioSequence struct {
+	db *sql.DB
+}
+
+// 2.- NewPostgresFolioSequence inyecta la conexión compartida por las instancias.
+func NewPostgresFolioSequence(db *sql.DB) *PostgresFolioSequence {
+	if db == nil {
+		panic("postgres db is required")
+	}
+	return &PostgresFolioSequence{db: db}
+}
+
+// 3.- NextValue delega en nextval, que nunca repite valores entre transacciones.
+func (s *PostgresFolioSequence) NextValue(ctx context.Context) (int64, error) {
+	var value int64
+	if err := s.db.QueryRowContext(ctx, "SELECT nextval('report_folio_seq')").Scan(&value); err != nil {
+		return 0, err
+	}
+	return value, nil
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"citizenapp/backend/internal/service"
+)
+
+// 1.- PostgresIdentityRepository implementa service.IdentityRepository sobre user_identities.
+type PostgresIdentityRepository struct {
+	db *sql.DB
+}
+
+// 2.- NewPostgresIdentityRepository valida la conexión inyectada.
+func NewPostgresIdentityRepository(db *sql.DB) *PostgresIdentityRepository {
+	if db == nil {
+		panic("postgres db is required")
+	}
+	return &PostgresIdentityRepository{db: db}
+}
+
+// 3.- FindIdentity busca el vínculo por proveedor y sujeto estable.
+func (r *PostgresIdentityRepository) FindIdentity(ctx context.Context, provider, subject string) (service.Identity, error) {
+	const query = `
+                SELECT provider, subject, email, created_at
+                FROM user_identities
+                WHERE provider = $1 AND subject = $2
+        `
+	var identity service.Identity
+	err := r.db.QueryRowContext(ctx, query, provider, subject).Scan(&identity.Provider, &identity.Subject, &identity.Email, &identity.CreatedAt)
+	if err == sql.ErrNoRows {
+		return service.Identity{}, service.ErrIdentityNotFound
+	}
+	return identity, err
+}
+
+// 4.- LinkIdentity crea el vínculo; una carrera con otra solicitud idéntica no es un error.
+func (r *PostgresIdentityRepository) LinkIdentity(ctx context.Context, identity service.Identity) error {
+	const query = `
+                INSERT INTO user_identities (provider, subject, email, created_at)
+                VALUES ($1, $2, $3, $4)
+                ON CONFLICT (provider, subject) DO NOTHING
+        `
+	_, err := r.db.ExecContext(ctx, query, identity.Provider, identity.Subject, identity.Email, identity.CreatedAt)
+	return err
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"citizenapp/backend/internal/service"
+)
+
+// 1.- PostgresLoginAttemptStore implementa service.LoginAttemptStore compartido entre instancias.
+type PostgresLoginAttemptStore struct {
+	db *sql.DB
+}
+
+// 2.- NewPostgresLoginAttemptStore valida la conexión inyectada.
+func NewPostgresLoginAttemptStore(db *sql.DB) *PostgresLoginAttemptStore {
+	if db == nil {
+		panic("postgres db is required")
+	}
+	return &PostgresLoginAttemptStore{db: db}
+}
+
+// 3.- Lookup devuelve el contador de la llave o un intento vacío.
+func (s *PostgresLoginAttemptStore) Lookup(ctx context.Context, key string) (service.LoginAttempt, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT key, failures, last_failure_at, locked_until FROM login_attempts WHERE key = $1", key)
+	attempt, err := scanLoginAttempt(row)
+	if err == sql.ErrNoRows {
+		return service.LoginAttempt{Key: key}, nil
+	}
+	return attempt, err
+}
+
+// 4.- RecordFailure incrementa el contador en una sola sentencia para tolerar intentos concurrentes.
+func (s *PostgresLoginAttemptStore) RecordFailure(ctx context.Context, key string, now, windowStart time.Time) (service.LoginAttempt, error) {
+	const query = `
+                INSERT INTO login_attempts (key, failures, last_failure_at)
+                VALUES ($1, 1, $2)
+                ON CONFLICT (key) DO UPDATE
+                SET failures = CASE
+                        WHEN GREATEST(login_attempts.last_failure_at, COALESCE(login_attempts.locked_until, login_attempts.last_failure_at)) < $3 THEN 1
+                        ELSE login_attempts.failures + 1
+                    END,
+                    last_failure_at = EXCLUDED.last_failure_at
+                RETURNING key, failures, last_failure_at, locked_until
+        `
+	return scanLoginAttempt(s.db.QueryRowContext(ctx, query, key, now, windowStart))
+}
+
+// 5.- Lock fija el fin del bloqueo sin acortar uno más largo ya vigente.
+func (s *PostgresLoginAttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	const query = `
+                UPDATE login_attempts
+                SET locked_until = GREATEST(COALESCE(locked_until, $2), $2)
+                WHERE key = $1
+        `
+	_, err := s.db.ExecContext(ctx, query, key, until)
+	return err
+}
+
+// 6.- Reset elimina el contador tras un inicio exitoso o un desbloqueo administrativo.
+func (s *PostgresLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM login_attempts WHERE key = $1", key)
+	return err
+}
+
+// 7.- PurgeExpired borra contadores sin actividad ni bloqueo desde before.
+func (s *PostgresLoginAttemptStore) PurgeExpired(ctx context.Context, before time.Time) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM login_attempts WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < $1)", before)
+	return err
+}
+
+func scanLoginAttempt(row *sql.Row) (service.LoginAttempt, error) {
+	var (
+		attempt     service.LoginAttempt
+		lockedUntil sql.NullTime
+	)
+	if err := row.Scan(&attempt.Key, &attempt.Failures, &attempt.LastFailure, &lockedUntil); err != nil {
+		return service.LoginAttempt{}, err
+	}
+	if lockedUntil.Valid {
+		attempt.LockedUntil = lockedUntil.Time
+	}
+	return attempt, nil
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"citizenapp/backend/internal/service"
+)
+
+// 1.- PostgresMessageStore implementa service.MessageStore sobre la tabla report_messages.
+type PostgresMessageStore struct {
+	db *sql.DB
+}
+
+// 2.- NewPostgresMessageStore valida la conexión inyectada.
+func NewPostgresMessageStore(db *sql.DB) *PostgresMessageStore {
+	if db == nil {
+		panic("postgres db is required")
+	}
+	return &PostgresMessageStore{db: db}
+}
+
+// 3.- CreateMessage guarda el mensaje como no leído.
+func (s *PostgresMessageStore) CreateMessage(ctx context.Context, message service.ReportMessage) error {
+	const query = `
+                INSERT INTO report_messages (id, report_id, sender, author, body, created_at)
+                VALUES ($1, $2, $3, $4, $5, $6)
+        `
+	_, err := s.db.ExecContext(ctx, query, message.ID, message.ReportID, message.Sender, message.Author, message.Body, message.CreatedAt)
+	return err
+}
+
+// 4.- Messages devuelve la conversación del reporte en orden cronológico.
+func (s *PostgresMessageStore) Messages(ctx context.Context, reportID string) ([]service.ReportMessage, error) {
+	const query = `
+                SELECT id, report_id, sender, author, body, created_at, read_at
+                FROM report_messages
+                WHERE report_id = $1
+                ORDER BY created_at ASC, id ASC
+        `
+	rows, err := s.db.QueryContext(ctx, query, reportID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	messages := make([]service.ReportMessage, 0)
+	for rows.Next() {
+		var message service.ReportMessage
+		var read sql.NullTime
+		if err := rows.Scan(&message.ID, &message.ReportID, &message.Sender, &message.Author, &message.Body, &message.CreatedAt, &read); err != nil {
+			return nil, err
+		}
+		if read.Valid {
+			message.ReadAt = &read.Time
+		}
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
+
+// 5.- MarkRead sella los mensajes pendientes del lado indicado.
+func (s *PostgresMessageStore) MarkRead(ctx context.Context, reportID, sender string, at time.Time) error {
+	const query = `
+                UPDATE report_messages
+                SET read_at = $3
+                WHERE report_id = $1 AND sender = $2 AND read_at IS NULL
+        `
+	_, err := s.db.ExecContext(ctx, query, reportID, sender, at)
+	return err
+}
+
+// 6.- UnreadCount cuenta los mensajes del lado indicado que siguen sin leerse.
+func (s *PostgresMessageStore) UnreadCount(ctx context.Context, reportID, sender string) (int, error) {
+	const query = `
+                SELECT COUNT(*)
+                FROM report_messages
+                WHERE report_id = $1 AND sender = $2 AND read_at IS NULL
+        `
+	var count int
+	err := s.db.QueryRowContext(ctx, query, reportID, sender).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"citizenapp/backend/internal/service"
+)
+
+// 1.- PostgresMFARepository implementa service.MFARepository con secretos y códigos por usuario.
+type PostgresMFARepository struct {
+	db *sql.DB
+}
+
+// 2.- NewPostgresMFARepository valida la conexión inyectada.
+func NewPostgresMFARepository(db *sql.DB) *PostgresMFARepository {
+	if db == nil {
+		panic("postgres db is required")
+	}
+	return &PostgresMFARepository{db: db}
+}
+
+// 3.- FindMFA devuelve la inscripción del usuario o ErrMFANotEnrolled.
+func (r *PostgresMFARepository) FindMFA(ctx context.Context, subject string) (service.MFAEnrollment, error) {
+	const query = `
+                SELECT email, secret, confirmed_at, last_used_step, created_at
+                FROM user_mfa
+                WHERE email = $1
+        `
+	var (
+		enrollment  service.MFAEnrollment
+		confirmedAt sql.NullTime
+	)
+	err := r.db.QueryRowContext(ctx, query, subject).Scan(
+		&enrollment.Subject, &enrollment.Secret, &confirmedAt, &enrollment.LastStep, &enrollment.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return service.MFAEnrollment{}, service.ErrMFANotEnrolled
+	}
+	if err != nil {
+		return service.MFAEnrollment{}, err
+	}
+	if confirmedAt.Valid {
+		enrollment.ConfirmedAt = confirmedAt.Time
+	}
+	return enrollment, nil
+}
+
+// 4.- SaveMFA reemplaza el secreto pendiente sin tocar una inscripción confirmada.
+func (r *PostgresMFARepository) SaveMFA(ctx context.Context, enrollment service.MFAEnrollment) error {
+	const query = `
+                INSERT INTO user_mfa (email, secret, last_used_step, created_at)
+                VALUES ($1, $2, $3, $4)
+                ON CONFLICT (email) DO UPDATE
+                SET secret = EXCLUDED.secret,
+                    last_used_step = EXCLUDED.last_used_step,
+                    created_at = EXCLUDED.created_at
+                WHERE user_mfa.confirmed_at IS NULL
+        `
+	result, err := r.db.ExecContext(ctx, query, enrollment.Subject, enrollment.Secret, enrollment.LastStep, enrollment.CreatedAt)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return service.ErrMFAAlreadyEnrolled
+	}
+	return nil
+}
+
+// 5.- ConfirmMFA activa el secreto y reemplaza los códigos de recuperación en una transacción.
+func (r *PostgresMFARepository) ConfirmMFA(ctx context.Context, subject string, at time.Time, recoveryHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, "UPDATE user_mfa SET confirmed_at = $2 WHERE email = $1 AND confirmed_at IS NULL", subject, at)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		tx.Rollback()
+		if err != nil {
+			return err
+		}
+		return service.ErrMFAAlreadyEnrolled
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE email = $1", subject); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, hash := range recoveryHashes {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO mfa_recovery_codes (email, code_hash) VALUES ($1, $2)", subject, hash); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// 6.- UseTOTPStep avanza el último paso usado sólo si el nuevo es posterior, evitando repeticiones.
+func (r *PostgresMFARepository) UseTOTPStep(ctx context.Context, subject string, step int64) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE user_mfa SET last_used_step = $2 WHERE email = $1 AND last_used_step < $2", subject, step)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return service.ErrInvalidMFACode
+	}
+	return nil
+}
+
+// 7.- ConsumeRecoveryCode marca el código como usado en una sola sentencia.
+func (r *PostgresMFARepository) ConsumeRecoveryCode(ctx context.Context, subject, codeHash string, at time.Time) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE mfa_recovery_codes SET used_at = $3 WHERE email = $1 AND code_hash = $2 AND used_at IS NULL", subject, codeHash, at)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return service.ErrInvalidMFACode
+	}
+	return nil
+}
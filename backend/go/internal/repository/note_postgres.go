@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"citizenapp/backend/internal/service"
+)
+
+// 1.- PostgresNoteStore implementa service.NoteStore sobre la tabla report_staff_notes.
+type PostgresNoteStore struct {
+	db *sql.DB
+}
+
+// 2.- NewPostgresNoteStore valida la conexión inyectada.
+func NewPostgresNoteStore(db *sql.DB) *PostgresNoteStore {
+	if db == nil {
+		panic("postgres db is required")
+	}
+	return &PostgresNoteStore{db: db}
+}
+
+// 3.- CreateNote guarda la nota con menciones y adjuntos como JSONB.
+func (s *PostgresNoteStore) CreateNote(ctx context.Context, note service.StaffNote) error {
+	const query = `
+                INSERT INTO report_staff_notes (id, report_id, author, body, mentions, attachments, created_at)
+                VALUES ($1, $2, $3, $4, $5, $6, $7)
+        `
+	mentions, attachments, err := encodeNoteLists(note)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, query, note.ID, note.ReportID, note.Author, note.Body, mentions, attachments, note.CreatedAt)
+	return err
+}
+
+// 4.- Notes devuelve las notas del reporte en orden cronológico.
+func (s *PostgresNoteStore) Notes(ctx context.Context, reportID string) ([]service.StaffNote, error) {
+	const query = `
+                SELECT id, report_id, author, body, mentions, attachments, created_at, edited_at
+                FROM report_staff_notes
+                WHERE report_id = $1
+                ORDER BY created_at ASC, id ASC
+        `
+	rows, err := s.db.QueryContext(ctx, query, reportID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	notes := make([]service.StaffNote, 0)
+	for rows.Next() {
+		note, err := scanNote(rows)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, note)
+	}
+	return notes, rows.Err()
+}
+
+// 5.- FindNote busca la nota dentro del reporte indicado o devuelve ErrNoteNotFound.
+func (s *PostgresNoteStore) FindNote(ctx context.Context, reportID, id string) (service.StaffNote, error) {
+	const query = `
+                SELECT id, report_id, author, body, mentions, attachments, created_at, edited_at
+                FROM report_staff_notes
+                WHERE report_id = $1 AND id = $2
+        `
+	note, err := scanNote(s.db.QueryRowContext(ctx, query, reportID, id))
+	if err == sql.ErrNoRows {
+		return service.StaffNote{}, service.ErrNoteNotFound
+	}
+	return note, err
+}
+
+// 6.- UpdateNote reemplaza el contenido editable y la fecha de edición.
+func (s *PostgresNoteStore) UpdateNote(ctx context.Context, note service.StaffNote) error {
+	const query = `
+                UPDATE report_staff_notes
+                SET body = $3, mentions = $4, attachments = $5, edited_at = $6
+                WHERE report_id = $1 AND id = $2
+        `
+	mentions, attachments, err := encodeNoteLists(note)
+	if err != nil {
+		return err
+	}
+	result, err := s.db.ExecContext(ctx, query, note.ReportID, note.ID, note.Body, mentions, attachments, note.EditedAt)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return service.ErrNoteNotFound
+	}
+	return nil
+}
+
+// encodeNoteLists serializa menciones y adjuntos para las columnas JSONB.
+func encodeNoteLists(note service.StaffNote) (string, string, error) {
+	mentions, err := encodeEvidence(note.Mentions)
+	if err != nil {
+		return "", "", err
+	}
+	attachments, err := encodeEvidence(note.Attachments)
+	if err != nil {
+		return "", "", err
+	}
+	return mentions, attachments, nil
+}
+
+// scanNote materializa una nota a partir de las columnas consultadas.
+func scanNote(row rowScanner) (service.StaffNote, error) {
+	var note service.StaffNote
+	var mentions, attachments []byte
+	var edited sql.NullTime
+	if err := row.Scan(&note.ID, &note.ReportID, &note.Author, &note.Body, &mentions, &attachments, &note.CreatedAt, &edited); err != nil {
+		return service.StaffNote{}, err
+	}
+	if err := json.Unmarshal(mentions, &note.Mentions); err != nil {
+		return service.StaffNote{}, fmt.Errorf("decode note mentions: %w", err)
+	}
+	if err := json.Unmarshal(attachments, &note.Attachments); err != nil {
+		return service.StaffNote{}, fmt.Errorf("decode note attachments: %w", err)
+	}
+	if edited.Valid {
+		note.EditedAt = &edited.Time
+	}
+	return note, nil
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"citizenapp/backend/internal/service"
+)
+
+// 1.- PostgresOneTimeTokenStore implementa service.OneTimeTokenStore guardando sólo hashes.
+type PostgresOneTimeTokenStore struct {
+	db *sql.DB
+}
+
+// 2.- NewPostgresOneTimeTokenStore valida la conexión inyectada.
+func NewPostgresOneTimeTokenStore(db *sql.DB) *PostgresOneTimeTokenStore {
+	if db == nil {
+		panic("postgres db is required")
+	}
+	return &PostgresOneTimeTokenStore{db: db}
+}
+
+// 3.- Create registra el token pendiente.
+func (s *PostgresOneTimeTokenStore) Create(ctx context.Context, token service.OneTimeToken) error {
+	const query = `
+                INSERT INTO one_time_tokens (id, purpose, subject, token_hash, expires_at, created_at)
+                VALUES ($1, $2, $3, $4, $5, $6)
+        `
+	_, err := s.db.ExecContext(ctx, query, token.ID, token.Purpose, token.Subject, token.TokenHash, token.ExpiresAt, token.CreatedAt)
+	return err
+}
+
+// 4.- Consume marca el token como usado en una sola sentencia para evitar dobles usos.
+func (s *PostgresOneTimeTokenStore) Consume(ctx context.Context, purpose, tokenHash string, now time.Time) (service.OneTimeToken, error) {
+	const query = `
+                UPDATE one_time_tokens
+                SET used_at = $3
+                WHERE purpose = $1
+                  AND token_hash = $2
+                  AND used_at IS NULL
+                  AND expires_at > $3
+                RETURNING id, purpose, subject, token_hash, expires_at, created_at
+        `
+	var token service.OneTimeToken
+	err := s.db.QueryRowContext(ctx, query, purpose, tokenHash, now).Scan(
+		&token.ID, &token.Purpose, &token.Subject, &token.TokenHash, &token.ExpiresAt, &token.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return service.OneTimeToken{}, service.ErrInvalidOneTimeToken
+	}
+	return token, err
+}
+
+// 5.- InvalidateSubject descarta los tokens pendientes al emitir uno nuevo.
+func (s *PostgresOneTimeTokenStore) InvalidateSubject(ctx context.Context, purpose, subject string, now time.Time) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE one_time_tokens SET used_at = $3 WHERE purpose = $1 AND subject = $2 AND used_at IS NULL", purpose, subject, now)
+	return err
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"citizenapp/backend/internal/service"
+)
+
+// 1.- PostgresRefreshTokenStore implementa service.RefreshTokenStore guardando sólo hashes.
+type PostgresRefreshTokenStore struct {
+	db *sql.DB
+}
+
+// 2.- NewPostgresRefreshTokenStore valida la conexión inyectada.
+func NewPostgresRefreshTokenStore(db *sql.DB) *PostgresRefreshTokenStore {
+	if db == nil {
+		panic("postgres db is required")
+	}
+	return &PostgresRefreshTokenStore{db: db}
+}
+
+// 3.- Create registra un nuevo token dentro de su familia con el contexto del inicio de sesión.
+func (s *PostgresRefreshTokenStore) Create(ctx context.Context, token service.RefreshToken) error {
+	const query = `
+                INSERT INTO refresh_tokens (id, family_id, subject, token_hash, expires_at, created_at, amr, auth_time)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        `
+	methods := token.AuthMethods
+	if methods == nil {
+		methods = []string{}
+	}
+	amr, err := json.Marshal(methods)
+	if err != nil {
+		return fmt.Errorf("encode refresh token amr: %w", err)
+	}
+	var authTime sql.NullTime
+	if !token.AuthTime.IsZero() {
+		authTime = sql.NullTime{Time: token.AuthTime, Valid: true}
+	}
+	_, err = s.db.ExecContext(ctx, query,
+		token.ID, token.FamilyID, token.Subject, token.TokenHash, token.ExpiresAt, token.CreatedAt, string(amr), authTime,
+	)
+	return err
+}
+
+// 4.- Consume marca el token como usado de forma atómica y detecta reutilizaciones.
+func (s *PostgresRefreshTokenStore) Consume(ctx context.Context, tokenHash string, now time.Time) (service.RefreshToken, error) {
+	const consumeQuery = `
+                UPDATE refresh_tokens
+                SET used_at = $2
+                WHERE token_hash = $1
+                  AND used_at IS NULL
+                  AND revoked_at IS NULL
+                  AND expires_at > $2
+                RETURNING id, family_id, subject, token_hash, expires_at, created_at, amr, auth_time
+        `
+	token, err := scanRefreshToken(s.db.QueryRowContext(ctx, consumeQuery, tokenHash, now))
+	if err == nil {
+		return token, nil
+	}
+	if err != sql.ErrNoRows {
+		return service.RefreshToken{}, err
+	}
+	const inspectQuery = `
+                SELECT id, family_id, subject, token_hash, expires_at, created_at, used_at IS NOT NULL
+                FROM refresh_tokens
+                WHERE token_hash = $1
+        `
+	var used bool
+	err = s.db.QueryRowContext(ctx, inspectQuery, tokenHash).Scan(
+		&token.ID, &token.FamilyID, &token.Subject, &token.TokenHash, &token.ExpiresAt, &token.CreatedAt, &used,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return service.RefreshToken{}, service.ErrInvalidRefreshToken
+		}
+		return service.RefreshToken{}, err
+	}
+	if used {
+		return token, service.ErrRefreshTokenReused
+	}
+	return service.RefreshToken{}, service.ErrInvalidRefreshToken
+}
+
+// 5.- RevokeFamily invalida todos los tokens emitidos a partir del mismo inicio de sesión.
+func (s *PostgresRefreshTokenStore) RevokeFamily(ctx context.Context, familyID string, now time.Time) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = $2 WHERE family_id = $1 AND revoked_at IS NULL", familyID, now)
+	return err
+}
+
+// 5.1.- RevokeSubject invalida todas las familias del usuario, por ejemplo al cerrar todas sus sesiones.
+func (s *PostgresRefreshTokenStore) RevokeSubject(ctx context.Context, subject string, now time.Time) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = $2 WHERE subject = $1 AND revoked_at IS NULL", subject, now)
+	return err
+}
+
+// 6.- scanRefreshToken lee las columnas de refresh_tokens, incluido el contexto del inicio de sesión.
+func scanRefreshToken(row rowScanner) (service.RefreshToken, error) {
+	var (
+		token    service.RefreshToken
+		amr      []byte
+		authTime sql.NullTime
+	)
+	err := row.Scan(&token.ID, &token.FamilyID, &token.Subject, &token.TokenHash, &token.ExpiresAt, &token.CreatedAt, &amr, &authTime)
+	if err != nil {
+		return service.RefreshToken{}, err
+	}
+	if err := json.Unmarshal(amr, &token.AuthMethods); err != nil {
+		return service.RefreshToken{}, fmt.Errorf("decode refresh token amr: %w", err)
+	}
+	if authTime.Valid {
+		token.AuthTime = authTime.Time
+	}
+	return token, nil
+}
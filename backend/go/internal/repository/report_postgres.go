@@ -3,12 +3,37 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"citizenapp/backend/internal/service"
 )
 
+// reportColumns enumera las columnas leídas por scanReport en el mismo orden.
+const reportColumns = `
+                        id,
+                        incident_type_id,
+                        incident_type_name,
+                        incident_type_requires_evidence,
+                        description,
+                        latitude,
+                        longitude,
+                        address,
+                        evidence_urls,
+                        contact_email,
+                        contact_phone,
+                        status,
+                        reporter,
+                        anonymous,
+                        department_id,
+                        crew_id,
+                        assigned_at,
+                        created_at,
+                        status_changed_at
+`
+
 // 1.- PostgresReportRepository implementa service.ReportRepository con SQL estándar.
 type PostgresReportRepository struct {
 	db *sql.DB
@@ -22,8 +47,8 @@ func NewPostgresReportRepository(db *sql.DB) *PostgresReportRepository {
 	return &PostgresReportRepository{db: db}
 }
 
-// 3.- Create inserta el reporte y devuelve el registro almacenado.
-func (r *PostgresReportRepository) Create(ctx context.Context, report service.Report) (service.Report, error) {
+// 3.- Create inserta el reporte y su evento inicial dentro de una transacción.
+func (r *PostgresReportRepository) Create(ctx context.Context, report service.Report, event service.ReportEvent) (service.Report, error) {
 	const query = `
                 INSERT INTO reports (
                         id,
@@ -33,14 +58,29 @@ func (r *PostgresReportRepository) Create(ctx context.Context, report service.Re
                         description,
                         latitude,
                         longitude,
+                        address,
+                        evidence_urls,
+                        contact_email,
+                        contact_phone,
                         status,
-                        created_at
-                ) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+                        reporter,
+                        anonymous,
+                        created_at,
+                        status_changed_at
+                ) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,NULLIF($13, ''),$14,$15,$16)
                 RETURNING incident_type_name, incident_type_requires_evidence
         `
+	evidence, err := encodeEvidence(report.EvidenceURLs)
+	if err != nil {
+		return service.Report{}, err
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return service.Report{}, err
+	}
 	var name string
 	var requires bool
-	err := r.db.QueryRowContext(
+	err = tx.QueryRowContext(
 		ctx,
 		query,
 		report.ID,
@@ -50,63 +90,71 @@ func (r *PostgresReportRepository) Create(ctx context.Context, report service.Re
 		report.Description,
 		report.Latitude,
 		report.Longitude,
+		report.Address,
+		evidence,
+		report.ContactEmail,
+		report.ContactPhone,
 		report.Status,
+		report.Reporter,
+		report.Anonymous,
 		report.CreatedAt,
+		report.StatusChangedAt,
 	).Scan(&name, &requires)
 	if err != nil {
+		tx.Rollback()
+		return service.Report{}, err
+	}
+	if _, err := insertEvent(ctx, tx, event); err != nil {
+		tx.Rollback()
+		return service.Report{}, err
+	}
+	if err := tx.Commit(); err != nil {
 		return service.Report{}, err
 	}
 	report.IncidentType.Name = name
 	report.IncidentType.RequiresEvidence = requires
+	if report.EvidenceURLs == nil {
+		report.EvidenceURLs = []string{}
+	}
 	return report, nil
 }
 
 // 4.- FindByID obtiene el reporte persistido o ErrReportNotFound.
 func (r *PostgresReportRepository) FindByID(ctx context.Context, id string) (service.Report, error) {
-	const query = `
-                SELECT
-                        id,
-                        incident_type_id,
-                        incident_type_name,
-                        incident_type_requires_evidence,
-                        description,
-                        latitude,
-                        longitude,
-                        status,
-                        created_at
-                FROM reports
-                WHERE id = $1
-        `
-	var report service.Report
-	var created time.Time
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&report.ID,
-		&report.IncidentType.ID,
-		&report.IncidentType.Name,
-		&report.IncidentType.RequiresEvidence,
-		&report.Description,
-		&report.Latitude,
-		&report.Longitude,
-		&report.Status,
-		&created,
-	)
+	query := "SELECT " + reportColumns + " FROM reports WHERE id = $1"
+	report, err := scanReport(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return service.Report{}, service.ErrReportNotFound
 		}
 		return service.Report{}, err
 	}
-	report.CreatedAt = created
 	return report, nil
 }
 
-// 5.- List devuelve los reportes paginados junto con el conteo total.
-func (r *PostgresReportRepository) List(ctx context.Context, page, pageSize int, status string) ([]service.Report, int, error) {
+// 5.- List devuelve los reportes paginados junto con el conteo total; los filtros se combinan con AND.
+func (r *PostgresReportRepository) List(ctx context.Context, filter service.ReportFilter) ([]service.Report, int, error) {
 	baseArgs := []any{}
+	conditions := []string{}
+	if filter.Status != "" {
+		baseArgs = append(baseArgs, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(baseArgs)))
+	}
+	if filter.Reporter != "" {
+		baseArgs = append(baseArgs, filter.Reporter)
+		conditions = append(conditions, fmt.Sprintf("reporter = $%d", len(baseArgs)))
+	}
+	if filter.DepartmentID != "" {
+		baseArgs = append(baseArgs, filter.DepartmentID)
+		conditions = append(conditions, fmt.Sprintf("department_id = $%d", len(baseArgs)))
+	}
+	if filter.CrewID != "" {
+		baseArgs = append(baseArgs, filter.CrewID)
+		conditions = append(conditions, fmt.Sprintf("crew_id = $%d", len(baseArgs)))
+	}
 	whereClause := ""
-	if status != "" {
-		whereClause = " WHERE status = $1"
-		baseArgs = append(baseArgs, status)
+	if len(conditions) > 0 {
+		whereClause = " WHERE " + strings.Join(conditions, " AND ")
 	}
 	countQuery := "SELECT COUNT(*) FROM reports" + whereClause
 	var total int
@@ -116,26 +164,17 @@ func (r *PostgresReportRepository) List(ctx context.Context, page, pageSize int,
 	if total == 0 {
 		return []service.Report{}, 0, nil
 	}
-	offset := page * pageSize
+	offset := filter.Page * filter.PageSize
 	limitIndex := len(baseArgs) + 1
 	offsetIndex := len(baseArgs) + 2
 	listQuery := fmt.Sprintf(`
-                SELECT
-                        id,
-                        incident_type_id,
-                        incident_type_name,
-                        incident_type_requires_evidence,
-                        description,
-                        latitude,
-                        longitude,
-                        status,
-                        created_at
+                SELECT %s
                 FROM reports%s
                 ORDER BY created_at DESC
                 LIMIT $%d OFFSET $%d
-        `, whereClause, limitIndex, offsetIndex)
+        `, reportColumns, whereClause, limitIndex, offsetIndex)
 	args := append([]any{}, baseArgs...)
-	args = append(args, pageSize, offset)
+	args = append(args, filter.PageSize, offset)
 	rows, err := r.db.QueryContext(ctx, listQuery, args...)
 	if err != nil {
 		return nil, 0, err
@@ -143,22 +182,10 @@ func (r *PostgresReportRepository) List(ctx context.Context, page, pageSize int,
 	defer rows.Close()
 	reports := make([]service.Report, 0)
 	for rows.Next() {
-		var report service.Report
-		var created time.Time
-		if err := rows.Scan(
-			&report.ID,
-			&report.IncidentType.ID,
-			&report.IncidentType.Name,
-			&report.IncidentType.RequiresEvidence,
-			&report.Description,
-			&report.Latitude,
-			&report.Longitude,
-			&report.Status,
-			&created,
-		); err != nil {
+		report, err := scanReport(rows)
+		if err != nil {
 			return nil, 0, err
 		}
-		report.CreatedAt = created
 		reports = append(reports, report)
 	}
 	if err := rows.Err(); err != nil {
@@ -183,48 +210,230 @@ func (r *PostgresReportRepository) Delete(ctx context.Context, id string) error
 	return nil
 }
 
-// 7.- Lookup construye el seguimiento del folio reutilizando FindByID.
-func (r *PostgresReportRepository) Lookup(ctx context.Context, id string) (service.FolioStatus, error) {
-	report, err := r.FindByID(ctx, id)
+// 7.- AppendEvent agrega un evento a la bitácora si el reporte existe.
+func (r *PostgresReportRepository) AppendEvent(ctx context.Context, event service.ReportEvent) (service.ReportEvent, error) {
+	stored, err := insertEvent(ctx, r.db, event)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return service.ReportEvent{}, service.ErrReportNotFound
+		}
+		return service.ReportEvent{}, err
+	}
+	return stored, nil
+}
+
+// 7.1.- Events devuelve la bitácora del reporte en orden cronológico.
+func (r *PostgresReportRepository) Events(ctx context.Context, reportID string) ([]service.ReportEvent, error) {
+	const query = `
+                SELECT id, report_id, kind, status, actor, reason, COALESCE(department_id, ''), COALESCE(crew_id, ''), created_at
+                FROM report_events
+                WHERE report_id = $1
+                ORDER BY created_at ASC, id ASC
+        `
+	rows, err := r.db.QueryContext(ctx, query, reportID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := make([]service.ReportEvent, 0)
+	for rows.Next() {
+		var event service.ReportEvent
+		if err := rows.Scan(&event.ID, &event.ReportID, &event.Kind, &event.Status, &event.Actor, &event.Reason, &event.DepartmentID, &event.CrewID, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// 8.- UpdateStatus cambia el estatus sólo si nadie lo modificó y registra el evento en la misma transacción.
+func (r *PostgresReportRepository) UpdateStatus(ctx context.Context, fromStatus string, event service.ReportEvent) (service.Report, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return service.Report{}, err
+	}
+	updateQuery := "UPDATE reports SET status = $1, status_changed_at = $4 WHERE id = $2 AND status = $3 RETURNING " + reportColumns
+	report, err := scanReport(tx.QueryRowContext(ctx, updateQuery, event.Status, event.ReportID, fromStatus, event.CreatedAt))
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return service.Report{}, r.missingOrConflict(ctx, event.ReportID)
+		}
+		return service.Report{}, err
+	}
+	if _, err := insertEvent(ctx, tx, event); err != nil {
+		tx.Rollback()
+		return service.Report{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return service.Report{}, err
+	}
+	return report, nil
+}
+
+// 8.1.- Assign reemplaza la asignación vigente y registra el evento en la misma transacción.
+func (r *PostgresReportRepository) Assign(ctx context.Context, assignment service.Assignment, event service.ReportEvent) (service.Report, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return service.Report{}, err
+	}
+	updateQuery := "UPDATE reports SET department_id = $1, crew_id = NULLIF($2, ''), assigned_at = $3 WHERE id = $4 RETURNING " + reportColumns
+	report, err := scanReport(tx.QueryRowContext(ctx, updateQuery, assignment.DepartmentID, assignment.CrewID, assignment.AssignedAt, event.ReportID))
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return service.Report{}, service.ErrReportNotFound
+		}
+		return service.Report{}, err
+	}
+	if _, err := insertEvent(ctx, tx, event); err != nil {
+		tx.Rollback()
+		return service.Report{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return service.Report{}, err
+	}
+	return report, nil
+}
+
+// 9.- StatusCounts agrupa los reportes por estatus para que el flujo calcule métricas.
+func (r *PostgresReportRepository) StatusCounts(ctx context.Context) (map[string]int, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT status, COUNT(*) FROM reports GROUP BY status")
 	if err != nil {
-		return service.FolioStatus{}, err
-	}
-	status := service.FolioStatus{
-		Folio:      report.ID,
-		Status:     report.Status,
-		LastUpdate: time.Now(),
-		History: []string{
-			"Reporte recibido",
-			"Asignado a cuadrilla",
-		},
-	}
-	return status, nil
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts[status] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
 }
 
-// 8.- UpdateStatusWithMetrics utiliza una transacción para mantener consistencia.
-func (r *PostgresReportRepository) UpdateStatusWithMetrics(ctx context.Context, id, status string) (service.Report, service.AdminDashboardMetrics, error) {
+// 9.1.- AnonymizeReporter limpia contacto, actor y autoría de notas en una transacción para no dejar datos a medias.
+func (r *PostgresReportRepository) AnonymizeReporter(ctx context.Context, email, actor string) (int, error) {
+	const reports = `
+                UPDATE reports
+                SET contact_email = NULL, contact_phone = NULL, reporter = NULL
+                WHERE LOWER(contact_email) = $1
+                   OR reporter = $1
+                   OR id IN (SELECT report_id FROM report_events WHERE kind = 'submitted' AND actor = $1)
+        `
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return service.Report{}, service.AdminDashboardMetrics{}, err
+		return 0, err
 	}
-	const updateQuery = `
+	result, err := tx.ExecContext(ctx, reports, email)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE report_events SET actor = $2 WHERE actor = $1", email, actor); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE report_staff_notes SET author = $2 WHERE author = $1", email, actor); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE report_messages SET author = $2 WHERE author = $1", email, actor); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(affected), nil
+}
+
+// 9.2.- ClaimReports reasigna el autor y el evento de envío y quita la marca anónima en una transacción.
+func (r *PostgresReportRepository) ClaimReports(ctx context.Context, anonymousActor, email string) (int, error) {
+	const reports = `
                 UPDATE reports
-                SET status = $1
-                WHERE id = $2
-                RETURNING
-                        id,
-                        incident_type_id,
-                        incident_type_name,
-                        incident_type_requires_evidence,
-                        description,
-                        latitude,
-                        longitude,
-                        status,
-                        created_at
+                SET anonymous = FALSE, reporter = $2
+                WHERE reporter = $1
+                   OR id IN (SELECT report_id FROM report_events WHERE kind = 'submitted' AND actor = $1)
         `
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	result, err := tx.ExecContext(ctx, reports, anonymousActor, email)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE report_events SET actor = $2 WHERE actor = $1", anonymousActor, email); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(affected), nil
+}
+
+// 10.- missingOrConflict distingue un reporte inexistente de un estatus modificado en paralelo.
+func (r *PostgresReportRepository) missingOrConflict(ctx context.Context, id string) error {
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM reports WHERE id = $1)", id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return service.ErrReportNotFound
+	}
+	return service.ErrStatusConflict
+}
+
+// 10.1.- insertEvent escribe el evento sólo si el reporte existe, sin importar el ejecutor SQL.
+func insertEvent(ctx context.Context, runner interface {
+	QueryRowContext(context.Context, string, ...any) *sql.Row
+}, event service.ReportEvent) (service.ReportEvent, error) {
+	const query = `
+                INSERT INTO report_events (report_id, kind, status, actor, reason, department_id, crew_id, created_at)
+                SELECT id, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8 FROM reports WHERE id = $1
+                RETURNING id
+        `
+	if err := runner.QueryRowContext(ctx, query, event.ReportID, event.Kind, event.Status, event.Actor, event.Reason, event.DepartmentID, event.CrewID, event.CreatedAt).Scan(&event.ID); err != nil {
+		return service.ReportEvent{}, err
+	}
+	return event, nil
+}
+
+// 11.- rowScanner abstrae *sql.Row y *sql.Rows para reutilizar scanReport.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// 12.- scanReport materializa un reporte a partir de las columnas de reportColumns.
+func scanReport(row rowScanner) (service.Report, error) {
 	var report service.Report
 	var created time.Time
-	err = tx.QueryRowContext(ctx, updateQuery, status, id).Scan(
+	var evidence []byte
+	var contactEmail, contactPhone, reporter, departmentID, crewID sql.NullString
+	var assignedAt sql.NullTime
+	if err := row.Scan(
 		&report.ID,
 		&report.IncidentType.ID,
 		&report.IncidentType.Name,
@@ -232,52 +441,45 @@ func (r *PostgresReportRepository) UpdateStatusWithMetrics(ctx context.Context,
 		&report.Description,
 		&report.Latitude,
 		&report.Longitude,
+		&report.Address,
+		&evidence,
+		&contactEmail,
+		&contactPhone,
 		&report.Status,
+		&reporter,
+		&report.Anonymous,
+		&departmentID,
+		&crewID,
+		&assignedAt,
 		&created,
-	)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			tx.Rollback()
-			return service.Report{}, service.AdminDashboardMetrics{}, service.ErrReportNotFound
-		}
-		tx.Rollback()
-		return service.Report{}, service.AdminDashboardMetrics{}, err
+		&report.StatusChangedAt,
+	); err != nil {
+		return service.Report{}, err
 	}
 	report.CreatedAt = created
-	metrics, err := r.metricsFromTx(ctx, tx)
-	if err != nil {
-		tx.Rollback()
-		return service.Report{}, service.AdminDashboardMetrics{}, err
+	report.ContactEmail = contactEmail.String
+	report.ContactPhone = contactPhone.String
+	report.Reporter = reporter.String
+	if departmentID.Valid {
+		report.Assignment = &service.Assignment{DepartmentID: departmentID.String, CrewID: crewID.String, AssignedAt: assignedAt.Time}
 	}
-	if err := tx.Commit(); err != nil {
-		return service.Report{}, service.AdminDashboardMetrics{}, err
+	report.EvidenceURLs = []string{}
+	if len(evidence) > 0 {
+		if err := json.Unmarshal(evidence, &report.EvidenceURLs); err != nil {
+			return service.Report{}, fmt.Errorf("decode evidence urls: %w", err)
+		}
 	}
-	return report, metrics, nil
-}
-
-// 9.- Metrics recupera los totales agregados del almacenamiento.
-func (r *PostgresReportRepository) Metrics(ctx context.Context) (service.AdminDashboardMetrics, error) {
-	return r.metricsFromTx(ctx, r.db)
+	return report, nil
 }
 
-// 10.- metricsFromTx centraliza el cálculo de conteos según el contexto SQL.
-func (r *PostgresReportRepository) metricsFromTx(ctx context.Context, runner interface {
-	QueryRowContext(context.Context, string, ...any) *sql.Row
-}) (service.AdminDashboardMetrics, error) {
-	const query = `
-                SELECT
-                        COALESCE(SUM(CASE WHEN status = 'en_revision' THEN 1 ELSE 0 END), 0) AS pending,
-                        COALESCE(SUM(CASE WHEN status = 'resuelto' THEN 1 ELSE 0 END), 0) AS resolved,
-                        COALESCE(SUM(CASE WHEN status = 'critico' THEN 1 ELSE 0 END), 0) AS critical
-                FROM reports
-        `
-	var metrics service.AdminDashboardMetrics
-	row := runner.QueryRowContext(ctx, query)
-	if err := row.Scan(&metrics.PendingReports, &metrics.ResolvedReports, &metrics.CriticalIncidents); err != nil {
-		if err == sql.ErrNoRows {
-			return service.AdminDashboardMetrics{}, nil
-		}
-		return service.AdminDashboardMetrics{}, err
+// 13.- encodeEvidence serializa las URLs de evidencia para la columna JSONB.
+func encodeEvidence(urls []string) (string, error) {
+	if urls == nil {
+		urls = []string{}
+	}
+	encoded, err := json.Marshal(urls)
+	if err != nil {
+		return "", fmt.Errorf("encode evidence urls: %w", err)
 	}
-	return metrics, nil
+	return string(encoded), nil
 }
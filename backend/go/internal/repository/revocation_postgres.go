@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// 1.- PostgresRevocationStore implementa service.RevocationStore con expiración por fila.
+type PostgresRevocationStore struct {
+	db *sql.DB
+}
+
+// 2.- NewPostgresRevocationStore valida la conexión inyectada.
+func NewPostgresRevocationStore(db *sql.DB) *PostgresRevocationStore {
+	if db == nil {
+		panic("postgres db is required")
+	}
+	return &PostgresRevocationStore{db: db}
+}
+
+// 3.- RevokeToken agrega el jti a la lista de denegación hasta su expiración natural.
+func (s *PostgresRevocationStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	const query = `
+                INSERT INTO revoked_tokens (jti, expires_at)
+                VALUES ($1, $2)
+                ON CONFLICT (jti) DO NOTHING
+        `
+	_, err := s.db.ExecContext(ctx, query, jti, expiresAt)
+	return err
+}
+
+// 4.- IsTokenRevoked consulta si el jti sigue en la lista de denegación.
+func (s *PostgresRevocationStore) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1 AND expires_at > NOW())", jti).Scan(&revoked)
+	return revoked, err
+}
+
+// 5.- RevokeSubject guarda el corte más reciente para el usuario.
+func (s *PostgresRevocationStore) RevokeSubject(ctx context.Context, subject string, cutoff, until time.Time) error {
+	const query = `
+                INSERT INTO revoked_subjects (subject, revoked_before, expires_at)
+                VALUES ($1, $2, $3)
+                ON CONFLICT (subject) DO UPDATE
+                SET revoked_before = GREATEST(revoked_subjects.revoked_before, EXCLUDED.revoked_before),
+                    expires_at = GREATEST(revoked_subjects.expires_at, EXCLUDED.expires_at)
+        `
+	_, err := s.db.ExecContext(ctx, query, subject, cutoff, until)
+	return err
+}
+
+// 6.- SubjectCutoff devuelve el corte vigente o el tiempo cero.
+func (s *PostgresRevocationStore) SubjectCutoff(ctx context.Context, subject string, now time.Time) (time.Time, error) {
+	var cutoff time.Time
+	err := s.db.QueryRowContext(ctx, "SELECT revoked_before FROM revoked_subjects WHERE subject = $1 AND expires_at > $2", subject, now).Scan(&cutoff)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return cutoff, err
+}
+
+// 7.- PurgeExpired elimina las filas que ya no pueden afectar a ningún token vigente.
+func (s *PostgresRevocationStore) PurgeExpired(ctx context.Context, now time.Time) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at <= $1", now); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, "DELETE FROM revoked_subjects WHERE expires_at <= $1", now)
+	return err
+}
@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"citizenapp/backend/internal/service"
+)
+
+// 1.- PostgresSessionStore implementa service.SessionStore con una fila por dispositivo.
+type PostgresSessionStore struct {
+	db *sql.DB
+}
+
+// 2.- NewPostgresSessionStore valida la conexión inyectada.
+func NewPostgresSessionStore(db *sql.DB) *PostgresSessionStore {
+	if db == nil {
+		panic("postgres db is required")
+	}
+	return &PostgresSessionStore{db: db}
+}
+
+// 3.- CreateSession registra el inicio de sesión con los datos del dispositivo.
+func (s *PostgresSessionStore) CreateSession(ctx context.Context, session service.Session) error {
+	const query = `
+                INSERT INTO user_sessions (id, email, device_name, platform, ip, user_agent, created_at, last_seen_at)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        `
+	_, err := s.db.ExecContext(ctx, query,
+		session.ID, session.Subject, session.DeviceName, session.Platform, session.IP, session.UserAgent,
+		session.CreatedAt, session.LastSeenAt,
+	)
+	return err
+}
+
+// 4.- TouchSession actualiza la actividad sin reabrir sesiones revocadas.
+func (s *PostgresSessionStore) TouchSession(ctx context.Context, id string, at time.Time, ip string) error {
+	const query = `
+                UPDATE user_sessions
+                SET last_seen_at = $2,
+                    ip = CASE WHEN $3 = '' THEN ip ELSE $3 END
+                WHERE id = $1 AND revoked_at IS NULL
+        `
+	result, err := s.db.ExecContext(ctx, query, id, at, ip)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return service.ErrSessionNotFound
+	}
+	return nil
+}
+
+// 5.- FindSession devuelve la sesión aunque esté revocada; el servicio decide qué hacer.
+func (s *PostgresSessionStore) FindSession(ctx context.Context, id string) (service.Session, error) {
+	const query = `
+                SELECT id, email, device_name, platform, ip, user_agent, created_at, last_seen_at, revoked_at
+                FROM user_sessions
+                WHERE id = $1
+        `
+	session, err := scanSession(s.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return service.Session{}, service.ErrSessionNotFound
+	}
+	return session, err
+}
+
+// 6.- ListSessions ordena por actividad reciente para mostrar primero el dispositivo en uso.
+func (s *PostgresSessionStore) ListSessions(ctx context.Context, subject string, activeSince time.Time) ([]service.Session, error) {
+	const query = `
+                SELECT id, email, device_name, platform, ip, user_agent, created_at, last_seen_at, revoked_at
+                FROM user_sessions
+                WHERE email = $1 AND revoked_at IS NULL AND last_seen_at > $2
+                ORDER BY last_seen_at DESC
+        `
+	rows, err := s.db.QueryContext(ctx, query, subject, activeSince)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := make([]service.Session, 0)
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// 7.- RevokeSession marca la sesión y borra su registro push en una transacción.
+func (s *PostgresSessionStore) RevokeSession(ctx context.Context, id string, at time.Time) error {
+	return s.revoke(ctx, "id = $1", id, at)
+}
+
+// 7.1.- RevokeSubjectSessions cierra todos los dispositivos del usuario.
+func (s *PostgresSessionStore) RevokeSubjectSessions(ctx context.Context, subject string, at time.Time) error {
+	return s.revoke(ctx, "email = $1", subject, at)
+}
+
+func (s *PostgresSessionStore) revoke(ctx context.Context, condition, value string, at time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "UPDATE user_sessions SET revoked_at = $2 WHERE "+condition+" AND revoked_at IS NULL", value, at); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM push_registrations WHERE session_id IN (SELECT id FROM user_sessions WHERE "+condition+")", value); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// 8.- SavePushRegistration retira el token de otras sesiones (reinstalación) y reemplaza el de esta.
+func (s *PostgresSessionStore) SavePushRegistration(ctx context.Context, registration service.PushRegistration) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "DELETE FROM push_registrations WHERE token = $1 AND session_id <> $2", registration.Token, registration.SessionID); err != nil {
+		return err
+	}
+	const upsert = `
+                INSERT INTO push_registrations (session_id, provider, token, updated_at)
+                SELECT id, $2, $3, $4 FROM user_sessions WHERE id = $1 AND revoked_at IS NULL
+                ON CONFLICT (session_id) DO UPDATE
+                SET provider = EXCLUDED.provider,
+                    token = EXCLUDED.token,
+                    updated_at = EXCLUDED.updated_at
+        `
+	result, err := tx.ExecContext(ctx, upsert, registration.SessionID, registration.Provider, registration.Token, registration.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return service.ErrSessionNotFound
+	}
+	return tx.Commit()
+}
+
+// 9.- DeletePushRegistration es idempotente.
+func (s *PostgresSessionStore) DeletePushRegistration(ctx context.Context, sessionID string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM push_registrations WHERE session_id = $1", sessionID)
+	return err
+}
+
+// 10.- PushRegistrations lista los tokens de las sesiones vigentes del usuario.
+func (s *PostgresSessionStore) PushRegistrations(ctx context.Context, subject string) ([]service.PushRegistration, error) {
+	const query = `
+                SELECT p.session_id, s.email, p.provider, p.token, p.updated_at
+                FROM push_registrations p
+                JOIN user_sessions s ON s.id = p.session_id
+                WHERE s.email = $1 AND s.revoked_at IS NULL
+                ORDER BY p.updated_at DESC
+        `
+	rows, err := s.db.QueryContext(ctx, query, subject)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var registrations []service.PushRegistration
+	for rows.Next() {
+		var registration service.PushRegistration
+		if err := rows.Scan(&registration.SessionID, &registration.Subject, &registration.Provider, &registration.Token, &registration.UpdatedAt); err != nil {
+			return nil, err
+		}
+		registrations = append(registrations, registration)
+	}
+	return registrations, rows.Err()
+}
+
+// 11.- PurgeExpired borra las sesiones revocadas o inactivas desde before; sus registros push caen en cascada.
+func (s *PostgresSessionStore) PurgeExpired(ctx context.Context, before time.Time) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM user_sessions WHERE revoked_at < $1 OR last_seen_at < $1", before)
+	return err
+}
+
+func scanSession(row rowScanner) (service.Session, error) {
+	var (
+		session   service.Session
+		revokedAt sql.NullTime
+	)
+	err := row.Scan(
+		&session.ID, &session.Subject, &session.DeviceName, &session.Platform, &session.IP, &session.UserAgent,
+		&session.CreatedAt, &session.LastSeenAt, &revokedAt,
+	)
+	if err != nil {
+		return service.Session{}, err
+	}
+	if revokedAt.Valid {
+		session.RevokedAt = revokedAt.Time
+	}
+	return session, nil
+}
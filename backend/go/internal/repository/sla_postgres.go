@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"citizenapp/backend/internal/service"
+)
+
+// 1.- PostgresSLAStore implementa service.SLAStore sobre reports y report_sla_breaches.
+type PostgresSLAStore struct {
+	db *sql.DB
+}
+
+// 2.- NewPostgresSLAStore valida la conexión inyectada.
+func NewPostgresSLAStore(db *sql.DB) *PostgresSLAStore {
+	if db == nil {
+		panic("postgres db is required")
+	}
+	return &PostgresSLAStore{db: db}
+}
+
+// 3.- SLACandidates descarta en SQL las estancias recientes o ya registradas; el plazo hábil lo calcula el servicio.
+func (s *PostgresSLAStore) SLACandidates(ctx context.Context, statuses []string, enteredBefore time.Time) ([]service.Report, error) {
+	query := "SELECT " + reportColumns + `
+                FROM reports r
+                WHERE r.status = ANY($1)
+                  AND r.status_changed_at < $2
+                  AND NOT EXISTS (
+                        SELECT 1 FROM report_sla_breaches b
+                        WHERE b.report_id = r.id AND b.status = r.status AND b.entered_at = r.status_changed_at
+                  )
+                ORDER BY r.status_changed_at ASC
+        `
+	rows, err := s.db.QueryContext(ctx, query, statuses, enteredBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	reports := make([]service.Report, 0)
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+// 4.- RecordBreach inserta el incumplimiento y su evento en una transacción; la clave única evita escalar dos veces.
+func (s *PostgresSLAStore) RecordBreach(ctx context.Context, breach service.SLABreach, event service.ReportEvent) (bool, error) {
+	const query = `
+                INSERT INTO report_sla_breaches (report_id, incident_type_id, status, entered_at, due_at, breached_at)
+                VALUES ($1, $2, $3, $4, $5, $6)
+                ON CONFLICT (report_id, status, entered_at) DO NOTHING
+        `
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	result, err := tx.ExecContext(ctx, query, breach.ReportID, breach.IncidentTypeID, breach.Status, breach.EnteredAt, breach.DueAt, breach.BreachedAt)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if affected == 0 {
+		tx.Rollback()
+		return false, nil
+	}
+	if _, err := insertEvent(ctx, tx, event); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// 5.- ActiveBreaches sólo cuenta los incumplimientos cuya estancia sigue abierta.
+func (s *PostgresSLAStore) ActiveBreaches(ctx context.Context) (map[string]int, error) {
+	const query = `
+                SELECT b.incident_type_id, COUNT(*)
+                FROM report_sla_breaches b
+                JOIN reports r ON r.id = b.report_id AND r.status = b.status AND r.status_changed_at = b.entered_at
+                GROUP BY b.incident_type_id
+        `
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[string]int)
+	for rows.Next() {
+		var typeID string
+		var count int
+		if err := rows.Scan(&typeID, &count); err != nil {
+			return nil, err
+		}
+		counts[typeID] = count
+	}
+	return counts, rows.Err()
+}
@@ -3,6 +3,10 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
 
 	"citizenapp/backend/internal/service"
 )
@@ -20,14 +24,22 @@ func NewPostgresUserRepository(db *sql.DB) *PostgresUserRepository {
 	return &PostgresUserRepository{db: db}
 }
 
-// 3.- Create inserta el usuario o devuelve ErrEmailConflict si ya existe.
-func (r *PostgresUserRepository) Create(ctx context.Context, email, passwordHash string) error {
+// 3.- Create inserta el usuario con su rol o devuelve ErrEmailConflict si ya existe.
+func (r *PostgresUserRepository) Create(ctx context.Context, user service.User) error {
 	const query = `
-                INSERT INTO users (email, password_hash, created_at)
-                VALUES ($1, $2, NOW())
+                INSERT INTO users (email, password_hash, role, created_at, verified_at, display_name, phone, notification_prefs)
+                VALUES ($1, $2, $3, NOW(), $4, $5, $6, $7)
                 ON CONFLICT (email) DO NOTHING
         `
-	result, err := r.db.ExecContext(ctx, query, email, passwordHash)
+	var verifiedAt sql.NullTime
+	if user.Verified() {
+		verifiedAt = sql.NullTime{Time: user.VerifiedAt, Valid: true}
+	}
+	prefs, err := json.Marshal(user.Notifications)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.ExecContext(ctx, query, user.Email, user.PasswordHash, user.Role, verifiedAt, user.DisplayName, user.Phone, string(prefs))
 	if err != nil {
 		return err
 	}
@@ -41,21 +53,17 @@ func (r *PostgresUserRepository) Create(ctx context.Context, email, passwordHash
 	return nil
 }
 
-// 4.- PasswordHash devuelve el hash guardado o ErrInvalidCredentials.
-func (r *PostgresUserRepository) PasswordHash(ctx context.Context, email string) (string, error) {
-	const query = `
-                SELECT password_hash
-                FROM users
-                WHERE email = $1
-        `
-	var hash string
-	if err := r.db.QueryRowContext(ctx, query, email).Scan(&hash); err != nil {
+// 4.- FindByEmail devuelve la cuenta con su hash y rol o ErrUserNotFound.
+func (r *PostgresUserRepository) FindByEmail(ctx context.Context, email string) (service.User, error) {
+	query := "SELECT " + userColumns + " FROM users WHERE email = $1"
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, email))
+	if err != nil {
 		if err == sql.ErrNoRows {
-			return "", service.ErrInvalidCredentials
+			return service.User{}, service.ErrUserNotFound
 		}
-		return "", err
+		return service.User{}, err
 	}
-	return hash, nil
+	return user, nil
 }
 
 // 5.- Exists confirma la presencia del usuario en la tabla.
@@ -69,3 +77,207 @@ func (r *PostgresUserRepository) Exists(ctx context.Context, email string) (bool
 	}
 	return exists, nil
 }
+
+// 6.- UpdateRole cambia el rol del usuario o devuelve ErrUserNotFound.
+func (r *PostgresUserRepository) UpdateRole(ctx context.Context, email, role string) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE users SET role = $1 WHERE email = $2", role, email)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return service.ErrUserNotFound
+	}
+	return nil
+}
+
+// 7.- UpdatePassword reemplaza el hash de la contraseña o devuelve ErrUserNotFound.
+func (r *PostgresUserRepository) UpdatePassword(ctx context.Context, email, passwordHash string) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE users SET password_hash = $1 WHERE email = $2", passwordHash, email)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return service.ErrUserNotFound
+	}
+	return nil
+}
+
+// 8.- MarkVerified fija la fecha de verificación sin sobrescribir una previa.
+func (r *PostgresUserRepository) MarkVerified(ctx context.Context, email string, at time.Time) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE users SET verified_at = COALESCE(verified_at, $1) WHERE email = $2", at, email)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return service.ErrUserNotFound
+	}
+	return nil
+}
+
+// 9.- UpgradePasswordHash compara y reemplaza en una sola sentencia; si el hash ya cambió no hace nada.
+func (r *PostgresUserRepository) UpgradePasswordHash(ctx context.Context, email, currentHash, newHash string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET password_hash = $1 WHERE email = $2 AND password_hash = $3", newHash, email, currentHash)
+	return err
+}
+
+// 10.- UpdateProfile guarda los datos editables desde /me.
+func (r *PostgresUserRepository) UpdateProfile(ctx context.Context, user service.User) error {
+	prefs, err := json.Marshal(user.Notifications)
+	if err != nil {
+		return err
+	}
+	const query = `
+                UPDATE users
+                SET display_name = $2, phone = $3, notification_prefs = $4
+                WHERE email = $1
+        `
+	result, err := r.db.ExecContext(ctx, query, user.Email, user.DisplayName, user.Phone, string(prefs))
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return service.ErrUserNotFound
+	}
+	return nil
+}
+
+// 11.- Delete borra la cuenta; identidades federadas y MFA se eliminan en cascada.
+func (r *PostgresUserRepository) Delete(ctx context.Context, email string) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM users WHERE email = $1", email)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return service.ErrUserNotFound
+	}
+	return nil
+}
+
+// 12.- List filtra por texto, rol y estado y pagina por fecha de alta.
+func (r *PostgresUserRepository) List(ctx context.Context, query service.UserQuery) ([]service.User, int, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+	if query.Search != "" {
+		args = append(args, "%"+likeEscaper.Replace(strings.ToLower(query.Search))+"%")
+		conditions = append(conditions, fmt.Sprintf("(email LIKE $%d OR LOWER(display_name) LIKE $%d)", len(args), len(args)))
+	}
+	if query.Role != "" {
+		args = append(args, query.Role)
+		conditions = append(conditions, fmt.Sprintf("role = $%d", len(args)))
+	}
+	switch query.Status {
+	case service.UserStatusDisabled:
+		conditions = append(conditions, "disabled_at IS NOT NULL")
+	case service.UserStatusInvited:
+		conditions = append(conditions, "disabled_at IS NULL AND password_hash = '' AND verified_at IS NULL")
+	case service.UserStatusActive:
+		conditions = append(conditions, "disabled_at IS NULL AND (password_hash <> '' OR verified_at IS NOT NULL)")
+	}
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users"+whereClause, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	if total == 0 {
+		return []service.User{}, 0, nil
+	}
+	listQuery := fmt.Sprintf(`
+                SELECT %s
+                FROM users%s
+                ORDER BY created_at DESC, email
+                LIMIT $%d OFFSET $%d
+        `, userColumns, whereClause, len(args)+1, len(args)+2)
+	args = append(args, query.PageSize, query.Page*query.PageSize)
+	rows, err := r.db.QueryContext(ctx, listQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	users := make([]service.User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// 13.- SetDisabled guarda la fecha de suspensión; una fecha en cero reactiva la cuenta.
+func (r *PostgresUserRepository) SetDisabled(ctx context.Context, email string, at time.Time) error {
+	var disabledAt sql.NullTime
+	if !at.IsZero() {
+		disabledAt = sql.NullTime{Time: at, Valid: true}
+	}
+	result, err := r.db.ExecContext(ctx, "UPDATE users SET disabled_at = $1 WHERE email = $2", disabledAt, email)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return service.ErrUserNotFound
+	}
+	return nil
+}
+
+const userColumns = "email, password_hash, role, created_at, verified_at, display_name, phone, notification_prefs, disabled_at"
+
+// likeEscaper evita que los comodines escritos por el administrador amplíen la búsqueda.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func scanUser(row rowScanner) (service.User, error) {
+	var (
+		user       service.User
+		verifiedAt sql.NullTime
+		disabledAt sql.NullTime
+		prefs      []byte
+	)
+	err := row.Scan(
+		&user.Email, &user.PasswordHash, &user.Role, &user.CreatedAt, &verifiedAt, &user.DisplayName, &user.Phone, &prefs, &disabledAt,
+	)
+	if err != nil {
+		return service.User{}, err
+	}
+	if verifiedAt.Valid {
+		user.VerifiedAt = verifiedAt.Time
+	}
+	if disabledAt.Valid {
+		user.DisabledAt = disabledAt.Time
+	}
+	if err := json.Unmarshal(prefs, &user.Notifications); err != nil {
+		return service.User{}, err
+	}
+	return user, nil
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// 1.- NotificationPreferences indica por qué canales quiere el ciudadano recibir avisos de sus reportes.
+type NotificationPreferences struct {
+	Email bool `json:"email"`
+	Push  bool `json:"push"`
+	SMS   bool `json:"sms"`
+}
+
+// DefaultNotificationPreferences aplica a las cuentas nuevas: correo y push activos, SMS apagado.
+func DefaultNotificationPreferences() NotificationPreferences {
+	return NotificationPreferences{Email: true, Push: true}
+}
+
+// 2.- Profile es la vista de la cuenta propia; nunca incluye el hash de la contraseña.
+type Profile struct {
+	Email         string                  `json:"email"`
+	DisplayName   string                  `json:"displayName"`
+	Phone         string                  `json:"phone"`
+	Role          string                  `json:"role"`
+	EmailVerified bool                    `json:"emailVerified"`
+	HasPassword   bool                    `json:"hasPassword"`
+	Notifications NotificationPreferences `json:"notifications"`
+	CreatedAt     time.Time               `json:"createdAt"`
+}
+
+// 3.- ProfileUpdate sólo modifica los campos presentes.
+type ProfileUpdate struct {
+	DisplayName   *string
+	Phone         *string
+	Notifications *NotificationPreferences
+}
+
+// 4.- Errores de la administración de la cuenta propia.
+var (
+	// ErrPasswordUnchanged evita "cambiar" la contraseña por la misma.
+	ErrPasswordUnchanged = errors.New("new password must differ from the current one")
+	// ErrRecentLoginRequired pide iniciar sesión de nuevo a las cuentas sin contraseña antes de borrarlas.
+	ErrRecentLoginRequired = errors.New("sign in again to confirm this action")
+)
+
+// accountDeletionFreshness es la antigüedad máxima del inicio de sesión con el que una cuenta sin contraseña se borra.
+const accountDeletionFreshness = 5 * time.Minute
+
+// 5.- Profile devuelve los datos de la cuenta autenticada.
+func (s *AuthService) Profile(ctx context.Context, email string) (Profile, error) {
+	user, err := s.repo.FindByEmail(ctx, strings.TrimSpace(strings.ToLower(email)))
+	if err != nil {
+		return Profile{}, err
+	}
+	return user.Profile(), nil
+}
+
+// 6.- UpdateProfile guarda los campos enviados y devuelve el perfil resultante.
+func (s *AuthService) UpdateProfile(ctx context.Context, email string, update ProfileUpdate) (Profile, error) {
+	normalized := strings.TrimSpace(strings.ToLower(email))
+	user, err := s.repo.FindByEmail(ctx, normalized)
+	if err != nil {
+		return Profile{}, err
+	}
+	if update.DisplayName != nil {
+		user.DisplayName = strings.TrimSpace(*update.DisplayName)
+	}
+	if update.Phone != nil {
+		user.Phone = strings.TrimSpace(*update.Phone)
+	}
+	if update.Notifications != nil {
+		user.Notifications = *update.Notifications
+	}
+	if err := s.repo.UpdateProfile(ctx, user); err != nil {
+		return Profile{}, err
+	}
+	return user.Profile(), nil
+}
+
+// 7.- ChangePassword exige la contraseña actual, aplica la política y cierra todas las sesiones.
+func (s *AuthService) ChangePassword(ctx context.Context, email, current, replacement string) error {
+	normalized := strings.TrimSpace(strings.ToLower(email))
+	ip := ClientInfoFrom(ctx).IP
+	// Un token robado no debe servir para adivinar la contraseña sin límite.
+	if err := s.checkLockout(ctx, normalized, ip); err != nil {
+		return err
+	}
+	user, err := s.repo.FindByEmail(ctx, normalized)
+	if err != nil {
+		return err
+	}
+	if err := s.verifyPassword(current, user.PasswordHash); err != nil {
+		s.recordLoginFailure(ctx, "invalid_current_password", normalized, ip)
+		return ErrInvalidCredentials
+	}
+	if strings.TrimSpace(replacement) == "" {
+		return ErrInvalidCredentials
+	}
+	if current == replacement {
+		return ErrPasswordUnchanged
+	}
+	if err := s.checkPassword(ctx, replacement); err != nil {
+		return err
+	}
+	hashed, err := s.hashPassword(replacement)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UpdatePassword(ctx, normalized, hashed); err != nil {
+		return err
+	}
+	s.logger.Info().
+		Str("event", "auth.password.changed").
+		Str("subject", normalized).
+		Msg("password changed")
+	return s.RevokeSessions(ctx, normalized)
+}
+
+// 8.- ConfirmAccountDeletion exige la contraseña actual, o un inicio de sesión reciente si la cuenta no tiene contraseña.
+func (s *AuthService) ConfirmAccountDeletion(ctx context.Context, principal Principal, current string) error {
+	if principal.Role == RoleAnonymous || principal.APIKeyID != "" || IsAnonymousSubject(principal.Subject) {
+		return ErrForbidden
+	}
+	normalized := strings.TrimSpace(strings.ToLower(principal.Subject))
+	ip := ClientInfoFrom(ctx).IP
+	if err := s.checkLockout(ctx, normalized, ip); err != nil {
+		return err
+	}
+	user, err := s.repo.FindByEmail(ctx, normalized)
+	if err != nil {
+		return err
+	}
+	if user.PasswordHash == "" {
+		// Las cuentas de proveedores sociales no tienen contraseña; se exige un inicio de sesión interactivo reciente.
+		// auth_time no cambia al renovar, así que un token de renovación robado no basta.
+		if len(principal.AuthMethods) == 0 || principal.AuthTime.IsZero() || s.now().Sub(principal.AuthTime) > accountDeletionFreshness {
+			return ErrRecentLoginRequired
+		}
+		return nil
+	}
+	if err := s.verifyPassword(current, user.PasswordHash); err != nil {
+		s.recordLoginFailure(ctx, "invalid_current_password", normalized, ip)
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// 9.- DeleteAccount cierra las sesiones y borra la cuenta; los reportes se anonimizan antes desde ReportService.
+func (s *AuthService) DeleteAccount(ctx context.Context, email string) error {
+	normalized := strings.TrimSpace(strings.ToLower(email))
+	if err := s.RevokeSessions(ctx, normalized); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, normalized); err != nil {
+		return err
+	}
+	s.logger.Info().
+		Str("event", "auth.account.deleted").
+		Str("subject", normalized).
+		Msg("account deleted")
+	return nil
+}
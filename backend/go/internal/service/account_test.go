@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestProfileUpdateAndPasswordChange(t *testing.T) {
+	// 1.- Una cuenta nueva arranca sin nombre y con las preferencias por defecto.
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	repo := newFakeUserRepository()
+	svc := NewAuthService(repo, 1, time.Hour, []byte("test-secret"),
+		WithRevocation(newMemoryRevocationStore()), WithClock(func() time.Time { return now }))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	session, err := svc.Register(ctx, "vecina@example.com", "ClaveVieja1")
+	if err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	profile, err := svc.Profile(ctx, "Vecina@Example.com")
+	if err != nil {
+		t.Fatalf("Profile returned error: %v", err)
+	}
+	if profile.DisplayName != "" || profile.Notifications != DefaultNotificationPreferences() || !profile.HasPassword {
+		t.Fatalf("unexpected initial profile: %+v", profile)
+	}
+
+	// 2.- La actualización parcial sólo toca los campos enviados.
+	name, phone := "  Ana López ", "+525512345678"
+	if _, err := svc.UpdateProfile(ctx, "vecina@example.com", ProfileUpdate{DisplayName: &name}); err != nil {
+		t.Fatalf("UpdateProfile returned error: %v", err)
+	}
+	profile, err = svc.UpdateProfile(ctx, "vecina@example.com", ProfileUpdate{
+		Phone:         &phone,
+		Notifications: &NotificationPreferences{Email: false, Push: true, SMS: true},
+	})
+	if err != nil {
+		t.Fatalf("UpdateProfile returned error: %v", err)
+	}
+	if profile.DisplayName != "Ana López" || profile.Phone != phone || profile.Notifications.Email || !profile.Notifications.SMS {
+		t.Fatalf("unexpected profile after update: %+v", profile)
+	}
+
+	// 3.- El cambio exige la contraseña actual y una nueva distinta.
+	if err := svc.ChangePassword(ctx, "vecina@example.com", "Incorrecta1", "ClaveNueva1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected invalid credentials, got %v", err)
+	}
+	if err := svc.ChangePassword(ctx, "vecina@example.com", "ClaveVieja1", "ClaveVieja1"); !errors.Is(err, ErrPasswordUnchanged) {
+		t.Fatalf("expected unchanged password, got %v", err)
+	}
+
+	// 4.- Tras el cambio, las sesiones previas se revocan y sólo la contraseña nueva funciona.
+	now = now.Add(time.Minute)
+	if err := svc.ChangePassword(ctx, "vecina@example.com", "ClaveVieja1", "ClaveNueva1"); err != nil {
+		t.Fatalf("ChangePassword returned error: %v", err)
+	}
+	if _, err := svc.ValidateToken(ctx, session.Token); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("expected previous session to be revoked, got %v", err)
+	}
+	now = now.Add(time.Second)
+	if _, err := svc.Authenticate(ctx, "vecina@example.com", "ClaveVieja1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected old password to fail, got %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, "vecina@example.com", "ClaveNueva1"); err != nil {
+		t.Fatalf("Authenticate returned error: %v", err)
+	}
+}
+
+func TestConfirmAccountDeletionRequiresPasswordOrRecentLogin(t *testing.T) {
+	// 1.- Una cuenta con contraseña sólo confirma el borrado con la contraseña actual.
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	repo := newFakeUserRepository()
+	svc := NewAuthService(repo, 1, time.Hour, []byte("test-secret"),
+		WithPasswordHasher(NewBcryptHasher(bcrypt.MinCost)), WithClock(func() time.Time { return now }))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	session, err := svc.Register(ctx, "vecina@example.com", "ClaveSegura1")
+	if err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	principal, err := svc.ValidateToken(ctx, session.Token)
+	if err != nil {
+		t.Fatalf("ValidateToken returned error: %v", err)
+	}
+	if err := svc.ConfirmAccountDeletion(ctx, principal, ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected missing password to fail, got %v", err)
+	}
+	if err := svc.ConfirmAccountDeletion(ctx, principal, "Incorrecta1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected wrong password to fail, got %v", err)
+	}
+	if err := svc.ConfirmAccountDeletion(ctx, principal, "ClaveSegura1"); err != nil {
+		t.Fatalf("ConfirmAccountDeletion returned error: %v", err)
+	}
+
+	// 2.- Las identidades anónimas y las claves de API nunca borran cuentas.
+	anonymous := Principal{Subject: AnonymousSubject("3f6c1a2e-9b7d-4e8f"), Role: RoleAnonymous, AuthTime: now}
+	if err := svc.ConfirmAccountDeletion(ctx, anonymous, ""); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected anonymous principal to be forbidden, got %v", err)
+	}
+	apiKey := Principal{Subject: "vecina@example.com", Role: RoleCitizen, APIKeyID: "key-1"}
+	if err := svc.ConfirmAccountDeletion(ctx, apiKey, "ClaveSegura1"); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected API key to be forbidden, got %v", err)
+	}
+
+	// 3.- Una cuenta sin contraseña necesita un token emitido hace menos de cinco minutos.
+	if err := repo.Create(ctx, User{Email: "social@example.com", Role: RoleCitizen, CreatedAt: now}); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	social := Principal{Subject: "social@example.com", Role: RoleCitizen, AuthMethods: []string{"fed"}, AuthTime: now.Add(-10 * time.Minute)}
+	if err := svc.ConfirmAccountDeletion(ctx, social, ""); !errors.Is(err, ErrRecentLoginRequired) {
+		t.Fatalf("expected stale token to require a new login, got %v", err)
+	}
+	social.AuthTime = now.Add(-time.Minute)
+	if err := svc.ConfirmAccountDeletion(ctx, social, ""); err != nil {
+		t.Fatalf("ConfirmAccountDeletion returned error: %v", err)
+	}
+}
+
+func TestRefreshedTokensDoNotCountAsARecentLoginForDeletion(t *testing.T) {
+	// 1.- Una cuenta creada con un proveedor social inicia sesión y puede confirmar el borrado enseguida.
+	idp := newFakeIdentityProvider(t)
+	provider, err := NewSocialProvider(idp.config(), idp.server.Client())
+	if err != nil {
+		t.Fatalf("NewSocialProvider returned error: %v", err)
+	}
+	var elapsed time.Duration
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Hour, []byte("test-secret"),
+		WithSocialLogin(newMemoryIdentityRepository(), provider), WithRefreshTokens(newMemoryRefreshStore(), 24*time.Hour),
+		WithClock(func() time.Time { return time.Now().Add(elapsed) }))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	login, err := svc.SocialAuthenticate(ctx, "google", SocialCredential{IDToken: idp.sign(t, baseIDClaims("g-1", "vecina@example.com")), Nonce: "n-123"})
+	if err != nil {
+		t.Fatalf("SocialAuthenticate returned error: %v", err)
+	}
+	principal, err := svc.ValidateToken(ctx, login.Token)
+	if err != nil {
+		t.Fatalf("ValidateToken returned error: %v", err)
+	}
+	if err := svc.ConfirmAccountDeletion(ctx, principal, ""); err != nil {
+		t.Fatalf("ConfirmAccountDeletion returned error: %v", err)
+	}
+
+	// 2.- Un token recién renovado diez minutos después no cuenta como inicio de sesión reciente.
+	elapsed = 10 * time.Minute
+	rotated, err := svc.Refresh(ctx, login.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+	principal, err = svc.ValidateToken(ctx, rotated.Token)
+	if err != nil {
+		t.Fatalf("ValidateToken returned error: %v", err)
+	}
+	if err := svc.ConfirmAccountDeletion(ctx, principal, ""); !errors.Is(err, ErrRecentLoginRequired) {
+		t.Fatalf("expected a refreshed token to require a new login, got %v", err)
+	}
+}
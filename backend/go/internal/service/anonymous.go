@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	anonymousSubjectPrefix   = "anon:"
+	minInstallIDLength       = 32
+	defaultAnonymousTTL      = time.Hour
+	defaultAnonymousIPLimit  = 20
+	anonymousIssueRateWindow = time.Hour
+)
+
+// 1.- Errores del reporte anónimo.
+var (
+	ErrInvalidInstallID  = errors.New("install id must be a random value of at least 32 characters")
+	ErrAnonymousDisabled = errors.New("anonymous reporting is disabled")
+)
+
+// 2.- AnonymousSubject deriva la identidad estable del dispositivo sin guardar el identificador de instalación.
+func AnonymousSubject(installID string) string {
+	return anonymousSubjectPrefix + hashToken(strings.TrimSpace(installID))[:32]
+}
+
+// 2.1.- IsAnonymousSubject distingue los actores emitidos por AnonymousToken; un correo nunca tiene este prefijo.
+func IsAnonymousSubject(subject string) bool {
+	return strings.HasPrefix(subject, anonymousSubjectPrefix)
+}
+
+// 3.- WithAnonymousAccess habilita tokens para reportar sin cuenta; ipLimit acota cuántos emite cada IP por hora.
+func WithAnonymousAccess(ttl time.Duration, ipLimit int) AuthOption {
+	return func(s *AuthService) {
+		s.anonymousTTL = ttl
+		if s.anonymousTTL <= 0 {
+			s.anonymousTTL = defaultAnonymousTTL
+		}
+		s.anonymousLimit = ipLimit
+		if s.anonymousLimit <= 0 {
+			s.anonymousLimit = defaultAnonymousIPLimit
+		}
+		s.anonymousLimiter = newRateLimiter(anonymousIssueRateWindow)
+	}
+}
+
+// 4.- AnonymousToken emite un token de corta vida que sólo permite enviar reportes; no tiene sesión ni renovación.
+func (s *AuthService) AnonymousToken(ctx context.Context, installID string) (AuthResponse, error) {
+	select {
+	case <-ctx.Done():
+		return AuthResponse{}, ctx.Err()
+	default:
+	}
+	if s.anonymousLimiter == nil {
+		return AuthResponse{}, ErrAnonymousDisabled
+	}
+	installID = strings.TrimSpace(installID)
+	if len(installID) < minInstallIDLength {
+		return AuthResponse{}, ErrInvalidInstallID
+	}
+	now := s.now()
+	if ip := ClientInfoFrom(ctx).IP; ip != "" {
+		if retryAfter, ok := s.anonymousLimiter.allow(ip, s.anonymousLimit, now); !ok {
+			return AuthResponse{}, &RateLimitError{RetryAfter: retryAfter}
+		}
+	}
+	jti, err := randomID()
+	if err != nil {
+		return AuthResponse{}, err
+	}
+	verified := false
+	claims := AccessClaims{
+		Role:          RoleAnonymous,
+		EmailVerified: &verified,
+		AMR:           []string{"anon"},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   AnonymousSubject(installID),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.anonymousTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	signed, err := s.sign(claims)
+	if err != nil {
+		return AuthResponse{}, err
+	}
+	s.logger.Info().
+		Str("event", "auth.anonymous.issued").
+		Str("subject", claims.Subject).
+		Msg("anonymous token issued")
+	return AuthResponse{Token: signed, ExpiresAt: claims.ExpiresAt.Time}, nil
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnonymousTokensOnlySubmitReports(t *testing.T) {
+	// 1.- Sin la opción habilitada no se emiten tokens anónimos.
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	installID := strings.Repeat("a1b2c3d4", 5)
+	ctx := WithClientInfo(context.Background(), ClientInfo{IP: "10.0.0.1"})
+	disabled := NewAuthService(newFakeUserRepository(), 1, time.Minute, []byte("test-secret"))
+	if _, err := disabled.AnonymousToken(ctx, installID); !errors.Is(err, ErrAnonymousDisabled) {
+		t.Fatalf("expected anonymous access to be disabled, got %v", err)
+	}
+
+	// 2.- El token identifica al dispositivo por un hash estable y sólo concede enviar reportes.
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Minute, []byte("test-secret"),
+		WithAnonymousAccess(30*time.Minute, 2), WithUnverifiedPermissions(), WithClock(func() time.Time { return now }))
+	if _, err := svc.AnonymousToken(ctx, "corto"); !errors.Is(err, ErrInvalidInstallID) {
+		t.Fatalf("expected invalid install id, got %v", err)
+	}
+	resp, err := svc.AnonymousToken(ctx, installID)
+	if err != nil {
+		t.Fatalf("AnonymousToken returned error: %v", err)
+	}
+	if resp.RefreshToken != "" || !resp.ExpiresAt.Equal(now.Add(30*time.Minute)) {
+		t.Fatalf("unexpected anonymous token %+v", resp)
+	}
+	principal, err := svc.ValidateToken(ctx, resp.Token)
+	if err != nil {
+		t.Fatalf("ValidateToken returned error: %v", err)
+	}
+	if principal.Subject != AnonymousSubject(installID) || strings.Contains(principal.Subject, installID) {
+		t.Fatalf("unexpected anonymous subject %q", principal.Subject)
+	}
+	if svc.Authorize(principal, PermReportsSubmit) != nil || svc.Authorize(principal, PermReportsRead) == nil {
+		t.Fatalf("unexpected anonymous permissions")
+	}
+
+	// 3.- La IP agota su cuota y el rol anónimo no puede asignarse a una cuenta.
+	if _, err := svc.AnonymousToken(ctx, installID); err != nil {
+		t.Fatalf("AnonymousToken returned error: %v", err)
+	}
+	if _, err := svc.AnonymousToken(ctx, installID); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected rate limit, got %v", err)
+	}
+	if ValidRole(RoleAnonymous) {
+		t.Fatalf("anonymous role must not be assignable")
+	}
+}
+
+func TestClaimAnonymousReportsMovesThemToTheAccount(t *testing.T) {
+	// 4.- Dos reportes desde la instalación y uno de otro dispositivo quedan marcados como anónimos.
+	repo := newFakeReportRepository()
+	svc := NewReportService(repo, newTestFolioGenerator(), 1, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	installID := strings.Repeat("f0e1d2c3", 5)
+	payload := map[string]any{"incidentTypeId": "pothole", "description": "Bache", "latitude": 19.43, "longitude": -99.13}
+	var submitted []Report
+	for _, actor := range []string{AnonymousSubject(installID), AnonymousSubject(installID), AnonymousSubject(strings.Repeat("z", 40))} {
+		report, err := svc.Submit(ctx, actor, payload)
+		if err != nil {
+			t.Fatalf("Submit returned error: %v", err)
+		}
+		if !report.Anonymous {
+			t.Fatalf("expected anonymous flag on %+v", report)
+		}
+		submitted = append(submitted, report)
+	}
+
+	// 5.- Una identidad anónima no puede reclamar; la cuenta recibe sólo los reportes de su instalación.
+	anonymous := Principal{Subject: AnonymousSubject(installID), Role: RoleAnonymous}
+	if _, err := svc.ClaimAnonymousReports(ctx, anonymous, installID); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected anonymous claim to be forbidden, got %v", err)
+	}
+	citizen := Principal{Subject: "vecina@example.com", Role: RoleCitizen}
+	result, err := svc.ClaimAnonymousReports(ctx, citizen, installID)
+	if err != nil || result.Claimed != 2 {
+		t.Fatalf("unexpected claim result %+v (%v)", result, err)
+	}
+	claimed, err := svc.Get(ctx, submitted[0].ID)
+	if err != nil || claimed.Anonymous || claimed.Reporter != citizen.Subject {
+		t.Fatalf("expected claimed report without flag, got %+v (%v)", claimed, err)
+	}
+	events, err := svc.Events(ctx, submitted[0].ID)
+	if err != nil || events[0].Actor != citizen.Subject {
+		t.Fatalf("expected submitted event to name the account, got %+v (%v)", events, err)
+	}
+	if other, _ := svc.Get(ctx, submitted[2].ID); !other.Anonymous {
+		t.Fatalf("expected other device report to stay anonymous")
+	}
+	if result, _ := svc.ClaimAnonymousReports(ctx, citizen, installID); result.Claimed != 0 {
+		t.Fatalf("expected second claim to find nothing, got %+v", result)
+	}
+}
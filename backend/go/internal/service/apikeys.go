@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	apiKeyPrefix           = "cak_"
+	apiKeyDisplayLength    = 12
+	defaultAPIKeyRateLimit = 60
+	apiKeyRateWindow       = time.Minute
+	// Registrar el último uso en cada petición saturaría la tabla; basta con una precisión de minutos.
+	apiKeyTouchInterval = time.Minute
+)
+
+// 1.- Alcances que pueden concederse a una clave de API.
+const (
+	ScopeReportsRead  = "reports:read"
+	ScopeReportsWrite = "reports:write"
+)
+
+// 1.1.- scopePermissions traduce cada alcance a los permisos que evalúan las rutas.
+var scopePermissions = map[string][]Permission{
+	ScopeReportsRead:  {PermReportsRead, PermReportsReadAll},
+	ScopeReportsWrite: {PermReportsSubmit, PermReportsUpdate},
+}
+
+// 2.- APIKey describe una credencial de integración; sólo se guarda el hash del valor.
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	RateLimit  int        `json:"rateLimit"`
+	CreatedBy  string     `json:"createdBy"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  time.Time  `json:"-"`
+}
+
+// 2.1.- NewAPIKey reúne los datos que el administrador fija al crear una clave.
+type NewAPIKey struct {
+	Name      string
+	Scopes    []string
+	RateLimit int
+	ExpiresAt time.Time
+}
+
+// 2.2.- CreatedAPIKey devuelve el valor en claro una única vez.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// 3.- APIKeyStore persiste las claves de integración.
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, key APIKey) error
+	FindAPIKeyByHash(ctx context.Context, hash string) (APIKey, error)
+	// ListAPIKeys devuelve las claves sin revocar, las más recientes primero.
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string, at time.Time) error
+	TouchAPIKey(ctx context.Context, id string, at time.Time) error
+}
+
+// 4.- Errores de las claves de API.
+var (
+	ErrInvalidAPIKey       = errors.New("invalid api key")
+	ErrAPIKeyNotFound      = errors.New("api key not found")
+	ErrInvalidAPIKeyScope  = errors.New("invalid api key scope")
+	ErrInvalidAPIKeyExpiry = errors.New("api key expiry must be in the future")
+	ErrRateLimited         = errors.New("rate limit exceeded")
+	errAPIKeysDisabled     = errors.New("api keys are not configured")
+)
+
+// 4.1.- RateLimitError indica cuánto falta para que la clave recupere su cuota.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s; retry in %s", ErrRateLimited, e.RetryAfter)
+}
+
+// Is permite usar errors.Is(err, ErrRateLimited) sin conocer el tiempo restante.
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// 5.- ValidScope confirma que el alcance pertenece al catálogo conocido.
+func ValidScope(scope string) bool {
+	_, ok := scopePermissions[scope]
+	return ok
+}
+
+// 5.1.- ScopesGrant evalúa si algún alcance concede el permiso solicitado.
+func ScopesGrant(scopes []string, perm Permission) bool {
+	for _, scope := range scopes {
+		for _, granted := range scopePermissions[scope] {
+			if granted == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 6.- WithAPIKeys habilita la autenticación por X-API-Key; el límite por minuto se cuenta en cada instancia.
+func WithAPIKeys(store APIKeyStore) AuthOption {
+	return func(s *AuthService) {
+		s.apiKeys = store
+		s.apiKeyLimiter = newRateLimiter(apiKeyRateWindow)
+	}
+}
+
+// 7.- CreateAPIKey genera el valor aleatorio, guarda su hash y lo devuelve en claro sólo en esta respuesta.
+func (s *AuthService) CreateAPIKey(ctx context.Context, actor Principal, spec NewAPIKey) (CreatedAPIKey, error) {
+	if s.apiKeys == nil {
+		return CreatedAPIKey{}, errAPIKeysDisabled
+	}
+	scopes, err := normalizeScopes(spec.Scopes)
+	if err != nil {
+		return CreatedAPIKey{}, err
+	}
+	now := s.now()
+	if !spec.ExpiresAt.IsZero() && !spec.ExpiresAt.After(now) {
+		return CreatedAPIKey{}, ErrInvalidAPIKeyExpiry
+	}
+	id, err := randomID()
+	if err != nil {
+		return CreatedAPIKey{}, err
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return CreatedAPIKey{}, err
+	}
+	raw := apiKeyPrefix + secret
+	key := APIKey{
+		ID:        id,
+		Name:      strings.TrimSpace(spec.Name),
+		Prefix:    raw[:apiKeyDisplayLength],
+		Hash:      hashToken(raw),
+		Scopes:    scopes,
+		RateLimit: spec.RateLimit,
+		CreatedBy: actor.Subject,
+		CreatedAt: now,
+	}
+	if key.RateLimit <= 0 {
+		key.RateLimit = defaultAPIKeyRateLimit
+	}
+	if !spec.ExpiresAt.IsZero() {
+		expiresAt := spec.ExpiresAt.UTC()
+		key.ExpiresAt = &expiresAt
+	}
+	if err := s.apiKeys.CreateAPIKey(ctx, key); err != nil {
+		return CreatedAPIKey{}, err
+	}
+	s.logger.Info().
+		Str("event", "auth.apikey.created").
+		Str("api_key_id", key.ID).
+		Strs("scopes", key.Scopes).
+		Str("actor", actor.Subject).
+		Msg("api key created")
+	return CreatedAPIKey{APIKey: key, Key: raw}, nil
+}
+
+// normalizeScopes elimina duplicados y rechaza alcances desconocidos.
+func normalizeScopes(scopes []string) ([]string, error) {
+	normalized := make([]string, 0, len(scopes))
+	seen := make(map[string]struct{}, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(strings.ToLower(scope))
+		if !ValidScope(scope) {
+			return nil, ErrInvalidAPIKeyScope
+		}
+		if _, ok := seen[scope]; ok {
+			continue
+		}
+		seen[scope] = struct{}{}
+		normalized = append(normalized, scope)
+	}
+	if len(normalized) == 0 {
+		return nil, ErrInvalidAPIKeyScope
+	}
+	return normalized, nil
+}
+
+// 8.- APIKeys lista las claves vigentes sin exponer su valor.
+func (s *AuthService) APIKeys(ctx context.Context) ([]APIKey, error) {
+	if s.apiKeys == nil {
+		return nil, errAPIKeysDisabled
+	}
+	return s.apiKeys.ListAPIKeys(ctx)
+}
+
+// 9.- RevokeAPIKey invalida la clave de inmediato; las peticiones siguientes responden 401.
+func (s *AuthService) RevokeAPIKey(ctx context.Context, actor Principal, id string) error {
+	if s.apiKeys == nil {
+		return errAPIKeysDisabled
+	}
+	if err := s.apiKeys.RevokeAPIKey(ctx, strings.TrimSpace(id), s.now()); err != nil {
+		return err
+	}
+	s.logger.Info().
+		Str("event", "auth.apikey.revoked").
+		Str("api_key_id", id).
+		Str("actor", actor.Subject).
+		Msg("api key revoked")
+	return nil
+}
+
+// 10.- AuthenticateAPIKey valida la clave, aplica su cuota por minuto y devuelve una identidad limitada a sus alcances.
+func (s *AuthService) AuthenticateAPIKey(ctx context.Context, raw string) (Principal, error) {
+	raw = strings.TrimSpace(raw)
+	if s.apiKeys == nil || !strings.HasPrefix(raw, apiKeyPrefix) {
+		return Principal{}, ErrInvalidAPIKey
+	}
+	key, err := s.apiKeys.FindAPIKeyByHash(ctx, hashToken(raw))
+	if err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			return Principal{}, ErrInvalidAPIKey
+		}
+		return Principal{}, err
+	}
+	now := s.now()
+	if !key.RevokedAt.IsZero() || (key.ExpiresAt != nil && !key.ExpiresAt.After(now)) {
+		return Principal{}, ErrInvalidAPIKey
+	}
+	if retryAfter, ok := s.apiKeyLimiter.allow(key.ID, key.RateLimit, now); !ok {
+		return Principal{}, &RateLimitError{RetryAfter: retryAfter}
+	}
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.apiKeys.TouchAPIKey(ctx, key.ID, now); err != nil {
+			s.logger.Error().
+				Err(err).
+				Str("event", "auth.apikey.failed").
+				Str("api_key_id", key.ID).
+				Msg("cannot record api key usage")
+		}
+	}
+	principal := Principal{
+		Subject:  "apikey:" + key.ID,
+		APIKeyID: key.ID,
+		Scopes:   key.Scopes,
+	}
+	if key.ExpiresAt != nil {
+		principal.ExpiresAt = *key.ExpiresAt
+	}
+	return principal, nil
+}
+
+// 11.- rateLimiter cuenta peticiones en ventanas fijas por clave.
+type rateLimiter struct {
+	mu      sync.Mutex
+	window  time.Duration
+	buckets map[string]rateBucket
+}
+
+type rateBucket struct {
+	start time.Time
+	count int
+}
+
+func newRateLimiter(window time.Duration) *rateLimiter {
+	return &rateLimiter{window: window, buckets: make(map[string]rateBucket)}
+}
+
+// allow registra la petición y, si excede el límite, indica cuándo abre la siguiente ventana.
+func (l *rateLimiter) allow(key string, limit int, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	bucket, ok := l.buckets[key]
+	if !ok || !now.Before(bucket.start.Add(l.window)) {
+		l.sweep(now)
+		bucket = rateBucket{start: now}
+	}
+	if bucket.count >= limit {
+		return bucket.start.Add(l.window).Sub(now), false
+	}
+	bucket.count++
+	l.buckets[key] = bucket
+	return 0, true
+}
+
+// sweep descarta ventanas vencidas para que las claves revocadas no acumulen memoria.
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		if !now.Before(bucket.start.Add(l.window)) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 1.- memoryAPIKeyStore indexa las claves por hash como la restricción UNIQUE de Postgres.
+type memoryAPIKeyStore struct {
+	mu   sync.Mutex
+	keys map[string]APIKey
+}
+
+func newMemoryAPIKeyStore() *memoryAPIKeyStore {
+	return &memoryAPIKeyStore{keys: make(map[string]APIKey)}
+}
+
+func (m *memoryAPIKeyStore) CreateAPIKey(_ context.Context, key APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[key.ID] = key
+	return nil
+}
+
+func (m *memoryAPIKeyStore) FindAPIKeyByHash(_ context.Context, hash string) (APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range m.keys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+	return APIKey{}, ErrAPIKeyNotFound
+}
+
+func (m *memoryAPIKeyStore) ListAPIKeys(_ context.Context) ([]APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]APIKey, 0)
+	for _, key := range m.keys {
+		if key.RevokedAt.IsZero() {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (m *memoryAPIKeyStore) RevokeAPIKey(_ context.Context, id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, ok := m.keys[id]
+	if !ok || !key.RevokedAt.IsZero() {
+		return ErrAPIKeyNotFound
+	}
+	key.RevokedAt = at
+	m.keys[id] = key
+	return nil
+}
+
+func (m *memoryAPIKeyStore) TouchAPIKey(_ context.Context, id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := m.keys[id]
+	key.LastUsedAt = &at
+	m.keys[id] = key
+	return nil
+}
+
+func TestAPIKeysAuthenticateWithinScopesAndQuota(t *testing.T) {
+	// 2.- El administrador crea una clave de lectura con cuota de dos peticiones por minuto.
+	now := time.Date(2024, 5, 1, 10, 0, 30, 0, time.UTC)
+	store := newMemoryAPIKeyStore()
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Minute, []byte("test-secret"),
+		WithAPIKeys(store), WithUnverifiedPermissions(), WithClock(func() time.Time { return now }))
+	ctx := context.Background()
+	admin := Principal{Subject: "admin@example.com", Role: RoleAdmin}
+	if _, err := svc.CreateAPIKey(ctx, admin, NewAPIKey{Name: "portal", Scopes: []string{"reports:delete"}}); !errors.Is(err, ErrInvalidAPIKeyScope) {
+		t.Fatalf("expected invalid scope, got %v", err)
+	}
+	if _, err := svc.CreateAPIKey(ctx, admin, NewAPIKey{Name: "portal", Scopes: []string{ScopeReportsRead}, ExpiresAt: now}); !errors.Is(err, ErrInvalidAPIKeyExpiry) {
+		t.Fatalf("expected invalid expiry, got %v", err)
+	}
+	created, err := svc.CreateAPIKey(ctx, admin, NewAPIKey{
+		Name:      "Portal de datos abiertos",
+		Scopes:    []string{"REPORTS:READ", ScopeReportsRead},
+		RateLimit: 2,
+		ExpiresAt: now.Add(24 * time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("CreateAPIKey returned error: %v", err)
+	}
+	if !strings.HasPrefix(created.Key, created.Prefix) || len(created.Scopes) != 1 || created.CreatedBy != admin.Subject {
+		t.Fatalf("unexpected created key %+v", created)
+	}
+	if stored := store.keys[created.ID]; stored.Hash == created.Key || stored.Hash != hashToken(created.Key) {
+		t.Fatalf("expected only the hash to be stored, got %q", stored.Hash)
+	}
+
+	// 3.- La identidad resultante sólo concede los permisos de sus alcances y registra el uso.
+	principal, err := svc.AuthenticateAPIKey(ctx, created.Key)
+	if err != nil {
+		t.Fatalf("AuthenticateAPIKey returned error: %v", err)
+	}
+	if svc.Authorize(principal, PermReportsRead) != nil || svc.Authorize(principal, PermReportsSubmit) == nil {
+		t.Fatalf("unexpected permissions for scopes %v", principal.Scopes)
+	}
+	if used := store.keys[created.ID].LastUsedAt; used == nil || !used.Equal(now) {
+		t.Fatalf("expected last use to be recorded, got %v", used)
+	}
+	if _, err := svc.AuthenticateAPIKey(ctx, created.Key+"x"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("expected unknown key to fail, got %v", err)
+	}
+
+	// 4.- Superar la cuota responde con el tiempo que falta para la siguiente ventana.
+	if _, err := svc.AuthenticateAPIKey(ctx, created.Key); err != nil {
+		t.Fatalf("AuthenticateAPIKey returned error: %v", err)
+	}
+	var limited *RateLimitError
+	if _, err := svc.AuthenticateAPIKey(ctx, created.Key); !errors.As(err, &limited) || limited.RetryAfter != time.Minute {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+	now = now.Add(time.Minute)
+	if _, err := svc.AuthenticateAPIKey(ctx, created.Key); err != nil {
+		t.Fatalf("expected quota to reset, got %v", err)
+	}
+
+	// 5.- Las claves vencidas o revocadas dejan de autenticar y desaparecen del listado.
+	now = now.Add(24 * time.Hour)
+	if _, err := svc.AuthenticateAPIKey(ctx, created.Key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("expected expired key to fail, got %v", err)
+	}
+	if err := svc.RevokeAPIKey(ctx, admin, created.ID); err != nil {
+		t.Fatalf("RevokeAPIKey returned error: %v", err)
+	}
+	if err := svc.RevokeAPIKey(ctx, admin, created.ID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Fatalf("expected second revoke to report not found, got %v", err)
+	}
+	if keys, _ := svc.APIKeys(ctx); len(keys) != 0 {
+		t.Fatalf("expected no active keys, got %+v", keys)
+	}
+}
@@ -7,17 +7,78 @@ import (
 	"strings"
 	"time"
 
+	"citizenapp/backend/internal/observability"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // 1.- AuthService administra solicitudes concurrentes con persistencia externa.
 type AuthService struct {
-	jobs      chan authJob
-	workers   int
-	tokenTTL  time.Duration
-	repo      UserRepository
-	jwtSecret []byte
+	jobs         chan authJob
+	workers      int
+	tokenTTL     time.Duration
+	repo         UserRepository
+	jwtSecret    []byte
+	keyRing      *KeyRing
+	refreshStore RefreshTokenStore
+	refreshTTL   time.Duration
+	revocations  RevocationStore
+	// Esquema de hash vigente y política opcional para contraseñas nuevas.
+	hasher         PasswordHasher
+	passwordPolicy *PasswordPolicy
+	// Dependencias de los flujos que envían tokens por correo.
+	oneTimeTokens OneTimeTokenStore
+	mailer        Mailer
+	mailQueue     chan mailJob
+	resetTTL      time.Duration
+	resetURL      string
+	inviteTTL     time.Duration
+	inviteURL     string
+	// Proveedores federados aceptados y sus vínculos con cuentas locales.
+	identities      IdentityRepository
+	socialProviders map[string]*SocialProvider
+	// Contadores de fallos para el bloqueo temporal del inicio de sesión.
+	loginAttempts LoginAttemptStore
+	lockout       LockoutPolicy
+	// Verificación de correo y permisos disponibles antes de confirmarlo.
+	emailVerification     bool
+	verifyTTL             time.Duration
+	verifyURL             string
+	unverifiedPermissions map[Permission]struct{}
+	// verifyResendLimiter espacía los reenvíos del correo de confirmación por cuenta.
+	verifyResendLimiter *rateLimiter
+	// Sesiones por dispositivo; su ID es la familia de tokens de renovación.
+	sessions SessionStore
+	// sessionListener corta las conexiones en vivo de las sesiones revocadas.
+	sessionListener SessionListener
+	// Tokens para reportar sin cuenta y límite de emisión por IP.
+	anonymousTTL     time.Duration
+	anonymousLimit   int
+	anonymousLimiter *rateLimiter
+	// Claves de integraciones máquina a máquina y su límite de peticiones.
+	apiKeys       APIKeyStore
+	apiKeyLimiter *rateLimiter
+	// Segundo factor TOTP y roles que deben inscribirse.
+	mfa       MFARepository
+	mfaIssuer string
+	mfaRoles  map[string]struct{}
+	// accountStatus evita consultar la base en cada token para saber si la cuenta sigue activa.
+	accountStatus *accountStatusCache
+	now           func() time.Time
+	logger        zerolog.Logger
+}
+
+// AuthOption ajusta dependencias opcionales del servicio de autenticación.
+type AuthOption func(*AuthService)
+
+// WithClock sustituye el reloj del servicio, útil para pruebas deterministas.
+func WithClock(now func() time.Time) AuthOption {
+	return func(s *AuthService) {
+		if now != nil {
+			s.now = now
+		}
+	}
 }
 
 type authJob struct {
@@ -32,17 +93,83 @@ type authResult struct {
 	err      error
 }
 
-// 2.- UserRepository define las operaciones requeridas para credenciales.
+// 2.- UserRepository define las operaciones requeridas para credenciales y roles.
 type UserRepository interface {
-	Create(ctx context.Context, email, passwordHash string) error
-	PasswordHash(ctx context.Context, email string) (string, error)
+	Create(ctx context.Context, user User) error
+	FindByEmail(ctx context.Context, email string) (User, error)
 	Exists(ctx context.Context, email string) (bool, error)
+	UpdateRole(ctx context.Context, email, role string) error
+	UpdatePassword(ctx context.Context, email, passwordHash string) error
+	// MarkVerified conserva la primera fecha de verificación si la cuenta ya estaba verificada.
+	MarkVerified(ctx context.Context, email string, at time.Time) error
+	// UpgradePasswordHash reemplaza el hash sólo si sigue siendo currentHash, para no pisar un cambio concurrente.
+	UpgradePasswordHash(ctx context.Context, email, currentHash, newHash string) error
+	// UpdateProfile guarda nombre, teléfono y preferencias; devuelve ErrUserNotFound si la cuenta no existe.
+	UpdateProfile(ctx context.Context, user User) error
+	Delete(ctx context.Context, email string) error
+	// List devuelve la página solicitada y el total de cuentas que cumplen el filtro.
+	List(ctx context.Context, query UserQuery) ([]User, int, error)
+	// SetDisabled suspende la cuenta con la fecha indicada o la reactiva con una fecha en cero.
+	SetDisabled(ctx context.Context, email string, at time.Time) error
+}
+
+// 2.1.- User modela la cuenta persistida junto con su rol.
+type User struct {
+	Email        string
+	PasswordHash string
+	Role         string
+	CreatedAt    time.Time
+	// VerifiedAt queda en cero mientras el correo no se confirme.
+	VerifiedAt time.Time
+	// Datos de perfil que el ciudadano administra desde /me.
+	DisplayName   string
+	Phone         string
+	Notifications NotificationPreferences
+	// DisabledAt queda en cero salvo que un administrador suspenda la cuenta.
+	DisabledAt time.Time
+}
+
+// Verified indica si la cuenta confirmó la propiedad de su correo.
+func (u User) Verified() bool {
+	return !u.VerifiedAt.IsZero()
+}
+
+// Profile proyecta la cuenta sin el hash de la contraseña.
+func (u User) Profile() Profile {
+	return Profile{
+		Email:         u.Email,
+		DisplayName:   u.DisplayName,
+		Phone:         u.Phone,
+		Role:          u.Role,
+		EmailVerified: u.Verified(),
+		HasPassword:   u.PasswordHash != "",
+		Notifications: u.Notifications,
+		CreatedAt:     u.CreatedAt,
+	}
+}
+
+// 2.2.- AccessClaims extiende los claims registrados con el rol del usuario.
+type AccessClaims struct {
+	Role string `json:"role"`
+	// EmailVerified viaja como "ev"; los tokens previos sin el claim se consideran verificados.
+	EmailVerified *bool `json:"ev,omitempty"`
+	// AMR lista los métodos del inicio de sesión interactivo (RFC 8176): pwd, fed, otp, mfa.
+	AMR []string `json:"amr,omitempty"`
+	// SessionID viaja como "sid" y permite revocar los tokens de un solo dispositivo.
+	SessionID string `json:"sid,omitempty"`
+	// AuthTime es el momento del inicio de sesión interactivo; a diferencia de iat, no cambia al renovar.
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	jwt.RegisteredClaims
 }
 
 // 3.- AuthResponse modela la respuesta esperada por el cliente Flutter.
 type AuthResponse struct {
-	Token     string    `json:"token"`
-	ExpiresAt time.Time `json:"expiresAt"`
+	Token            string     `json:"token"`
+	ExpiresAt        time.Time  `json:"expiresAt"`
+	RefreshToken     string     `json:"refreshToken,omitempty"`
+	RefreshExpiresAt *time.Time `json:"refreshExpiresAt,omitempty"`
+	// RecoveryCodes sólo se entrega al completar una inscripción MFA exigida en el login.
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
 }
 
 // 4.- Declaramos errores reutilizables para mapear códigos HTTP.
@@ -51,22 +178,38 @@ var (
 	ErrEmailConflict      = errors.New("email already registered")
 	ErrUnsupportedLogin   = errors.New("unsupported provider")
 	ErrInvalidToken       = errors.New("invalid token")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrInvalidRole        = errors.New("invalid role")
+	ErrForbidden          = errors.New("forbidden")
 )
 
-// 5.- NewAuthService configura el pool de trabajadores y agrega la clave JWT.
-func NewAuthService(repo UserRepository, workers int, tokenTTL time.Duration, jwtSecret []byte) *AuthService {
+// 5.- NewAuthService configura el pool de trabajadores y agrega la clave JWT; el secreto es opcional si hay anillo de llaves.
+func NewAuthService(repo UserRepository, workers int, tokenTTL time.Duration, jwtSecret []byte, opts ...AuthOption) *AuthService {
 	if repo == nil {
 		panic("user repository is required")
 	}
-	if len(jwtSecret) == 0 {
-		panic("jwt secret is required")
-	}
 	s := &AuthService{
-		jobs:      make(chan authJob),
-		workers:   workers,
-		tokenTTL:  tokenTTL,
-		repo:      repo,
-		jwtSecret: append([]byte(nil), jwtSecret...),
+		jobs:          make(chan authJob),
+		workers:       workers,
+		tokenTTL:      tokenTTL,
+		repo:          repo,
+		jwtSecret:     append([]byte(nil), jwtSecret...),
+		now:           time.Now,
+		logger:        observability.NamedLogger("auth_service"),
+		resetTTL:      defaultResetTTL,
+		inviteTTL:     defaultInviteTTL,
+		hasher:        NewBcryptHasher(bcrypt.DefaultCost),
+		accountStatus: newAccountStatusCache(defaultAccountStatusTTL),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if len(s.jwtSecret) == 0 && s.keyRing == nil {
+		panic("jwt secret or signing keys are required")
+	}
+	if s.mailer != nil {
+		s.mailQueue = make(chan mailJob, mailQueueSize)
+		go s.mailWorker()
 	}
 	for i := 0; i < workers; i++ {
 		go s.worker()
@@ -74,8 +217,12 @@ func NewAuthService(repo UserRepository, workers int, tokenTTL time.Duration, jw
 	return s
 }
 
-// 6.- Authenticate coloca el trabajo en cola y espera el resultado.
+// 6.- Authenticate descarta cuentas o IPs bloqueadas, coloca el trabajo en cola y espera el resultado.
 func (s *AuthService) Authenticate(ctx context.Context, email, password string) (AuthResponse, error) {
+	normalized := strings.TrimSpace(strings.ToLower(email))
+	if err := s.checkLockout(ctx, normalized, ClientInfoFrom(ctx).IP); err != nil {
+		return AuthResponse{}, err
+	}
 	resultCh := make(chan authResult, 1)
 	job := authJob{email: email, password: password, ctx: ctx, result: resultCh}
 	select {
@@ -105,17 +252,35 @@ func (s *AuthService) Register(ctx context.Context, email, password string) (Aut
 	if normalized == "" || strings.TrimSpace(password) == "" {
 		return AuthResponse{}, ErrInvalidCredentials
 	}
+	if err := s.checkPassword(ctx, password); err != nil {
+		return AuthResponse{}, err
+	}
 	hashed, err := s.hashPassword(password)
 	if err != nil {
 		return AuthResponse{}, err
 	}
-	if err := s.repo.Create(ctx, normalized, hashed); err != nil {
+	user := User{Email: normalized, PasswordHash: hashed, Role: RoleCitizen, CreatedAt: s.now(), Notifications: DefaultNotificationPreferences()}
+	if !s.emailVerification {
+		// Sin verificación configurada no habría forma de confirmar la cuenta más adelante.
+		user.VerifiedAt = user.CreatedAt
+	}
+	if err := s.repo.Create(ctx, user); err != nil {
 		return AuthResponse{}, err
 	}
-	return s.newToken(normalized)
+	if s.emailVerification {
+		if err := s.sendVerification(ctx, user.Email); err != nil {
+			// La cuenta ya existe; el usuario puede pedir otro correo desde /auth/verify/resend.
+			s.logger.Error().
+				Err(err).
+				Str("event", "auth.verify.failed").
+				Str("subject", user.Email).
+				Msg("cannot send verification email")
+		}
+	}
+	return s.issue(ctx, user)
 }
 
-// 8.- Recover valida la existencia de la cuenta para simular el envío de correo.
+// 8.- Recover envía un enlace de restablecimiento sin revelar si la cuenta existe.
 func (s *AuthService) Recover(ctx context.Context, email string) error {
 	select {
 	case <-ctx.Done():
@@ -126,31 +291,16 @@ func (s *AuthService) Recover(ctx context.Context, email string) error {
 	if normalized == "" {
 		return ErrInvalidCredentials
 	}
-	exists, err := s.repo.Exists(ctx, normalized)
-	if err != nil {
-		return err
-	}
-	if !exists {
-		return ErrInvalidCredentials
+	// Los fallos internos sólo se registran: devolverlos distinguiría cuentas existentes.
+	if err := s.sendPasswordReset(normalized); err != nil {
+		s.logger.Error().
+			Err(err).
+			Str("event", "auth.recover.failed").
+			Msg("cannot issue password reset")
 	}
 	return nil
 }
 
-// 9.- SocialAuthenticate genera un token virtual para proveedores federados.
-func (s *AuthService) SocialAuthenticate(ctx context.Context, provider string) (AuthResponse, error) {
-	select {
-	case <-ctx.Done():
-		return AuthResponse{}, ctx.Err()
-	default:
-	}
-	switch provider {
-	case "google", "apple", "facebook":
-		return s.newToken(provider)
-	default:
-		return AuthResponse{}, ErrUnsupportedLogin
-	}
-}
-
 // 10.- worker procesa cada autenticación en segundo plano.
 func (s *AuthService) worker() {
 	for job := range s.jobs {
@@ -160,16 +310,25 @@ func (s *AuthService) worker() {
 		default:
 		}
 		normalized := strings.TrimSpace(strings.ToLower(job.email))
-		stored, err := s.repo.PasswordHash(job.ctx, normalized)
+		ip := ClientInfoFrom(job.ctx).IP
+		user, err := s.repo.FindByEmail(job.ctx, normalized)
 		if err != nil {
+			if errors.Is(err, ErrUserNotFound) {
+				// Las cuentas inexistentes también se bloquean para no revelar cuáles existen.
+				s.recordLoginFailure(job.ctx, "invalid_credentials", normalized, ip)
+				err = ErrInvalidCredentials
+			}
 			job.result <- authResult{err: err}
 			continue
 		}
-		if err := s.verifyPassword(job.password, stored); err != nil {
+		if err := s.verifyPassword(job.password, user.PasswordHash); err != nil {
+			s.recordLoginFailure(job.ctx, "invalid_credentials", normalized, ip)
 			job.result <- authResult{err: ErrInvalidCredentials}
 			continue
 		}
-		resp, err := s.newToken(normalized)
+		s.clearLoginFailures(job.ctx, normalized)
+		s.upgradePasswordHash(job.ctx, user, job.password)
+		resp, err := s.completeLogin(job.ctx, user, "pwd")
 		if err != nil {
 			job.result <- authResult{err: err}
 			continue
@@ -178,18 +337,84 @@ func (s *AuthService) worker() {
 	}
 }
 
-// 11.- newToken centraliza la construcción del token y expiración.
-func (s *AuthService) newToken(seed string) (AuthResponse, error) {
-	now := time.Now()
-	claims := jwt.RegisteredClaims{
-		Subject:   seed,
-		ExpiresAt: jwt.NewNumericDate(now.Add(s.tokenTTL)),
-		IssuedAt:  jwt.NewNumericDate(now),
+// 10.1.- AssignRole cambia el rol persistido; aplica a partir del siguiente token emitido.
+func (s *AuthService) AssignRole(ctx context.Context, email, role string) error {
+	if !ValidRole(role) {
+		return ErrInvalidRole
+	}
+	normalized := strings.TrimSpace(strings.ToLower(email))
+	return s.repo.UpdateRole(ctx, normalized, role)
+}
+
+// authContext describe el inicio de sesión interactivo: sus métodos (amr) y cuándo ocurrió (auth_time).
+type authContext struct {
+	methods []string
+	at      time.Time
+}
+
+// 10.2.- issue abre una sesión nueva tras un inicio de sesión interactivo con los métodos indicados.
+func (s *AuthService) issue(ctx context.Context, user User, amr ...string) (AuthResponse, error) {
+	return s.issueSession(ctx, user, "", authContext{methods: amr, at: s.now()})
+}
+
+// 10.3.- issueSession emite el token de acceso y, si está habilitado, el de renovación de la familia.
+func (s *AuthService) issueSession(ctx context.Context, user User, familyID string, auth authContext) (AuthResponse, error) {
+	// Es el único punto que firma tokens, así que cubre login, MFA, renovación e invitaciones.
+	if user.Disabled() {
+		return AuthResponse{}, ErrAccountDisabled
+	}
+	if familyID == "" {
+		id, err := randomID()
+		if err != nil {
+			return AuthResponse{}, err
+		}
+		familyID = id
+		s.startSession(ctx, user.Email, familyID)
+	} else {
+		s.touchSession(ctx, familyID)
+	}
+	resp, err := s.newToken(user, familyID, auth)
+	if err != nil {
+		return AuthResponse{}, err
+	}
+	if s.refreshStore == nil {
+		return resp, nil
+	}
+	refresh, expires, err := s.newRefreshToken(ctx, user.Email, familyID, auth)
+	if err != nil {
+		return AuthResponse{}, err
+	}
+	resp.RefreshToken = refresh
+	resp.RefreshExpiresAt = &expires
+	return resp, nil
+}
+
+// 11.- newToken centraliza la construcción del token, el rol, la verificación y la expiración.
+func (s *AuthService) newToken(user User, sessionID string, auth authContext) (AuthResponse, error) {
+	now := s.now()
+	jti, err := randomID()
+	if err != nil {
+		return AuthResponse{}, err
+	}
+	verified := user.Verified()
+	claims := AccessClaims{
+		Role:          user.Role,
+		EmailVerified: &verified,
+		AMR:           auth.methods,
+		SessionID:     sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   user.Email,
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.tokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	if !auth.at.IsZero() {
+		claims.AuthTime = jwt.NewNumericDate(auth.at)
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := token.SignedString(s.jwtSecret)
+	signed, err := s.sign(claims)
 	if err != nil {
-		return AuthResponse{}, fmt.Errorf("sign token: %w", err)
+		return AuthResponse{}, err
 	}
 	return AuthResponse{
 		Token:     signed,
@@ -197,37 +422,105 @@ func (s *AuthService) newToken(seed string) (AuthResponse, error) {
 	}, nil
 }
 
-// 12.- hashPassword asegura que las contraseñas se guarden homogeneizadas.
-func (s *AuthService) hashPassword(password string) (string, error) {
-	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+// 11.1.- sign firma con el anillo de llaves o, si no existe, con el secreto HS256.
+func (s *AuthService) sign(claims jwt.Claims) (string, error) {
+	var (
+		signed string
+		err    error
+	)
+	if s.keyRing != nil {
+		signed, err = s.keyRing.Sign(claims)
+	} else {
+		signed, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.jwtSecret)
+	}
 	if err != nil {
-		return "", fmt.Errorf("hash password: %w", err)
+		return "", fmt.Errorf("sign token: %w", err)
 	}
-	return string(hashed), nil
+	return signed, nil
 }
 
-// 13.- verifyPassword compara la contraseña plana contra el hash almacenado.
-func (s *AuthService) verifyPassword(password, hashed string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(password))
-}
-
-// 14.- ValidateToken analiza y verifica firmas HMAC para las rutas protegidas.
-func (s *AuthService) ValidateToken(token string) (string, error) {
-	parsed, err := jwt.ParseWithClaims(token, &jwt.RegisteredClaims{}, func(t *jwt.Token) (any, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+// 11.2.- verificationKey elige la llave según el algoritmo; HS256 sólo se acepta si aún hay secreto configurado.
+func (s *AuthService) verificationKey(t *jwt.Token) (any, error) {
+	if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+		// Durante la migración a llaves asimétricas los tokens HS256 previos siguen vigentes hasta expirar.
+		if len(s.jwtSecret) == 0 || t.Method != jwt.SigningMethodHS256 {
 			return nil, fmt.Errorf("unexpected signing method: %s", t.Method.Alg())
 		}
 		return s.jwtSecret, nil
-	}, jwt.WithLeeway(5*time.Second))
+	}
+	if s.keyRing == nil {
+		return nil, fmt.Errorf("unexpected signing method: %s", t.Method.Alg())
+	}
+	return s.keyRing.VerificationKey(t)
+}
+
+// 12.- hashPassword asegura que las contraseñas se guarden con el esquema vigente.
+func (s *AuthService) hashPassword(password string) (string, error) {
+	return s.hasher.Hash(password)
+}
+
+// 13.- verifyPassword compara la contraseña plana contra el hash almacenado, sea cual sea su esquema.
+func (s *AuthService) verifyPassword(password, hashed string) error {
+	return s.hasher.Verify(password, hashed)
+}
+
+// 13.1.- upgradePasswordHash migra el hash tras un login correcto; un fallo sólo se registra.
+func (s *AuthService) upgradePasswordHash(ctx context.Context, user User, password string) {
+	if !s.hasher.NeedsRehash(user.PasswordHash) {
+		return
+	}
+	hashed, err := s.hasher.Hash(password)
+	if err == nil {
+		err = s.repo.UpgradePasswordHash(ctx, user.Email, user.PasswordHash, hashed)
+	}
+	if err != nil {
+		s.logger.Error().
+			Err(err).
+			Str("event", "auth.password.rehash_failed").
+			Str("subject", user.Email).
+			Msg("cannot upgrade password hash")
+		return
+	}
+	s.logger.Info().
+		Str("event", "auth.password.rehashed").
+		Str("subject", user.Email).
+		Msg("password hash upgraded")
+}
+
+// 14.- ValidateToken verifica la firma, descarta tokens revocados y devuelve la identidad con su rol.
+func (s *AuthService) ValidateToken(ctx context.Context, token string) (Principal, error) {
+	parsed, err := jwt.ParseWithClaims(token, &AccessClaims{}, s.verificationKey, jwt.WithLeeway(5*time.Second), jwt.WithTimeFunc(s.now))
 	if err != nil {
-		return "", ErrInvalidToken
+		return Principal{}, ErrInvalidToken
 	}
-	claims, ok := parsed.Claims.(*jwt.RegisteredClaims)
+	claims, ok := parsed.Claims.(*AccessClaims)
 	if !ok || !parsed.Valid {
-		return "", ErrInvalidToken
+		return Principal{}, ErrInvalidToken
+	}
+	if claims.ExpiresAt == nil || claims.ExpiresAt.Time.Before(s.now()) {
+		return Principal{}, ErrInvalidToken
+	}
+	if !ValidRole(claims.Role) && claims.Role != RoleAnonymous {
+		return Principal{}, ErrInvalidToken
+	}
+	// Una cuenta suspendida se reporta como tal aunque sus sesiones también estén revocadas.
+	if err := s.checkAccountStatus(ctx, claims.Subject); err != nil {
+		return Principal{}, err
+	}
+	if err := s.checkRevocation(ctx, claims); err != nil {
+		return Principal{}, err
+	}
+	principal := Principal{
+		Subject:       claims.Subject,
+		Role:          claims.Role,
+		TokenID:       claims.ID,
+		ExpiresAt:     claims.ExpiresAt.Time,
+		EmailVerified: claims.EmailVerified == nil || *claims.EmailVerified,
+		AuthMethods:   claims.AMR,
+		SessionID:     claims.SessionID,
 	}
-	if claims.ExpiresAt == nil || time.Until(claims.ExpiresAt.Time) < 0 {
-		return "", ErrInvalidToken
+	if claims.AuthTime != nil {
+		principal.AuthTime = claims.AuthTime.Time
 	}
-	return claims.Subject, nil
+	return principal, nil
 }
@@ -3,6 +3,8 @@ package service
 import (
 	"context"
 	"errors"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -13,31 +15,140 @@ import (
 // 1.- fakeUserRepository simula la persistencia de usuarios para las pruebas.
 type fakeUserRepository struct {
 	mu    sync.RWMutex
-	users map[string]string
+	users map[string]User
 }
 
 func newFakeUserRepository() *fakeUserRepository {
-	return &fakeUserRepository{users: make(map[string]string)}
+	return &fakeUserRepository{users: make(map[string]User)}
 }
 
-func (f *fakeUserRepository) Create(_ context.Context, email, passwordHash string) error {
+func (f *fakeUserRepository) Create(_ context.Context, user User) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
-	if _, exists := f.users[email]; exists {
+	if _, exists := f.users[user.Email]; exists {
 		return ErrEmailConflict
 	}
-	f.users[email] = passwordHash
+	f.users[user.Email] = user
 	return nil
 }
 
-func (f *fakeUserRepository) PasswordHash(_ context.Context, email string) (string, error) {
+func (f *fakeUserRepository) FindByEmail(_ context.Context, email string) (User, error) {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
-	hash, ok := f.users[email]
+	user, ok := f.users[email]
 	if !ok {
-		return "", ErrInvalidCredentials
+		return User{}, ErrUserNotFound
 	}
-	return hash, nil
+	return user, nil
+}
+
+func (f *fakeUserRepository) UpdateRole(_ context.Context, email, role string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user, ok := f.users[email]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.Role = role
+	f.users[email] = user
+	return nil
+}
+
+func (f *fakeUserRepository) UpdatePassword(_ context.Context, email, passwordHash string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user, ok := f.users[email]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.PasswordHash = passwordHash
+	f.users[email] = user
+	return nil
+}
+
+func (f *fakeUserRepository) UpgradePasswordHash(_ context.Context, email, currentHash, newHash string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user, ok := f.users[email]
+	if !ok || user.PasswordHash != currentHash {
+		return nil
+	}
+	user.PasswordHash = newHash
+	f.users[email] = user
+	return nil
+}
+
+func (f *fakeUserRepository) UpdateProfile(_ context.Context, user User) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored, ok := f.users[user.Email]
+	if !ok {
+		return ErrUserNotFound
+	}
+	stored.DisplayName, stored.Phone, stored.Notifications = user.DisplayName, user.Phone, user.Notifications
+	f.users[user.Email] = stored
+	return nil
+}
+
+func (f *fakeUserRepository) Delete(_ context.Context, email string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.users[email]; !ok {
+		return ErrUserNotFound
+	}
+	delete(f.users, email)
+	return nil
+}
+
+func (f *fakeUserRepository) MarkVerified(_ context.Context, email string, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user, ok := f.users[email]
+	if !ok {
+		return ErrUserNotFound
+	}
+	if !user.Verified() {
+		user.VerifiedAt = at
+	}
+	f.users[email] = user
+	return nil
+}
+
+func (f *fakeUserRepository) List(_ context.Context, query UserQuery) ([]User, int, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	search := strings.ToLower(query.Search)
+	var matched []User
+	for _, user := range f.users {
+		if search != "" && !strings.Contains(user.Email, search) && !strings.Contains(strings.ToLower(user.DisplayName), search) {
+			continue
+		}
+		if (query.Role != "" && user.Role != query.Role) || (query.Status != "" && user.Status() != query.Status) {
+			continue
+		}
+		matched = append(matched, user)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].CreatedAt.After(matched[j].CreatedAt)
+		}
+		return matched[i].Email < matched[j].Email
+	})
+	start := min(query.Page*query.PageSize, len(matched))
+	end := min(start+query.PageSize, len(matched))
+	return append([]User{}, matched[start:end]...), len(matched), nil
+}
+
+func (f *fakeUserRepository) SetDisabled(_ context.Context, email string, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user, ok := f.users[email]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.DisabledAt = at
+	f.users[email] = user
+	return nil
 }
 
 func (f *fakeUserRepository) Exists(_ context.Context, email string) (bool, error) {
@@ -110,7 +221,7 @@ func TestRegisterStoresBCryptHashAndIssuesJWT(t *testing.T) {
 
 	// 3.- Confirmamos que el hash guardado no coincide con el texto plano.
 	repo.mu.RLock()
-	stored := repo.users["hash@example.com"]
+	stored := repo.users["hash@example.com"].PasswordHash
 	repo.mu.RUnlock()
 	if stored == "ClaveFuerte1" {
 		t.Fatalf("password stored as plain text")
@@ -119,12 +230,45 @@ func TestRegisterStoresBCryptHashAndIssuesJWT(t *testing.T) {
 		t.Fatalf("bcrypt comparison failed: %v", err)
 	}
 
-	// 4.- Validamos el JWT y recuperamos el sujeto.
-	subject, err := svc.ValidateToken(resp.Token)
+	// 4.- Validamos el JWT y recuperamos el sujeto con el rol ciudadano.
+	principal, err := svc.ValidateToken(ctx, resp.Token)
+	if err != nil {
+		t.Fatalf("token validation failed: %v", err)
+	}
+	if principal.Subject != "hash@example.com" {
+		t.Fatalf("expected subject hash@example.com, got %s", principal.Subject)
+	}
+	if principal.Role != RoleCitizen {
+		t.Fatalf("expected citizen role, got %s", principal.Role)
+	}
+}
+
+func TestAssignRoleIsCarriedInNextToken(t *testing.T) {
+	// 1.- Registramos una cuenta y la promovemos a supervisor.
+	repo := newFakeUserRepository()
+	svc := NewAuthService(repo, 1, time.Minute, []byte("jwt-secret"))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := svc.Register(ctx, "jefa@example.com", "ClaveFuerte1"); err != nil {
+		t.Fatalf("unexpected register error: %v", err)
+	}
+	if err := svc.AssignRole(ctx, "Jefa@Example.com", "superuser"); !errors.Is(err, ErrInvalidRole) {
+		t.Fatalf("expected ErrInvalidRole, got %v", err)
+	}
+	if err := svc.AssignRole(ctx, "Jefa@Example.com", RoleSupervisor); err != nil {
+		t.Fatalf("AssignRole returned error: %v", err)
+	}
+
+	// 2.- El siguiente login emite el rol como claim del JWT.
+	resp, err := svc.Authenticate(ctx, "jefa@example.com", "ClaveFuerte1")
+	if err != nil {
+		t.Fatalf("Authenticate returned error: %v", err)
+	}
+	principal, err := svc.ValidateToken(ctx, resp.Token)
 	if err != nil {
 		t.Fatalf("token validation failed: %v", err)
 	}
-	if subject != "hash@example.com" {
-		t.Fatalf("expected subject hash@example.com, got %s", subject)
+	if principal.Role != RoleSupervisor || !principal.Can(PermReportsDelete) || principal.Can(PermUsersManage) {
+		t.Fatalf("unexpected principal permissions: %+v", principal)
 	}
 }
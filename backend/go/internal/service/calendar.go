@@ -0,0 +1,126 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxCalendarDays acota la búsqueda del siguiente día hábil aunque el calendario tenga muchos festivos.
+const maxCalendarDays = 3660
+
+// 1.- BusinessCalendarConfig describe el horario municipal en el documento de SLA.
+type BusinessCalendarConfig struct {
+	Timezone string   `json:"timezone"`
+	Workdays []string `json:"workdays"`
+	Opens    string   `json:"opens"`
+	Closes   string   `json:"closes"`
+	Holidays []string `json:"holidays"`
+}
+
+// 2.- BusinessCalendar suma tiempo hábil respetando jornada, días laborables y festivos en la zona del municipio.
+type BusinessCalendar struct {
+	location *time.Location
+	opens    int
+	closes   int
+	workdays [7]bool
+	holidays map[string]struct{}
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// 3.- NewBusinessCalendar valida el horario; por omisión usa UTC, lunes a viernes de 09:00 a 18:00.
+func NewBusinessCalendar(config BusinessCalendarConfig) (*BusinessCalendar, error) {
+	calendar := &BusinessCalendar{location: time.UTC, holidays: make(map[string]struct{}, len(config.Holidays))}
+	if tz := strings.TrimSpace(config.Timezone); tz != "" {
+		location, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("calendar timezone: %w", err)
+		}
+		calendar.location = location
+	}
+	workdays := config.Workdays
+	if len(workdays) == 0 {
+		workdays = []string{"mon", "tue", "wed", "thu", "fri"}
+	}
+	for _, name := range workdays {
+		day, ok := weekdayNames[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("calendar workday %q is not a weekday abbreviation", name)
+		}
+		calendar.workdays[day] = true
+	}
+	var err error
+	if calendar.opens, err = parseClock(config.Opens, "09:00"); err != nil {
+		return nil, err
+	}
+	if calendar.closes, err = parseClock(config.Closes, "18:00"); err != nil {
+		return nil, err
+	}
+	if calendar.closes <= calendar.opens {
+		return nil, errors.New("calendar closing time must be after opening time")
+	}
+	for _, holiday := range config.Holidays {
+		date, err := time.Parse(time.DateOnly, strings.TrimSpace(holiday))
+		if err != nil {
+			return nil, fmt.Errorf("calendar holiday %q must use YYYY-MM-DD", holiday)
+		}
+		calendar.holidays[date.Format(time.DateOnly)] = struct{}{}
+	}
+	return calendar, nil
+}
+
+// parseClock convierte HH:MM en minutos desde la medianoche.
+func parseClock(value, fallback string) (int, error) {
+	if strings.TrimSpace(value) == "" {
+		value = fallback
+	}
+	parsed, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("calendar time %q must use HH:MM", value)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+// 4.- Add devuelve el instante en que se cumplen d horas hábiles contadas desde from.
+func (c *BusinessCalendar) Add(from time.Time, d time.Duration) time.Time {
+	cursor := from.In(c.location)
+	remaining := d
+	for i := 0; i < maxCalendarDays; i++ {
+		year, month, day := cursor.Date()
+		if c.isBusinessDay(cursor) {
+			opens := time.Date(year, month, day, 0, c.opens, 0, 0, c.location)
+			closes := time.Date(year, month, day, 0, c.closes, 0, 0, c.location)
+			if cursor.Before(opens) {
+				cursor = opens
+			}
+			if cursor.Before(closes) {
+				available := closes.Sub(cursor)
+				if remaining <= available {
+					return cursor.Add(remaining)
+				}
+				remaining -= available
+			}
+		}
+		cursor = time.Date(year, month, day+1, 0, 0, 0, 0, c.location)
+	}
+	return cursor
+}
+
+// isBusinessDay descarta fines de semana y festivos según la fecha local.
+func (c *BusinessCalendar) isBusinessDay(t time.Time) bool {
+	if !c.workdays[t.Weekday()] {
+		return false
+	}
+	_, holiday := c.holidays[t.Format(time.DateOnly)]
+	return !holiday
+}
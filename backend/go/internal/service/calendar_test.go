@@ -0,0 +1,56 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBusinessCalendarSkipsNightsWeekendsAndHolidays(t *testing.T) {
+	// 1.- Calendario de la Ciudad de México con el lunes 6 de mayo como día inhábil.
+	calendar, err := NewBusinessCalendar(BusinessCalendarConfig{
+		Timezone: "America/Mexico_City",
+		Opens:    "09:00",
+		Closes:   "18:00",
+		Holidays: []string{"2024-05-06"},
+	})
+	if err != nil {
+		t.Fatalf("NewBusinessCalendar returned error: %v", err)
+	}
+	location, _ := time.LoadLocation("America/Mexico_City")
+
+	cases := []struct {
+		name string
+		from time.Time
+		add  time.Duration
+		want time.Time
+	}{
+		// 2.- Dentro de la jornada sólo se suma el tiempo.
+		{"same day", time.Date(2024, 5, 2, 10, 0, 0, 0, location), 3 * time.Hour, time.Date(2024, 5, 2, 13, 0, 0, 0, location)},
+		// 3.- El viernes por la tarde continúa el martes porque el lunes es festivo.
+		{"weekend and holiday", time.Date(2024, 5, 3, 17, 0, 0, 0, location), 2 * time.Hour, time.Date(2024, 5, 7, 10, 0, 0, 0, location)},
+		// 4.- Antes de abrir se empieza a contar desde la apertura.
+		{"before opening", time.Date(2024, 5, 7, 6, 30, 0, 0, location), time.Hour, time.Date(2024, 5, 7, 10, 0, 0, 0, location)},
+		// 5.- La entrada en UTC se interpreta en la zona del municipio.
+		{"utc input", time.Date(2024, 5, 8, 23, 0, 0, 0, time.UTC), 9 * time.Hour, time.Date(2024, 5, 9, 17, 0, 0, 0, location)},
+	}
+	for _, tc := range cases {
+		if got := calendar.Add(tc.from, tc.add); !got.Equal(tc.want) {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestBusinessCalendarRejectsInvalidConfig(t *testing.T) {
+	// 6.- Los errores de captura se detectan al cargar el documento y no al vigilar plazos.
+	invalid := []BusinessCalendarConfig{
+		{Timezone: "Luna/Tranquilidad"},
+		{Workdays: []string{"lunes"}},
+		{Opens: "18:00", Closes: "09:00"},
+		{Holidays: []string{"16/09/2024"}},
+	}
+	for _, config := range invalid {
+		if _, err := NewBusinessCalendar(config); err == nil {
+			t.Errorf("expected %+v to be rejected", config)
+		}
+	}
+}
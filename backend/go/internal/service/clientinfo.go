@@ -0,0 +1,25 @@
+package service
+
+import "context"
+
+// 1.- ClientInfo describe el origen de la petición que llega desde la capa HTTP.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+	// DeviceName y Platform los declara la app para mostrarlos en la lista de sesiones.
+	DeviceName string
+	Platform   string
+}
+
+type clientInfoKey struct{}
+
+// 2.- WithClientInfo adjunta el origen de la petición al contexto.
+func WithClientInfo(ctx context.Context, info ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, info)
+}
+
+// 3.- ClientInfoFrom recupera el origen o un valor vacío si la capa HTTP no lo adjuntó.
+func ClientInfoFrom(ctx context.Context) ClientInfo {
+	info, _ := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return info
+}
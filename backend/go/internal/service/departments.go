@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// 1.- Department agrupa las cuadrillas de una dependencia municipal.
+type Department struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Crews     []Crew    `json:"crews"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// 1.1.- Crew es el equipo de campo que atiende los reportes asignados.
+type Crew struct {
+	ID           string    `json:"id"`
+	DepartmentID string    `json:"departmentId"`
+	Name         string    `json:"name"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// 1.2.- Assignment indica qué dependencia y, si ya se eligió, qué cuadrilla atiende el reporte.
+type Assignment struct {
+	DepartmentID string    `json:"departmentId"`
+	CrewID       string    `json:"crewId,omitempty"`
+	AssignedAt   time.Time `json:"assignedAt"`
+}
+
+// 1.3.- AssignmentRequest agrupa el destino con quién asigna y por qué.
+type AssignmentRequest struct {
+	DepartmentID string
+	CrewID       string
+	Actor        string
+	Reason       string
+}
+
+// 2.- DepartmentStore persiste el catálogo de dependencias y cuadrillas.
+type DepartmentStore interface {
+	CreateDepartment(ctx context.Context, department Department) error
+	CreateCrew(ctx context.Context, crew Crew) error
+	// Departments devuelve las dependencias por nombre con sus cuadrillas.
+	Departments(ctx context.Context) ([]Department, error)
+	FindDepartment(ctx context.Context, id string) (Department, error)
+	FindCrew(ctx context.Context, id string) (Crew, error)
+}
+
+// 3.- Errores del catálogo de dependencias y de la asignación.
+var (
+	ErrDepartmentNotFound       = errors.New("department not found")
+	ErrCrewNotFound             = errors.New("crew not found")
+	ErrDepartmentExists         = errors.New("department name already exists")
+	ErrCrewExists               = errors.New("crew name already exists in the department")
+	ErrCrewDepartmentMismatch   = errors.New("crew does not belong to the department")
+	ErrAssignmentReasonRequired = errors.New("reassigning a report requires a reason")
+	errDepartmentsDisabled      = errors.New("departments are not configured")
+)
+
+// 4.- WithDepartments habilita el catálogo de dependencias y la asignación de reportes.
+func WithDepartments(store DepartmentStore) ReportOption {
+	return func(s *ReportService) {
+		s.departments = store
+	}
+}
+
+// 5.- Departments lista las dependencias con sus cuadrillas para el panel de asignación.
+func (s *ReportService) Departments(ctx context.Context) ([]Department, error) {
+	if s.departments == nil {
+		return nil, errDepartmentsDisabled
+	}
+	return s.departments.Departments(ctx)
+}
+
+// 6.- CreateDepartment registra una dependencia; el nombre no distingue mayúsculas.
+func (s *ReportService) CreateDepartment(ctx context.Context, actor, name string) (Department, error) {
+	if s.departments == nil {
+		return Department{}, errDepartmentsDisabled
+	}
+	id, err := randomID()
+	if err != nil {
+		return Department{}, err
+	}
+	department := Department{ID: id, Name: strings.TrimSpace(name), Crews: []Crew{}, CreatedAt: s.now().UTC()}
+	if err := s.departments.CreateDepartment(ctx, department); err != nil {
+		return Department{}, err
+	}
+	s.logger.Info().
+		Str("event", "department.created").
+		Str("department_id", department.ID).
+		Str("actor", actor).
+		Msg("department created")
+	return department, nil
+}
+
+// 7.- CreateCrew agrega una cuadrilla a una dependencia existente.
+func (s *ReportService) CreateCrew(ctx context.Context, actor, departmentID, name string) (Crew, error) {
+	if s.departments == nil {
+		return Crew{}, errDepartmentsDisabled
+	}
+	department, err := s.departments.FindDepartment(ctx, strings.TrimSpace(departmentID))
+	if err != nil {
+		return Crew{}, err
+	}
+	id, err := randomID()
+	if err != nil {
+		return Crew{}, err
+	}
+	crew := Crew{ID: id, DepartmentID: department.ID, Name: strings.TrimSpace(name), CreatedAt: s.now().UTC()}
+	if err := s.departments.CreateCrew(ctx, crew); err != nil {
+		return Crew{}, err
+	}
+	s.logger.Info().
+		Str("event", "crew.created").
+		Str("department_id", department.ID).
+		Str("crew_id", crew.ID).
+		Str("actor", actor).
+		Msg("crew created")
+	return crew, nil
+}
+
+// 8.- Assign canaliza el reporte a una dependencia o cuadrilla; reasignar exige motivo.
+func (s *ReportService) Assign(ctx context.Context, id string, request AssignmentRequest) (Report, error) {
+	select {
+	case <-ctx.Done():
+		return Report{}, ctx.Err()
+	default:
+	}
+	if s.departments == nil {
+		return Report{}, errDepartmentsDisabled
+	}
+	assignment, err := s.resolveAssignment(ctx, request)
+	if err != nil {
+		return Report{}, err
+	}
+	report, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return Report{}, err
+	}
+	reason := strings.TrimSpace(request.Reason)
+	if report.Assignment != nil && reason == "" {
+		return Report{}, ErrAssignmentReasonRequired
+	}
+	event := ReportEvent{
+		ReportID:     id,
+		Kind:         ReportEventAssigned,
+		Status:       report.Status,
+		Actor:        request.Actor,
+		Reason:       reason,
+		DepartmentID: assignment.DepartmentID,
+		CrewID:       assignment.CrewID,
+		CreatedAt:    assignment.AssignedAt,
+	}
+	updated, err := s.repo.Assign(ctx, assignment, event)
+	if err != nil {
+		s.logger.Error().Err(err).Str("event", "report.assign.failed").Str("report_id", id).Msg("unable to assign report")
+		return Report{}, err
+	}
+	s.logger.Info().
+		Str("event", "report.assigned").
+		Str("report_id", id).
+		Str("department_id", assignment.DepartmentID).
+		Str("crew_id", assignment.CrewID).
+		Str("actor", request.Actor).
+		Msg("report assigned")
+	return updated, nil
+}
+
+// resolveAssignment valida el destino; con sólo la cuadrilla se deduce su dependencia.
+func (s *ReportService) resolveAssignment(ctx context.Context, request AssignmentRequest) (Assignment, error) {
+	assignment := Assignment{
+		DepartmentID: strings.TrimSpace(request.DepartmentID),
+		CrewID:       strings.TrimSpace(request.CrewID),
+		AssignedAt:   s.now().UTC(),
+	}
+	if assignment.CrewID != "" {
+		crew, err := s.departments.FindCrew(ctx, assignment.CrewID)
+		if err != nil {
+			return Assignment{}, err
+		}
+		if assignment.DepartmentID != "" && assignment.DepartmentID != crew.DepartmentID {
+			return Assignment{}, ErrCrewDepartmentMismatch
+		}
+		assignment.DepartmentID = crew.DepartmentID
+		return assignment, nil
+	}
+	if _, err := s.departments.FindDepartment(ctx, assignment.DepartmentID); err != nil {
+		return Assignment{}, err
+	}
+	return assignment, nil
+}
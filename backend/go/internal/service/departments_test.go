@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 1.- memoryDepartmentStore replica los índices únicos por nombre de Postgres.
+type memoryDepartmentStore struct {
+	mu          sync.Mutex
+	departments map[string]Department
+	crews       map[string]Crew
+}
+
+func newMemoryDepartmentStore() *memoryDepartmentStore {
+	return &memoryDepartmentStore{departments: make(map[string]Department), crews: make(map[string]Crew)}
+}
+
+func (m *memoryDepartmentStore) CreateDepartment(_ context.Context, department Department) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.departments {
+		if strings.EqualFold(existing.Name, department.Name) {
+			return ErrDepartmentExists
+		}
+	}
+	m.departments[department.ID] = department
+	return nil
+}
+
+func (m *memoryDepartmentStore) CreateCrew(_ context.Context, crew Crew) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.crews {
+		if existing.DepartmentID == crew.DepartmentID && strings.EqualFold(existing.Name, crew.Name) {
+			return ErrCrewExists
+		}
+	}
+	m.crews[crew.ID] = crew
+	return nil
+}
+
+func (m *memoryDepartmentStore) Departments(_ context.Context) ([]Department, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	departments := make([]Department, 0, len(m.departments))
+	for _, department := range m.departments {
+		department.Crews = []Crew{}
+		for _, crew := range m.crews {
+			if crew.DepartmentID == department.ID {
+				department.Crews = append(department.Crews, crew)
+			}
+		}
+		departments = append(departments, department)
+	}
+	sort.Slice(departments, func(i, j int) bool { return departments[i].Name < departments[j].Name })
+	return departments, nil
+}
+
+func (m *memoryDepartmentStore) FindDepartment(_ context.Context, id string) (Department, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	department, ok := m.departments[id]
+	if !ok {
+		return Department{}, ErrDepartmentNotFound
+	}
+	return department, nil
+}
+
+func (m *memoryDepartmentStore) FindCrew(_ context.Context, id string) (Crew, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	crew, ok := m.crews[id]
+	if !ok {
+		return Crew{}, ErrCrewNotFound
+	}
+	return crew, nil
+}
+
+func TestAssignRecordsHistoryAndRequiresReasonToReassign(t *testing.T) {
+	// 2.- Damos de alta dos dependencias con una cuadrilla cada una.
+	repo := newFakeReportRepository()
+	svc := NewReportService(repo, newTestFolioGenerator(), 1, 1, WithDepartments(newMemoryDepartmentStore()))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	works, err := svc.CreateDepartment(ctx, "admin@example.com", "Obras Públicas")
+	if err != nil {
+		t.Fatalf("CreateDepartment returned error: %v", err)
+	}
+	if _, err := svc.CreateDepartment(ctx, "admin@example.com", "obras públicas"); !errors.Is(err, ErrDepartmentExists) {
+		t.Fatalf("expected duplicate department, got %v", err)
+	}
+	lighting, _ := svc.CreateDepartment(ctx, "admin@example.com", "Alumbrado")
+	bacheo, err := svc.CreateCrew(ctx, "admin@example.com", works.ID, "Bacheo Norte")
+	if err != nil {
+		t.Fatalf("CreateCrew returned error: %v", err)
+	}
+	nightCrew, _ := svc.CreateCrew(ctx, "admin@example.com", lighting.ID, "Turno nocturno")
+	if _, err := svc.CreateCrew(ctx, "admin@example.com", "desconocida", "Sin dependencia"); !errors.Is(err, ErrDepartmentNotFound) {
+		t.Fatalf("expected missing department, got %v", err)
+	}
+
+	// 3.- La primera asignación no requiere motivo y deduce la dependencia de la cuadrilla.
+	payload := map[string]any{"incidentTypeId": "pothole", "description": "Bache", "latitude": 19.4, "longitude": -99.1}
+	report, err := svc.Submit(ctx, "vecino@example.com", payload)
+	if err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+	if _, err := svc.Assign(ctx, report.ID, AssignmentRequest{DepartmentID: lighting.ID, CrewID: bacheo.ID}); !errors.Is(err, ErrCrewDepartmentMismatch) {
+		t.Fatalf("expected crew mismatch, got %v", err)
+	}
+	assigned, err := svc.Assign(ctx, report.ID, AssignmentRequest{CrewID: bacheo.ID, Actor: "operador@example.com"})
+	if err != nil {
+		t.Fatalf("Assign returned error: %v", err)
+	}
+	if assigned.Assignment == nil || assigned.Assignment.DepartmentID != works.ID || assigned.Assignment.CrewID != bacheo.ID {
+		t.Fatalf("unexpected assignment %+v", assigned.Assignment)
+	}
+
+	// 4.- Reasignar sin motivo falla; el motivo queda en la bitácora interna pero no en el seguimiento público.
+	if _, err := svc.Assign(ctx, report.ID, AssignmentRequest{CrewID: nightCrew.ID, Actor: "operador@example.com"}); !errors.Is(err, ErrAssignmentReasonRequired) {
+		t.Fatalf("expected reason to be required, got %v", err)
+	}
+	if _, err := svc.Assign(ctx, report.ID, AssignmentRequest{CrewID: nightCrew.ID, Actor: "operador@example.com", Reason: "Es una luminaria"}); err != nil {
+		t.Fatalf("Assign returned error: %v", err)
+	}
+	events, err := svc.Events(ctx, report.ID)
+	if err != nil || len(events) != 3 {
+		t.Fatalf("unexpected events %+v (%v)", events, err)
+	}
+	if last := events[2]; last.Kind != ReportEventAssigned || last.CrewID != nightCrew.ID || last.Reason != "Es una luminaria" {
+		t.Fatalf("unexpected reassignment event %+v", last)
+	}
+	status, err := svc.Lookup(ctx, report.ID)
+	if err != nil || status.History[1].Description != "Asignado a cuadrilla" {
+		t.Fatalf("unexpected folio history %+v (%v)", status.History, err)
+	}
+	for _, entry := range status.History {
+		if entry.Reason != "" {
+			t.Fatalf("expected the reassignment reason to stay internal, got %+v", entry)
+		}
+	}
+
+	// 5.- El listado filtra por dependencia y cuadrilla vigentes.
+	byCrew, err := svc.List(ctx, ReportFilter{CrewID: nightCrew.ID})
+	if err != nil || byCrew.TotalCount != 1 {
+		t.Fatalf("expected report under the night crew, got %+v (%v)", byCrew, err)
+	}
+	if byDepartment, _ := svc.List(ctx, ReportFilter{DepartmentID: works.ID}); byDepartment.TotalCount != 0 {
+		t.Fatalf("expected no reports left in the previous department, got %+v", byDepartment)
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"fmt"
+	"time"
+)
+
+// 1.- Tipos de evento que conforman la bitácora de cada reporte.
+const (
+	ReportEventSubmitted     = "submitted"
+	ReportEventStatusChanged = "status_changed"
+	ReportEventAssigned      = "assigned"
+	ReportEventNote          = "note"
+	// ReportEventEscalated es interno: lo registra el SLA y no aparece en el seguimiento ciudadano.
+	ReportEventEscalated = "escalated"
+)
+
+// 2.- ReportEvent registra un hecho con sello de tiempo, actor y motivo.
+type ReportEvent struct {
+	ID       int64  `json:"id"`
+	ReportID string `json:"reportId"`
+	Kind     string `json:"kind"`
+	Status   string `json:"status"`
+	Actor    string `json:"actor"`
+	Reason   string `json:"reason,omitempty"`
+	// DepartmentID y CrewID sólo se llenan en los eventos de asignación.
+	DepartmentID string    `json:"departmentId,omitempty"`
+	CrewID       string    `json:"crewId,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// 3.- FolioHistoryEntry es la vista ciudadana de un evento, sin datos del personal; Reason sólo viaja en las notas.
+type FolioHistoryEntry struct {
+	Type        string    `json:"type"`
+	Status      string    `json:"status"`
+	Description string    `json:"description"`
+	Reason      string    `json:"reason,omitempty"`
+	OccurredAt  time.Time `json:"occurredAt"`
+}
+
+// 4.- StatusChange agrupa el nuevo estatus con quién lo solicitó y por qué.
+type StatusChange struct {
+	Status string
+	Actor  string
+	Reason string
+}
+
+// 5.- buildFolioStatus arma el seguimiento público a partir de la bitácora real.
+func buildFolioStatus(report Report, events []ReportEvent) FolioStatus {
+	status := FolioStatus{
+		Folio:      report.ID,
+		Status:     report.Status,
+		LastUpdate: report.CreatedAt,
+		History:    make([]FolioHistoryEntry, 0, len(events)),
+	}
+	for _, event := range events {
+		if event.Kind == ReportEventEscalated {
+			continue
+		}
+		entry := FolioHistoryEntry{
+			Type:        event.Kind,
+			Status:      event.Status,
+			Description: describeEvent(event),
+			OccurredAt:  event.CreatedAt,
+		}
+		// Sólo las notas se escriben para el ciudadano; los motivos de cambios y reasignaciones son internos.
+		if event.Kind == ReportEventNote {
+			entry.Reason = event.Reason
+		}
+		status.History = append(status.History, entry)
+		if event.CreatedAt.After(status.LastUpdate) {
+			status.LastUpdate = event.CreatedAt
+		}
+	}
+	return status
+}
+
+// 6.- describeEvent traduce el tipo de evento en un mensaje legible para el ciudadano.
+func describeEvent(event ReportEvent) string {
+	switch event.Kind {
+	case ReportEventSubmitted:
+		return "Reporte recibido"
+	case ReportEventStatusChanged:
+		return fmt.Sprintf("Estatus actualizado a %s", event.Status)
+	case ReportEventAssigned:
+		if event.CrewID == "" {
+			return "Canalizado a la dependencia"
+		}
+		return "Asignado a cuadrilla"
+	case ReportEventNote:
+		return "Actualización del personal"
+	default:
+		return event.Kind
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 1.- FolioGenerator entrega folios únicos aun con múltiples instancias del backend.
+type FolioGenerator interface {
+	NextFolio(ctx context.Context) (string, error)
+}
+
+// 2.- FolioSequence abstrae la fuente durable de números consecutivos.
+type FolioSequence interface {
+	NextValue(ctx context.Context) (int64, error)
+}
+
+// 3.- ErrInvalidFolio indica un folio mal capturado o con dígito verificador incorrecto.
+var ErrInvalidFolio = errors.New("invalid folio")
+
+var (
+	folioPattern       = regexp.MustCompile(`^([A-Z]{1,8})-([0-9]{4})-([0-9]{7,12})-([0-9])$`)
+	legacyFolioPattern = regexp.MustCompile(`^F-[0-9]{5}$`)
+	prefixPattern      = regexp.MustCompile(`^[A-Z]{1,8}$`)
+)
+
+// 4.- SequenceFolioGenerator arma folios PREFIJO-AÑO-CONSECUTIVO-DÍGITO.
+type SequenceFolioGenerator struct {
+	sequence FolioSequence
+	prefix   string
+	now      func() time.Time
+}
+
+// 5.- NewSequenceFolioGenerator valida el prefijo municipal y la secuencia inyectada.
+// El prefijo llega de la configuración, así que un valor inválido se devuelve como error.
+func NewSequenceFolioGenerator(sequence FolioSequence, prefix string) (*SequenceFolioGenerator, error) {
+	if sequence == nil {
+		panic("folio sequence is required")
+	}
+	normalized := strings.ToUpper(strings.TrimSpace(prefix))
+	if !prefixPattern.MatchString(normalized) {
+		return nil, fmt.Errorf("folio prefix %q must contain 1 to 8 letters", prefix)
+	}
+	return &SequenceFolioGenerator{sequence: sequence, prefix: normalized, now: time.Now}, nil
+}
+
+// 6.- NextFolio toma el siguiente consecutivo y agrega el dígito verificador.
+func (g *SequenceFolioGenerator) NextFolio(ctx context.Context) (string, error) {
+	value, err := g.sequence.NextValue(ctx)
+	if err != nil {
+		return "", fmt.Errorf("next folio value: %w", err)
+	}
+	if value <= 0 {
+		return "", fmt.Errorf("folio sequence returned %d", value)
+	}
+	year := g.now().Year()
+	number := fmt.Sprintf("%07d", value)
+	check := folioCheckDigit(fmt.Sprintf("%04d%s", year, number))
+	return fmt.Sprintf("%s-%04d-%s-%d", g.prefix, year, number, check), nil
+}
+
+// 7.- NormalizeFolio limpia la captura y rechaza folios con formato o dígito inválido.
+func NormalizeFolio(raw string) (string, error) {
+	folio := strings.ToUpper(strings.TrimSpace(raw))
+	if legacyFolioPattern.MatchString(folio) {
+		return folio, nil
+	}
+	parts := folioPattern.FindStringSubmatch(folio)
+	if parts == nil {
+		return "", ErrInvalidFolio
+	}
+	expected := folioCheckDigit(parts[2] + parts[3])
+	if strconv.Itoa(expected) != parts[4] {
+		return "", ErrInvalidFolio
+	}
+	return folio, nil
+}
+
+// dammTable es la cuasigrupo de orden 10 usada por el algoritmo de Damm.
+var dammTable = [10][10]int{
+	{0, 3, 1, 7, 5, 9, 8, 6, 4, 2},
+	{7, 0, 9, 2, 1, 5, 4, 8, 6, 3},
+	{4, 2, 0, 6, 8, 7, 1, 3, 5, 9},
+	{1, 7, 5, 0, 9, 8, 3, 4, 2, 6},
+	{6, 1, 2, 3, 0, 4, 5, 9, 7, 8},
+	{3, 6, 7, 4, 2, 0, 9, 5, 8, 1},
+	{5, 8, 6, 9, 7, 2, 0, 1, 3, 4},
+	{8, 9, 4, 5, 3, 6, 2, 0, 1, 7},
+	{9, 4, 3, 8, 6, 1, 7, 2, 0, 5},
+	{2, 5, 8, 1, 4, 3, 6, 7, 9, 0},
+}
+
+// 8.- folioCheckDigit detecta cualquier dígito erróneo y transposiciones adyacentes.
+func folioCheckDigit(digits string) int {
+	interim := 0
+	for _, r := range digits {
+		interim = dammTable[interim][int(r-'0')]
+	}
+	return interim
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSequenceFolioGeneratorProducesVerifiableFolios(t *testing.T) {
+	// 1.- Fijamos el reloj para obtener un folio determinista.
+	gen, err := NewSequenceFolioGenerator(&memoryFolioSequence{}, "cdmx")
+	if err != nil {
+		t.Fatalf("NewSequenceFolioGenerator returned error: %v", err)
+	}
+	gen.now = func() time.Time { return time.Date(2026, time.March, 3, 10, 0, 0, 0, time.UTC) }
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// 2.- Generamos dos folios consecutivos y verificamos que sean distintos y válidos.
+	first, err := gen.NextFolio(ctx)
+	if err != nil {
+		t.Fatalf("NextFolio returned error: %v", err)
+	}
+	second, err := gen.NextFolio(ctx)
+	if err != nil {
+		t.Fatalf("NextFolio returned error: %v", err)
+	}
+	if first == second {
+		t.Fatalf("expected unique folios, got %s twice", first)
+	}
+	if first[:15] != "CDMX-2026-00000" {
+		t.Fatalf("unexpected folio layout: %s", first)
+	}
+	for _, folio := range []string{first, second} {
+		if _, err := NormalizeFolio(folio); err != nil {
+			t.Fatalf("generated folio %s did not validate: %v", folio, err)
+		}
+	}
+}
+
+func TestNormalizeFolioRejectsTypos(t *testing.T) {
+	// 1.- Construimos un folio válido a partir del dígito verificador.
+	valid := "CDMX-2026-0000123-" + string(rune('0'+folioCheckDigit("20260000123")))
+	if normalized, err := NormalizeFolio(" " + valid + " "); err != nil || normalized != valid {
+		t.Fatalf("expected %s to validate, got %q, %v", valid, normalized, err)
+	}
+
+	// 2.- Un dígito cambiado o una transposición adyacente deben rechazarse.
+	mistyped := []string{
+		"CDMX-2026-0000124" + valid[len(valid)-2:],
+		"CDMX-2026-0000213" + valid[len(valid)-2:],
+		"CDMX-2062-0000123" + valid[len(valid)-2:],
+		"CDMX-2026-123",
+		"",
+	}
+	for _, folio := range mistyped {
+		if _, err := NormalizeFolio(folio); !errors.Is(err, ErrInvalidFolio) {
+			t.Fatalf("expected ErrInvalidFolio for %q, got %v", folio, err)
+		}
+	}
+
+	// 3.- Los folios heredados siguen siendo consultables.
+	if _, err := NormalizeFolio("f-12345"); err != nil {
+		t.Fatalf("expected legacy folio to validate, got %v", err)
+	}
+}
+
+func TestSequenceFolioGeneratorRejectsInvalidPrefixes(t *testing.T) {
+	// 1.- Un prefijo mal configurado se devuelve como error en lugar de detener el proceso.
+	for _, prefix := range []string{"", "F1", "MUNICIPIO", "CD-MX"} {
+		if gen, err := NewSequenceFolioGenerator(&memoryFolioSequence{}, prefix); err == nil || gen != nil {
+			t.Fatalf("expected error for prefix %q, got %v", prefix, gen)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 1.- JWKSCache descarga y conserva las llaves públicas de un proveedor de identidad.
+type JWKSCache struct {
+	url         string
+	client      *http.Client
+	ttl         time.Duration
+	minInterval time.Duration
+	now         func() time.Time
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+}
+
+// 2.- NewJWKSCache define la vigencia de la caché; un kid desconocido fuerza una recarga limitada.
+func NewJWKSCache(url string, client *http.Client, ttl time.Duration) *JWKSCache {
+	if url == "" {
+		panic("jwks url is required")
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &JWKSCache{
+		url:         url,
+		client:      client,
+		ttl:         ttl,
+		minInterval: time.Minute,
+		now:         time.Now,
+		keys:        make(map[string]crypto.PublicKey),
+	}
+}
+
+// 3.- Key devuelve la llave del kid solicitado recargando el JWKS cuando hace falta.
+func (c *JWKSCache) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	key, ok := c.keys[kid]
+	expired := now.Sub(c.fetchedAt) >= c.ttl
+	// Las rotaciones del proveedor publican kids nuevos; se recarga sin esperar al ttl pero con un intervalo mínimo.
+	if expired || (!ok && now.Sub(c.fetchedAt) >= c.minInterval) {
+		keys, err := c.fetch(ctx)
+		if err != nil {
+			if ok {
+				return key, nil
+			}
+			return nil, err
+		}
+		c.keys = keys
+		c.fetchedAt = now
+		key, ok = keys[kid]
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// 4.- fetch descarga el documento JWKS y convierte las llaves RSA y EC soportadas.
+func (c *JWKSCache) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks: unexpected status %d", resp.StatusCode)
+	}
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode jwks: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	for _, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks contains no usable keys")
+	}
+	return keys, nil
+}
+
+// 5.- publicKey traduce la representación JWK a la llave pública de crypto.
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
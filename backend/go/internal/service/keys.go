@@ -0,0 +1,199 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 1.- SigningKey representa una llave RS256 o EdDSA identificada por su kid.
+type SigningKey struct {
+	ID      string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// 2.- KeyRing firma con la llave activa y verifica con todas las llaves publicadas.
+type KeyRing struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+	order  []string
+}
+
+// 3.- JSONWebKey es la representación pública que se publica en /.well-known/jwks.json.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// 4.- JSONWebKeySet agrupa las llaves vigentes para verificadores externos.
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// 5.- LoadSigningKey lee un PEM privado (PKCS#8 o PKCS#1) o público; el kid es la huella RFC 7638.
+func LoadSigningKey(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read signing key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("signing key %s: no PEM block found", path)
+	}
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("signing key %s: unsupported PEM type %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("signing key %s: %w", path, err)
+	}
+	return NewSigningKey(parsed)
+}
+
+// 6.- NewSigningKey acepta llaves privadas o sólo públicas para verificación.
+func NewSigningKey(key any) (*SigningKey, error) {
+	k := &SigningKey{}
+	switch typed := key.(type) {
+	case *rsa.PrivateKey:
+		k.method, k.private, k.public = jwt.SigningMethodRS256, typed, &typed.PublicKey
+	case *rsa.PublicKey:
+		k.method, k.public = jwt.SigningMethodRS256, typed
+	case ed25519.PrivateKey:
+		k.method, k.private, k.public = jwt.SigningMethodEdDSA, typed, typed.Public()
+	case ed25519.PublicKey:
+		k.method, k.public = jwt.SigningMethodEdDSA, typed
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %T", key)
+	}
+	if rsaKey, ok := k.public.(*rsa.PublicKey); ok && rsaKey.N.BitLen() < 2048 {
+		return nil, errors.New("rsa signing keys must be at least 2048 bits")
+	}
+	k.ID = thumbprint(k.JWK())
+	return k, nil
+}
+
+// 7.- JWK devuelve la parte pública de la llave en formato JWK.
+func (k *SigningKey) JWK() JSONWebKey {
+	jwk := JSONWebKey{Kid: k.ID, Use: "sig", Alg: k.method.Alg()}
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+	return jwk
+}
+
+// 8.- thumbprint calcula la huella RFC 7638 para que todas las instancias deriven el mismo kid.
+func thumbprint(jwk JSONWebKey) string {
+	var canonical []byte
+	switch jwk.Kty {
+	case "RSA":
+		canonical, _ = json.Marshal(struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N})
+	case "OKP":
+		canonical, _ = json.Marshal(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X})
+	}
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// 9.- NewKeyRing exige una llave activa privada; las demás sólo verifican tokens ya emitidos.
+func NewKeyRing(active *SigningKey, verification ...*SigningKey) (*KeyRing, error) {
+	if active == nil || active.private == nil {
+		return nil, errors.New("active signing key must include the private key")
+	}
+	ring := &KeyRing{active: active, keys: map[string]*SigningKey{active.ID: active}, order: []string{active.ID}}
+	for _, key := range verification {
+		if key == nil {
+			continue
+		}
+		if _, exists := ring.keys[key.ID]; exists {
+			continue
+		}
+		ring.keys[key.ID] = key
+		ring.order = append(ring.order, key.ID)
+	}
+	return ring, nil
+}
+
+// 10.- Sign firma los claims con la llave activa y agrega el encabezado kid.
+func (r *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(r.active.method, claims)
+	token.Header["kid"] = r.active.ID
+	return token.SignedString(r.active.private)
+}
+
+// 11.- VerificationKey localiza la llave del kid y confirma que el algoritmo coincida.
+func (r *KeyRing) VerificationKey(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	key, ok := r.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if t.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %s", t.Method.Alg())
+	}
+	return key.public, nil
+}
+
+// 12.- JWKS publica las llaves en orden: primero la activa y luego las de verificación.
+func (r *KeyRing) JWKS() JSONWebKeySet {
+	set := JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(r.order))}
+	for _, kid := range r.order {
+		set.Keys = append(set.Keys, r.keys[kid].JWK())
+	}
+	return set
+}
+
+// 13.- WithSigningKeys cambia la firma HS256 por el anillo de llaves asimétricas.
+func WithSigningKeys(ring *KeyRing) AuthOption {
+	return func(s *AuthService) {
+		s.keyRing = ring
+	}
+}
+
+// 14.- JWKS expone las llaves públicas del servicio; sin anillo configurado la lista está vacía.
+func (s *AuthService) JWKS() JSONWebKeySet {
+	if s.keyRing == nil {
+		return JSONWebKeySet{Keys: []JSONWebKey{}}
+	}
+	return s.keyRing.JWKS()
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 1.- writePEM guarda la llave en PKCS#8 dentro de un directorio temporal.
+func writePEM(t *testing.T, dir, name string, key any) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("cannot marshal key: %v", err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("cannot write key: %v", err)
+	}
+	return path
+}
+
+func TestKeyRingRotationKeepsPreviousTokensValid(t *testing.T) {
+	// 2.- Cargamos una llave RSA y otra Ed25519 desde archivos PEM.
+	dir := t.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("cannot generate rsa key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate ed25519 key: %v", err)
+	}
+	oldKey, err := LoadSigningKey(writePEM(t, dir, "old.pem", rsaKey))
+	if err != nil {
+		t.Fatalf("LoadSigningKey returned error: %v", err)
+	}
+	newKey, err := LoadSigningKey(writePEM(t, dir, "new.pem", edKey))
+	if err != nil {
+		t.Fatalf("LoadSigningKey returned error: %v", err)
+	}
+
+	// 3.- Emitimos un token con la llave RSA sin secreto HS256 configurado.
+	repo := newFakeUserRepository()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	oldRing, _ := NewKeyRing(oldKey)
+	before := NewAuthService(repo, 1, time.Minute, nil, WithSigningKeys(oldRing))
+	issued, err := before.Register(ctx, "vecino@example.com", "s3cr3t-pass")
+	if err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	header, _, _ := strings.Cut(issued.Token, ".")
+	if parsed, _, err := jwt.NewParser().ParseUnverified(issued.Token, &AccessClaims{}); err != nil || parsed.Header["kid"] != oldKey.ID || parsed.Method.Alg() != "RS256" {
+		t.Fatalf("unexpected token header %s (%v)", header, err)
+	}
+
+	// 4.- Tras rotar, la llave anterior sólo verifica y los tokens nuevos usan EdDSA.
+	rotated, err := NewKeyRing(newKey, oldKey)
+	if err != nil {
+		t.Fatalf("NewKeyRing returned error: %v", err)
+	}
+	after := NewAuthService(repo, 1, time.Minute, nil, WithSigningKeys(rotated))
+	if _, err := after.ValidateToken(ctx, issued.Token); err != nil {
+		t.Fatalf("expected previous token to remain valid, got %v", err)
+	}
+	fresh, err := after.Authenticate(ctx, "vecino@example.com", "s3cr3t-pass")
+	if err != nil {
+		t.Fatalf("Authenticate returned error: %v", err)
+	}
+	if parsed, _, _ := jwt.NewParser().ParseUnverified(fresh.Token, &AccessClaims{}); parsed.Header["kid"] != newKey.ID || parsed.Method.Alg() != "EdDSA" {
+		t.Fatalf("expected EdDSA token signed by new key, got %v", parsed.Header)
+	}
+	jwks := after.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].Kid != newKey.ID || jwks.Keys[0].Kty != "OKP" || jwks.Keys[1].Kty != "RSA" {
+		t.Fatalf("unexpected jwks: %+v", jwks)
+	}
+
+	// 5.- Al retirar la llave anterior sus tokens dejan de validarse.
+	retired, _ := NewKeyRing(newKey)
+	final := NewAuthService(repo, 1, time.Minute, nil, WithSigningKeys(retired))
+	if _, err := final.ValidateToken(ctx, issued.Token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected retired key to be rejected, got %v", err)
+	}
+	if _, err := final.ValidateToken(ctx, fresh.Token); err != nil {
+		t.Fatalf("expected active key token to validate, got %v", err)
+	}
+}
+
+func TestKeyRingRejectsAlgorithmConfusion(t *testing.T) {
+	// 1.- Un token HS256 firmado con la llave pública no debe aceptarse sin secreto configurado.
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("cannot generate rsa key: %v", err)
+	}
+	key, err := NewSigningKey(rsaKey)
+	if err != nil {
+		t.Fatalf("NewSigningKey returned error: %v", err)
+	}
+	ring, _ := NewKeyRing(key)
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Minute, nil, WithSigningKeys(ring))
+	claims := AccessClaims{Role: RoleAdmin, RegisteredClaims: jwt.RegisteredClaims{
+		Subject:   "intruso@example.com",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}}
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = key.ID
+	signed, _ := forged.SignedString(x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey))
+	if _, err := svc.ValidateToken(context.Background(), signed); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected forged token to be rejected, got %v", err)
+	}
+
+	// 2.- Una llave sólo pública no puede ser la llave activa.
+	public, _ := NewSigningKey(&rsaKey.PublicKey)
+	if _, err := NewKeyRing(public); err == nil {
+		t.Fatalf("expected error for public-only active key")
+	}
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"citizenapp/backend/internal/observability"
+)
+
+// 1.- LoginAttempt acumula los fallos recientes de una cuenta o de una IP.
+type LoginAttempt struct {
+	Key         string
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// 2.- LoginAttemptStore comparte los contadores entre instancias.
+type LoginAttemptStore interface {
+	// Lookup devuelve el estado de la llave o un intento vacío si no existe.
+	Lookup(ctx context.Context, key string) (LoginAttempt, error)
+	// RecordFailure incrementa el contador; si el último fallo y el fin del bloqueo son anteriores a windowStart, reinicia en uno.
+	RecordFailure(ctx context.Context, key string, now, windowStart time.Time) (LoginAttempt, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
+
+// 3.- LockoutPolicy define umbrales y el retroceso exponencial del bloqueo.
+type LockoutPolicy struct {
+	AccountThreshold int
+	IPThreshold      int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	Window           time.Duration
+}
+
+// 4.- DefaultLockoutPolicy bloquea una cuenta tras 5 fallos y una IP tras 20 dentro de 15 minutos.
+func DefaultLockoutPolicy() LockoutPolicy {
+	return LockoutPolicy{
+		AccountThreshold: 5,
+		IPThreshold:      20,
+		BaseDelay:        time.Minute,
+		MaxDelay:         time.Hour,
+		Window:           15 * time.Minute,
+	}
+}
+
+// 5.- ErrLoginLocked identifica intentos rechazados por bloqueo temporal.
+var ErrLoginLocked = errors.New("too many login attempts")
+
+// 5.1.- LockoutError indica cuánto falta para que se permita otro intento.
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("%s; retry in %s", ErrLoginLocked, e.RetryAfter)
+}
+
+// Is permite usar errors.Is(err, ErrLoginLocked) sin conocer el tiempo restante.
+func (e *LockoutError) Is(target error) bool {
+	return target == ErrLoginLocked
+}
+
+// 6.- WithLoginLockout habilita los contadores por cuenta e IP; los campos en cero toman el valor por defecto.
+func WithLoginLockout(store LoginAttemptStore, policy LockoutPolicy) AuthOption {
+	return func(s *AuthService) {
+		defaults := DefaultLockoutPolicy()
+		if policy.AccountThreshold <= 0 {
+			policy.AccountThreshold = defaults.AccountThreshold
+		}
+		if policy.IPThreshold <= 0 {
+			policy.IPThreshold = defaults.IPThreshold
+		}
+		if policy.BaseDelay <= 0 {
+			policy.BaseDelay = defaults.BaseDelay
+		}
+		if policy.MaxDelay < policy.BaseDelay {
+			policy.MaxDelay = defaults.MaxDelay
+			if policy.MaxDelay < policy.BaseDelay {
+				policy.MaxDelay = policy.BaseDelay
+			}
+		}
+		if policy.Window <= 0 {
+			policy.Window = defaults.Window
+		}
+		s.loginAttempts = store
+		s.lockout = policy
+	}
+}
+
+// 7.- UnlockAccount borra los fallos de la cuenta para que pueda iniciar sesión de inmediato.
+func (s *AuthService) UnlockAccount(ctx context.Context, email string) error {
+	normalized := strings.TrimSpace(strings.ToLower(email))
+	if _, err := s.repo.FindByEmail(ctx, normalized); err != nil {
+		return err
+	}
+	if s.loginAttempts == nil {
+		return nil
+	}
+	if err := s.loginAttempts.Reset(ctx, accountAttemptKey(normalized)); err != nil {
+		return err
+	}
+	s.logger.Info().
+		Str("event", "auth.lockout.cleared").
+		Str("subject", normalized).
+		Msg("account unlocked")
+	return nil
+}
+
+// 8.- checkLockout rechaza el intento antes de gastar una comparación bcrypt.
+func (s *AuthService) checkLockout(ctx context.Context, email, ip string) error {
+	if s.loginAttempts == nil {
+		return nil
+	}
+	now := s.now()
+	var wait time.Duration
+	for _, key := range loginAttemptKeys(email, ip) {
+		attempt, err := s.loginAttempts.Lookup(ctx, key)
+		if err != nil {
+			return err
+		}
+		if remaining := attempt.LockedUntil.Sub(now); remaining > wait {
+			wait = remaining
+		}
+	}
+	if wait <= 0 {
+		return nil
+	}
+	observability.IncLoginFailure("locked")
+	return &LockoutError{RetryAfter: wait}
+}
+
+// 9.- recordLoginFailure cuenta el fallo y bloquea con retroceso exponencial al superar el umbral.
+func (s *AuthService) recordLoginFailure(ctx context.Context, reason, email, ip string) {
+	observability.IncLoginFailure(reason)
+	if s.loginAttempts == nil {
+		return
+	}
+	now := s.now()
+	for _, key := range loginAttemptKeys(email, ip) {
+		threshold, scope := s.lockout.AccountThreshold, "account"
+		if strings.HasPrefix(key, "ip:") {
+			threshold, scope = s.lockout.IPThreshold, "ip"
+		}
+		attempt, err := s.loginAttempts.RecordFailure(ctx, key, now, now.Add(-s.lockout.Window))
+		if err == nil && attempt.Failures >= threshold {
+			until := now.Add(s.lockout.delay(attempt.Failures - threshold))
+			if err = s.loginAttempts.Lock(ctx, key, until); err == nil {
+				observability.IncLoginLockout(scope)
+				s.logger.Warn().
+					Str("event", "auth.lockout").
+					Str("key", key).
+					Int("failures", attempt.Failures).
+					Time("locked_until", until).
+					Msg("login temporarily locked")
+			}
+		}
+		if err != nil {
+			// Un fallo del store no debe convertir credenciales inválidas en un error 5xx.
+			s.logger.Error().
+				Err(err).
+				Str("event", "auth.lockout.failed").
+				Str("key", key).
+				Msg("cannot record login failure")
+		}
+	}
+}
+
+// 10.- clearLoginFailures reinicia sólo la cuenta; la IP conserva sus fallos para no premiar cuentas propias.
+func (s *AuthService) clearLoginFailures(ctx context.Context, email string) {
+	if s.loginAttempts == nil {
+		return
+	}
+	if err := s.loginAttempts.Reset(ctx, accountAttemptKey(email)); err != nil {
+		s.logger.Error().
+			Err(err).
+			Str("event", "auth.lockout.failed").
+			Msg("cannot reset login failures")
+	}
+}
+
+// 11.- delay duplica el bloqueo por cada fallo adicional sobre el umbral hasta MaxDelay.
+func (p LockoutPolicy) delay(excess int) time.Duration {
+	delay := p.BaseDelay
+	for i := 0; i < excess && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+func accountAttemptKey(email string) string {
+	return "account:" + email
+}
+
+func loginAttemptKeys(email, ip string) []string {
+	keys := []string{accountAttemptKey(email)}
+	if ip != "" {
+		keys = append(keys, "ip:"+ip)
+	}
+	return keys
+}
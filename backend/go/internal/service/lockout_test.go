@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 1.- memoryLoginAttemptStore replica la semántica de ventana del store de Postgres.
+type memoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]LoginAttempt
+}
+
+func newMemoryLoginAttemptStore() *memoryLoginAttemptStore {
+	return &memoryLoginAttemptStore{attempts: make(map[string]LoginAttempt)}
+}
+
+func (m *memoryLoginAttemptStore) Lookup(_ context.Context, key string) (LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if attempt, ok := m.attempts[key]; ok {
+		return attempt, nil
+	}
+	return LoginAttempt{Key: key}, nil
+}
+
+func (m *memoryLoginAttemptStore) RecordFailure(_ context.Context, key string, now, windowStart time.Time) (LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	attempt, ok := m.attempts[key]
+	if !ok || (attempt.LastFailure.Before(windowStart) && attempt.LockedUntil.Before(windowStart)) {
+		attempt = LoginAttempt{Key: key}
+	}
+	attempt.Failures++
+	attempt.LastFailure = now
+	m.attempts[key] = attempt
+	return attempt, nil
+}
+
+func (m *memoryLoginAttemptStore) Lock(_ context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	attempt := m.attempts[key]
+	if until.After(attempt.LockedUntil) {
+		attempt.LockedUntil = until
+	}
+	m.attempts[key] = attempt
+	return nil
+}
+
+func (m *memoryLoginAttemptStore) Reset(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.attempts, key)
+	return nil
+}
+
+func TestLoginLockoutBacksOffExponentially(t *testing.T) {
+	// 2.- Con umbral de tres fallos el cuarto intento se rechaza aun con la contraseña correcta.
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	repo := newFakeUserRepository()
+	svc := NewAuthService(repo, 1, time.Minute, []byte("test-secret"),
+		WithClock(func() time.Time { return now }),
+		WithLoginLockout(newMemoryLoginAttemptStore(), LockoutPolicy{AccountThreshold: 3, IPThreshold: 100, BaseDelay: time.Minute, MaxDelay: 4 * time.Minute}))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := svc.Register(ctx, "vecino@example.com", "s3cr3t-pass"); err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := svc.Authenticate(ctx, "vecino@example.com", "wrong-pass"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: expected ErrInvalidCredentials, got %v", i, err)
+		}
+	}
+	_, err := svc.Authenticate(ctx, "vecino@example.com", "s3cr3t-pass")
+	var locked *LockoutError
+	if !errors.As(err, &locked) || locked.RetryAfter != time.Minute || !errors.Is(err, ErrLoginLocked) {
+		t.Fatalf("expected one minute lockout, got %v", err)
+	}
+
+	// 3.- Cada fallo posterior al bloqueo duplica la espera.
+	now = now.Add(61 * time.Second)
+	if _, err := svc.Authenticate(ctx, "vecino@example.com", "wrong-pass"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials after lockout, got %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, "vecino@example.com", "s3cr3t-pass"); !errors.As(err, &locked) || locked.RetryAfter != 2*time.Minute {
+		t.Fatalf("expected two minute lockout, got %v", err)
+	}
+
+	// 4.- Un inicio exitoso tras el bloqueo reinicia el contador de la cuenta.
+	now = now.Add(2*time.Minute + time.Second)
+	if _, err := svc.Authenticate(ctx, "vecino@example.com", "s3cr3t-pass"); err != nil {
+		t.Fatalf("expected login after lockout, got %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, "vecino@example.com", "wrong-pass"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected counter reset, got %v", err)
+	}
+}
+
+func TestLoginLockoutPerIPAndAdminUnlock(t *testing.T) {
+	// 1.- Fallos repartidos entre cuentas desde la misma IP bloquean esa IP para todas.
+	store := newMemoryLoginAttemptStore()
+	repo := newFakeUserRepository()
+	svc := NewAuthService(repo, 1, time.Minute, []byte("test-secret"),
+		WithLoginLockout(store, LockoutPolicy{AccountThreshold: 2, IPThreshold: 3}))
+	base, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := svc.Register(base, "vecino@example.com", "s3cr3t-pass"); err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	attacker := WithClientInfo(base, ClientInfo{IP: "203.0.113.7"})
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if _, err := svc.Authenticate(attacker, email, "guess"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("expected ErrInvalidCredentials for %s, got %v", email, err)
+		}
+	}
+	if _, err := svc.Authenticate(attacker, "vecino@example.com", "s3cr3t-pass"); !errors.Is(err, ErrLoginLocked) {
+		t.Fatalf("expected IP lockout, got %v", err)
+	}
+	if _, err := svc.Authenticate(WithClientInfo(base, ClientInfo{IP: "198.51.100.2"}), "vecino@example.com", "s3cr3t-pass"); err != nil {
+		t.Fatalf("expected other IPs to log in, got %v", err)
+	}
+
+	// 2.- El desbloqueo administrativo libera la cuenta; las cuentas desconocidas devuelven ErrUserNotFound.
+	for i := 0; i < 2; i++ {
+		_, _ = svc.Authenticate(base, "vecino@example.com", "wrong-pass")
+	}
+	if _, err := svc.Authenticate(base, "vecino@example.com", "s3cr3t-pass"); !errors.Is(err, ErrLoginLocked) {
+		t.Fatalf("expected account lockout, got %v", err)
+	}
+	if err := svc.UnlockAccount(base, "nadie@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+	if err := svc.UnlockAccount(base, "VECINO@example.com"); err != nil {
+		t.Fatalf("UnlockAccount returned error: %v", err)
+	}
+	if _, err := svc.Authenticate(base, "vecino@example.com", "s3cr3t-pass"); err != nil {
+		t.Fatalf("expected login after unlock, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// 1.- MailMessage es el correo de texto plano que generan los flujos de cuenta.
+type MailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// 2.- Mailer abstrae el transporte para usar SMTP en producción y archivos o memoria en local.
+type Mailer interface {
+	Send(ctx context.Context, message MailMessage) error
+}
+
+const mailQueueSize = 64
+
+// 3.- WithMailer habilita el envío de correos transaccionales en segundo plano.
+func WithMailer(mailer Mailer) AuthOption {
+	return func(s *AuthService) {
+		s.mailer = mailer
+	}
+}
+
+// 3.1.- mailJob lleva un mensaje listo o la función que lo arma fuera de la petición.
+type mailJob struct {
+	message MailMessage
+	// prepare consulta la cuenta y emite tokens en el worker; ErrUserNotFound descarta el envío sin ruido.
+	prepare func(ctx context.Context) (MailMessage, error)
+}
+
+// 4.- enqueueMail evita que la latencia del transporte delate si la cuenta existe.
+func (s *AuthService) enqueueMail(message MailMessage) {
+	s.enqueueMailJob(mailJob{message: message})
+}
+
+// 4.1.- enqueueMailJob encola el trabajo; si la cola está llena se descarta y se registra.
+func (s *AuthService) enqueueMailJob(job mailJob) {
+	select {
+	case s.mailQueue <- job:
+	default:
+		s.logger.Error().
+			Str("event", "auth.mail.dropped").
+			Str("subject", job.message.Subject).
+			Msg("mail queue full, message dropped")
+	}
+}
+
+// 5.- mailWorker prepara y entrega los correos encolados con un tiempo límite por mensaje.
+func (s *AuthService) mailWorker() {
+	for job := range s.mailQueue {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		s.deliverMail(ctx, job)
+		cancel()
+	}
+}
+
+func (s *AuthService) deliverMail(ctx context.Context, job mailJob) {
+	message := job.message
+	if job.prepare != nil {
+		prepared, err := job.prepare(ctx)
+		if errors.Is(err, ErrUserNotFound) {
+			return
+		}
+		if err != nil {
+			s.logger.Error().
+				Err(err).
+				Str("event", "auth.mail.failed").
+				Str("subject", message.Subject).
+				Msg("cannot prepare mail")
+			return
+		}
+		message = prepared
+	}
+	if err := s.mailer.Send(ctx, message); err != nil {
+		s.logger.Error().
+			Err(err).
+			Str("event", "auth.mail.failed").
+			Str("subject", message.Subject).
+			Msg("cannot deliver mail")
+	}
+}
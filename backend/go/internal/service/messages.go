@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// 1.- Lados de la conversación pública de un reporte.
+const (
+	MessageSenderStaff   = "staff"
+	MessageSenderCitizen = "citizen"
+)
+
+// 2.- ReportMessage es un mensaje visible para el ciudadano; a diferencia de las notas, sí le llega.
+type ReportMessage struct {
+	ID       string `json:"id"`
+	ReportID string `json:"reportId"`
+	Sender   string `json:"sender"`
+	// Author sólo se muestra al personal; el ciudadano ve al equipo, no a la persona.
+	Author    string     `json:"author,omitempty"`
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ReadAt    *time.Time `json:"readAt,omitempty"`
+	// Recipients son las cuentas que deben recibir el mensaje en vivo; no se persiste.
+	Recipients []string `json:"-"`
+}
+
+// 2.1.- ForCitizen oculta la identidad del personal antes de entregar el mensaje al ciudadano.
+func (m ReportMessage) ForCitizen() ReportMessage {
+	if m.Sender == MessageSenderStaff {
+		m.Author = ""
+	}
+	m.Recipients = nil
+	return m
+}
+
+// 3.- MessageStore persiste la conversación de cada reporte.
+type MessageStore interface {
+	CreateMessage(ctx context.Context, message ReportMessage) error
+	// Messages devuelve la conversación del reporte en orden cronológico.
+	Messages(ctx context.Context, reportID string) ([]ReportMessage, error)
+	// MarkRead marca como leídos los mensajes pendientes que envió el lado indicado.
+	MarkRead(ctx context.Context, reportID, sender string, at time.Time) error
+	// UnreadCount cuenta los mensajes del lado indicado que el otro lado no ha leído.
+	UnreadCount(ctx context.Context, reportID, sender string) (int, error)
+}
+
+// 4.- Errores de la mensajería.
+var (
+	ErrReportHasNoReporter = errors.New("report has no citizen account to message")
+	ErrEmptyMessage        = errors.New("message body cannot be blank")
+	errMessagesDisabled    = errors.New("report messaging is not configured")
+)
+
+// 5.- WithMessages habilita la conversación entre el personal y quien reportó.
+func WithMessages(store MessageStore) ReportOption {
+	return func(s *ReportService) {
+		s.messages = store
+	}
+}
+
+// 6.- Messages devuelve la conversación y marca como leído lo que envió el otro lado.
+func (s *ReportService) Messages(ctx context.Context, reportID string, principal Principal) ([]ReportMessage, error) {
+	if s.messages == nil {
+		return nil, errMessagesDisabled
+	}
+	report, err := s.repo.FindByID(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	side, err := conversationSide(report, principal)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := s.messages.Messages(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	other := oppositeSender(side)
+	if err := s.messages.MarkRead(ctx, reportID, other, now); err != nil {
+		return nil, err
+	}
+	for i := range messages {
+		if messages[i].Sender == other && messages[i].ReadAt == nil {
+			messages[i].ReadAt = &now
+		}
+		if side == MessageSenderCitizen {
+			messages[i] = messages[i].ForCitizen()
+		}
+	}
+	return messages, nil
+}
+
+// 7.- SendMessage agrega un mensaje a la conversación y calcula quién debe recibirlo en vivo.
+func (s *ReportService) SendMessage(ctx context.Context, reportID string, principal Principal, body string) (ReportMessage, error) {
+	if s.messages == nil {
+		return ReportMessage{}, errMessagesDisabled
+	}
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return ReportMessage{}, ErrEmptyMessage
+	}
+	report, err := s.repo.FindByID(ctx, reportID)
+	if err != nil {
+		return ReportMessage{}, err
+	}
+	side, err := conversationSide(report, principal)
+	if err != nil {
+		return ReportMessage{}, err
+	}
+	if !hasCitizenAccount(report) {
+		return ReportMessage{}, ErrReportHasNoReporter
+	}
+	history, err := s.messages.Messages(ctx, reportID)
+	if err != nil {
+		return ReportMessage{}, err
+	}
+	id, err := randomID()
+	if err != nil {
+		return ReportMessage{}, err
+	}
+	message := ReportMessage{
+		ID:        id,
+		ReportID:  reportID,
+		Sender:    side,
+		Author:    principal.Subject,
+		Body:      body,
+		CreatedAt: s.now().UTC(),
+	}
+	if err := s.messages.CreateMessage(ctx, message); err != nil {
+		return ReportMessage{}, err
+	}
+	s.logger.Info().
+		Str("event", "report.message.sent").
+		Str("report_id", reportID).
+		Str("message_id", message.ID).
+		Str("sender", side).
+		Msg("report message sent")
+	message.Recipients = messageRecipients(report, history, message)
+	return message, nil
+}
+
+// conversationSide ubica a la identidad en la conversación; quien no participa recibe ErrReportNotFound.
+func conversationSide(report Report, principal Principal) (string, error) {
+	if principal.APIKeyID == "" && principal.Can(PermReportsContact) {
+		return MessageSenderStaff, nil
+	}
+	if principal.Subject != "" && principal.Subject == report.Reporter && hasCitizenAccount(report) {
+		return MessageSenderCitizen, nil
+	}
+	return "", ErrReportNotFound
+}
+
+// hasCitizenAccount descarta reportes anónimos o de cuentas eliminadas; nadie podría leer la respuesta.
+func hasCitizenAccount(report Report) bool {
+	return report.Reporter != "" && report.Reporter != DeletedActor && !IsAnonymousSubject(report.Reporter)
+}
+
+// messageRecipients envía al ciudadano lo que escribe el personal y al personal que ya conversó lo que responde el ciudadano.
+func messageRecipients(report Report, history []ReportMessage, message ReportMessage) []string {
+	if message.Sender == MessageSenderStaff {
+		return []string{report.Reporter}
+	}
+	recipients := make([]string, 0, len(history))
+	for _, previous := range history {
+		if previous.Sender == MessageSenderStaff && previous.Author != DeletedActor && !slices.Contains(recipients, previous.Author) {
+			recipients = append(recipients, previous.Author)
+		}
+	}
+	return recipients
+}
+
+func oppositeSender(side string) string {
+	if side == MessageSenderStaff {
+		return MessageSenderCitizen
+	}
+	return MessageSenderStaff
+}
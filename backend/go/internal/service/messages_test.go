@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 1.- memoryMessageStore conserva la conversación en orden de envío.
+type memoryMessageStore struct {
+	mu       sync.Mutex
+	messages []ReportMessage
+}
+
+func (m *memoryMessageStore) CreateMessage(_ context.Context, message ReportMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+func (m *memoryMessageStore) Messages(_ context.Context, reportID string) ([]ReportMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var messages []ReportMessage
+	for _, message := range m.messages {
+		if message.ReportID == reportID {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+func (m *memoryMessageStore) MarkRead(_ context.Context, reportID, sender string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.messages {
+		if m.messages[i].ReportID == reportID && m.messages[i].Sender == sender && m.messages[i].ReadAt == nil {
+			read := at
+			m.messages[i].ReadAt = &read
+		}
+	}
+	return nil
+}
+
+func (m *memoryMessageStore) UnreadCount(_ context.Context, reportID, sender string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, message := range m.messages {
+		if message.ReportID == reportID && message.Sender == sender && message.ReadAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func TestSendMessageRoutesRepliesToParticipatingStaff(t *testing.T) {
+	// 2.- Un reporte con cuenta y otro enviado sin cuenta desde un dispositivo.
+	repo := newFakeReportRepository()
+	svc := NewReportService(repo, newTestFolioGenerator(), 1, 1, WithMessages(&memoryMessageStore{}))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	report, err := svc.Submit(ctx, "vecino@example.com", map[string]any{"incidentTypeId": "pothole", "description": "Bache"})
+	if err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+	anonymous, err := svc.Submit(ctx, AnonymousSubject("instalacion-0123456789abcdef0123456789"), map[string]any{"incidentTypeId": "pothole", "description": "Bache"})
+	if err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+	citizen := Principal{Subject: "vecino@example.com", Role: RoleCitizen}
+	operator := Principal{Subject: "operador@example.com", Role: RoleOperator}
+	supervisor := Principal{Subject: "supervisora@example.com", Role: RoleSupervisor}
+
+	// 3.- Nadie puede escribir a un reporte anónimo y una integración no entra a la conversación.
+	if _, err := svc.SendMessage(ctx, anonymous.ID, operator, "Hola"); !errors.Is(err, ErrReportHasNoReporter) {
+		t.Fatalf("expected anonymous reports to reject messages, got %v", err)
+	}
+	integration := Principal{Subject: "apikey:1", APIKeyID: "1", Scopes: []string{string(PermReportsContact)}}
+	if _, err := svc.SendMessage(ctx, report.ID, integration, "Hola"); !errors.Is(err, ErrReportNotFound) {
+		t.Fatalf("expected integrations to stay out, got %v", err)
+	}
+
+	// 4.- Un cuerpo sólo con espacios se rechaza; sin preguntas previas la respuesta no tiene destinatarios en vivo.
+	if _, err := svc.SendMessage(ctx, report.ID, citizen, " \n\t "); !errors.Is(err, ErrEmptyMessage) {
+		t.Fatalf("expected blank messages to be rejected, got %v", err)
+	}
+	first, err := svc.SendMessage(ctx, report.ID, citizen, " Sigue abierto ")
+	if err != nil || first.Body != "Sigue abierto" || len(first.Recipients) != 0 {
+		t.Fatalf("unexpected message %+v (%v)", first, err)
+	}
+
+	// 5.- Tras conversar, la respuesta llega una sola vez a cada persona del personal.
+	for _, staff := range []Principal{operator, supervisor, operator} {
+		message, err := svc.SendMessage(ctx, report.ID, staff, "¿Número exterior?")
+		if err != nil || len(message.Recipients) != 1 || message.Recipients[0] != citizen.Subject {
+			t.Fatalf("unexpected staff message %+v (%v)", message, err)
+		}
+	}
+	reply, err := svc.SendMessage(ctx, report.ID, citizen, "Es el 42")
+	if err != nil || len(reply.Recipients) != 2 {
+		t.Fatalf("expected the reply for both staff members, got %+v (%v)", reply, err)
+	}
+
+	// 6.- Leer la conversación sólo marca lo que escribió el otro lado.
+	status, err := svc.Lookup(ctx, report.ID)
+	if err != nil || status.UnreadMessages != 3 {
+		t.Fatalf("expected three unread staff messages, got %+v (%v)", status, err)
+	}
+	if _, err := svc.Messages(ctx, report.ID, operator); err != nil {
+		t.Fatalf("Messages returned error: %v", err)
+	}
+	if status, _ := svc.Lookup(ctx, report.ID); status.UnreadMessages != 3 {
+		t.Fatalf("staff reading must not clear the citizen indicator, got %d", status.UnreadMessages)
+	}
+	messages, err := svc.Messages(ctx, report.ID, citizen)
+	if err != nil || len(messages) != 5 || messages[1].Author != "" {
+		t.Fatalf("unexpected citizen view %+v (%v)", messages, err)
+	}
+	if status, _ := svc.Lookup(ctx, report.ID); status.UnreadMessages != 0 {
+		t.Fatalf("expected the indicator to clear, got %d", status.UnreadMessages)
+	}
+}
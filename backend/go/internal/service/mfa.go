@@ -0,0 +1,348 @@
+package service
+
+import (
+	"context"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 1.- MFAEnrollment guarda el secreto TOTP; ConfirmedAt en cero indica una inscripción pendiente.
+type MFAEnrollment struct {
+	Subject     string
+	Secret      string
+	ConfirmedAt time.Time
+	LastStep    int64
+	CreatedAt   time.Time
+}
+
+// Confirmed indica si la cuenta ya completa el segundo factor al iniciar sesión.
+func (e MFAEnrollment) Confirmed() bool {
+	return !e.ConfirmedAt.IsZero()
+}
+
+// 2.- MFARepository persiste los secretos TOTP y los códigos de recuperación.
+type MFARepository interface {
+	// FindMFA devuelve ErrMFANotEnrolled si la cuenta nunca inició la inscripción.
+	FindMFA(ctx context.Context, subject string) (MFAEnrollment, error)
+	// SaveMFA reemplaza una inscripción pendiente; si ya está confirmada devuelve ErrMFAAlreadyEnrolled.
+	SaveMFA(ctx context.Context, enrollment MFAEnrollment) error
+	// ConfirmMFA activa el secreto y reemplaza los códigos de recuperación en una sola operación.
+	ConfirmMFA(ctx context.Context, subject string, at time.Time, recoveryHashes []string) error
+	// UseTOTPStep registra el paso usado; un paso igual o anterior al último devuelve ErrInvalidMFACode.
+	UseTOTPStep(ctx context.Context, subject string, step int64) error
+	// ConsumeRecoveryCode marca el código como usado o devuelve ErrInvalidMFACode.
+	ConsumeRecoveryCode(ctx context.Context, subject, codeHash string, at time.Time) error
+}
+
+// 3.- Errores del segundo factor.
+var (
+	ErrMFARequired         = errors.New("mfa required")
+	ErrMFANotEnrolled      = errors.New("mfa not enrolled")
+	ErrMFAAlreadyEnrolled  = errors.New("mfa already enrolled")
+	ErrInvalidMFACode      = errors.New("invalid mfa code")
+	ErrInvalidMFAChallenge = errors.New("invalid mfa challenge")
+)
+
+const (
+	mfaChallengeTTL    = 5 * time.Minute
+	mfaAudience        = "mfa"
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 5
+)
+
+// 4.- MFAChallenge sustituye a AuthResponse cuando falta completar el segundo factor.
+type MFAChallenge struct {
+	Token              string    `json:"mfaToken"`
+	ExpiresAt          time.Time `json:"expiresAt"`
+	EnrollmentRequired bool      `json:"enrollmentRequired"`
+}
+
+// 4.1.- MFAChallengeError transporta el reto para que el login responda sin emitir tokens.
+type MFAChallengeError struct {
+	Challenge MFAChallenge
+}
+
+func (e *MFAChallengeError) Error() string {
+	return ErrMFARequired.Error()
+}
+
+// Is permite usar errors.Is(err, ErrMFARequired) sin conocer el reto.
+func (e *MFAChallengeError) Is(target error) bool {
+	return target == ErrMFARequired
+}
+
+// 5.- MFASetup entrega el secreto y la URI otpauth:// para mostrar como código QR.
+type MFASetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauthUri"`
+}
+
+// mfaChallengeClaims se firma con la misma llave que los tokens de acceso pero con audiencia "mfa".
+type mfaChallengeClaims struct {
+	Method string `json:"method"`
+	Enroll bool   `json:"enroll,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// 6.- WithMFA habilita TOTP; los roles indicados deben inscribirse antes de obtener tokens.
+func WithMFA(repo MFARepository, issuer string, requiredRoles ...string) AuthOption {
+	return func(s *AuthService) {
+		s.mfa = repo
+		s.mfaIssuer = strings.TrimSpace(issuer)
+		if s.mfaIssuer == "" {
+			s.mfaIssuer = "Citizen Reports"
+		}
+		s.mfaRoles = make(map[string]struct{}, len(requiredRoles))
+		for _, role := range requiredRoles {
+			s.mfaRoles[role] = struct{}{}
+		}
+	}
+}
+
+// 7.- completeLogin emite los tokens o, si la cuenta usa o requiere MFA, devuelve el reto.
+func (s *AuthService) completeLogin(ctx context.Context, user User, method string) (AuthResponse, error) {
+	// Una cuenta suspendida no debe recibir siquiera el reto del segundo factor.
+	if user.Disabled() {
+		return AuthResponse{}, ErrAccountDisabled
+	}
+	if s.mfa == nil {
+		return s.issue(ctx, user, method)
+	}
+	enrollment, err := s.mfa.FindMFA(ctx, user.Email)
+	if err != nil && !errors.Is(err, ErrMFANotEnrolled) {
+		return AuthResponse{}, err
+	}
+	if err == nil && enrollment.Confirmed() {
+		return AuthResponse{}, s.challenge(user.Email, method, false)
+	}
+	if _, required := s.mfaRoles[user.Role]; required {
+		return AuthResponse{}, s.challenge(user.Email, method, true)
+	}
+	return s.issue(ctx, user, method)
+}
+
+// 8.- challenge firma el reto de corta duración que sólo aceptan los endpoints /auth/mfa.
+func (s *AuthService) challenge(subject, method string, enroll bool) error {
+	now := s.now()
+	jti, err := randomID()
+	if err != nil {
+		return err
+	}
+	claims := mfaChallengeClaims{
+		Method: method,
+		Enroll: enroll,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   subject,
+			Audience:  jwt.ClaimStrings{mfaAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaChallengeTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	signed, err := s.sign(claims)
+	if err != nil {
+		return err
+	}
+	return &MFAChallengeError{Challenge: MFAChallenge{Token: signed, ExpiresAt: claims.ExpiresAt.Time, EnrollmentRequired: enroll}}
+}
+
+// 9.- parseChallenge valida firma, audiencia y vigencia del reto y descarta los ya usados.
+func (s *AuthService) parseChallenge(ctx context.Context, token string) (*mfaChallengeClaims, error) {
+	if s.mfa == nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+	claims := &mfaChallengeClaims{}
+	parsed, err := jwt.ParseWithClaims(strings.TrimSpace(token), claims, s.verificationKey,
+		jwt.WithAudience(mfaAudience),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(s.now),
+	)
+	if err != nil || !parsed.Valid || claims.Subject == "" {
+		return nil, ErrInvalidMFAChallenge
+	}
+	if s.revocations != nil && claims.ID != "" {
+		revoked, err := s.revocations.IsTokenRevoked(ctx, claims.ID)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrInvalidMFAChallenge
+		}
+	}
+	return claims, nil
+}
+
+// 10.- VerifyMFA completa el inicio de sesión con un código TOTP o de recuperación.
+func (s *AuthService) VerifyMFA(ctx context.Context, challengeToken, code string) (AuthResponse, error) {
+	claims, err := s.parseChallenge(ctx, challengeToken)
+	if err != nil {
+		return AuthResponse{}, err
+	}
+	ip := ClientInfoFrom(ctx).IP
+	// Los códigos fallidos cuentan como intentos de login para frenar la fuerza bruta.
+	if err := s.checkLockout(ctx, claims.Subject, ip); err != nil {
+		return AuthResponse{}, err
+	}
+	var recoveryCodes []string
+	if claims.Enroll {
+		recoveryCodes, err = s.confirmEnrollment(ctx, claims.Subject, code)
+	} else {
+		err = s.verifySecondFactor(ctx, claims.Subject, code)
+	}
+	if err != nil {
+		if errors.Is(err, ErrInvalidMFACode) || errors.Is(err, ErrMFANotEnrolled) {
+			s.recordLoginFailure(ctx, "invalid_mfa", claims.Subject, ip)
+			return AuthResponse{}, ErrInvalidMFACode
+		}
+		return AuthResponse{}, err
+	}
+	s.clearLoginFailures(ctx, claims.Subject)
+	if s.revocations != nil {
+		if err := s.revocations.RevokeToken(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+			return AuthResponse{}, err
+		}
+	}
+	user, err := s.repo.FindByEmail(ctx, claims.Subject)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return AuthResponse{}, ErrInvalidMFAChallenge
+		}
+		return AuthResponse{}, err
+	}
+	resp, err := s.issue(ctx, user, claims.Method, "otp", "mfa")
+	if err != nil {
+		return AuthResponse{}, err
+	}
+	resp.RecoveryCodes = recoveryCodes
+	return resp, nil
+}
+
+// 11.- EnrollMFA genera un secreto pendiente para la cuenta autenticada.
+func (s *AuthService) EnrollMFA(ctx context.Context, subject string) (MFASetup, error) {
+	if s.mfa == nil {
+		return MFASetup{}, errors.New("mfa is not configured")
+	}
+	existing, err := s.mfa.FindMFA(ctx, subject)
+	if err == nil && existing.Confirmed() {
+		return MFASetup{}, ErrMFAAlreadyEnrolled
+	}
+	if err != nil && !errors.Is(err, ErrMFANotEnrolled) {
+		return MFASetup{}, err
+	}
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return MFASetup{}, err
+	}
+	if err := s.mfa.SaveMFA(ctx, MFAEnrollment{Subject: subject, Secret: secret, LastStep: -1, CreatedAt: s.now()}); err != nil {
+		return MFASetup{}, err
+	}
+	return MFASetup{Secret: secret, URI: totpURI(s.mfaIssuer, subject, secret)}, nil
+}
+
+// 12.- EnrollMFAWithChallenge permite inscribirse a quien aún no tiene token por exigirlo su rol.
+func (s *AuthService) EnrollMFAWithChallenge(ctx context.Context, challengeToken string) (MFASetup, error) {
+	claims, err := s.parseChallenge(ctx, challengeToken)
+	if err != nil {
+		return MFASetup{}, err
+	}
+	if !claims.Enroll {
+		return MFASetup{}, ErrInvalidMFAChallenge
+	}
+	return s.EnrollMFA(ctx, claims.Subject)
+}
+
+// 13.- ConfirmMFA activa el secreto con un primer código válido y devuelve los códigos de recuperación.
+func (s *AuthService) ConfirmMFA(ctx context.Context, subject, code string) ([]string, error) {
+	if s.mfa == nil {
+		return nil, ErrMFANotEnrolled
+	}
+	return s.confirmEnrollment(ctx, subject, code)
+}
+
+// 14.- confirmEnrollment valida el código contra el secreto pendiente y genera los códigos de recuperación.
+func (s *AuthService) confirmEnrollment(ctx context.Context, subject, code string) ([]string, error) {
+	enrollment, err := s.mfa.FindMFA(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment.Confirmed() {
+		return nil, ErrMFAAlreadyEnrolled
+	}
+	step, ok := matchTOTP(enrollment.Secret, code, s.now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfa.ConfirmMFA(ctx, subject, s.now(), hashes); err != nil {
+		return nil, err
+	}
+	if err := s.mfa.UseTOTPStep(ctx, subject, step); err != nil {
+		return nil, err
+	}
+	s.logger.Info().
+		Str("event", "auth.mfa.enrolled").
+		Str("subject", subject).
+		Msg("mfa enrollment confirmed")
+	return codes, nil
+}
+
+// 15.- verifySecondFactor acepta un código TOTP no reutilizado o un código de recuperación.
+func (s *AuthService) verifySecondFactor(ctx context.Context, subject, code string) error {
+	enrollment, err := s.mfa.FindMFA(ctx, subject)
+	if err != nil {
+		return err
+	}
+	if !enrollment.Confirmed() {
+		return ErrMFANotEnrolled
+	}
+	if step, ok := matchTOTP(enrollment.Secret, code, s.now()); ok {
+		return s.mfa.UseTOTPStep(ctx, subject, step)
+	}
+	normalized := normalizeRecoveryCode(code)
+	if len(normalized) != recoveryCodeLength*8/5 {
+		return ErrInvalidMFACode
+	}
+	if err := s.mfa.ConsumeRecoveryCode(ctx, subject, hashToken(normalized), s.now()); err != nil {
+		return err
+	}
+	s.logger.Warn().
+		Str("event", "auth.mfa.recovery_code_used").
+		Str("subject", subject).
+		Msg("mfa recovery code used")
+	return nil
+}
+
+var recoveryEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// 16.- newRecoveryCodes genera códigos "xxxx-xxxx" y sus hashes para persistir.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := randomBytes(recoveryCodeLength)
+		if err != nil {
+			return nil, nil, err
+		}
+		encoded := recoveryEncoding.EncodeToString(raw)
+		codes = append(codes, encoded[:4]+"-"+encoded[4:])
+		hashes = append(hashes, hashToken(encoded))
+	}
+	return codes, hashes, nil
+}
+
+// 17.- normalizeRecoveryCode tolera mayúsculas, guiones y espacios al capturar el código.
+func normalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 1.- memoryMFARepository replica las garantías del repositorio Postgres.
+type memoryMFARepository struct {
+	mu       sync.Mutex
+	enrolled map[string]MFAEnrollment
+	recovery map[string]map[string]bool
+}
+
+func newMemoryMFARepository() *memoryMFARepository {
+	return &memoryMFARepository{enrolled: make(map[string]MFAEnrollment), recovery: make(map[string]map[string]bool)}
+}
+
+func (m *memoryMFARepository) FindMFA(_ context.Context, subject string) (MFAEnrollment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	enrollment, ok := m.enrolled[subject]
+	if !ok {
+		return MFAEnrollment{}, ErrMFANotEnrolled
+	}
+	return enrollment, nil
+}
+
+func (m *memoryMFARepository) SaveMFA(_ context.Context, enrollment MFAEnrollment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.enrolled[enrollment.Subject].Confirmed() {
+		return ErrMFAAlreadyEnrolled
+	}
+	m.enrolled[enrollment.Subject] = enrollment
+	return nil
+}
+
+func (m *memoryMFARepository) ConfirmMFA(_ context.Context, subject string, at time.Time, hashes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	enrollment := m.enrolled[subject]
+	enrollment.ConfirmedAt = at
+	m.enrolled[subject] = enrollment
+	m.recovery[subject] = make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		m.recovery[subject][hash] = false
+	}
+	return nil
+}
+
+func (m *memoryMFARepository) UseTOTPStep(_ context.Context, subject string, step int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	enrollment := m.enrolled[subject]
+	if step <= enrollment.LastStep {
+		return ErrInvalidMFACode
+	}
+	enrollment.LastStep = step
+	m.enrolled[subject] = enrollment
+	return nil
+}
+
+func (m *memoryMFARepository) ConsumeRecoveryCode(_ context.Context, subject, codeHash string, _ time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	used, ok := m.recovery[subject][codeHash]
+	if !ok || used {
+		return ErrInvalidMFACode
+	}
+	m.recovery[subject][codeHash] = true
+	return nil
+}
+
+func TestTOTPMatchesRFC6238Vectors(t *testing.T) {
+	// 2.- Vectores SHA1 del apéndice B de RFC 6238 truncados a seis dígitos.
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	vectors := map[int64]string{59: "287082", 1111111109: "081804", 1234567890: "005924", 2000000000: "279037"}
+	for unix, want := range vectors {
+		got, err := totpCode(secret, totpStep(time.Unix(unix, 0)))
+		if err != nil || got != want {
+			t.Fatalf("totp at %d: got %s (%v), want %s", unix, got, err, want)
+		}
+	}
+	if _, ok := matchTOTP(secret, "287082", time.Unix(59+30, 0)); !ok {
+		t.Fatalf("expected previous step to be accepted")
+	}
+	if _, ok := matchTOTP(secret, "287082", time.Unix(59+90, 0)); ok {
+		t.Fatalf("expected code outside the skew window to be rejected")
+	}
+}
+
+func TestMFAEnrollmentAndTwoStepLogin(t *testing.T) {
+	// 3.- Un supervisor sin MFA recibe un reto de inscripción en lugar de tokens.
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	repo := newFakeUserRepository()
+	mfa := newMemoryMFARepository()
+	svc := NewAuthService(repo, 1, time.Minute, []byte("test-secret"),
+		WithClock(func() time.Time { return now }),
+		WithRevocation(newMemoryRevocationStore()),
+		WithRefreshTokens(newMemoryRefreshStore(), time.Hour),
+		WithMFA(mfa, "Municipio", RoleSupervisor))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := svc.Register(ctx, "jefa@example.com", "s3cr3t-pass"); err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	if err := svc.AssignRole(ctx, "jefa@example.com", RoleSupervisor); err != nil {
+		t.Fatalf("AssignRole returned error: %v", err)
+	}
+	_, err := svc.Authenticate(ctx, "jefa@example.com", "s3cr3t-pass")
+	var challenge *MFAChallengeError
+	if !errors.As(err, &challenge) || !challenge.Challenge.EnrollmentRequired {
+		t.Fatalf("expected enrollment challenge, got %v", err)
+	}
+	if _, err := svc.ValidateToken(ctx, challenge.Challenge.Token); err == nil {
+		t.Fatalf("challenge token must not work as access token")
+	}
+
+	// 4.- El reto permite obtener el secreto y el primer código completa la inscripción.
+	setup, err := svc.EnrollMFAWithChallenge(ctx, challenge.Challenge.Token)
+	if err != nil {
+		t.Fatalf("EnrollMFAWithChallenge returned error: %v", err)
+	}
+	if !strings.HasPrefix(setup.URI, "otpauth://totp/Municipio:jefa@example.com?") || !strings.Contains(setup.URI, "secret="+setup.Secret) {
+		t.Fatalf("unexpected provisioning uri %q", setup.URI)
+	}
+	code, _ := totpCode(setup.Secret, totpStep(now))
+	if _, err := svc.VerifyMFA(ctx, challenge.Challenge.Token, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("expected ErrInvalidMFACode, got %v", err)
+	}
+	session, err := svc.VerifyMFA(ctx, challenge.Challenge.Token, code)
+	if err != nil {
+		t.Fatalf("VerifyMFA returned error: %v", err)
+	}
+	if len(session.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("expected %d recovery codes, got %v", recoveryCodeCount, session.RecoveryCodes)
+	}
+	principal, err := svc.ValidateToken(ctx, session.Token)
+	if err != nil || strings.Join(principal.AuthMethods, ",") != "pwd,otp,mfa" {
+		t.Fatalf("unexpected principal %+v (%v)", principal, err)
+	}
+	rotated, err := svc.Refresh(ctx, session.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+	if principal, err := svc.ValidateToken(ctx, rotated.Token); err != nil || strings.Join(principal.AuthMethods, ",") != "pwd,otp,mfa" {
+		t.Fatalf("expected the refreshed token to keep the second factor, got %+v (%v)", principal, err)
+	}
+	if _, err := svc.VerifyMFA(ctx, challenge.Challenge.Token, code); !errors.Is(err, ErrInvalidMFAChallenge) {
+		t.Fatalf("expected used challenge to be rejected, got %v", err)
+	}
+
+	// 5.- Los siguientes logins exigen un código nuevo; el mismo paso no puede repetirse.
+	_, err = svc.Authenticate(ctx, "jefa@example.com", "s3cr3t-pass")
+	if !errors.As(err, &challenge) || challenge.Challenge.EnrollmentRequired {
+		t.Fatalf("expected verification challenge, got %v", err)
+	}
+	if _, err := svc.VerifyMFA(ctx, challenge.Challenge.Token, code); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("expected replayed code to fail, got %v", err)
+	}
+	now = now.Add(30 * time.Second)
+	next, _ := totpCode(setup.Secret, totpStep(now))
+	if _, err := svc.VerifyMFA(ctx, challenge.Challenge.Token, next); err != nil {
+		t.Fatalf("expected next step to work, got %v", err)
+	}
+
+	// 6.- Un código de recuperación funciona una sola vez, con o sin guion.
+	_, err = svc.Authenticate(ctx, "jefa@example.com", "s3cr3t-pass")
+	if !errors.As(err, &challenge) {
+		t.Fatalf("expected challenge, got %v", err)
+	}
+	recovery := strings.ToUpper(strings.ReplaceAll(session.RecoveryCodes[0], "-", ""))
+	if _, err := svc.VerifyMFA(ctx, challenge.Challenge.Token, recovery); err != nil {
+		t.Fatalf("expected recovery code to work, got %v", err)
+	}
+	_, err = svc.Authenticate(ctx, "jefa@example.com", "s3cr3t-pass")
+	if !errors.As(err, &challenge) {
+		t.Fatalf("expected challenge, got %v", err)
+	}
+	if _, err := svc.VerifyMFA(ctx, challenge.Challenge.Token, session.RecoveryCodes[0]); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("expected used recovery code to fail, got %v", err)
+	}
+}
+
+func TestOptionalMFAForCitizens(t *testing.T) {
+	// 1.- Los ciudadanos inician sesión directamente hasta que se inscriben por su cuenta.
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Minute, []byte("test-secret"),
+		WithClock(func() time.Time { return now }),
+		WithMFA(newMemoryMFARepository(), "", RoleSupervisor))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := svc.Register(ctx, "vecino@example.com", "s3cr3t-pass"); err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, "vecino@example.com", "s3cr3t-pass"); err != nil {
+		t.Fatalf("expected direct login, got %v", err)
+	}
+
+	// 2.- Tras confirmar la inscripción el login devuelve un reto.
+	setup, err := svc.EnrollMFA(ctx, "vecino@example.com")
+	if err != nil {
+		t.Fatalf("EnrollMFA returned error: %v", err)
+	}
+	if _, err := svc.ConfirmMFA(ctx, "vecino@example.com", "123456"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("expected ErrInvalidMFACode, got %v", err)
+	}
+	code, _ := totpCode(setup.Secret, totpStep(now))
+	codes, err := svc.ConfirmMFA(ctx, "vecino@example.com", code)
+	if err != nil || len(codes) != recoveryCodeCount {
+		t.Fatalf("ConfirmMFA returned %v (%v)", codes, err)
+	}
+	if _, err := svc.EnrollMFA(ctx, "vecino@example.com"); !errors.Is(err, ErrMFAAlreadyEnrolled) {
+		t.Fatalf("expected ErrMFAAlreadyEnrolled, got %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, "vecino@example.com", "s3cr3t-pass"); !errors.Is(err, ErrMFARequired) {
+		t.Fatalf("expected ErrMFARequired, got %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const defaultNoteEditWindow = 15 * time.Minute
+
+// 1.- StaffNote es una nota interna de coordinación; nunca forma parte del seguimiento ciudadano.
+type StaffNote struct {
+	ID          string     `json:"id"`
+	ReportID    string     `json:"reportId"`
+	Author      string     `json:"author"`
+	Body        string     `json:"body"`
+	Mentions    []string   `json:"mentions"`
+	Attachments []string   `json:"attachments"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	EditedAt    *time.Time `json:"editedAt,omitempty"`
+	// EditableUntil se calcula con la ventana vigente; no se persiste.
+	EditableUntil time.Time `json:"editableUntil"`
+}
+
+// 1.1.- StaffNoteInput agrupa el texto, las menciones y los adjuntos capturados.
+type StaffNoteInput struct {
+	Body        string
+	Mentions    []string
+	Attachments []string
+}
+
+// 2.- NoteStore persiste las notas internas de cada reporte.
+type NoteStore interface {
+	CreateNote(ctx context.Context, note StaffNote) error
+	// Notes devuelve las notas del reporte en orden cronológico.
+	Notes(ctx context.Context, reportID string) ([]StaffNote, error)
+	FindNote(ctx context.Context, reportID, id string) (StaffNote, error)
+	// UpdateNote reemplaza texto, menciones, adjuntos y fecha de edición; devuelve ErrNoteNotFound si no existe.
+	UpdateNote(ctx context.Context, note StaffNote) error
+}
+
+// 3.- StaffDirectory valida a quién se puede mencionar y le avisa.
+type StaffDirectory interface {
+	IsStaff(ctx context.Context, email string) (bool, error)
+	NotifyMention(ctx context.Context, note StaffNote, email string) error
+}
+
+// 4.- Errores de las notas internas.
+var (
+	ErrNoteNotFound         = errors.New("note not found")
+	ErrNoteNotAuthor        = errors.New("only the author can edit a note")
+	ErrNoteEditWindowClosed = errors.New("note edit window has closed")
+	ErrInvalidMention       = errors.New("mentions must reference active staff accounts")
+	errNotesDisabled        = errors.New("staff notes are not configured")
+)
+
+// 5.- WithStaffNotes habilita las notas internas; una ventana no positiva usa 15 minutos.
+func WithStaffNotes(store NoteStore, directory StaffDirectory, editWindow time.Duration) ReportOption {
+	return func(s *ReportService) {
+		if editWindow <= 0 {
+			editWindow = defaultNoteEditWindow
+		}
+		s.notes = store
+		s.staff = directory
+		s.noteEditWindow = editWindow
+	}
+}
+
+// 6.- Notes lista las notas internas del reporte para el personal.
+func (s *ReportService) Notes(ctx context.Context, reportID string) ([]StaffNote, error) {
+	if s.notes == nil {
+		return nil, errNotesDisabled
+	}
+	if _, err := s.repo.FindByID(ctx, reportID); err != nil {
+		return nil, err
+	}
+	notes, err := s.notes.Notes(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range notes {
+		notes[i] = s.withEditWindow(notes[i])
+	}
+	return notes, nil
+}
+
+// 7.- AddStaffNote registra la nota interna y avisa a cada persona mencionada.
+func (s *ReportService) AddStaffNote(ctx context.Context, reportID, author string, input StaffNoteInput) (StaffNote, error) {
+	if s.notes == nil {
+		return StaffNote{}, errNotesDisabled
+	}
+	if _, err := s.repo.FindByID(ctx, reportID); err != nil {
+		return StaffNote{}, err
+	}
+	mentions, err := s.resolveMentions(ctx, author, input.Mentions)
+	if err != nil {
+		return StaffNote{}, err
+	}
+	id, err := randomID()
+	if err != nil {
+		return StaffNote{}, err
+	}
+	note := StaffNote{
+		ID:          id,
+		ReportID:    reportID,
+		Author:      author,
+		Body:        strings.TrimSpace(input.Body),
+		Mentions:    mentions,
+		Attachments: toStrings(input.Attachments),
+		CreatedAt:   s.now().UTC(),
+	}
+	if err := s.notes.CreateNote(ctx, note); err != nil {
+		return StaffNote{}, err
+	}
+	s.logger.Info().
+		Str("event", "report.note.created").
+		Str("report_id", reportID).
+		Str("note_id", note.ID).
+		Str("actor", author).
+		Int("mentions", len(mentions)).
+		Msg("staff note added")
+	s.notifyMentions(ctx, note, mentions)
+	return s.withEditWindow(note), nil
+}
+
+// 8.- EditStaffNote permite al autor corregir su nota dentro de la ventana de edición.
+func (s *ReportService) EditStaffNote(ctx context.Context, reportID, noteID, author string, input StaffNoteInput) (StaffNote, error) {
+	if s.notes == nil {
+		return StaffNote{}, errNotesDisabled
+	}
+	note, err := s.notes.FindNote(ctx, reportID, noteID)
+	if err != nil {
+		return StaffNote{}, err
+	}
+	if note.Author != author {
+		return StaffNote{}, ErrNoteNotAuthor
+	}
+	now := s.now().UTC()
+	if now.After(note.CreatedAt.Add(s.noteEditWindow)) {
+		return StaffNote{}, ErrNoteEditWindowClosed
+	}
+	mentions, err := s.resolveMentions(ctx, author, input.Mentions)
+	if err != nil {
+		return StaffNote{}, err
+	}
+	added := make([]string, 0, len(mentions))
+	for _, email := range mentions {
+		if !slices.Contains(note.Mentions, email) {
+			added = append(added, email)
+		}
+	}
+	note.Body = strings.TrimSpace(input.Body)
+	note.Mentions = mentions
+	note.Attachments = toStrings(input.Attachments)
+	note.EditedAt = &now
+	if err := s.notes.UpdateNote(ctx, note); err != nil {
+		return StaffNote{}, err
+	}
+	s.logger.Info().
+		Str("event", "report.note.edited").
+		Str("report_id", reportID).
+		Str("note_id", note.ID).
+		Str("actor", author).
+		Msg("staff note edited")
+	s.notifyMentions(ctx, note, added)
+	return s.withEditWindow(note), nil
+}
+
+// resolveMentions normaliza y deduplica los correos; la autora no se menciona a sí misma.
+func (s *ReportService) resolveMentions(ctx context.Context, author string, raw []string) ([]string, error) {
+	mentions := make([]string, 0, len(raw))
+	for _, value := range raw {
+		email := strings.TrimSpace(strings.ToLower(value))
+		if email == "" || email == author || slices.Contains(mentions, email) {
+			continue
+		}
+		if s.staff == nil {
+			return nil, ErrInvalidMention
+		}
+		ok, err := s.staff.IsStaff(ctx, email)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMention, email)
+		}
+		mentions = append(mentions, email)
+	}
+	return mentions, nil
+}
+
+// notifyMentions no bloquea la nota si el aviso falla; sólo lo registra.
+func (s *ReportService) notifyMentions(ctx context.Context, note StaffNote, emails []string) {
+	for _, email := range emails {
+		if err := s.staff.NotifyMention(ctx, note, email); err != nil {
+			s.logger.Error().Err(err).Str("event", "report.note.mention.failed").Str("note_id", note.ID).Msg("unable to notify mention")
+		}
+	}
+}
+
+func (s *ReportService) withEditWindow(note StaffNote) StaffNote {
+	note.EditableUntil = note.CreatedAt.Add(s.noteEditWindow)
+	if note.Mentions == nil {
+		note.Mentions = []string{}
+	}
+	if note.Attachments == nil {
+		note.Attachments = []string{}
+	}
+	return note
+}
+
+// 9.- IsStaff acepta cuentas activas cuyo rol puede consultar todos los reportes.
+func (s *AuthService) IsStaff(ctx context.Context, email string) (bool, error) {
+	user, err := s.repo.FindByEmail(ctx, strings.TrimSpace(strings.ToLower(email)))
+	if errors.Is(err, ErrUserNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return user.Status() == UserStatusActive && RoleHasPermission(user.Role, PermReportsReadAll), nil
+}
+
+// 10.- NotifyMention avisa por correo a la persona mencionada si no desactivó los correos.
+func (s *AuthService) NotifyMention(ctx context.Context, note StaffNote, email string) error {
+	if s.mailer == nil {
+		return nil
+	}
+	user, err := s.repo.FindByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if !user.Notifications.Email {
+		return nil
+	}
+	s.enqueueMail(MailMessage{
+		To:      user.Email,
+		Subject: "Te mencionaron en el reporte " + note.ReportID,
+		Body:    fmt.Sprintf("%s escribió una nota interna en el reporte %s:\n\n%s", note.Author, note.ReportID, note.Body),
+	})
+	return nil
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 1.- memoryNoteStore conserva las notas en orden de creación.
+type memoryNoteStore struct {
+	mu    sync.Mutex
+	notes []StaffNote
+}
+
+func (m *memoryNoteStore) CreateNote(_ context.Context, note StaffNote) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notes = append(m.notes, note)
+	return nil
+}
+
+func (m *memoryNoteStore) Notes(_ context.Context, reportID string) ([]StaffNote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var notes []StaffNote
+	for _, note := range m.notes {
+		if note.ReportID == reportID {
+			notes = append(notes, note)
+		}
+	}
+	return notes, nil
+}
+
+func (m *memoryNoteStore) FindNote(_ context.Context, reportID, id string) (StaffNote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, note := range m.notes {
+		if note.ReportID == reportID && note.ID == id {
+			return note, nil
+		}
+	}
+	return StaffNote{}, ErrNoteNotFound
+}
+
+func (m *memoryNoteStore) UpdateNote(_ context.Context, note StaffNote) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.notes {
+		if m.notes[i].ReportID == note.ReportID && m.notes[i].ID == note.ID {
+			m.notes[i] = note
+			return nil
+		}
+	}
+	return ErrNoteNotFound
+}
+
+// 2.- staticDirectory reconoce una lista fija de personal y registra los avisos enviados.
+type staticDirectory struct {
+	mu       sync.Mutex
+	staff    map[string]bool
+	notified []string
+}
+
+func (d *staticDirectory) IsStaff(_ context.Context, email string) (bool, error) {
+	return d.staff[email], nil
+}
+
+func (d *staticDirectory) NotifyMention(_ context.Context, _ StaffNote, email string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.notified = append(d.notified, email)
+	return nil
+}
+
+func TestStaffNotesNotifyMentionsAndCloseAfterTheEditWindow(t *testing.T) {
+	// 3.- Ventana de diez minutos con un reloj controlado por la prueba.
+	clock := &testClock{now: time.Date(2024, 5, 6, 10, 0, 0, 0, time.UTC)}
+	directory := &staticDirectory{staff: map[string]bool{"supervisora@example.com": true, "operador2@example.com": true}}
+	repo := newFakeReportRepository()
+	svc := NewReportService(repo, newTestFolioGenerator(), 1, 1,
+		WithStaffNotes(&memoryNoteStore{}, directory, 10*time.Minute), WithReportClock(clock.Now))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	report, err := svc.Submit(ctx, "vecino@example.com", map[string]any{"incidentTypeId": "pothole", "description": "Bache"})
+	if err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+
+	// 4.- Las menciones se normalizan, se deduplican y sólo admiten personal.
+	if _, err := svc.AddStaffNote(ctx, report.ID, "operador@example.com", StaffNoteInput{Body: "Hola", Mentions: []string{"vecino@example.com"}}); !errors.Is(err, ErrInvalidMention) {
+		t.Fatalf("expected invalid mention, got %v", err)
+	}
+	note, err := svc.AddStaffNote(ctx, report.ID, "operador@example.com", StaffNoteInput{
+		Body:     " Falta material ",
+		Mentions: []string{"Supervisora@Example.com", "supervisora@example.com", "operador@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("AddStaffNote returned error: %v", err)
+	}
+	if note.Body != "Falta material" || len(note.Mentions) != 1 || !note.EditableUntil.Equal(clock.Now().Add(10*time.Minute)) {
+		t.Fatalf("unexpected note %+v", note)
+	}
+
+	// 5.- Al editar sólo se avisa a las menciones nuevas.
+	clock.Set(clock.Now().Add(5 * time.Minute))
+	if _, err := svc.EditStaffNote(ctx, report.ID, note.ID, "supervisora@example.com", StaffNoteInput{Body: "Otra"}); !errors.Is(err, ErrNoteNotAuthor) {
+		t.Fatalf("expected author check, got %v", err)
+	}
+	edited, err := svc.EditStaffNote(ctx, report.ID, note.ID, "operador@example.com", StaffNoteInput{
+		Body:     "Falta material, pedir a almacén",
+		Mentions: []string{"supervisora@example.com", "operador2@example.com"},
+	})
+	if err != nil || edited.EditedAt == nil {
+		t.Fatalf("unexpected edit %+v (%v)", edited, err)
+	}
+	if len(directory.notified) != 2 || directory.notified[1] != "operador2@example.com" {
+		t.Fatalf("unexpected notifications %v", directory.notified)
+	}
+
+	// 6.- Pasada la ventana la nota queda fija y nunca aparece en el folio.
+	clock.Set(clock.Now().Add(6 * time.Minute))
+	if _, err := svc.EditStaffNote(ctx, report.ID, note.ID, "operador@example.com", StaffNoteInput{Body: "Tarde"}); !errors.Is(err, ErrNoteEditWindowClosed) {
+		t.Fatalf("expected closed edit window, got %v", err)
+	}
+	status, err := svc.Lookup(ctx, report.ID)
+	if err != nil || len(status.History) != 1 {
+		t.Fatalf("expected notes to stay out of the folio history, got %+v (%v)", status.History, err)
+	}
+	if notes, _ := svc.Notes(ctx, report.ID); len(notes) != 1 || notes[0].Body != "Falta material, pedir a almacén" {
+		t.Fatalf("unexpected notes %+v", notes)
+	}
+}
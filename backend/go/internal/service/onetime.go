@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// 1.- Propósitos de los tokens de un solo uso enviados por correo.
+const (
+	TokenPurposeReset  = "password_reset"
+	TokenPurposeVerify = "email_verification"
+	TokenPurposeInvite = "invitation"
+)
+
+// 2.- OneTimeToken describe un token opaco de un solo uso persistido como hash.
+type OneTimeToken struct {
+	ID        string
+	Purpose   string
+	Subject   string
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// 3.- OneTimeTokenStore persiste los tokens y garantiza que se consuman una sola vez.
+type OneTimeTokenStore interface {
+	Create(ctx context.Context, token OneTimeToken) error
+	// Consume marca el token como usado o devuelve ErrInvalidOneTimeToken si no existe, expiró o ya se usó.
+	Consume(ctx context.Context, purpose, tokenHash string, now time.Time) (OneTimeToken, error)
+	// InvalidateSubject descarta los tokens pendientes del sujeto para el propósito indicado.
+	InvalidateSubject(ctx context.Context, purpose, subject string, now time.Time) error
+}
+
+// 4.- ErrInvalidOneTimeToken agrupa los tokens desconocidos, vencidos o ya utilizados.
+var ErrInvalidOneTimeToken = errors.New("invalid or expired token")
+
+// 5.- WithOneTimeTokens habilita los flujos que envían tokens por correo.
+func WithOneTimeTokens(store OneTimeTokenStore) AuthOption {
+	return func(s *AuthService) {
+		s.oneTimeTokens = store
+	}
+}
+
+// 6.- issueOneTimeToken reemplaza los tokens pendientes del sujeto y devuelve el valor en claro.
+func (s *AuthService) issueOneTimeToken(ctx context.Context, purpose, subject string, ttl time.Duration) (string, error) {
+	now := s.now()
+	if err := s.oneTimeTokens.InvalidateSubject(ctx, purpose, subject, now); err != nil {
+		return "", err
+	}
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	id, err := randomID()
+	if err != nil {
+		return "", err
+	}
+	record := OneTimeToken{
+		ID:        id,
+		Purpose:   purpose,
+		Subject:   subject,
+		TokenHash: hashToken(raw),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	if err := s.oneTimeTokens.Create(ctx, record); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
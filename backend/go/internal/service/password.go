@@ -0,0 +1,185 @@
+package service
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 1.- PasswordHasher genera hashes con el esquema vigente y reconoce los anteriores.
+type PasswordHasher interface {
+	// Hash codifica la contraseña con el algoritmo y los parámetros configurados.
+	Hash(password string) (string, error)
+	// Verify acepta cualquier esquema soportado para no bloquear cuentas antiguas.
+	Verify(password, encoded string) error
+	// NeedsRehash indica si el hash usa otro algoritmo o parámetros distintos a los vigentes.
+	NeedsRehash(encoded string) bool
+}
+
+// 2.- Errores de verificación; el worker los traduce a ErrInvalidCredentials.
+var (
+	ErrPasswordMismatch        = errors.New("password does not match")
+	ErrUnsupportedPasswordHash = errors.New("unsupported password hash")
+)
+
+// 3.- Argon2Params describe el costo de argon2id; Memory se expresa en KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// 4.- DefaultArgon2Params sigue la recomendación mínima de OWASP (19 MiB, 2 iteraciones, 1 hilo).
+func DefaultArgon2Params() Argon2Params {
+	return Argon2Params{Memory: 19 * 1024, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+}
+
+// 5.- NewBcryptHasher produce hashes bcrypt; un costo inválido toma bcrypt.DefaultCost.
+func NewBcryptHasher(cost int) PasswordHasher {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = bcrypt.DefaultCost
+	}
+	return bcryptHasher{cost: cost}
+}
+
+// 6.- NewArgon2idHasher produce hashes PHC ($argon2id$v=19$m=...,t=...,p=...$sal$hash); los campos en cero toman el valor por defecto.
+func NewArgon2idHasher(params Argon2Params) PasswordHasher {
+	defaults := DefaultArgon2Params()
+	if params.Memory == 0 {
+		params.Memory = defaults.Memory
+	}
+	if params.Iterations == 0 {
+		params.Iterations = defaults.Iterations
+	}
+	if params.Parallelism == 0 {
+		params.Parallelism = defaults.Parallelism
+	}
+	if params.SaltLength == 0 {
+		params.SaltLength = defaults.SaltLength
+	}
+	if params.KeyLength == 0 {
+		params.KeyLength = defaults.KeyLength
+	}
+	return argon2idHasher{params: params}
+}
+
+// WithPasswordHasher reemplaza el esquema vigente; los hashes previos se migran en el siguiente login.
+func WithPasswordHasher(hasher PasswordHasher) AuthOption {
+	return func(s *AuthService) {
+		if hasher != nil {
+			s.hasher = hasher
+		}
+	}
+}
+
+type bcryptHasher struct {
+	cost int
+}
+
+func (h bcryptHasher) Hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", fmt.Errorf("hash password: %w", err)
+	}
+	return string(hashed), nil
+}
+
+func (h bcryptHasher) Verify(password, encoded string) error {
+	return verifyPasswordHash(password, encoded)
+}
+
+func (h bcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.cost
+}
+
+type argon2idHasher struct {
+	params Argon2Params
+}
+
+func (h argon2idHasher) Hash(password string) (string, error) {
+	salt, err := randomBytes(int(h.params.SaltLength))
+	if err != nil {
+		return "", fmt.Errorf("hash password: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+	return encodeArgon2id(h.params, salt, key), nil
+}
+
+func (h argon2idHasher) Verify(password, encoded string) error {
+	return verifyPasswordHash(password, encoded)
+}
+
+func (h argon2idHasher) NeedsRehash(encoded string) bool {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	params.SaltLength, params.KeyLength = uint32(len(salt)), uint32(len(key))
+	return params != h.params
+}
+
+// 7.- verifyPasswordHash elige el algoritmo según el prefijo del hash almacenado.
+func verifyPasswordHash(password, encoded string) error {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return err
+		}
+		candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(candidate, key) != 1 {
+			return ErrPasswordMismatch
+		}
+		return nil
+	case strings.HasPrefix(encoded, "$2"):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrPasswordMismatch
+		}
+		return err
+	default:
+		return ErrUnsupportedPasswordHash
+	}
+}
+
+var argon2Encoding = base64.RawStdEncoding
+
+func encodeArgon2id(params Argon2Params, salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		argon2Encoding.EncodeToString(salt), argon2Encoding.EncodeToString(key))
+}
+
+// 8.- decodeArgon2id interpreta el formato PHC y rechaza versiones distintas a la soportada.
+func decodeArgon2id(encoded string) (Argon2Params, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2Params{}, nil, nil, ErrUnsupportedPasswordHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, ErrUnsupportedPasswordHash
+	}
+	var params Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil ||
+		params.Iterations == 0 || params.Parallelism == 0 {
+		return Argon2Params{}, nil, nil, ErrUnsupportedPasswordHash
+	}
+	salt, err := argon2Encoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrUnsupportedPasswordHash
+	}
+	key, err := argon2Encoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2Params{}, nil, nil, ErrUnsupportedPasswordHash
+	}
+	return params, salt, key, nil
+}
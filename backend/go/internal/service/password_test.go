@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestArgon2idHasherVerifiesEverySchemeAndFlagsOutdatedHashes(t *testing.T) {
+	// 1.- El hash argon2id usa el formato PHC y valida sólo la contraseña correcta.
+	params := Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1}
+	hasher := NewArgon2idHasher(params)
+	encoded, err := hasher.Hash("ClaveSegura1")
+	if err != nil {
+		t.Fatalf("Hash returned error: %v", err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("unexpected encoding %q", encoded)
+	}
+	if err := hasher.Verify("ClaveSegura1", encoded); err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+	if err := hasher.Verify("OtraClave1", encoded); !errors.Is(err, ErrPasswordMismatch) {
+		t.Fatalf("expected mismatch, got %v", err)
+	}
+	if hasher.NeedsRehash(encoded) {
+		t.Fatalf("hash with current parameters must not be rehashed")
+	}
+
+	// 2.- Los hashes bcrypt siguen validando, pero se marcan para migrar igual que otros parámetros argon2id.
+	legacy, err := NewBcryptHasher(bcrypt.MinCost).Hash("ClaveSegura1")
+	if err != nil {
+		t.Fatalf("bcrypt Hash returned error: %v", err)
+	}
+	if err := hasher.Verify("ClaveSegura1", legacy); err != nil {
+		t.Fatalf("expected bcrypt hash to verify, got %v", err)
+	}
+	if !hasher.NeedsRehash(legacy) {
+		t.Fatalf("expected bcrypt hash to need rehash")
+	}
+	if !NewArgon2idHasher(Argon2Params{Memory: 2048, Iterations: 1, Parallelism: 1}).NeedsRehash(encoded) {
+		t.Fatalf("expected argon2id hash with weaker memory to need rehash")
+	}
+	if !NewBcryptHasher(bcrypt.MinCost + 1).NeedsRehash(legacy) {
+		t.Fatalf("expected bcrypt hash with another cost to need rehash")
+	}
+
+	// 3.- Un hash desconocido o malformado nunca valida.
+	for _, bad := range []string{"plain", "$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5", "$argon2id$v=19$m=1024,t=0,p=1$c2FsdA$a2V5"} {
+		if err := hasher.Verify("ClaveSegura1", bad); !errors.Is(err, ErrUnsupportedPasswordHash) {
+			t.Fatalf("expected unsupported hash for %q, got %v", bad, err)
+		}
+	}
+}
+
+func TestLoginUpgradesOutdatedPasswordHash(t *testing.T) {
+	// 1.- Registramos la cuenta con el esquema bcrypt por defecto.
+	repo := newFakeUserRepository()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	legacy := NewAuthService(repo, 1, time.Minute, []byte("test-secret"))
+	if _, err := legacy.Register(ctx, "vecino@example.com", "ClaveSegura1"); err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	before, _ := repo.FindByEmail(ctx, "vecino@example.com")
+	if !strings.HasPrefix(before.PasswordHash, "$2") {
+		t.Fatalf("expected bcrypt hash, got %q", before.PasswordHash)
+	}
+
+	// 2.- Con argon2id configurado, un intento fallido no toca el hash y uno correcto lo migra.
+	svc := NewAuthService(repo, 1, time.Minute, []byte("test-secret"),
+		WithPasswordHasher(NewArgon2idHasher(Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1})))
+	if _, err := svc.Authenticate(ctx, "vecino@example.com", "OtraClave1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected invalid credentials, got %v", err)
+	}
+	if unchanged, _ := repo.FindByEmail(ctx, "vecino@example.com"); unchanged.PasswordHash != before.PasswordHash {
+		t.Fatalf("failed login must not rehash")
+	}
+	if _, err := svc.Authenticate(ctx, "vecino@example.com", "ClaveSegura1"); err != nil {
+		t.Fatalf("Authenticate returned error: %v", err)
+	}
+	after, _ := repo.FindByEmail(ctx, "vecino@example.com")
+	if !strings.HasPrefix(after.PasswordHash, "$argon2id$") {
+		t.Fatalf("expected argon2id hash after login, got %q", after.PasswordHash)
+	}
+
+	// 3.- La contraseña sigue funcionando con el hash nuevo y no se vuelve a migrar.
+	if _, err := svc.Authenticate(ctx, "vecino@example.com", "ClaveSegura1"); err != nil {
+		t.Fatalf("Authenticate after upgrade returned error: %v", err)
+	}
+	if current, _ := repo.FindByEmail(ctx, "vecino@example.com"); current.PasswordHash != after.PasswordHash {
+		t.Fatalf("current hash must not be rehashed again")
+	}
+}
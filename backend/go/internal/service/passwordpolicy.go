@@ -0,0 +1,282 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// 1.- Reglas que puede reportar la política; el cliente las usa para resaltar cada requisito.
+const (
+	PasswordRuleMinLength = "min_length"
+	PasswordRuleMaxLength = "max_length"
+	PasswordRuleDenylist  = "denylist"
+	PasswordRuleBreached  = "breached"
+)
+
+// 2.- ErrWeakPassword agrupa los rechazos de la política de contraseñas.
+var ErrWeakPassword = errors.New("password does not meet the policy")
+
+// 2.1.- PasswordViolation describe una regla incumplida.
+type PasswordViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// 2.2.- PasswordPolicyError lista todas las reglas incumplidas, no sólo la primera.
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	rules := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		rules = append(rules, violation.Rule)
+	}
+	return fmt.Sprintf("%s: %s", ErrWeakPassword, strings.Join(rules, ", "))
+}
+
+// Is permite usar errors.Is(err, ErrWeakPassword) sin inspeccionar las reglas.
+func (e *PasswordPolicyError) Is(target error) bool {
+	return target == ErrWeakPassword
+}
+
+// 3.- BreachedPasswordChecker consulta contraseñas filtradas sin exponer la contraseña en claro.
+type BreachedPasswordChecker interface {
+	IsBreached(ctx context.Context, password string) (bool, error)
+}
+
+// 4.- PasswordPolicy configura longitud, palabras prohibidas y la lista de filtraciones.
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	// Denylist se compara sin mayúsculas ni sustituciones comunes (0→o, 4→a, $→s...).
+	Denylist []string
+	Breached BreachedPasswordChecker
+}
+
+// 5.- DefaultPasswordPolicy exige entre 8 y 128 caracteres y rechaza las palabras más comunes.
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength: 8,
+		MaxLength: 128,
+		Denylist: []string{
+			"password", "contrasena", "qwerty", "123456", "abc123", "letmein", "admin",
+			"welcome", "bienvenido", "iloveyou", "teamo", "municipio", "ciudadano",
+		},
+	}
+}
+
+// WithPasswordPolicy valida las contraseñas nuevas en el registro y el restablecimiento; los campos en cero toman el valor por defecto.
+func WithPasswordPolicy(policy PasswordPolicy) AuthOption {
+	return func(s *AuthService) {
+		defaults := DefaultPasswordPolicy()
+		if policy.MinLength <= 0 {
+			policy.MinLength = defaults.MinLength
+		}
+		if policy.MaxLength < policy.MinLength {
+			policy.MaxLength = defaults.MaxLength
+			if policy.MaxLength < policy.MinLength {
+				policy.MaxLength = policy.MinLength
+			}
+		}
+		if policy.Denylist == nil {
+			policy.Denylist = defaults.Denylist
+		}
+		words := make([]string, 0, len(policy.Denylist))
+		for _, word := range policy.Denylist {
+			if normalized := normalizePasswordWord(word); normalized != "" {
+				words = append(words, normalized)
+			}
+		}
+		policy.Denylist = words
+		s.passwordPolicy = &policy
+	}
+}
+
+// 6.- checkPassword evalúa todas las reglas para devolver un único error con cada violación.
+func (s *AuthService) checkPassword(ctx context.Context, password string) error {
+	if s.passwordPolicy == nil {
+		return nil
+	}
+	policy := s.passwordPolicy
+	var violations []PasswordViolation
+	length := utf8.RuneCountInString(password)
+	if length < policy.MinLength {
+		violations = append(violations, PasswordViolation{
+			Rule:    PasswordRuleMinLength,
+			Message: fmt.Sprintf("must be at least %d characters", policy.MinLength),
+		})
+	}
+	if length > policy.MaxLength {
+		violations = append(violations, PasswordViolation{
+			Rule:    PasswordRuleMaxLength,
+			Message: fmt.Sprintf("must be at most %d characters", policy.MaxLength),
+		})
+	}
+	normalized := normalizePasswordWord(password)
+	for _, word := range policy.Denylist {
+		if strings.Contains(normalized, word) {
+			violations = append(violations, PasswordViolation{
+				Rule:    PasswordRuleDenylist,
+				Message: "must not contain common or easily guessed words",
+			})
+			break
+		}
+	}
+	if policy.Breached != nil {
+		breached, err := policy.Breached.IsBreached(ctx, password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			violations = append(violations, PasswordViolation{
+				Rule:    PasswordRuleBreached,
+				Message: "appears in a known data breach",
+			})
+		}
+	}
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+var passwordSubstitutions = strings.NewReplacer(
+	"0", "o", "1", "i", "3", "e", "4", "a", "@", "a", "5", "s", "$", "s", "7", "t",
+	"á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ñ", "n",
+)
+
+// normalizePasswordWord baja a minúsculas y deshace las sustituciones típicas para comparar con la lista.
+func normalizePasswordWord(word string) string {
+	return passwordSubstitutions.Replace(strings.ToLower(strings.TrimSpace(word)))
+}
+
+// 7.- BreachedPasswordList agrupa los SHA-1 filtrados por su prefijo de 5 caracteres, igual que el modelo k-anonymity.
+type BreachedPasswordList struct {
+	buckets map[string]map[string]struct{}
+	size    int
+}
+
+// 8.- LoadBreachedPasswordList lee un archivo plano con líneas "SHA1" o "SHA1:conteo" y lo conserva en memoria.
+func LoadBreachedPasswordList(path string) (*BreachedPasswordList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open breached password list: %w", err)
+	}
+	defer file.Close()
+	list := &BreachedPasswordList{buckets: make(map[string]map[string]struct{})}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.ToUpper(strings.TrimSpace(scanner.Text()))
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		hash, _, _ := strings.Cut(entry, ":")
+		if len(hash) != 40 || strings.Trim(hash, "0123456789ABCDEF") != "" {
+			return nil, fmt.Errorf("breached password list %s:%d: invalid hash", path, line)
+		}
+		list.add(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read breached password list: %w", err)
+	}
+	return list, nil
+}
+
+// Len devuelve la cantidad de hashes cargados.
+func (l *BreachedPasswordList) Len() int {
+	return l.size
+}
+
+// IsBreached calcula el SHA-1 y sólo compara dentro del grupo de su prefijo.
+func (l *BreachedPasswordList) IsBreached(_ context.Context, password string) (bool, error) {
+	hash := passwordSHA1(password)
+	_, found := l.buckets[hash[:5]][hash[5:]]
+	return found, nil
+}
+
+func (l *BreachedPasswordList) add(hash string) {
+	bucket, ok := l.buckets[hash[:5]]
+	if !ok {
+		bucket = make(map[string]struct{})
+		l.buckets[hash[:5]] = bucket
+	}
+	if _, exists := bucket[hash[5:]]; !exists {
+		bucket[hash[5:]] = struct{}{}
+		l.size++
+	}
+}
+
+// 9.- BreachedPasswordRanges consulta el formato de rangos de Have I Been Pwned: un archivo por prefijo de 5 caracteres
+// con líneas "SUFIJO:conteo". Cada consulta abre sólo el archivo de su prefijo, así que el corpus completo no ocupa memoria.
+type BreachedPasswordRanges struct {
+	dir string
+}
+
+// 10.- OpenBreachedPasswordRanges valida que el directorio exista; los archivos se leen en cada consulta.
+func OpenBreachedPasswordRanges(dir string) (*BreachedPasswordRanges, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("open breached password ranges: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("breached password ranges %s: not a directory", dir)
+	}
+	return &BreachedPasswordRanges{dir: dir}, nil
+}
+
+// IsBreached busca el sufijo en "<PREFIJO>" o "<PREFIJO>.txt"; un prefijo sin archivo no tiene filtraciones.
+func (r *BreachedPasswordRanges) IsBreached(_ context.Context, password string) (bool, error) {
+	hash := passwordSHA1(password)
+	file, err := r.openRange(hash[:5])
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("open breached password range: %w", err)
+	}
+	defer file.Close()
+	suffix := hash[5:]
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.ToUpper(strings.TrimSpace(scanner.Text()))
+		if entry == "" {
+			continue
+		}
+		candidate, _, _ := strings.Cut(entry, ":")
+		if len(candidate) != 35 || strings.Trim(candidate, "0123456789ABCDEF") != "" {
+			return false, fmt.Errorf("breached password range %s:%d: invalid suffix", file.Name(), line)
+		}
+		if candidate == suffix {
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("read breached password range: %w", err)
+	}
+	return false, nil
+}
+
+// openRange acepta el nombre del API de rangos y el que genera la herramienta de descarga, con extensión .txt.
+func (r *BreachedPasswordRanges) openRange(prefix string) (*os.File, error) {
+	file, err := os.Open(filepath.Join(r.dir, prefix))
+	if errors.Is(err, fs.ErrNotExist) {
+		file, err = os.Open(filepath.Join(r.dir, prefix+".txt"))
+	}
+	return file, err
+}
+
+// passwordSHA1 devuelve el SHA-1 en hexadecimal con mayúsculas, como lo publica Have I Been Pwned.
+func passwordSHA1(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
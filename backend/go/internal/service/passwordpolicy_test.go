@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPasswordPolicyReportsEveryViolation(t *testing.T) {
+	// 1.- La lista de filtraciones se carga con hashes completos y conteos opcionales.
+	sum := sha1.Sum([]byte("Tulum2024!"))
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	content := "# muestra\n" + strings.ToUpper(hex.EncodeToString(sum[:])) + ":42\n" +
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("cannot write list: %v", err)
+	}
+	breached, err := LoadBreachedPasswordList(path)
+	if err != nil {
+		t.Fatalf("LoadBreachedPasswordList returned error: %v", err)
+	}
+	if breached.Len() != 2 {
+		t.Fatalf("expected 2 hashes, got %d", breached.Len())
+	}
+
+	// 2.- Una contraseña corta con una palabra prohibida reporta ambas reglas en el mismo error.
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Minute, []byte("test-secret"),
+		WithPasswordPolicy(PasswordPolicy{MinLength: 10, Denylist: []string{"tulum"}, Breached: breached}))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err = svc.Register(ctx, "vecino@example.com", "7ulum1")
+	var policyErr *PasswordPolicyError
+	if !errors.As(err, &policyErr) || !errors.Is(err, ErrWeakPassword) {
+		t.Fatalf("expected policy error, got %v", err)
+	}
+	if rules := violatedRules(policyErr); rules != "min_length,denylist" {
+		t.Fatalf("unexpected rules %q", rules)
+	}
+
+	// 3.- Las contraseñas filtradas se rechazan aunque cumplan el resto de las reglas.
+	_, err = svc.Register(ctx, "vecino@example.com", "Tulum2024!")
+	if !errors.As(err, &policyErr) || violatedRules(policyErr) != "denylist,breached" {
+		t.Fatalf("expected denylist and breached violations, got %v", err)
+	}
+	if _, err := svc.Register(ctx, "vecino@example.com", "caballo-bateria-grapa"); err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+
+	// 4.- Un archivo con hashes inválidos se rechaza al cargar.
+	if err := os.WriteFile(path, []byte("no-es-un-hash\n"), 0o600); err != nil {
+		t.Fatalf("cannot write list: %v", err)
+	}
+	if _, err := LoadBreachedPasswordList(path); err == nil {
+		t.Fatalf("expected error for malformed list")
+	}
+}
+
+func TestBreachedPasswordRangesOpenOnlyThePrefixFile(t *testing.T) {
+	// 1.- Armamos un directorio de rangos: un archivo por prefijo con líneas "SUFIJO:conteo".
+	sum := sha1.Sum([]byte("Tulum2024!"))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	dir := t.TempDir()
+	content := "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n" + hash[5:] + ":42\r\n"
+	if err := os.WriteFile(filepath.Join(dir, hash[:5]+".txt"), []byte(content), 0o600); err != nil {
+		t.Fatalf("cannot write range: %v", err)
+	}
+	ranges, err := OpenBreachedPasswordRanges(dir)
+	if err != nil {
+		t.Fatalf("OpenBreachedPasswordRanges returned error: %v", err)
+	}
+
+	// 2.- El registro rechaza la contraseña filtrada y acepta una cuyo prefijo no tiene archivo.
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Minute, []byte("test-secret"),
+		WithPasswordPolicy(PasswordPolicy{MinLength: 8, Breached: ranges}))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err = svc.Register(ctx, "vecino@example.com", "Tulum2024!")
+	var policyErr *PasswordPolicyError
+	if !errors.As(err, &policyErr) || violatedRules(policyErr) != "breached" {
+		t.Fatalf("expected breached violation, got %v", err)
+	}
+	if _, err := svc.Register(ctx, "vecino@example.com", "caballo-bateria-grapa"); err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+
+	// 3.- Un archivo de rango dañado se reporta en la consulta y una ruta que no es directorio, al abrir.
+	if err := os.WriteFile(filepath.Join(dir, hash[:5]+".txt"), []byte("no-es-un-sufijo\n"), 0o600); err != nil {
+		t.Fatalf("cannot write range: %v", err)
+	}
+	if _, err := ranges.IsBreached(ctx, "Tulum2024!"); err == nil {
+		t.Fatalf("expected error for malformed range")
+	}
+	if _, err := OpenBreachedPasswordRanges(filepath.Join(dir, hash[:5]+".txt")); err == nil {
+		t.Fatalf("expected error for a file instead of a directory")
+	}
+}
+
+func TestPasswordResetKeepsTokenWhenPolicyFails(t *testing.T) {
+	// 1.- Solicitamos el restablecimiento con la política por defecto.
+	mailer := newChannelMailer()
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Minute, []byte("test-secret"),
+		WithOneTimeTokens(newMemoryOneTimeTokenStore()), WithMailer(mailer),
+		WithPasswordReset(15*time.Minute, "https://app.example.com/reset"),
+		WithPasswordPolicy(DefaultPasswordPolicy()))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := svc.Register(ctx, "vecino@example.com", "ClaveVieja1"); err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	if err := svc.Recover(ctx, "vecino@example.com"); err != nil {
+		t.Fatalf("Recover returned error: %v", err)
+	}
+	token := tokenFromBody(t, mailer.wait(t).Body)
+
+	// 2.- Una contraseña débil no consume el token, así que el usuario puede reintentar.
+	if err := svc.ResetPassword(ctx, token, "P@ssw0rd123"); !errors.Is(err, ErrWeakPassword) {
+		t.Fatalf("expected weak password, got %v", err)
+	}
+	if err := svc.ResetPassword(ctx, token, "ClaveNueva1"); err != nil {
+		t.Fatalf("ResetPassword returned error: %v", err)
+	}
+}
+
+func violatedRules(err *PasswordPolicyError) string {
+	rules := make([]string, 0, len(err.Violations))
+	for _, violation := range err.Violations {
+		rules = append(rules, violation.Rule)
+	}
+	return strings.Join(rules, ",")
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultResetTTL = 30 * time.Minute
+	resetSubject    = "Restablece tu contraseña"
+)
+
+// 1.- WithPasswordReset define la vigencia del token y la pantalla que recibe el enlace.
+func WithPasswordReset(ttl time.Duration, resetURL string) AuthOption {
+	return func(s *AuthService) {
+		if ttl > 0 {
+			s.resetTTL = ttl
+		}
+		s.resetURL = strings.TrimSpace(resetURL)
+	}
+}
+
+// 2.- sendPasswordReset encola la emisión del token; buscar la cuenta en el worker iguala el tiempo de respuesta.
+func (s *AuthService) sendPasswordReset(email string) error {
+	if s.oneTimeTokens == nil || s.mailer == nil {
+		return errors.New("password reset delivery is not configured")
+	}
+	s.enqueueMailJob(mailJob{
+		message: MailMessage{Subject: resetSubject},
+		prepare: func(ctx context.Context) (MailMessage, error) {
+			user, err := s.repo.FindByEmail(ctx, email)
+			if err != nil {
+				return MailMessage{}, err
+			}
+			token, err := s.issueOneTimeToken(ctx, TokenPurposeReset, user.Email, s.resetTTL)
+			if err != nil {
+				return MailMessage{}, err
+			}
+			return MailMessage{To: user.Email, Subject: resetSubject, Body: s.resetBody(token)}, nil
+		},
+	})
+	return nil
+}
+
+// 3.- resetBody arma el texto con el enlace o, si no hay URL configurada, con el token en claro.
+func (s *AuthService) resetBody(token string) string {
+	action := "Usa este código en la aplicación para elegir una nueva contraseña:\n\n" + token
+	if s.resetURL != "" {
+		action = "Abre este enlace para elegir una nueva contraseña:\n\n" + s.resetURL + "?token=" + url.QueryEscape(token)
+	}
+	minutes := int(s.resetTTL / time.Minute)
+	return fmt.Sprintf("Recibimos una solicitud para restablecer tu contraseña.\n\n%s\n\nVence en %d minutos y sólo puede usarse una vez. Si no hiciste esta solicitud, ignora este correo.\n", action, minutes)
+}
+
+// 4.- ResetPassword consume el token, guarda el nuevo hash y cierra las sesiones previas.
+func (s *AuthService) ResetPassword(ctx context.Context, token, password string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+	if strings.TrimSpace(password) == "" {
+		return ErrInvalidCredentials
+	}
+	if s.oneTimeTokens == nil {
+		return ErrInvalidOneTimeToken
+	}
+	// La política se evalúa antes de consumir el token para que el usuario pueda reintentar.
+	if err := s.checkPassword(ctx, password); err != nil {
+		return err
+	}
+	hashed, err := s.hashPassword(password)
+	if err != nil {
+		return err
+	}
+	consumed, err := s.oneTimeTokens.Consume(ctx, TokenPurposeReset, hashToken(strings.TrimSpace(token)), s.now())
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UpdatePassword(ctx, consumed.Subject, hashed); err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return ErrInvalidOneTimeToken
+		}
+		return err
+	}
+	// Recibir el token prueba también la propiedad del correo.
+	if err := s.repo.MarkVerified(ctx, consumed.Subject, s.now()); err != nil {
+		return err
+	}
+	s.logger.Info().
+		Str("event", "auth.password.reset").
+		Str("subject", consumed.Subject).
+		Msg("password reset completed")
+	// Quien tenía la contraseña anterior no debe conservar sesiones abiertas.
+	return s.RevokeSessions(ctx, consumed.Subject)
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 1.- memoryOneTimeTokenStore replica el consumo único del repositorio Postgres.
+type memoryOneTimeTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]OneTimeToken
+	used   map[string]bool
+}
+
+func newMemoryOneTimeTokenStore() *memoryOneTimeTokenStore {
+	return &memoryOneTimeTokenStore{tokens: make(map[string]OneTimeToken), used: make(map[string]bool)}
+}
+
+func (m *memoryOneTimeTokenStore) Create(_ context.Context, token OneTimeToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[token.TokenHash] = token
+	return nil
+}
+
+func (m *memoryOneTimeTokenStore) Consume(_ context.Context, purpose, tokenHash string, now time.Time) (OneTimeToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token, ok := m.tokens[tokenHash]
+	if !ok || token.Purpose != purpose || m.used[tokenHash] || !token.ExpiresAt.After(now) {
+		return OneTimeToken{}, ErrInvalidOneTimeToken
+	}
+	m.used[tokenHash] = true
+	return token, nil
+}
+
+func (m *memoryOneTimeTokenStore) InvalidateSubject(_ context.Context, purpose, subject string, _ time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for hash, token := range m.tokens {
+		if token.Purpose == purpose && token.Subject == subject {
+			m.used[hash] = true
+		}
+	}
+	return nil
+}
+
+// 2.- channelMailer publica cada correo en un canal para esperar el envío asíncrono.
+type channelMailer struct {
+	sent chan MailMessage
+}
+
+func newChannelMailer() *channelMailer {
+	return &channelMailer{sent: make(chan MailMessage, 8)}
+}
+
+func (m *channelMailer) Send(_ context.Context, message MailMessage) error {
+	m.sent <- message
+	return nil
+}
+
+func (m *channelMailer) wait(t *testing.T) MailMessage {
+	t.Helper()
+	select {
+	case message := <-m.sent:
+		return message
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timeout waiting for mail")
+	}
+	return MailMessage{}
+}
+
+// 3.- tokenFromBody extrae el valor que sigue a "?token=" en el enlace enviado.
+func tokenFromBody(t *testing.T, body string) string {
+	t.Helper()
+	_, after, ok := strings.Cut(body, "?token=")
+	if !ok {
+		t.Fatalf("reset link not found in %q", body)
+	}
+	return strings.Fields(after)[0]
+}
+
+func TestPasswordResetFlow(t *testing.T) {
+	// 4.- Registramos una cuenta y solicitamos el restablecimiento.
+	mailer := newChannelMailer()
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Minute, []byte("test-secret"),
+		WithOneTimeTokens(newMemoryOneTimeTokenStore()), WithMailer(mailer),
+		WithPasswordReset(15*time.Minute, "https://app.example.com/reset"))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := svc.Register(ctx, "vecino@example.com", "ClaveVieja1"); err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	if err := svc.Recover(ctx, "Vecino@Example.com"); err != nil {
+		t.Fatalf("Recover returned error: %v", err)
+	}
+	message := mailer.wait(t)
+	if message.To != "vecino@example.com" || !strings.Contains(message.Body, "https://app.example.com/reset?token=") {
+		t.Fatalf("unexpected reset mail: %+v", message)
+	}
+	token := tokenFromBody(t, message.Body)
+
+	// 5.- El token cambia la contraseña una sola vez.
+	if err := svc.ResetPassword(ctx, token, "ClaveNueva1"); err != nil {
+		t.Fatalf("ResetPassword returned error: %v", err)
+	}
+	if err := svc.ResetPassword(ctx, token, "OtraClave1"); !errors.Is(err, ErrInvalidOneTimeToken) {
+		t.Fatalf("expected single-use token, got %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, "vecino@example.com", "ClaveVieja1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected old password to fail, got %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, "vecino@example.com", "ClaveNueva1"); err != nil {
+		t.Fatalf("expected new password to work, got %v", err)
+	}
+}
+
+func TestRecoverDoesNotRevealUnknownAccounts(t *testing.T) {
+	// 1.- Una cuenta inexistente responde igual que una real y no genera correo.
+	mailer := newChannelMailer()
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Minute, []byte("test-secret"),
+		WithOneTimeTokens(newMemoryOneTimeTokenStore()), WithMailer(mailer))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := svc.Recover(ctx, "nadie@example.com"); err != nil {
+		t.Fatalf("expected nil error for unknown account, got %v", err)
+	}
+	select {
+	case message := <-mailer.sent:
+		t.Fatalf("unexpected mail for unknown account: %+v", message)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// 2.- Una segunda solicitud invalida el token anterior.
+	if _, err := svc.Register(ctx, "vecino@example.com", "ClaveVieja1"); err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	_ = svc.Recover(ctx, "vecino@example.com")
+	first := mailer.wait(t)
+	_ = svc.Recover(ctx, "vecino@example.com")
+	second := mailer.wait(t)
+	if !strings.Contains(first.Body, "código") {
+		t.Fatalf("expected plain code without reset URL, got %q", first.Body)
+	}
+	firstToken := strings.Fields(strings.SplitN(first.Body, "\n\n", 3)[2])[0]
+	secondToken := strings.Fields(strings.SplitN(second.Body, "\n\n", 3)[2])[0]
+	if err := svc.ResetPassword(ctx, firstToken, "ClaveNueva1"); !errors.Is(err, ErrInvalidOneTimeToken) {
+		t.Fatalf("expected superseded token to fail, got %v", err)
+	}
+	if err := svc.ResetPassword(ctx, secondToken, "ClaveNueva1"); err != nil {
+		t.Fatalf("expected latest token to work, got %v", err)
+	}
+}
+
+func TestRecoverLooksUpTheAccountOffTheRequestPath(t *testing.T) {
+	// 1.- Con el repositorio bloqueado, Recover responde de inmediato también para una cuenta real.
+	repo := newFakeUserRepository()
+	mailer := newChannelMailer()
+	svc := NewAuthService(repo, 1, time.Minute, []byte("test-secret"),
+		WithOneTimeTokens(newMemoryOneTimeTokenStore()), WithMailer(mailer))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := svc.Register(ctx, "vecino@example.com", "ClaveVieja1"); err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	repo.mu.Lock()
+	done := make(chan error, 1)
+	go func() { done <- svc.Recover(ctx, "vecino@example.com") }()
+	select {
+	case err := <-done:
+		if err != nil {
+			repo.mu.Unlock()
+			t.Fatalf("Recover returned error: %v", err)
+		}
+	case <-time.After(500 * time.Millisecond):
+		repo.mu.Unlock()
+		t.Fatalf("Recover waited for the account lookup")
+	}
+
+	// 2.- Al liberar el repositorio el worker emite el token y envía el correo.
+	repo.mu.Unlock()
+	if message := mailer.wait(t); message.To != "vecino@example.com" {
+		t.Fatalf("unexpected reset mail: %+v", message)
+	}
+}
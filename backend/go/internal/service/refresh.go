@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// 1.- RefreshToken describe un token opaco persistido sólo como hash.
+type RefreshToken struct {
+	ID        string
+	FamilyID  string
+	Subject   string
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	// AuthMethods y AuthTime recuerdan el inicio de sesión interactivo que creó la familia; cada rotación los hereda.
+	AuthMethods []string
+	AuthTime    time.Time
+}
+
+// 2.- RefreshTokenStore persiste los tokens y consume cada uno una sola vez.
+type RefreshTokenStore interface {
+	Create(ctx context.Context, token RefreshToken) error
+	// Consume marca el token como usado; si ya se había usado devuelve el registro junto con ErrRefreshTokenReused.
+	Consume(ctx context.Context, tokenHash string, now time.Time) (RefreshToken, error)
+	RevokeFamily(ctx context.Context, familyID string, now time.Time) error
+	RevokeSubject(ctx context.Context, subject string, now time.Time) error
+}
+
+// 3.- Errores específicos del flujo de renovación.
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+)
+
+// 4.- WithRefreshTokens habilita tokens de renovación rotativos con la vigencia indicada.
+func WithRefreshTokens(store RefreshTokenStore, ttl time.Duration) AuthOption {
+	return func(s *AuthService) {
+		s.refreshStore = store
+		s.refreshTTL = ttl
+	}
+}
+
+// 5.- Refresh rota el token y revoca toda la familia si detecta reutilización.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (AuthResponse, error) {
+	select {
+	case <-ctx.Done():
+		return AuthResponse{}, ctx.Err()
+	default:
+	}
+	if s.refreshStore == nil || strings.TrimSpace(refreshToken) == "" {
+		return AuthResponse{}, ErrInvalidRefreshToken
+	}
+	now := s.now()
+	consumed, err := s.refreshStore.Consume(ctx, hashToken(strings.TrimSpace(refreshToken)), now)
+	if err != nil {
+		if errors.Is(err, ErrRefreshTokenReused) {
+			if revokeErr := s.refreshStore.RevokeFamily(ctx, consumed.FamilyID, now); revokeErr != nil {
+				return AuthResponse{}, revokeErr
+			}
+			s.logger.Warn().
+				Str("event", "auth.refresh.reused").
+				Str("subject", consumed.Subject).
+				Str("family_id", consumed.FamilyID).
+				Msg("refresh token reuse detected, family revoked")
+		}
+		return AuthResponse{}, err
+	}
+	user, err := s.repo.FindByEmail(ctx, consumed.Subject)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return AuthResponse{}, ErrInvalidRefreshToken
+		}
+		return AuthResponse{}, err
+	}
+	return s.issueSession(ctx, user, consumed.FamilyID, authContext{methods: consumed.AuthMethods, at: consumed.AuthTime})
+}
+
+// 6.- newRefreshToken crea y persiste un token dentro de la familia indicada.
+func (s *AuthService) newRefreshToken(ctx context.Context, subject, familyID string, auth authContext) (string, time.Time, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	id, err := randomID()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if familyID == "" {
+		familyID = id
+	}
+	now := s.now()
+	record := RefreshToken{
+		ID:          id,
+		FamilyID:    familyID,
+		Subject:     subject,
+		TokenHash:   hashToken(raw),
+		ExpiresAt:   now.Add(s.refreshTTL),
+		CreatedAt:   now,
+		AuthMethods: auth.methods,
+		AuthTime:    auth.at,
+	}
+	if err := s.refreshStore.Create(ctx, record); err != nil {
+		return "", time.Time{}, err
+	}
+	return raw, record.ExpiresAt, nil
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 1.- memoryRefreshStore replica la semántica de consumo único del repositorio Postgres.
+type memoryRefreshStore struct {
+	mu      sync.Mutex
+	tokens  map[string]RefreshToken
+	used    map[string]bool
+	revoked map[string]bool
+}
+
+func newMemoryRefreshStore() *memoryRefreshStore {
+	return &memoryRefreshStore{tokens: make(map[string]RefreshToken), used: make(map[string]bool), revoked: make(map[string]bool)}
+}
+
+func (m *memoryRefreshStore) Create(_ context.Context, token RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[token.TokenHash] = token
+	return nil
+}
+
+func (m *memoryRefreshStore) Consume(_ context.Context, tokenHash string, now time.Time) (RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token, ok := m.tokens[tokenHash]
+	if !ok {
+		return RefreshToken{}, ErrInvalidRefreshToken
+	}
+	if m.used[tokenHash] {
+		return token, ErrRefreshTokenReused
+	}
+	if m.revoked[token.FamilyID] || !token.ExpiresAt.After(now) {
+		return RefreshToken{}, ErrInvalidRefreshToken
+	}
+	m.used[tokenHash] = true
+	return token, nil
+}
+
+func (m *memoryRefreshStore) RevokeFamily(_ context.Context, familyID string, _ time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.revoked[familyID] = true
+	return nil
+}
+
+func (m *memoryRefreshStore) RevokeSubject(_ context.Context, subject string, _ time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, token := range m.tokens {
+		if token.Subject == subject {
+			m.revoked[token.FamilyID] = true
+		}
+	}
+	return nil
+}
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	// 2.- Registramos un usuario con tokens de renovación habilitados.
+	store := newMemoryRefreshStore()
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Minute, []byte("test-secret"), WithRefreshTokens(store, time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	initial, err := svc.Register(ctx, "vecino@example.com", "s3cr3t-pass")
+	if err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	if initial.RefreshToken == "" || initial.RefreshExpiresAt == nil {
+		t.Fatalf("expected refresh token on register, got %+v", initial)
+	}
+
+	// 3.- Cada renovación entrega un token distinto dentro de la misma familia.
+	rotated, err := svc.Refresh(ctx, initial.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+	if rotated.RefreshToken == "" || rotated.RefreshToken == initial.RefreshToken {
+		t.Fatalf("expected a rotated refresh token")
+	}
+	first := store.tokens[hashToken(initial.RefreshToken)]
+	second := store.tokens[hashToken(rotated.RefreshToken)]
+	if first.FamilyID == "" || first.FamilyID != second.FamilyID {
+		t.Fatalf("expected shared family, got %q and %q", first.FamilyID, second.FamilyID)
+	}
+	principal, err := svc.ValidateToken(ctx, rotated.Token)
+	if err != nil || principal.Subject != "vecino@example.com" {
+		t.Fatalf("unexpected principal %+v (%v)", principal, err)
+	}
+}
+
+func TestRefreshKeepsTheLoginAuthenticationContext(t *testing.T) {
+	// 1.- El inicio de sesión con contraseña registra amr y auth_time.
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	loginAt := now
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Minute, []byte("test-secret"),
+		WithPasswordHasher(NewBcryptHasher(bcrypt.MinCost)), WithRefreshTokens(newMemoryRefreshStore(), time.Hour),
+		WithClock(func() time.Time { return now }))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := svc.Register(ctx, "vecino@example.com", "s3cr3t-pass"); err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	login, err := svc.Authenticate(ctx, "vecino@example.com", "s3cr3t-pass")
+	if err != nil {
+		t.Fatalf("Authenticate returned error: %v", err)
+	}
+
+	// 2.- Diez minutos después la renovación trae un iat nuevo pero conserva los métodos y la hora del login.
+	now = now.Add(10 * time.Minute)
+	rotated, err := svc.Refresh(ctx, login.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+	principal, err := svc.ValidateToken(ctx, rotated.Token)
+	if err != nil {
+		t.Fatalf("ValidateToken returned error: %v", err)
+	}
+	if rotated.Token == login.Token || !principal.AuthTime.Equal(loginAt) || strings.Join(principal.AuthMethods, ",") != "pwd" {
+		t.Fatalf("unexpected refreshed principal %+v", principal)
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	// 1.- Obtenemos dos generaciones de tokens de la misma sesión.
+	store := newMemoryRefreshStore()
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Minute, []byte("test-secret"), WithRefreshTokens(store, time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	initial, err := svc.Register(ctx, "vecino@example.com", "s3cr3t-pass")
+	if err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	rotated, err := svc.Refresh(ctx, initial.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+
+	// 2.- Reutilizar el token anterior se reporta y revoca la familia completa.
+	if _, err := svc.Refresh(ctx, initial.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("expected ErrRefreshTokenReused, got %v", err)
+	}
+	if _, err := svc.Refresh(ctx, rotated.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expected revoked family to reject latest token, got %v", err)
+	}
+
+	// 3.- Un inicio de sesión nuevo abre otra familia que sigue funcionando.
+	fresh, err := svc.Authenticate(ctx, "vecino@example.com", "s3cr3t-pass")
+	if err != nil {
+		t.Fatalf("Authenticate returned error: %v", err)
+	}
+	if _, err := svc.Refresh(ctx, fresh.RefreshToken); err != nil {
+		t.Fatalf("expected fresh family to refresh, got %v", err)
+	}
+}
+
+func TestRefreshRejectsExpiredAndUnknownTokens(t *testing.T) {
+	// 1.- Controlamos el reloj para expirar el token sin esperar.
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	store := newMemoryRefreshStore()
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Minute, []byte("test-secret"),
+		WithRefreshTokens(store, time.Hour), WithClock(func() time.Time { return now }))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	initial, err := svc.Register(ctx, "vecino@example.com", "s3cr3t-pass")
+	if err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+
+	// 2.- Tokens desconocidos o vencidos se rechazan con el mismo error.
+	if _, err := svc.Refresh(ctx, "desconocido-0123456789"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expected ErrInvalidRefreshToken, got %v", err)
+	}
+	now = now.Add(2 * time.Hour)
+	if _, err := svc.Refresh(ctx, initial.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expected expired token to be rejected, got %v", err)
+	}
+}
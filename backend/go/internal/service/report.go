@@ -4,10 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
-	"math/rand"
 	"strings"
-	"sync"
 	"time"
 
 	"citizenapp/backend/internal/observability"
@@ -21,20 +18,47 @@ type Report struct {
 	Description  string       `json:"description"`
 	Latitude     float64      `json:"latitude"`
 	Longitude    float64      `json:"longitude"`
+	Address      string       `json:"address"`
+	EvidenceURLs []string     `json:"evidenceUrls"`
+	ContactEmail string       `json:"contactEmail,omitempty"`
+	ContactPhone string       `json:"contactPhone,omitempty"`
 	Status       string       `json:"status"`
-	CreatedAt    time.Time    `json:"createdAt"`
+	// Reporter es el sujeto que envió el reporte; no se publica para no exponer cuentas.
+	Reporter string `json:"-"`
+	// Anonymous marca los reportes enviados sin cuenta que nadie ha reclamado.
+	Anonymous bool `json:"anonymous"`
+	// Assignment queda vacío hasta que el personal canaliza el reporte.
+	Assignment *Assignment `json:"assignment,omitempty"`
+	CreatedAt  time.Time   `json:"createdAt"`
+	// StatusChangedAt marca el inicio de la estancia en el estatus actual; de ahí corre el SLA.
+	StatusChangedAt time.Time `json:"statusChangedAt"`
+}
+
+// 1.0.1.- VisibleTo indica si la identidad puede consultar el reporte: el personal ve todos y el resto sólo los propios.
+func (r Report) VisibleTo(principal Principal) bool {
+	return principal.Can(PermReportsReadAll) || (r.Reporter != "" && r.Reporter == principal.Subject)
+}
+
+// 1.1.- WithoutContact devuelve una copia sin los datos de contacto del ciudadano.
+func (r Report) WithoutContact() Report {
+	r.ContactEmail = ""
+	r.ContactPhone = ""
+	return r
 }
 
 // 2.- FolioStatus encapsula el seguimiento de cada folio solicitado.
 type FolioStatus struct {
-	Folio      string    `json:"folio"`
-	Status     string    `json:"status"`
-	LastUpdate time.Time `json:"lastUpdate"`
-	History    []string  `json:"history"`
+	Folio      string              `json:"folio"`
+	Status     string              `json:"status"`
+	LastUpdate time.Time           `json:"lastUpdate"`
+	History    []FolioHistoryEntry `json:"history"`
+	// UnreadMessages cuenta los mensajes del personal que el ciudadano aún no lee.
+	UnreadMessages int `json:"unreadMessages"`
 }
 
 type submitJob struct {
 	ctx      context.Context
+	actor    string
 	payload  map[string]any
 	resultCh chan<- submitResult
 }
@@ -63,31 +87,78 @@ type PaginatedReports struct {
 	TotalCount int      `json:"totalCount"`
 }
 
+// 3.0.1.- ReportFilter acota el listado por estatus, asignación y, si Reporter no está vacío, a los reportes de ese sujeto.
+type ReportFilter struct {
+	Status       string
+	Reporter     string
+	DepartmentID string
+	CrewID       string
+	Page         int
+	PageSize     int
+}
+
+// 3.1.- ClaimResult indica cuántos reportes anónimos pasaron a la cuenta.
+type ClaimResult struct {
+	Claimed int `json:"claimed"`
+}
+
 // 4.- AdminDashboardMetrics resume los conteos para el panel administrativo.
 type AdminDashboardMetrics struct {
-	PendingReports    int `json:"pendingReports"`
-	ResolvedReports   int `json:"resolvedReports"`
-	CriticalIncidents int `json:"criticalIncidents"`
+	PendingReports    int            `json:"pendingReports"`
+	ResolvedReports   int            `json:"resolvedReports"`
+	CriticalIncidents int            `json:"criticalIncidents"`
+	ByStatus          map[string]int `json:"byStatus"`
+	// SLABreaches cuenta los reportes que siguen en un estatus cuyo plazo ya venció.
+	SLABreaches               int            `json:"slaBreaches"`
+	SLABreachesByIncidentType map[string]int `json:"slaBreachesByIncidentType,omitempty"`
 }
 
 // 5.- ReportRepository define el contrato de persistencia para reportes.
 type ReportRepository interface {
-	Create(ctx context.Context, report Report) (Report, error)
+	Create(ctx context.Context, report Report, event ReportEvent) (Report, error)
 	FindByID(ctx context.Context, id string) (Report, error)
-	List(ctx context.Context, page, pageSize int, status string) ([]Report, int, error)
+	List(ctx context.Context, filter ReportFilter) ([]Report, int, error)
 	Delete(ctx context.Context, id string) error
-	Lookup(ctx context.Context, id string) (FolioStatus, error)
-	UpdateStatusWithMetrics(ctx context.Context, id, status string) (Report, AdminDashboardMetrics, error)
-	Metrics(ctx context.Context) (AdminDashboardMetrics, error)
+	AppendEvent(ctx context.Context, event ReportEvent) (ReportEvent, error)
+	Events(ctx context.Context, reportID string) ([]ReportEvent, error)
+	UpdateStatus(ctx context.Context, fromStatus string, event ReportEvent) (Report, error)
+	// Assign fija la asignación vigente y registra el evento en la misma transacción.
+	Assign(ctx context.Context, assignment Assignment, event ReportEvent) (Report, error)
+	StatusCounts(ctx context.Context) (map[string]int, error)
+	// AnonymizeReporter borra el contacto de los reportes de email y sustituye su nombre como actor en la bitácora.
+	AnonymizeReporter(ctx context.Context, email, actor string) (int, error)
+	// ClaimReports transfiere a email los reportes enviados por el actor anónimo y les quita la marca.
+	ClaimReports(ctx context.Context, anonymousActor, email string) (int, error)
 }
 
+const (
+	defaultReportPageSize = 20
+	maxReportPageSize     = 100
+)
+
+// DeletedActor reemplaza al autor en la bitácora cuando su cuenta se elimina.
+const DeletedActor = "deleted-user"
+
 // 6.- ReportService orquesta los pools de envío y consulta.
 type ReportService struct {
 	submitJobs chan submitJob
 	lookupJobs chan lookupJob
 	repo       ReportRepository
-	rand       *rand.Rand
-	randMutex  sync.Mutex
+	folios     FolioGenerator
+	workflow   *Workflow
+	// departments es opcional; sin él la asignación no está disponible.
+	departments DepartmentStore
+	// sla, slaStore y escalator son opcionales; sin ellos no se vigilan plazos.
+	sla       *SLA
+	slaStore  SLAStore
+	escalator SLAEscalator
+	// notes y staff habilitan las notas internas del personal.
+	notes          NoteStore
+	staff          StaffDirectory
+	noteEditWindow time.Duration
+	// messages habilita la conversación pública con quien reportó.
+	messages MessageStore
+	now      func() time.Time
 	// 6.1.- logger documenta los eventos para auditoría estructurada.
 	logger zerolog.Logger
 }
@@ -98,25 +169,38 @@ var (
 	ErrInvalidStatus  = errors.New("invalid status")
 )
 
-var allowedStatuses = map[string]struct{}{
-	"en_revision": {},
-	"en_proceso":  {},
-	"resuelto":    {},
-	"critico":     {},
+// ReportOption ajusta dependencias opcionales del servicio de reportes.
+type ReportOption func(*ReportService)
+
+// WithWorkflow reemplaza el flujo de estatus predeterminado.
+func WithWorkflow(workflow *Workflow) ReportOption {
+	return func(s *ReportService) {
+		if workflow != nil {
+			s.workflow = workflow
+		}
+	}
 }
 
 // 8.- NewReportService inicializa los trabajadores concurrentes.
-func NewReportService(repo ReportRepository, submitWorkers, lookupWorkers int) *ReportService {
+func NewReportService(repo ReportRepository, folios FolioGenerator, submitWorkers, lookupWorkers int, opts ...ReportOption) *ReportService {
 	if repo == nil {
 		panic("report repository is required")
 	}
+	if folios == nil {
+		panic("folio generator is required")
+	}
 	observability.EnsureMetrics(nil)
 	s := &ReportService{
 		submitJobs: make(chan submitJob, max(32, submitWorkers*16)),
 		lookupJobs: make(chan lookupJob, max(32, lookupWorkers*16)),
 		repo:       repo,
-		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		folios:     folios,
+		workflow:   DefaultWorkflow(),
 		logger:     observability.NamedLogger("report_service"),
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
 	observability.SetReportSubmitQueueDepth(len(s.submitJobs))
 	observability.SetReportLookupQueueDepth(len(s.lookupJobs))
@@ -129,10 +213,10 @@ func NewReportService(repo ReportRepository, submitWorkers, lookupWorkers int) *
 	return s
 }
 
-// 9.- Submit genera un folio y almacena el reporte en el repositorio.
-func (s *ReportService) Submit(ctx context.Context, payload map[string]any) (Report, error) {
+// 9.- Submit genera un folio y almacena el reporte junto con su evento inicial.
+func (s *ReportService) Submit(ctx context.Context, actor string, payload map[string]any) (Report, error) {
 	resultCh := make(chan submitResult, 1)
-	job := submitJob{ctx: ctx, payload: payload, resultCh: resultCh}
+	job := submitJob{ctx: ctx, actor: actor, payload: payload, resultCh: resultCh}
 	select {
 	case <-ctx.Done():
 		return Report{}, ctx.Err()
@@ -150,8 +234,13 @@ func (s *ReportService) Submit(ctx context.Context, payload map[string]any) (Rep
 	}
 }
 
-// 10.- Lookup consulta el almacenamiento persistente y devuelve el historial.
+// 10.- Lookup valida el folio capturado y consulta el historial persistente.
 func (s *ReportService) Lookup(ctx context.Context, folio string) (FolioStatus, error) {
+	normalized, err := NormalizeFolio(folio)
+	if err != nil {
+		return FolioStatus{}, err
+	}
+	folio = normalized
 	resultCh := make(chan lookupResult, 1)
 	job := lookupJob{ctx: ctx, folio: folio, resultCh: resultCh}
 	select {
@@ -172,24 +261,31 @@ func (s *ReportService) Lookup(ctx context.Context, folio string) (FolioStatus,
 }
 
 // 11.- List delega la paginación al repositorio con filtros opcionales.
-func (s *ReportService) List(ctx context.Context, page, pageSize int, status string) (PaginatedReports, error) {
+func (s *ReportService) List(ctx context.Context, filter ReportFilter) (PaginatedReports, error) {
 	select {
 	case <-ctx.Done():
 		return PaginatedReports{}, ctx.Err()
 	default:
 	}
-	trimmed := strings.TrimSpace(status)
-	if trimmed != "" {
-		if _, ok := allowedStatuses[trimmed]; !ok {
-			return PaginatedReports{}, ErrInvalidStatus
-		}
+	filter.Status = strings.TrimSpace(filter.Status)
+	filter.DepartmentID = strings.TrimSpace(filter.DepartmentID)
+	filter.CrewID = strings.TrimSpace(filter.CrewID)
+	if filter.Status != "" && !s.workflow.HasStatus(filter.Status) {
+		return PaginatedReports{}, ErrInvalidStatus
+	}
+	if filter.Page < 0 {
+		filter.Page = 0
 	}
-	items, total, err := s.repo.List(ctx, page, pageSize, trimmed)
+	if filter.PageSize <= 0 {
+		filter.PageSize = defaultReportPageSize
+	}
+	filter.PageSize = min(filter.PageSize, maxReportPageSize)
+	items, total, err := s.repo.List(ctx, filter)
 	if err != nil {
 		return PaginatedReports{}, err
 	}
-	hasMore := (page+1)*pageSize < total
-	return PaginatedReports{Items: items, HasMore: hasMore, Page: page, TotalCount: total}, nil
+	hasMore := (filter.Page+1)*filter.PageSize < total
+	return PaginatedReports{Items: items, HasMore: hasMore, Page: filter.Page, TotalCount: total}, nil
 }
 
 // 12.- Get obtiene un reporte puntual por identificador.
@@ -206,22 +302,33 @@ func (s *ReportService) Get(ctx context.Context, id string) (Report, error) {
 	return report, nil
 }
 
-// 13.- UpdateStatus valida y actualiza el estatus de un reporte.
-func (s *ReportService) UpdateStatus(ctx context.Context, id, status string) (Report, error) {
+// 13.- UpdateStatus aplica el flujo configurado y registra la transición en la bitácora.
+func (s *ReportService) UpdateStatus(ctx context.Context, id string, change StatusChange) (Report, error) {
 	select {
 	case <-ctx.Done():
 		return Report{}, ctx.Err()
 	default:
 	}
-	trimmed := strings.TrimSpace(status)
-	if _, ok := allowedStatuses[trimmed]; !ok {
+	trimmed := strings.TrimSpace(change.Status)
+	if !s.workflow.HasStatus(trimmed) {
 		return Report{}, ErrInvalidStatus
 	}
 	previous, err := s.repo.FindByID(ctx, id)
 	if err != nil {
 		return Report{}, err
 	}
-	report, _, err := s.repo.UpdateStatusWithMetrics(ctx, id, trimmed)
+	if err := s.workflow.CheckTransition(previous.Status, trimmed, change.Reason); err != nil {
+		return Report{}, err
+	}
+	event := ReportEvent{
+		ReportID:  id,
+		Kind:      ReportEventStatusChanged,
+		Status:    trimmed,
+		Actor:     change.Actor,
+		Reason:    strings.TrimSpace(change.Reason),
+		CreatedAt: s.now(),
+	}
+	report, err := s.repo.UpdateStatus(ctx, previous.Status, event)
 	if err != nil {
 		s.logger.Error().Err(err).Str("event", "report.status.update.failed").Str("report_id", id).Msg("unable to update report status")
 		return Report{}, err
@@ -231,10 +338,45 @@ func (s *ReportService) UpdateStatus(ctx context.Context, id, status string) (Re
 		Str("report_id", report.ID).
 		Str("from_status", previous.Status).
 		Str("to_status", report.Status).
+		Str("actor", change.Actor).
 		Msg("status transition recorded")
 	return report, nil
 }
 
+// 13.1.- AddNote agrega una actualización visible en el historial del folio.
+func (s *ReportService) AddNote(ctx context.Context, id, actor, note string) (ReportEvent, error) {
+	select {
+	case <-ctx.Done():
+		return ReportEvent{}, ctx.Err()
+	default:
+	}
+	report, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return ReportEvent{}, err
+	}
+	return s.repo.AppendEvent(ctx, ReportEvent{
+		ReportID:  id,
+		Kind:      ReportEventNote,
+		Status:    report.Status,
+		Actor:     actor,
+		Reason:    strings.TrimSpace(note),
+		CreatedAt: s.now(),
+	})
+}
+
+// 13.2.- Events expone la bitácora completa, incluyendo actores, para el personal.
+func (s *ReportService) Events(ctx context.Context, id string) ([]ReportEvent, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+	if _, err := s.repo.FindByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.Events(ctx, id)
+}
+
 // 14.- Delete elimina un reporte del almacenamiento persistente.
 func (s *ReportService) Delete(ctx context.Context, id string) error {
 	select {
@@ -245,6 +387,53 @@ func (s *ReportService) Delete(ctx context.Context, id string) error {
 	return s.repo.Delete(ctx, id)
 }
 
+// 14.1.- AnonymizeReporter conserva los reportes de una cuenta eliminada pero sin datos que la identifiquen.
+func (s *ReportService) AnonymizeReporter(ctx context.Context, email string) (int, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+	normalized := strings.TrimSpace(strings.ToLower(email))
+	if normalized == "" {
+		return 0, nil
+	}
+	count, err := s.repo.AnonymizeReporter(ctx, normalized, DeletedActor)
+	if err != nil {
+		return 0, err
+	}
+	s.logger.Info().
+		Str("event", "report.reporter.anonymized").
+		Int("reports", count).
+		Msg("reports anonymized after account deletion")
+	return count, nil
+}
+
+// 14.2.- ClaimAnonymousReports vincula a la cuenta los reportes enviados desde el dispositivo antes de registrarse.
+func (s *ReportService) ClaimAnonymousReports(ctx context.Context, principal Principal, installID string) (ClaimResult, error) {
+	select {
+	case <-ctx.Done():
+		return ClaimResult{}, ctx.Err()
+	default:
+	}
+	if principal.Role == RoleAnonymous || principal.APIKeyID != "" || IsAnonymousSubject(principal.Subject) {
+		return ClaimResult{}, ErrForbidden
+	}
+	if len(strings.TrimSpace(installID)) < minInstallIDLength {
+		return ClaimResult{}, ErrInvalidInstallID
+	}
+	count, err := s.repo.ClaimReports(ctx, AnonymousSubject(installID), principal.Subject)
+	if err != nil {
+		return ClaimResult{}, err
+	}
+	s.logger.Info().
+		Str("event", "report.anonymous.claimed").
+		Str("subject", principal.Subject).
+		Int("reports", count).
+		Msg("anonymous reports claimed")
+	return ClaimResult{Claimed: count}, nil
+}
+
 // 15.- DashboardMetrics consolida los totales para el panel de control.
 func (s *ReportService) DashboardMetrics(ctx context.Context) (AdminDashboardMetrics, error) {
 	select {
@@ -252,7 +441,27 @@ func (s *ReportService) DashboardMetrics(ctx context.Context) (AdminDashboardMet
 		return AdminDashboardMetrics{}, ctx.Err()
 	default:
 	}
-	return s.repo.Metrics(ctx)
+	counts, err := s.repo.StatusCounts(ctx)
+	if err != nil {
+		return AdminDashboardMetrics{}, err
+	}
+	metrics := s.workflow.Metrics(counts)
+	if s.sla != nil {
+		breaches, err := s.slaStore.ActiveBreaches(ctx)
+		if err != nil {
+			return AdminDashboardMetrics{}, err
+		}
+		metrics.SLABreachesByIncidentType = breaches
+		for _, count := range breaches {
+			metrics.SLABreaches += count
+		}
+	}
+	return metrics, nil
+}
+
+// 15.1.- Workflow publica la definición vigente de estatus y transiciones.
+func (s *ReportService) Workflow() WorkflowDefinition {
+	return s.workflow.Definition()
 }
 
 func (s *ReportService) submitWorker() {
@@ -265,13 +474,19 @@ func (s *ReportService) submitWorker() {
 		}
 		typeID, _ := job.payload["incidentTypeId"].(string)
 		description, _ := job.payload["description"].(string)
+		contactEmail, _ := job.payload["contactEmail"].(string)
+		contactPhone, _ := job.payload["contactPhone"].(string)
+		address, _ := job.payload["address"].(string)
 		lat, _ := toFloat(job.payload["latitude"])
 		lng, _ := toFloat(job.payload["longitude"])
+		evidence := toStrings(job.payload["evidenceUrls"])
 
-		s.randMutex.Lock()
-		folio := s.rand.Intn(90000) + 10000
-		id := fmt.Sprintf("F-%05d", folio)
-		s.randMutex.Unlock()
+		id, err := s.folios.NextFolio(job.ctx)
+		if err != nil {
+			s.logger.Error().Err(err).Str("event", "report.folio.failed").Msg("unable to allocate folio")
+			job.resultCh <- submitResult{err: err}
+			continue
+		}
 
 		report := Report{
 			ID: id,
@@ -280,13 +495,27 @@ func (s *ReportService) submitWorker() {
 				Name:             "Incidente",
 				RequiresEvidence: false,
 			},
-			Description: description,
-			Latitude:    lat,
-			Longitude:   lng,
-			Status:      "en_revision",
-			CreatedAt:   time.Now(),
+			Description:  description,
+			Latitude:     lat,
+			Longitude:    lng,
+			Address:      strings.TrimSpace(address),
+			EvidenceURLs: evidence,
+			ContactEmail: strings.TrimSpace(strings.ToLower(contactEmail)),
+			ContactPhone: strings.TrimSpace(contactPhone),
+			Status:       s.workflow.Initial(),
+			Reporter:     job.actor,
+			Anonymous:    IsAnonymousSubject(job.actor),
+			CreatedAt:    s.now(),
 		}
-		stored, err := s.repo.Create(job.ctx, report)
+		report.StatusChangedAt = report.CreatedAt
+		submitted := ReportEvent{
+			ReportID:  report.ID,
+			Kind:      ReportEventSubmitted,
+			Status:    report.Status,
+			Actor:     job.actor,
+			CreatedAt: report.CreatedAt,
+		}
+		stored, err := s.repo.Create(job.ctx, report, submitted)
 		if err != nil {
 			s.logger.Error().Err(err).Str("event", "report.submit.failed").Str("report_id", report.ID).Msg("unable to persist report")
 			job.resultCh <- submitResult{err: err}
@@ -311,11 +540,25 @@ func (s *ReportService) lookupWorker() {
 			continue
 		default:
 		}
-		status, err := s.repo.Lookup(job.ctx, job.folio)
+		report, err := s.repo.FindByID(job.ctx, job.folio)
 		if err != nil {
 			job.resultCh <- lookupResult{err: err}
 			continue
 		}
+		events, err := s.repo.Events(job.ctx, report.ID)
+		if err != nil {
+			job.resultCh <- lookupResult{err: err}
+			continue
+		}
+		status := buildFolioStatus(report, events)
+		if s.messages != nil {
+			unread, err := s.messages.UnreadCount(job.ctx, report.ID, MessageSenderStaff)
+			if err != nil {
+				job.resultCh <- lookupResult{err: err}
+				continue
+			}
+			status.UnreadMessages = unread
+		}
 		job.resultCh <- lookupResult{status: status}
 	}
 }
@@ -349,3 +592,27 @@ func toFloat(value any) (float64, bool) {
 		return 0, false
 	}
 }
+
+// 18.- toStrings normaliza las listas de texto recibidas en el payload.
+func toStrings(value any) []string {
+	result := make([]string, 0)
+	switch v := value.(type) {
+	case []string:
+		for _, item := range v {
+			if trimmed := strings.TrimSpace(item); trimmed != "" {
+				result = append(result, trimmed)
+			}
+		}
+	case []any:
+		for _, item := range v {
+			text, ok := item.(string)
+			if !ok {
+				continue
+			}
+			if trimmed := strings.TrimSpace(text); trimmed != "" {
+				result = append(result, trimmed)
+			}
+		}
+	}
+	return result
+}
@@ -6,6 +6,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -14,16 +15,19 @@ import (
 type fakeReportRepository struct {
 	mu      sync.RWMutex
 	records map[string]Report
+	events  map[string][]ReportEvent
+	nextID  int64
 }
 
 func newFakeReportRepository() *fakeReportRepository {
-	return &fakeReportRepository{records: make(map[string]Report)}
+	return &fakeReportRepository{records: make(map[string]Report), events: make(map[string][]ReportEvent)}
 }
 
-func (f *fakeReportRepository) Create(_ context.Context, report Report) (Report, error) {
+func (f *fakeReportRepository) Create(_ context.Context, report Report, event ReportEvent) (Report, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	f.records[report.ID] = report
+	f.appendLocked(event)
 	return report, nil
 }
 
@@ -37,12 +41,24 @@ func (f *fakeReportRepository) FindByID(_ context.Context, id string) (Report, e
 	return report, nil
 }
 
-func (f *fakeReportRepository) List(_ context.Context, page, pageSize int, status string) ([]Report, int, error) {
+func (f *fakeReportRepository) List(_ context.Context, filter ReportFilter) ([]Report, int, error) {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
 	items := make([]Report, 0, len(f.records))
 	for _, report := range f.records {
-		if status != "" && report.Status != status {
+		if filter.Status != "" && report.Status != filter.Status {
+			continue
+		}
+		if filter.Reporter != "" && report.Reporter != filter.Reporter {
+			continue
+		}
+		if (filter.DepartmentID != "" || filter.CrewID != "") && report.Assignment == nil {
+			continue
+		}
+		if filter.DepartmentID != "" && report.Assignment.DepartmentID != filter.DepartmentID {
+			continue
+		}
+		if filter.CrewID != "" && report.Assignment.CrewID != filter.CrewID {
 			continue
 		}
 		items = append(items, report)
@@ -51,11 +67,11 @@ func (f *fakeReportRepository) List(_ context.Context, page, pageSize int, statu
 		return items[i].CreatedAt.After(items[j].CreatedAt)
 	})
 	total := len(items)
-	start := page * pageSize
+	start := filter.Page * filter.PageSize
 	if start > total {
 		start = total
 	}
-	end := start + pageSize
+	end := start + filter.PageSize
 	if end > total {
 		end = total
 	}
@@ -69,63 +85,134 @@ func (f *fakeReportRepository) Delete(_ context.Context, id string) error {
 		return ErrReportNotFound
 	}
 	delete(f.records, id)
+	delete(f.events, id)
 	return nil
 }
 
-func (f *fakeReportRepository) Lookup(ctx context.Context, id string) (FolioStatus, error) {
-	report, err := f.FindByID(ctx, id)
-	if err != nil {
-		return FolioStatus{}, err
+func (f *fakeReportRepository) AppendEvent(_ context.Context, event ReportEvent) (ReportEvent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.records[event.ReportID]; !ok {
+		return ReportEvent{}, ErrReportNotFound
 	}
-	return FolioStatus{
-		Folio:      report.ID,
-		Status:     report.Status,
-		LastUpdate: time.Now(),
-		History: []string{
-			"Reporte recibido",
-			"Asignado a cuadrilla",
-		},
-	}, nil
+	return f.appendLocked(event), nil
+}
+
+func (f *fakeReportRepository) Events(_ context.Context, reportID string) ([]ReportEvent, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return append([]ReportEvent(nil), f.events[reportID]...), nil
+}
+
+func (f *fakeReportRepository) appendLocked(event ReportEvent) ReportEvent {
+	f.nextID++
+	event.ID = f.nextID
+	f.events[event.ReportID] = append(f.events[event.ReportID], event)
+	return event
 }
 
-func (f *fakeReportRepository) UpdateStatusWithMetrics(ctx context.Context, id, status string) (Report, AdminDashboardMetrics, error) {
+func (f *fakeReportRepository) UpdateStatus(_ context.Context, fromStatus string, event ReportEvent) (Report, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
-	report, ok := f.records[id]
+	report, ok := f.records[event.ReportID]
+	if !ok {
+		return Report{}, ErrReportNotFound
+	}
+	if report.Status != fromStatus {
+		return Report{}, ErrStatusConflict
+	}
+	report.Status = event.Status
+	report.StatusChangedAt = event.CreatedAt
+	f.records[event.ReportID] = report
+	f.appendLocked(event)
+	return report, nil
+}
+
+func (f *fakeReportRepository) Assign(_ context.Context, assignment Assignment, event ReportEvent) (Report, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	report, ok := f.records[event.ReportID]
 	if !ok {
-		return Report{}, AdminDashboardMetrics{}, ErrReportNotFound
+		return Report{}, ErrReportNotFound
 	}
-	report.Status = status
-	f.records[id] = report
-	metrics := f.metricsLocked()
-	return report, metrics, nil
+	report.Assignment = &assignment
+	f.records[event.ReportID] = report
+	f.appendLocked(event)
+	return report, nil
 }
 
-func (f *fakeReportRepository) Metrics(_ context.Context) (AdminDashboardMetrics, error) {
+func (f *fakeReportRepository) StatusCounts(_ context.Context) (map[string]int, error) {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
-	return f.metricsLocked(), nil
+	counts := make(map[string]int)
+	for _, report := range f.records {
+		counts[report.Status]++
+	}
+	return counts, nil
 }
 
-func (f *fakeReportRepository) metricsLocked() AdminDashboardMetrics {
-	metrics := AdminDashboardMetrics{}
-	for _, report := range f.records {
-		switch report.Status {
-		case "en_revision":
-			metrics.PendingReports++
-		case "resuelto":
-			metrics.ResolvedReports++
-		case "critico":
-			metrics.CriticalIncidents++
+func (f *fakeReportRepository) AnonymizeReporter(_ context.Context, email, actor string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	count := 0
+	for id, report := range f.records {
+		submitted := false
+		for i, event := range f.events[id] {
+			if event.Actor == email {
+				submitted = submitted || event.Kind == ReportEventSubmitted
+				f.events[id][i].Actor = actor
+			}
+		}
+		if submitted || report.ContactEmail == email || report.Reporter == email {
+			report.ContactEmail, report.ContactPhone, report.Reporter = "", "", ""
+			f.records[id] = report
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (f *fakeReportRepository) ClaimReports(_ context.Context, anonymousActor, email string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	count := 0
+	for id, report := range f.records {
+		for i, event := range f.events[id] {
+			if event.Actor != anonymousActor {
+				continue
+			}
+			f.events[id][i].Actor = email
+			if event.Kind == ReportEventSubmitted {
+				report.Anonymous, report.Reporter = false, email
+				f.records[id] = report
+				count++
+			}
 		}
 	}
-	return metrics
+	return count, nil
+}
+
+// memoryFolioSequence entrega consecutivos en memoria para las pruebas.
+type memoryFolioSequence struct {
+	next atomic.Int64
+}
+
+func (m *memoryFolioSequence) NextValue(context.Context) (int64, error) {
+	return m.next.Add(1), nil
+}
+
+func newTestFolioGenerator() FolioGenerator {
+	gen, err := NewSequenceFolioGenerator(&memoryFolioSequence{}, "F")
+	if err != nil {
+		panic(err)
+	}
+	return gen
 }
 
 func TestReportSubmitAndLookupLifecycle(t *testing.T) {
 	// 2.- Configuramos el servicio de reportes con pools dedicados.
 	repo := newFakeReportRepository()
-	svc := NewReportService(repo, 2, 2)
+	svc := NewReportService(repo, newTestFolioGenerator(), 2, 2)
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
@@ -136,7 +223,7 @@ func TestReportSubmitAndLookupLifecycle(t *testing.T) {
 		"latitude":       19.43,
 		"longitude":      -99.13,
 	}
-	report, err := svc.Submit(ctx, payload)
+	report, err := svc.Submit(ctx, "vecino@example.com", payload)
 	if err != nil {
 		t.Fatalf("Submit returned error: %v", err)
 	}
@@ -147,7 +234,7 @@ func TestReportSubmitAndLookupLifecycle(t *testing.T) {
 		t.Fatalf("unexpected status: %s", report.Status)
 	}
 
-	// 4.- Consultamos el mismo folio y verificamos el historial simulado.
+	// 4.- Consultamos el mismo folio y verificamos que sólo exista el evento de recepción.
 	status, err := svc.Lookup(ctx, report.ID)
 	if err != nil {
 		t.Fatalf("Lookup returned error: %v", err)
@@ -155,15 +242,95 @@ func TestReportSubmitAndLookupLifecycle(t *testing.T) {
 	if status.Folio != report.ID {
 		t.Fatalf("status mismatch: %s vs %s", status.Folio, report.ID)
 	}
-	if len(status.History) < 2 {
-		t.Fatalf("expected history entries, got %d", len(status.History))
+	if len(status.History) != 1 || status.History[0].Type != ReportEventSubmitted {
+		t.Fatalf("expected a single submitted entry, got %+v", status.History)
+	}
+
+	// 5.- Un cambio de estatus agrega una entrada sin el motivo interno y mueve LastUpdate.
+	if _, err := svc.UpdateStatus(ctx, report.ID, StatusChange{Status: "en_proceso", Actor: "operador@example.com", Reason: "Cuadrilla en camino"}); err != nil {
+		t.Fatalf("UpdateStatus returned error: %v", err)
+	}
+	status, err = svc.Lookup(ctx, report.ID)
+	if err != nil {
+		t.Fatalf("Lookup returned error: %v", err)
+	}
+	if len(status.History) != 2 {
+		t.Fatalf("expected two history entries, got %+v", status.History)
+	}
+	last := status.History[1]
+	if last.Type != ReportEventStatusChanged || last.Status != "en_proceso" || last.Reason != "" {
+		t.Fatalf("unexpected status entry: %+v", last)
+	}
+	if !status.LastUpdate.Equal(last.OccurredAt) {
+		t.Fatalf("expected LastUpdate %v to match latest event %v", status.LastUpdate, last.OccurredAt)
+	}
+
+	// 6.- La bitácora interna conserva al actor que no se expone al ciudadano.
+	events, err := svc.Events(ctx, report.ID)
+	if err != nil {
+		t.Fatalf("Events returned error: %v", err)
+	}
+	if events[0].Actor != "vecino@example.com" || events[1].Actor != "operador@example.com" || events[1].Reason != "Cuadrilla en camino" {
+		t.Fatalf("unexpected internal events: %+v", events)
+	}
+
+	// 7.- Las notas se escriben para el ciudadano y sí muestran su texto en el seguimiento.
+	if _, err := svc.AddNote(ctx, report.ID, "operador@example.com", "Se programó la reparación"); err != nil {
+		t.Fatalf("AddNote returned error: %v", err)
+	}
+	status, err = svc.Lookup(ctx, report.ID)
+	if err != nil || status.History[2].Type != ReportEventNote || status.History[2].Reason != "Se programó la reparación" {
+		t.Fatalf("expected the public note in the history, got %+v (%v)", status.History, err)
+	}
+}
+
+func TestSubmitPersistsContactAddressAndEvidence(t *testing.T) {
+	// 1.- Enviamos un reporte con los datos completos que capturó el ciudadano.
+	repo := newFakeReportRepository()
+	svc := NewReportService(repo, newTestFolioGenerator(), 1, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	payload := map[string]any{
+		"incidentTypeId": "lighting",
+		"description":    "Lámpara apagada",
+		"contactEmail":   " Vecino@Example.com ",
+		"contactPhone":   "5512345678",
+		"latitude":       19.4,
+		"longitude":      -99.1,
+		"address":        "Av. Juárez 10, Centro",
+		"evidenceUrls":   []string{"https://cdn.example.com/a.jpg", " "},
+	}
+	report, err := svc.Submit(ctx, "vecino@example.com", payload)
+	if err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+
+	// 2.- Confirmamos que el repositorio recibió todos los campos normalizados.
+	stored, err := repo.FindByID(ctx, report.ID)
+	if err != nil {
+		t.Fatalf("FindByID returned error: %v", err)
+	}
+	if stored.ContactEmail != "vecino@example.com" || stored.ContactPhone != "5512345678" {
+		t.Fatalf("unexpected contact data: %+v", stored)
+	}
+	if stored.Address != "Av. Juárez 10, Centro" {
+		t.Fatalf("unexpected address: %q", stored.Address)
+	}
+	if len(stored.EvidenceURLs) != 1 || stored.EvidenceURLs[0] != "https://cdn.example.com/a.jpg" {
+		t.Fatalf("unexpected evidence urls: %v", stored.EvidenceURLs)
+	}
+
+	// 3.- La copia sin contacto conserva el resto de la información.
+	public := stored.WithoutContact()
+	if public.ContactEmail != "" || public.ContactPhone != "" || public.Address == "" {
+		t.Fatalf("unexpected redacted report: %+v", public)
 	}
 }
 
 func TestLookupReturnsNotFoundForUnknownFolio(t *testing.T) {
 	// 1.- Iniciamos el servicio para consultar un folio inexistente.
 	repo := newFakeReportRepository()
-	svc := NewReportService(repo, 1, 1)
+	svc := NewReportService(repo, newTestFolioGenerator(), 1, 1)
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 
@@ -176,7 +343,7 @@ func TestLookupReturnsNotFoundForUnknownFolio(t *testing.T) {
 func TestUpdateStatusReflectsOnMetrics(t *testing.T) {
 	// 1.- Creamos un reporte inicial y cambiamos su estado a resuelto.
 	repo := newFakeReportRepository()
-	svc := NewReportService(repo, 1, 1)
+	svc := NewReportService(repo, newTestFolioGenerator(), 1, 1)
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
@@ -186,12 +353,12 @@ func TestUpdateStatusReflectsOnMetrics(t *testing.T) {
 		"latitude":       19.4,
 		"longitude":      -99.1,
 	}
-	created, err := svc.Submit(ctx, payload)
+	created, err := svc.Submit(ctx, "vecino@example.com", payload)
 	if err != nil {
 		t.Fatalf("Submit returned error: %v", err)
 	}
 
-	updated, err := svc.UpdateStatus(ctx, created.ID, "resuelto")
+	updated, err := svc.UpdateStatus(ctx, created.ID, StatusChange{Status: "resuelto", Actor: "operador@example.com"})
 	if err != nil {
 		t.Fatalf("UpdateStatus returned error: %v", err)
 	}
@@ -208,3 +375,51 @@ func TestUpdateStatusReflectsOnMetrics(t *testing.T) {
 		t.Fatalf("unexpected metrics: %+v", metrics)
 	}
 }
+
+func TestListScopesReportsToTheirReporter(t *testing.T) {
+	// 1.- Dos ciudadanos envían reportes; cada uno queda ligado a su autor.
+	repo := newFakeReportRepository()
+	svc := NewReportService(repo, newTestFolioGenerator(), 1, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	payload := map[string]any{"incidentTypeId": "trash", "description": "Basura", "latitude": 19.4, "longitude": -99.1}
+	for _, actor := range []string{"ana@example.com", "ana@example.com", "luis@example.com"} {
+		if _, err := svc.Submit(ctx, actor, payload); err != nil {
+			t.Fatalf("Submit returned error: %v", err)
+		}
+	}
+
+	// 2.- El filtro por autor sólo devuelve los propios y respeta el estatus.
+	mine, err := svc.List(ctx, ReportFilter{Reporter: "ana@example.com"})
+	if err != nil || mine.TotalCount != 2 || len(mine.Items) != 2 {
+		t.Fatalf("unexpected own reports %+v (%v)", mine, err)
+	}
+	if mine.Items[0].Reporter != "ana@example.com" {
+		t.Fatalf("expected reporter on listed report, got %+v", mine.Items[0])
+	}
+	resolved, err := svc.List(ctx, ReportFilter{Reporter: "ana@example.com", Status: "resuelto"})
+	if err != nil || resolved.TotalCount != 0 {
+		t.Fatalf("expected no resolved reports, got %+v (%v)", resolved, err)
+	}
+	if _, err := svc.List(ctx, ReportFilter{Status: "inexistente"}); !errors.Is(err, ErrInvalidStatus) {
+		t.Fatalf("expected ErrInvalidStatus, got %v", err)
+	}
+
+	// 3.- Sin tamaño de página se usa el valor por defecto y un tamaño excesivo se acota.
+	all, err := svc.List(ctx, ReportFilter{Page: -1, PageSize: 1000})
+	if err != nil || all.TotalCount != 3 || all.Page != 0 {
+		t.Fatalf("unexpected full listing %+v (%v)", all, err)
+	}
+
+	// 4.- Sólo el personal o el autor pueden ver un reporte concreto.
+	report := mine.Items[0]
+	if !report.VisibleTo(Principal{Subject: "ana@example.com", Role: RoleCitizen}) {
+		t.Fatalf("expected reporter to see own report")
+	}
+	if report.VisibleTo(Principal{Subject: "luis@example.com", Role: RoleCitizen}) {
+		t.Fatalf("expected other citizen to be denied")
+	}
+	if !report.VisibleTo(Principal{Subject: "operador@example.com", Role: RoleOperator}) {
+		t.Fatalf("expected staff to see every report")
+	}
+}
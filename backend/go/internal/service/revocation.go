@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 1.- RevocationStore persiste la lista de denegación de tokens de acceso.
+type RevocationStore interface {
+	// RevokeToken agrega el jti hasta que el token expire por sí mismo.
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	// RevokeSubject invalida los tokens del sujeto emitidos antes de cutoff; el corte se conserva hasta until.
+	RevokeSubject(ctx context.Context, subject string, cutoff, until time.Time) error
+	// SubjectCutoff devuelve el último corte vigente o el tiempo cero si no existe.
+	SubjectCutoff(ctx context.Context, subject string, now time.Time) (time.Time, error)
+}
+
+// 2.- ErrTokenRevoked distingue los tokens invalidados antes de su expiración.
+var ErrTokenRevoked = errors.New("token revoked")
+
+// 3.- WithRevocation habilita la verificación del jti y de los cortes por usuario.
+func WithRevocation(store RevocationStore) AuthOption {
+	return func(s *AuthService) {
+		s.revocations = store
+	}
+}
+
+// 3.1.- SessionListener cierra las conexiones en vivo abiertas con tokens que acaban de revocarse.
+type SessionListener interface {
+	DisconnectSession(sessionID string)
+	DisconnectSubject(subject string)
+}
+
+// 3.2.- WithSessionListener avisa de cada sesión o cuenta revocada; sólo alcanza las conexiones de esta instancia.
+func WithSessionListener(listener SessionListener) AuthOption {
+	return func(s *AuthService) {
+		s.sessionListener = listener
+	}
+}
+
+// 4.- Logout revoca el token de acceso actual, su sesión y, si se envía, la familia del token de renovación.
+func (s *AuthService) Logout(ctx context.Context, principal Principal, refreshToken string) error {
+	if s.revocations != nil && principal.TokenID != "" {
+		if err := s.revocations.RevokeToken(ctx, principal.TokenID, principal.ExpiresAt); err != nil {
+			return err
+		}
+	}
+	if principal.SessionID != "" {
+		if err := s.endSession(ctx, principal.SessionID); err != nil {
+			return err
+		}
+	}
+	refreshToken = strings.TrimSpace(refreshToken)
+	if s.refreshStore == nil || refreshToken == "" {
+		return nil
+	}
+	consumed, err := s.refreshStore.Consume(ctx, hashToken(refreshToken), s.now())
+	if err != nil && !errors.Is(err, ErrRefreshTokenReused) {
+		// Un token de renovación inválido no impide cerrar la sesión de acceso.
+		return nil
+	}
+	if consumed.Subject != principal.Subject {
+		return nil
+	}
+	return s.refreshStore.RevokeFamily(ctx, consumed.FamilyID, s.now())
+}
+
+// 5.- RevokeSessions cierra todas las sesiones activas del usuario indicado.
+func (s *AuthService) RevokeSessions(ctx context.Context, email string) error {
+	normalized := strings.TrimSpace(strings.ToLower(email))
+	if _, err := s.repo.FindByEmail(ctx, normalized); err != nil {
+		return err
+	}
+	now := s.now()
+	if s.revocations != nil {
+		// iat tiene precisión de segundos; el corte se trunca igual para no matar un inicio de sesión inmediato.
+		// El corte sólo necesita durar lo mismo que el token de acceso más longevo.
+		if err := s.revocations.RevokeSubject(ctx, normalized, now.Truncate(time.Second), now.Add(s.tokenTTL)); err != nil {
+			return err
+		}
+	}
+	if s.refreshStore != nil {
+		if err := s.refreshStore.RevokeSubject(ctx, normalized, now); err != nil {
+			return err
+		}
+	}
+	if s.sessions != nil {
+		// Las sesiones con tokens de acceso aún vigentes se revocan por sid: cubren lo emitido en el mismo segundo del corte.
+		active, err := s.sessions.ListSessions(ctx, normalized, now.Add(-s.tokenTTL))
+		if err != nil {
+			return err
+		}
+		if err := s.sessions.RevokeSubjectSessions(ctx, normalized, now); err != nil {
+			return err
+		}
+		if s.revocations != nil {
+			for _, session := range active {
+				if err := s.revocations.RevokeToken(ctx, sessionRevocationKey(session.ID), now.Add(s.tokenTTL)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if s.sessionListener != nil {
+		s.sessionListener.DisconnectSubject(normalized)
+	}
+	s.logger.Info().
+		Str("event", "auth.sessions.revoked").
+		Str("subject", normalized).
+		Msg("all sessions revoked")
+	return nil
+}
+
+// 6.- checkRevocation consulta la lista de denegación para el token ya verificado.
+func (s *AuthService) checkRevocation(ctx context.Context, claims *AccessClaims) error {
+	if s.revocations == nil {
+		return nil
+	}
+	if claims.ID == "" || claims.IssuedAt == nil {
+		return ErrInvalidToken
+	}
+	revoked, err := s.revocations.IsTokenRevoked(ctx, claims.ID)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+	if claims.SessionID != "" {
+		if revoked, err = s.revocations.IsTokenRevoked(ctx, sessionRevocationKey(claims.SessionID)); err != nil {
+			return err
+		}
+		if revoked {
+			return ErrTokenRevoked
+		}
+	}
+	cutoff, err := s.revocations.SubjectCutoff(ctx, claims.Subject, s.now())
+	if err != nil {
+		return err
+	}
+	// El corte está en segundos completos: sólo se rechazan los tokens emitidos en segundos anteriores.
+	if !cutoff.IsZero() && claims.IssuedAt.Time.Before(cutoff) {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// 7.- RevocationCache envuelve un RevocationStore y evita consultar la base en cada petición.
+type RevocationCache struct {
+	store    RevocationStore
+	ttl      time.Duration
+	now      func() time.Time
+	mu       sync.Mutex
+	tokens   map[string]revocationEntry
+	subjects map[string]revocationEntry
+}
+
+type revocationEntry struct {
+	revoked bool
+	cutoff  time.Time
+	until   time.Time
+}
+
+const revocationCacheLimit = 100000
+
+// 8.- NewRevocationCache define cuánto tiempo se confía en una respuesta negativa del store.
+func NewRevocationCache(store RevocationStore, ttl time.Duration) *RevocationCache {
+	if store == nil {
+		panic("revocation store is required")
+	}
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	return &RevocationCache{
+		store:    store,
+		ttl:      ttl,
+		now:      time.Now,
+		tokens:   make(map[string]revocationEntry),
+		subjects: make(map[string]revocationEntry),
+	}
+}
+
+// 9.- RevokeToken persiste la revocación y la refleja de inmediato en esta instancia.
+func (c *RevocationCache) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	if err := c.store.RevokeToken(ctx, jti, expiresAt); err != nil {
+		return err
+	}
+	c.put(c.tokens, jti, revocationEntry{revoked: true, until: expiresAt})
+	return nil
+}
+
+// 10.- IsTokenRevoked responde desde memoria; las revocaciones positivas viven hasta la expiración.
+func (c *RevocationCache) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	if entry, ok := c.get(c.tokens, jti); ok {
+		return entry.revoked, nil
+	}
+	revoked, err := c.store.IsTokenRevoked(ctx, jti)
+	if err != nil {
+		return false, err
+	}
+	// Las negativas se revalidan tras ttl para captar revocaciones hechas en otras instancias.
+	c.put(c.tokens, jti, revocationEntry{revoked: revoked, until: c.now().Add(c.ttl)})
+	return revoked, nil
+}
+
+// 11.- RevokeSubject persiste el corte y lo aplica localmente sin esperar al ttl.
+func (c *RevocationCache) RevokeSubject(ctx context.Context, subject string, cutoff, until time.Time) error {
+	if err := c.store.RevokeSubject(ctx, subject, cutoff, until); err != nil {
+		return err
+	}
+	c.put(c.subjects, subject, revocationEntry{cutoff: cutoff, until: c.now().Add(c.ttl)})
+	return nil
+}
+
+// 12.- SubjectCutoff reutiliza el corte en memoria durante ttl.
+func (c *RevocationCache) SubjectCutoff(ctx context.Context, subject string, now time.Time) (time.Time, error) {
+	if entry, ok := c.get(c.subjects, subject); ok {
+		return entry.cutoff, nil
+	}
+	cutoff, err := c.store.SubjectCutoff(ctx, subject, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+	c.put(c.subjects, subject, revocationEntry{cutoff: cutoff, until: c.now().Add(c.ttl)})
+	return cutoff, nil
+}
+
+// 13.- get devuelve la entrada vigente o descarta la vencida.
+func (c *RevocationCache) get(entries map[string]revocationEntry, key string) (revocationEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := entries[key]
+	if !ok {
+		return revocationEntry{}, false
+	}
+	if !entry.until.After(c.now()) {
+		delete(entries, key)
+		return revocationEntry{}, false
+	}
+	return entry, true
+}
+
+// 14.- put guarda la entrada y purga vencidas cuando el mapa alcanza el límite.
+func (c *RevocationCache) put(entries map[string]revocationEntry, key string, entry revocationEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(entries) >= revocationCacheLimit {
+		now := c.now()
+		for k, v := range entries {
+			if !v.until.After(now) {
+				delete(entries, k)
+			}
+		}
+		if len(entries) >= revocationCacheLimit {
+			clear(entries)
+		}
+	}
+	entries[key] = entry
+}
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 1.- memoryRevocationStore cuenta las consultas para verificar el efecto de la caché.
+type memoryRevocationStore struct {
+	mu       sync.Mutex
+	tokens   map[string]time.Time
+	subjects map[string]time.Time
+	lookups  int
+}
+
+func newMemoryRevocationStore() *memoryRevocationStore {
+	return &memoryRevocationStore{tokens: make(map[string]time.Time), subjects: make(map[string]time.Time)}
+}
+
+func (m *memoryRevocationStore) RevokeToken(_ context.Context, jti string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[jti] = expiresAt
+	return nil
+}
+
+func (m *memoryRevocationStore) IsTokenRevoked(_ context.Context, jti string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lookups++
+	_, ok := m.tokens[jti]
+	return ok, nil
+}
+
+func (m *memoryRevocationStore) RevokeSubject(_ context.Context, subject string, cutoff, _ time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subjects[subject] = cutoff
+	return nil
+}
+
+func (m *memoryRevocationStore) SubjectCutoff(_ context.Context, subject string, _ time.Time) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lookups++
+	return m.subjects[subject], nil
+}
+
+func TestLogoutRevokesAccessAndRefreshTokens(t *testing.T) {
+	// 2.- Iniciamos sesión con revocación y tokens de renovación habilitados.
+	refresh := newMemoryRefreshStore()
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Minute, []byte("test-secret"),
+		WithRefreshTokens(refresh, time.Hour), WithRevocation(newMemoryRevocationStore()))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	session, err := svc.Register(ctx, "vecino@example.com", "s3cr3t-pass")
+	if err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	principal, err := svc.ValidateToken(ctx, session.Token)
+	if err != nil {
+		t.Fatalf("ValidateToken returned error: %v", err)
+	}
+	if principal.TokenID == "" {
+		t.Fatalf("expected jti in access token")
+	}
+
+	// 3.- Tras el logout ni el token de acceso ni el de renovación siguen siendo válidos.
+	if err := svc.Logout(ctx, principal, session.RefreshToken); err != nil {
+		t.Fatalf("Logout returned error: %v", err)
+	}
+	if _, err := svc.ValidateToken(ctx, session.Token); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("expected ErrTokenRevoked, got %v", err)
+	}
+	if _, err := svc.Refresh(ctx, session.RefreshToken); err == nil {
+		t.Fatalf("expected refresh token to be unusable after logout")
+	}
+}
+
+func TestRevokeSessionsInvalidatesEarlierTokens(t *testing.T) {
+	// 1.- Emitimos dos sesiones para la misma cuenta con un reloj controlado.
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	refresh := newMemoryRefreshStore()
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Hour, []byte("test-secret"),
+		WithRefreshTokens(refresh, 24*time.Hour), WithRevocation(newMemoryRevocationStore()),
+		WithClock(func() time.Time { return now }))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	first, err := svc.Register(ctx, "jefa@example.com", "s3cr3t-pass")
+	if err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	second, err := svc.Authenticate(ctx, "jefa@example.com", "s3cr3t-pass")
+	if err != nil {
+		t.Fatalf("Authenticate returned error: %v", err)
+	}
+
+	// 2.- El administrador revoca todo y ambas sesiones dejan de funcionar.
+	now = now.Add(time.Minute)
+	if err := svc.RevokeSessions(ctx, "Jefa@Example.com"); err != nil {
+		t.Fatalf("RevokeSessions returned error: %v", err)
+	}
+	for _, session := range []AuthResponse{first, second} {
+		if _, err := svc.ValidateToken(ctx, session.Token); !errors.Is(err, ErrTokenRevoked) {
+			t.Fatalf("expected ErrTokenRevoked, got %v", err)
+		}
+		if _, err := svc.Refresh(ctx, session.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Fatalf("expected revoked refresh token, got %v", err)
+		}
+	}
+
+	// 3.- Un inicio de sesión posterior al corte vuelve a ser válido.
+	now = now.Add(time.Second)
+	fresh, err := svc.Authenticate(ctx, "jefa@example.com", "s3cr3t-pass")
+	if err != nil {
+		t.Fatalf("Authenticate returned error: %v", err)
+	}
+	if _, err := svc.ValidateToken(ctx, fresh.Token); err != nil {
+		t.Fatalf("expected fresh token to be valid, got %v", err)
+	}
+	if err := svc.RevokeSessions(ctx, "nadie@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestLoginInTheSameSecondAsRevokeSessionsStaysValid(t *testing.T) {
+	// 1.- El corte ocurre a mitad de un segundo y la cuenta vuelve a entrar medio segundo después.
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Hour, []byte("test-secret"),
+		WithRefreshTokens(newMemoryRefreshStore(), 24*time.Hour), WithRevocation(newMemoryRevocationStore()),
+		WithClock(func() time.Time { return now }))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	old, err := svc.Register(ctx, "jefa@example.com", "s3cr3t-pass")
+	if err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	now = now.Add(5*time.Second + 200*time.Millisecond)
+	if err := svc.RevokeSessions(ctx, "jefa@example.com"); err != nil {
+		t.Fatalf("RevokeSessions returned error: %v", err)
+	}
+	now = now.Add(500 * time.Millisecond)
+	fresh, err := svc.Authenticate(ctx, "jefa@example.com", "s3cr3t-pass")
+	if err != nil {
+		t.Fatalf("Authenticate returned error: %v", err)
+	}
+
+	// 2.- El token anterior muere y el nuevo, con el mismo iat que el corte, sigue vivo.
+	if _, err := svc.ValidateToken(ctx, old.Token); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("expected the earlier token to be revoked, got %v", err)
+	}
+	if _, err := svc.ValidateToken(ctx, fresh.Token); err != nil {
+		t.Fatalf("expected the token issued after the cutoff to be valid, got %v", err)
+	}
+	if _, err := svc.Refresh(ctx, fresh.RefreshToken); err != nil {
+		t.Fatalf("expected the fresh refresh token to be valid, got %v", err)
+	}
+}
+
+// recordingSessionListener anota las sesiones y cuentas cuyas conexiones en vivo deben cerrarse.
+type recordingSessionListener struct {
+	sessions []string
+	subjects []string
+}
+
+func (l *recordingSessionListener) DisconnectSession(sessionID string) {
+	l.sessions = append(l.sessions, sessionID)
+}
+
+func (l *recordingSessionListener) DisconnectSubject(subject string) {
+	l.subjects = append(l.subjects, subject)
+}
+
+func TestRevocationsNotifyTheSessionListener(t *testing.T) {
+	// 1.- Una cuenta con dos dispositivos y un escucha de revocaciones.
+	listener := &recordingSessionListener{}
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Hour, []byte("test-secret"),
+		WithPasswordHasher(NewBcryptHasher(bcrypt.MinCost)), WithRevocation(newMemoryRevocationStore()),
+		WithRefreshTokens(newMemoryRefreshStore(), 24*time.Hour), WithSessions(newMemorySessionStore()),
+		WithSessionListener(listener))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	phone, err := svc.Register(ctx, "vecina@example.com", "s3cr3t-pass")
+	if err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	principal, err := svc.ValidateToken(ctx, phone.Token)
+	if err != nil {
+		t.Fatalf("ValidateToken returned error: %v", err)
+	}
+
+	// 2.- Cerrar sesión avisa por su sid; revocar la cuenta avisa por el sujeto.
+	if err := svc.Logout(ctx, principal, ""); err != nil {
+		t.Fatalf("Logout returned error: %v", err)
+	}
+	if len(listener.sessions) != 1 || listener.sessions[0] != principal.SessionID {
+		t.Fatalf("expected the session to be disconnected, got %v", listener.sessions)
+	}
+	if err := svc.RevokeSessions(ctx, "Vecina@Example.com"); err != nil {
+		t.Fatalf("RevokeSessions returned error: %v", err)
+	}
+	if len(listener.subjects) != 1 || listener.subjects[0] != "vecina@example.com" {
+		t.Fatalf("expected the subject to be disconnected, got %v", listener.subjects)
+	}
+}
+
+func TestRevocationCacheAvoidsRepeatedLookups(t *testing.T) {
+	// 1.- Envolvemos el store en una caché con reloj controlado.
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	store := newMemoryRevocationStore()
+	cache := NewRevocationCache(store, 30*time.Second)
+	cache.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// 2.- Las respuestas negativas se reutilizan dentro del ttl.
+	for i := 0; i < 5; i++ {
+		if revoked, err := cache.IsTokenRevoked(ctx, "jti-1"); err != nil || revoked {
+			t.Fatalf("unexpected revocation result %v (%v)", revoked, err)
+		}
+	}
+	if store.lookups != 1 {
+		t.Fatalf("expected a single store lookup, got %d", store.lookups)
+	}
+
+	// 3.- Una revocación hecha en otra instancia se observa al vencer el ttl.
+	_ = store.RevokeToken(ctx, "jti-1", now.Add(time.Hour))
+	if revoked, _ := cache.IsTokenRevoked(ctx, "jti-1"); revoked {
+		t.Fatalf("expected cached negative answer before ttl")
+	}
+	now = now.Add(31 * time.Second)
+	if revoked, _ := cache.IsTokenRevoked(ctx, "jti-1"); !revoked {
+		t.Fatalf("expected revocation after ttl")
+	}
+
+	// 4.- Las revocaciones locales se aplican de inmediato.
+	_, _ = cache.IsTokenRevoked(ctx, "jti-2")
+	if err := cache.RevokeToken(ctx, "jti-2", now.Add(time.Hour)); err != nil {
+		t.Fatalf("RevokeToken returned error: %v", err)
+	}
+	if revoked, _ := cache.IsTokenRevoked(ctx, "jti-2"); !revoked {
+		t.Fatalf("expected local revocation to bypass cached negative")
+	}
+}
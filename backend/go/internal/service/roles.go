@@ -0,0 +1,94 @@
+package service
+
+import "time"
+
+// 1.- Roles soportados para ciudadanos y personal municipal.
+const (
+	RoleCitizen    = "citizen"
+	RoleOperator   = "operator"
+	RoleSupervisor = "supervisor"
+	RoleAdmin      = "admin"
+	// RoleAnonymous sólo se emite a dispositivos sin cuenta y no puede asignarse a usuarios.
+	RoleAnonymous = "anonymous"
+)
+
+// 2.- Permission identifica una acción protegida por las rutas HTTP.
+type Permission string
+
+// 3.- Permisos evaluados por el middleware de autorización.
+const (
+	PermReportsSubmit Permission = "reports:submit"
+	PermReportsRead   Permission = "reports:read"
+	// PermReportsReadAll abre el listado de toda la ciudad; sin él sólo se ven los reportes propios.
+	PermReportsReadAll Permission = "reports:read_all"
+	PermReportsContact Permission = "reports:contact"
+	PermReportsUpdate  Permission = "reports:update"
+	PermReportsEvents  Permission = "reports:events"
+	PermReportsDelete  Permission = "reports:delete"
+	PermReportsAssign  Permission = "reports:assign"
+	// PermReportsNotes abre las notas internas del personal, que nunca ve el ciudadano.
+	PermReportsNotes  Permission = "reports:notes"
+	PermAdminMetrics  Permission = "admin:metrics"
+	PermUsersManage   Permission = "users:manage"
+	PermAPIKeysManage Permission = "apikeys:manage"
+	// PermDepartmentsManage permite dar de alta dependencias y cuadrillas.
+	PermDepartmentsManage Permission = "departments:manage"
+)
+
+// 4.- rolePermissions acumula los permisos de cada rol sobre el rol inferior.
+var rolePermissions = func() map[string]map[Permission]struct{} {
+	citizen := []Permission{PermReportsSubmit, PermReportsRead}
+	operator := append(append([]Permission{}, citizen...), PermReportsReadAll, PermReportsContact, PermReportsUpdate, PermReportsEvents, PermReportsAssign, PermReportsNotes)
+	supervisor := append(append([]Permission{}, operator...), PermReportsDelete, PermAdminMetrics)
+	admin := append(append([]Permission{}, supervisor...), PermUsersManage, PermAPIKeysManage, PermDepartmentsManage)
+	build := func(perms []Permission) map[Permission]struct{} {
+		set := make(map[Permission]struct{}, len(perms))
+		for _, perm := range perms {
+			set[perm] = struct{}{}
+		}
+		return set
+	}
+	return map[string]map[Permission]struct{}{
+		RoleCitizen:    build(citizen),
+		RoleOperator:   build(operator),
+		RoleSupervisor: build(supervisor),
+		RoleAdmin:      build(admin),
+		RoleAnonymous:  build([]Permission{PermReportsSubmit}),
+	}
+}()
+
+// 5.- ValidRole confirma que el rol pertenece al catálogo asignable a cuentas.
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok && role != RoleAnonymous
+}
+
+// 6.- RoleHasPermission evalúa si el rol concede el permiso solicitado.
+func RoleHasPermission(role string, perm Permission) bool {
+	_, ok := rolePermissions[role][perm]
+	return ok
+}
+
+// 7.- Principal representa la identidad autenticada de una petición.
+type Principal struct {
+	Subject       string
+	Role          string
+	TokenID       string
+	ExpiresAt     time.Time
+	EmailVerified bool
+	// AuthMethods y AuthTime describen el inicio de sesión interactivo y se conservan al renovar.
+	AuthMethods []string
+	AuthTime    time.Time
+	SessionID   string
+	// APIKeyID y Scopes sólo se llenan cuando la petición se autenticó con X-API-Key.
+	APIKeyID string
+	Scopes   []string
+}
+
+// 8.- Can indica si la identidad puede ejecutar la acción solicitada; las claves de API usan sus alcances.
+func (p Principal) Can(perm Permission) bool {
+	if p.APIKeyID != "" {
+		return ScopesGrant(p.Scopes, perm)
+	}
+	return RoleHasPermission(p.Role, perm)
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// 1.- Session representa un inicio de sesión; su ID coincide con la familia de tokens de renovación.
+type Session struct {
+	ID         string    `json:"id"`
+	Subject    string    `json:"-"`
+	DeviceName string    `json:"deviceName"`
+	Platform   string    `json:"platform"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"userAgent"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	// Current se calcula al listar para que la app marque el dispositivo en uso.
+	Current   bool      `json:"current"`
+	RevokedAt time.Time `json:"-"`
+}
+
+// 2.- PushRegistration asocia el token de notificaciones de un dispositivo a su sesión.
+type PushRegistration struct {
+	SessionID string    `json:"sessionId"`
+	Subject   string    `json:"-"`
+	Provider  string    `json:"provider"`
+	Token     string    `json:"-"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// 3.- Proveedores de notificaciones aceptados.
+const (
+	PushProviderFCM  = "fcm"
+	PushProviderAPNS = "apns"
+)
+
+// 4.- SessionStore persiste sesiones y registros push; revocar una sesión borra su registro.
+type SessionStore interface {
+	CreateSession(ctx context.Context, session Session) error
+	// TouchSession actualiza la última actividad y la IP de una sesión vigente.
+	TouchSession(ctx context.Context, id string, at time.Time, ip string) error
+	FindSession(ctx context.Context, id string) (Session, error)
+	// ListSessions devuelve las sesiones sin revocar con actividad posterior a activeSince.
+	ListSessions(ctx context.Context, subject string, activeSince time.Time) ([]Session, error)
+	RevokeSession(ctx context.Context, id string, at time.Time) error
+	RevokeSubjectSessions(ctx context.Context, subject string, at time.Time) error
+	// SavePushRegistration reemplaza el registro de la sesión y retira el token de cualquier otra.
+	SavePushRegistration(ctx context.Context, registration PushRegistration) error
+	DeletePushRegistration(ctx context.Context, sessionID string) error
+	// PushRegistrations lista los dispositivos del sujeto con sesión vigente.
+	PushRegistrations(ctx context.Context, subject string) ([]PushRegistration, error)
+}
+
+// 5.- Errores del manejo de sesiones.
+var (
+	ErrSessionNotFound      = errors.New("session not found")
+	ErrSessionRequired      = errors.New("token is not bound to a session")
+	ErrInvalidPushProvider  = errors.New("unsupported push provider")
+	errSessionStoreDisabled = errors.New("session tracking is not configured")
+)
+
+// 6.- WithSessions registra cada inicio de sesión para listarlo y revocarlo por dispositivo.
+func WithSessions(store SessionStore) AuthOption {
+	return func(s *AuthService) {
+		s.sessions = store
+	}
+}
+
+// 7.- startSession guarda el dispositivo que inició sesión; un fallo sólo se registra para no bloquear el login.
+func (s *AuthService) startSession(ctx context.Context, subject, id string) {
+	if s.sessions == nil {
+		return
+	}
+	info := ClientInfoFrom(ctx)
+	now := s.now()
+	session := Session{
+		ID:         id,
+		Subject:    subject,
+		DeviceName: info.DeviceName,
+		Platform:   info.Platform,
+		IP:         info.IP,
+		UserAgent:  info.UserAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+	if err := s.sessions.CreateSession(ctx, session); err != nil {
+		s.logger.Error().
+			Err(err).
+			Str("event", "auth.session.failed").
+			Str("subject", subject).
+			Msg("cannot record session")
+	}
+}
+
+// 8.- touchSession marca actividad al rotar el token de renovación.
+func (s *AuthService) touchSession(ctx context.Context, id string) {
+	if s.sessions == nil {
+		return
+	}
+	if err := s.sessions.TouchSession(ctx, id, s.now(), ClientInfoFrom(ctx).IP); err != nil && !errors.Is(err, ErrSessionNotFound) {
+		s.logger.Error().
+			Err(err).
+			Str("event", "auth.session.failed").
+			Str("session_id", id).
+			Msg("cannot update session activity")
+	}
+}
+
+// 9.- Sessions lista los dispositivos con sesión vigente y marca la del token actual.
+func (s *AuthService) Sessions(ctx context.Context, principal Principal) ([]Session, error) {
+	if s.sessions == nil {
+		return nil, errSessionStoreDisabled
+	}
+	// Sin renovación, una sesión se considera activa mientras pueda seguir renovándose.
+	window := s.refreshTTL
+	if window <= 0 {
+		window = s.tokenTTL
+	}
+	sessions, err := s.sessions.ListSessions(ctx, principal.Subject, s.now().Add(-window))
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == principal.SessionID
+	}
+	return sessions, nil
+}
+
+// 10.- RevokeSession cierra una sesión propia: su familia de renovación, sus tokens de acceso y su registro push.
+func (s *AuthService) RevokeSession(ctx context.Context, principal Principal, id string) error {
+	if s.sessions == nil {
+		return errSessionStoreDisabled
+	}
+	session, err := s.sessions.FindSession(ctx, strings.TrimSpace(id))
+	if err != nil {
+		return err
+	}
+	if session.Subject != principal.Subject || !session.RevokedAt.IsZero() {
+		return ErrSessionNotFound
+	}
+	return s.endSession(ctx, session.ID)
+}
+
+// 11.- endSession revoca todo lo emitido bajo la sesión; los tokens de acceso se rechazan por su claim sid.
+func (s *AuthService) endSession(ctx context.Context, id string) error {
+	now := s.now()
+	if s.sessions != nil {
+		if err := s.sessions.RevokeSession(ctx, id, now); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return err
+		}
+	}
+	if s.refreshStore != nil {
+		if err := s.refreshStore.RevokeFamily(ctx, id, now); err != nil {
+			return err
+		}
+	}
+	if s.revocations != nil {
+		if err := s.revocations.RevokeToken(ctx, sessionRevocationKey(id), now.Add(s.tokenTTL)); err != nil {
+			return err
+		}
+	}
+	if s.sessionListener != nil {
+		s.sessionListener.DisconnectSession(id)
+	}
+	s.logger.Info().
+		Str("event", "auth.session.revoked").
+		Str("session_id", id).
+		Msg("session revoked")
+	return nil
+}
+
+// 12.- RegisterPush asocia el token del dispositivo a la sesión del token de acceso.
+func (s *AuthService) RegisterPush(ctx context.Context, principal Principal, provider, token string) error {
+	if s.sessions == nil {
+		return errSessionStoreDisabled
+	}
+	if principal.SessionID == "" {
+		return ErrSessionRequired
+	}
+	provider = strings.TrimSpace(strings.ToLower(provider))
+	if provider != PushProviderFCM && provider != PushProviderAPNS {
+		return ErrInvalidPushProvider
+	}
+	return s.sessions.SavePushRegistration(ctx, PushRegistration{
+		SessionID: principal.SessionID,
+		Subject:   principal.Subject,
+		Provider:  provider,
+		Token:     strings.TrimSpace(token),
+		UpdatedAt: s.now(),
+	})
+}
+
+// 13.- UnregisterPush deja de enviar notificaciones al dispositivo actual.
+func (s *AuthService) UnregisterPush(ctx context.Context, principal Principal) error {
+	if s.sessions == nil {
+		return errSessionStoreDisabled
+	}
+	if principal.SessionID == "" {
+		return ErrSessionRequired
+	}
+	return s.sessions.DeletePushRegistration(ctx, principal.SessionID)
+}
+
+// 14.- PushRegistrations expone los dispositivos del usuario para los servicios que envían notificaciones.
+func (s *AuthService) PushRegistrations(ctx context.Context, subject string) ([]PushRegistration, error) {
+	if s.sessions == nil {
+		return nil, nil
+	}
+	return s.sessions.PushRegistrations(ctx, strings.TrimSpace(strings.ToLower(subject)))
+}
+
+// sessionRevocationKey comparte la lista de denegación de jti sin chocar con identificadores de token.
+func sessionRevocationKey(id string) string {
+	return "sid:" + id
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 1.- memorySessionStore replica el borrado de registros push al revocar del repositorio Postgres.
+type memorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+	push     map[string]PushRegistration
+}
+
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{sessions: make(map[string]Session), push: make(map[string]PushRegistration)}
+}
+
+func (m *memorySessionStore) CreateSession(_ context.Context, session Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[session.ID] = session
+	return nil
+}
+
+func (m *memorySessionStore) TouchSession(_ context.Context, id string, at time.Time, ip string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[id]
+	if !ok || !session.RevokedAt.IsZero() {
+		return ErrSessionNotFound
+	}
+	session.LastSeenAt = at
+	if ip != "" {
+		session.IP = ip
+	}
+	m.sessions[id] = session
+	return nil
+}
+
+func (m *memorySessionStore) FindSession(_ context.Context, id string) (Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[id]
+	if !ok {
+		return Session{}, ErrSessionNotFound
+	}
+	return session, nil
+}
+
+func (m *memorySessionStore) ListSessions(_ context.Context, subject string, activeSince time.Time) ([]Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sessions := make([]Session, 0)
+	for _, session := range m.sessions {
+		if session.Subject == subject && session.RevokedAt.IsZero() && session.LastSeenAt.After(activeSince) {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt) })
+	return sessions, nil
+}
+
+func (m *memorySessionStore) RevokeSession(_ context.Context, id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.revokeLocked(id, at)
+	return nil
+}
+
+func (m *memorySessionStore) RevokeSubjectSessions(_ context.Context, subject string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, session := range m.sessions {
+		if session.Subject == subject {
+			m.revokeLocked(id, at)
+		}
+	}
+	return nil
+}
+
+func (m *memorySessionStore) revokeLocked(id string, at time.Time) {
+	if session, ok := m.sessions[id]; ok && session.RevokedAt.IsZero() {
+		session.RevokedAt = at
+		m.sessions[id] = session
+	}
+	delete(m.push, id)
+}
+
+func (m *memorySessionStore) SavePushRegistration(_ context.Context, registration PushRegistration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if session, ok := m.sessions[registration.SessionID]; !ok || !session.RevokedAt.IsZero() {
+		return ErrSessionNotFound
+	}
+	for id, existing := range m.push {
+		if existing.Token == registration.Token {
+			delete(m.push, id)
+		}
+	}
+	m.push[registration.SessionID] = registration
+	return nil
+}
+
+func (m *memorySessionStore) DeletePushRegistration(_ context.Context, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.push, sessionID)
+	return nil
+}
+
+func (m *memorySessionStore) PushRegistrations(_ context.Context, subject string) ([]PushRegistration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var registrations []PushRegistration
+	for _, registration := range m.push {
+		if registration.Subject == subject {
+			registrations = append(registrations, registration)
+		}
+	}
+	return registrations, nil
+}
+
+func TestSessionsAreListedAndRevokedPerDevice(t *testing.T) {
+	// 2.- Dos inicios de sesión desde dispositivos distintos crean dos sesiones.
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	refresh := newMemoryRefreshStore()
+	store := newMemorySessionStore()
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Minute, []byte("test-secret"),
+		WithRefreshTokens(refresh, time.Hour), WithRevocation(newMemoryRevocationStore()),
+		WithSessions(store), WithClock(func() time.Time { return now }))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	phoneCtx := WithClientInfo(ctx, ClientInfo{IP: "10.0.0.1", DeviceName: "Pixel 8", Platform: "android"})
+	phone, err := svc.Register(phoneCtx, "vecina@example.com", "ClaveSegura1")
+	if err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	now = now.Add(time.Minute)
+	laptop, err := svc.Authenticate(WithClientInfo(ctx, ClientInfo{IP: "10.0.0.2", DeviceName: "Firefox", Platform: "web"}),
+		"vecina@example.com", "ClaveSegura1")
+	if err != nil {
+		t.Fatalf("Authenticate returned error: %v", err)
+	}
+
+	// 3.- La lista marca como actual la sesión del token que consulta.
+	principal, err := svc.ValidateToken(ctx, phone.Token)
+	if err != nil || principal.SessionID == "" {
+		t.Fatalf("expected session-bound principal, got %+v (%v)", principal, err)
+	}
+	sessions, err := svc.Sessions(ctx, principal)
+	if err != nil {
+		t.Fatalf("Sessions returned error: %v", err)
+	}
+	if len(sessions) != 2 || sessions[1].DeviceName != "Pixel 8" || !sessions[1].Current || sessions[0].Current {
+		t.Fatalf("unexpected sessions %+v", sessions)
+	}
+
+	// 4.- El registro push exige la sesión y un proveedor conocido.
+	if err := svc.RegisterPush(ctx, principal, "sms", "token"); !errors.Is(err, ErrInvalidPushProvider) {
+		t.Fatalf("expected invalid provider, got %v", err)
+	}
+	if err := svc.RegisterPush(ctx, principal, "FCM", "device-token"); err != nil {
+		t.Fatalf("RegisterPush returned error: %v", err)
+	}
+	if registrations, _ := svc.PushRegistrations(ctx, "vecina@example.com"); len(registrations) != 1 {
+		t.Fatalf("expected one push registration, got %+v", registrations)
+	}
+
+	// 5.- Otro usuario no puede cerrar la sesión ajena.
+	if err := svc.RevokeSession(ctx, Principal{Subject: "otra@example.com"}, principal.SessionID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected not found for foreign session, got %v", err)
+	}
+
+	// 6.- Cerrar el teléfono desde la laptop invalida sus tokens y su registro push; la laptop sigue activa.
+	laptopPrincipal, err := svc.ValidateToken(ctx, laptop.Token)
+	if err != nil {
+		t.Fatalf("ValidateToken returned error: %v", err)
+	}
+	if err := svc.RevokeSession(ctx, laptopPrincipal, principal.SessionID); err != nil {
+		t.Fatalf("RevokeSession returned error: %v", err)
+	}
+	if _, err := svc.ValidateToken(ctx, phone.Token); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("expected revoked phone token, got %v", err)
+	}
+	if _, err := svc.Refresh(ctx, phone.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expected phone refresh to fail, got %v", err)
+	}
+	if registrations, _ := svc.PushRegistrations(ctx, "vecina@example.com"); len(registrations) != 0 {
+		t.Fatalf("expected push registration to be removed, got %+v", registrations)
+	}
+	if _, err := svc.ValidateToken(ctx, laptop.Token); err != nil {
+		t.Fatalf("expected laptop token to remain valid, got %v", err)
+	}
+	now = now.Add(time.Minute)
+	if _, err := svc.Refresh(ctx, laptop.RefreshToken); err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+	if sessions, _ := svc.Sessions(ctx, laptopPrincipal); len(sessions) != 1 || !sessions[0].LastSeenAt.Equal(now) {
+		t.Fatalf("expected refreshed laptop session only, got %+v", sessions)
+	}
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// SLAActor firma en la bitácora los eventos que genera el programador de SLA.
+const SLAActor = "sla"
+
+// SLAAnyIncidentType declara la política que aplica a los tipos sin una propia.
+const SLAAnyIncidentType = "*"
+
+// 1.- SLAPolicy exige que un reporte del tipo indicado salga de Status antes de BusinessHours hábiles.
+type SLAPolicy struct {
+	IncidentTypeID string  `json:"incidentTypeId"`
+	Status         string  `json:"status"`
+	BusinessHours  float64 `json:"businessHours"`
+}
+
+// 2.- SLAConfig es el documento JSON con el calendario municipal y las políticas.
+type SLAConfig struct {
+	Calendar BusinessCalendarConfig `json:"calendar"`
+	Policies []SLAPolicy            `json:"policies"`
+}
+
+// 3.- SLA indexa las políticas por estatus y tipo de incidente.
+type SLA struct {
+	calendar *BusinessCalendar
+	targets  map[string]map[string]time.Duration
+	statuses []string
+	shortest time.Duration
+}
+
+// 4.- SLABreach describe una estancia en un estatus que superó su plazo.
+type SLABreach struct {
+	ReportID       string    `json:"reportId"`
+	IncidentTypeID string    `json:"incidentTypeId"`
+	Status         string    `json:"status"`
+	EnteredAt      time.Time `json:"enteredAt"`
+	DueAt          time.Time `json:"dueAt"`
+	BreachedAt     time.Time `json:"breachedAt"`
+}
+
+// 5.- SLAStore persiste los incumplimientos; cada estancia se registra una sola vez.
+type SLAStore interface {
+	// SLACandidates devuelve los reportes que siguen en alguno de los estatus desde antes de enteredBefore
+	// y cuya estancia actual aún no tiene incumplimiento registrado.
+	SLACandidates(ctx context.Context, statuses []string, enteredBefore time.Time) ([]Report, error)
+	// RecordBreach guarda el incumplimiento y su evento; devuelve false si esa estancia ya estaba registrada.
+	RecordBreach(ctx context.Context, breach SLABreach, event ReportEvent) (bool, error)
+	// ActiveBreaches cuenta por tipo de incidente los reportes que siguen en el estatus incumplido.
+	ActiveBreaches(ctx context.Context) (map[string]int, error)
+}
+
+// 6.- SLAEscalator avisa a los responsables cuando se registra un incumplimiento.
+type SLAEscalator interface {
+	Escalate(ctx context.Context, breach SLABreach) error
+}
+
+var errSLADisabled = errors.New("sla policies are not configured")
+
+// 7.- LoadSLA lee el documento de SLA y lo valida contra el flujo vigente.
+func LoadSLA(path string, workflow *Workflow) (*SLA, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read sla: %w", err)
+	}
+	var config SLAConfig
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil, fmt.Errorf("decode sla: %w", err)
+	}
+	return NewSLA(config, workflow)
+}
+
+// 8.- NewSLA rechaza estatus desconocidos o terminales, plazos no positivos y políticas duplicadas.
+func NewSLA(config SLAConfig, workflow *Workflow) (*SLA, error) {
+	if workflow == nil {
+		workflow = DefaultWorkflow()
+	}
+	calendar, err := NewBusinessCalendar(config.Calendar)
+	if err != nil {
+		return nil, err
+	}
+	sla := &SLA{calendar: calendar, targets: make(map[string]map[string]time.Duration)}
+	for _, policy := range config.Policies {
+		typeID := strings.TrimSpace(policy.IncidentTypeID)
+		if typeID == "" {
+			return nil, errors.New("sla policy incident type is required")
+		}
+		status, ok := workflow.statuses[strings.TrimSpace(policy.Status)]
+		if !ok {
+			return nil, fmt.Errorf("sla policy for %s uses unknown status %q", typeID, policy.Status)
+		}
+		if status.Terminal {
+			return nil, fmt.Errorf("sla policy for %s cannot target terminal status %s", typeID, status.ID)
+		}
+		if policy.BusinessHours <= 0 {
+			return nil, fmt.Errorf("sla policy for %s in %s must allow a positive number of hours", typeID, status.ID)
+		}
+		byType, ok := sla.targets[status.ID]
+		if !ok {
+			byType = make(map[string]time.Duration)
+			sla.targets[status.ID] = byType
+			sla.statuses = append(sla.statuses, status.ID)
+		}
+		if _, dup := byType[typeID]; dup {
+			return nil, fmt.Errorf("sla policy for %s in %s declared twice", typeID, status.ID)
+		}
+		target := time.Duration(policy.BusinessHours * float64(time.Hour))
+		byType[typeID] = target
+		if sla.shortest == 0 || target < sla.shortest {
+			sla.shortest = target
+		}
+	}
+	return sla, nil
+}
+
+// 9.- Due calcula el vencimiento de la estancia actual; false si ninguna política aplica.
+func (sla *SLA) Due(report Report) (time.Time, bool) {
+	byType, ok := sla.targets[report.Status]
+	if !ok {
+		return time.Time{}, false
+	}
+	target, ok := byType[report.IncidentType.ID]
+	if !ok {
+		if target, ok = byType[SLAAnyIncidentType]; !ok {
+			return time.Time{}, false
+		}
+	}
+	return sla.calendar.Add(statusEnteredAt(report), target), true
+}
+
+// statusEnteredAt toma la fecha de creación en reportes que nunca cambiaron de estatus.
+func statusEnteredAt(report Report) time.Time {
+	if report.StatusChangedAt.IsZero() {
+		return report.CreatedAt
+	}
+	return report.StatusChangedAt
+}
+
+// 10.- WithSLA habilita las políticas de SLA; escalator es opcional.
+func WithSLA(sla *SLA, store SLAStore, escalator SLAEscalator) ReportOption {
+	return func(s *ReportService) {
+		if sla != nil && store != nil {
+			s.sla = sla
+			s.slaStore = store
+			s.escalator = escalator
+		}
+	}
+}
+
+// 11.- WithReportClock sustituye el reloj del servicio de reportes, útil para pruebas deterministas.
+func WithReportClock(now func() time.Time) ReportOption {
+	return func(s *ReportService) {
+		if now != nil {
+			s.now = now
+		}
+	}
+}
+
+// 12.- CheckSLA registra los incumplimientos vencidos y escala cada uno una sola vez.
+func (s *ReportService) CheckSLA(ctx context.Context) ([]SLABreach, error) {
+	if s.sla == nil {
+		return nil, errSLADisabled
+	}
+	if len(s.sla.statuses) == 0 {
+		return nil, nil
+	}
+	now := s.now()
+	candidates, err := s.slaStore.SLACandidates(ctx, s.sla.statuses, now.Add(-s.sla.shortest))
+	if err != nil {
+		return nil, err
+	}
+	var breaches []SLABreach
+	var failures []error
+	for _, report := range candidates {
+		due, ok := s.sla.Due(report)
+		if !ok || !now.After(due) {
+			continue
+		}
+		breach := SLABreach{
+			ReportID:       report.ID,
+			IncidentTypeID: report.IncidentType.ID,
+			Status:         report.Status,
+			EnteredAt:      statusEnteredAt(report),
+			DueAt:          due,
+			BreachedAt:     now,
+		}
+		event := ReportEvent{
+			ReportID:  report.ID,
+			Kind:      ReportEventEscalated,
+			Status:    report.Status,
+			Actor:     SLAActor,
+			Reason:    fmt.Sprintf("Plazo vencido el %s", due.UTC().Format(time.RFC3339)),
+			CreatedAt: now,
+		}
+		recorded, err := s.slaStore.RecordBreach(ctx, breach, event)
+		if err != nil {
+			s.logger.Error().Err(err).Str("event", "report.sla.record.failed").Str("report_id", report.ID).Msg("unable to record sla breach")
+			failures = append(failures, err)
+			continue
+		}
+		if !recorded {
+			continue
+		}
+		s.logger.Warn().
+			Str("event", "report.sla.breached").
+			Str("report_id", report.ID).
+			Str("status", report.Status).
+			Time("due_at", due).
+			Msg("sla breached")
+		breaches = append(breaches, breach)
+		if s.escalator != nil {
+			if err := s.escalator.Escalate(ctx, breach); err != nil {
+				s.logger.Error().Err(err).Str("event", "report.sla.escalate.failed").Str("report_id", report.ID).Msg("unable to escalate sla breach")
+			}
+		}
+	}
+	return breaches, errors.Join(failures...)
+}
+
+// 13.- RunSLAScheduler revisa los plazos cada interval hasta que ctx se cancele.
+func (s *ReportService) RunSLAScheduler(ctx context.Context, interval time.Duration) {
+	if s.sla == nil || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.CheckSLA(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Error().Err(err).Str("event", "report.sla.check.failed").Msg("sla check finished with errors")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// 14.- Escalate avisa por correo a los supervisores activos del reporte con el plazo vencido.
+func (s *AuthService) Escalate(ctx context.Context, breach SLABreach) error {
+	if s.mailer == nil {
+		return nil
+	}
+	message := MailMessage{
+		Subject: "Reporte " + breach.ReportID + " fuera de plazo",
+		Body: fmt.Sprintf("El reporte %s (%s) sigue en %s desde %s y venció el %s.",
+			breach.ReportID, breach.IncidentTypeID, breach.Status,
+			breach.EnteredAt.UTC().Format(time.RFC3339), breach.DueAt.UTC().Format(time.RFC3339)),
+	}
+	query := UserQuery{Role: RoleSupervisor, Status: UserStatusActive, PageSize: maxUserPageSize}
+	for {
+		users, total, err := s.repo.List(ctx, query)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			message.To = user.Email
+			s.enqueueMail(message)
+		}
+		query.Page++
+		if len(users) == 0 || query.Page*query.PageSize >= total {
+			return nil
+		}
+	}
+}
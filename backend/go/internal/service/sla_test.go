@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 1.- memorySLAStore lee los reportes del repositorio falso y replica la clave única por estancia.
+type memorySLAStore struct {
+	mu       sync.Mutex
+	repo     *fakeReportRepository
+	breaches map[string]SLABreach
+}
+
+func newMemorySLAStore(repo *fakeReportRepository) *memorySLAStore {
+	return &memorySLAStore{repo: repo, breaches: make(map[string]SLABreach)}
+}
+
+func slaKey(reportID, status string, entered time.Time) string {
+	return reportID + "|" + status + "|" + entered.UTC().Format(time.RFC3339Nano)
+}
+
+func (m *memorySLAStore) SLACandidates(_ context.Context, statuses []string, enteredBefore time.Time) ([]Report, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.repo.mu.RLock()
+	defer m.repo.mu.RUnlock()
+	var candidates []Report
+	for _, report := range m.repo.records {
+		if !containsString(statuses, report.Status) || !report.StatusChangedAt.Before(enteredBefore) {
+			continue
+		}
+		if _, recorded := m.breaches[slaKey(report.ID, report.Status, report.StatusChangedAt)]; recorded {
+			continue
+		}
+		candidates = append(candidates, report)
+	}
+	return candidates, nil
+}
+
+func (m *memorySLAStore) RecordBreach(ctx context.Context, breach SLABreach, event ReportEvent) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := slaKey(breach.ReportID, breach.Status, breach.EnteredAt)
+	if _, recorded := m.breaches[key]; recorded {
+		return false, nil
+	}
+	if _, err := m.repo.AppendEvent(ctx, event); err != nil {
+		return false, err
+	}
+	m.breaches[key] = breach
+	return true, nil
+}
+
+func (m *memorySLAStore) ActiveBreaches(_ context.Context) (map[string]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.repo.mu.RLock()
+	defer m.repo.mu.RUnlock()
+	counts := make(map[string]int)
+	for _, breach := range m.breaches {
+		report, ok := m.repo.records[breach.ReportID]
+		if ok && report.Status == breach.Status && report.StatusChangedAt.Equal(breach.EnteredAt) {
+			counts[breach.IncidentTypeID]++
+		}
+	}
+	return counts, nil
+}
+
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
+
+// 2.- recordingEscalator guarda los incumplimientos escalados.
+type recordingEscalator struct {
+	mu       sync.Mutex
+	breaches []SLABreach
+}
+
+func (r *recordingEscalator) Escalate(_ context.Context, breach SLABreach) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.breaches = append(r.breaches, breach)
+	return nil
+}
+
+func (r *recordingEscalator) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.breaches)
+}
+
+// 3.- testClock permite avanzar el tiempo mientras los trabajadores lo leen.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+func TestCheckSLARecordsBreachesOnceAndReportsThemInMetrics(t *testing.T) {
+	// 4.- Jornada de 09:00 a 18:00 en UTC; los baches tienen dos días hábiles y el resto cuatro horas.
+	sla, err := NewSLA(SLAConfig{Policies: []SLAPolicy{
+		{IncidentTypeID: "pothole", Status: "en_revision", BusinessHours: 16},
+		{IncidentTypeID: SLAAnyIncidentType, Status: "en_revision", BusinessHours: 4},
+	}}, nil)
+	if err != nil {
+		t.Fatalf("NewSLA returned error: %v", err)
+	}
+	clock := &testClock{now: time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)}
+	repo := newFakeReportRepository()
+	escalator := &recordingEscalator{}
+	svc := NewReportService(repo, newTestFolioGenerator(), 1, 1,
+		WithSLA(sla, newMemorySLAStore(repo), escalator), WithReportClock(clock.Now))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	pothole, err := svc.Submit(ctx, "vecino@example.com", map[string]any{"incidentTypeId": "pothole", "description": "Bache"})
+	if err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+	trash, err := svc.Submit(ctx, "vecino@example.com", map[string]any{"incidentTypeId": "trash", "description": "Basura"})
+	if err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+
+	// 5.- A las 14:00 sólo venció la basura y volver a revisar no la escala de nuevo.
+	clock.Set(time.Date(2024, 5, 6, 14, 0, 0, 0, time.UTC))
+	breaches, err := svc.CheckSLA(ctx)
+	if err != nil || len(breaches) != 1 || breaches[0].ReportID != trash.ID {
+		t.Fatalf("expected only the trash report to breach, got %+v (%v)", breaches, err)
+	}
+	if due := breaches[0].DueAt; !due.Equal(time.Date(2024, 5, 6, 13, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected due date %s", due)
+	}
+	if again, _ := svc.CheckSLA(ctx); len(again) != 0 || escalator.count() != 1 {
+		t.Fatalf("expected the breach to escalate once, got %+v and %d escalations", again, escalator.count())
+	}
+
+	// 6.- El bache vence el martes a las 16:00 hábiles y se cuenta por tipo en el panel.
+	clock.Set(time.Date(2024, 5, 7, 15, 59, 0, 0, time.UTC))
+	if early, _ := svc.CheckSLA(ctx); len(early) != 0 {
+		t.Fatalf("expected the pothole to be on time, got %+v", early)
+	}
+	clock.Set(time.Date(2024, 5, 8, 9, 30, 0, 0, time.UTC))
+	if late, _ := svc.CheckSLA(ctx); len(late) != 1 || late[0].ReportID != pothole.ID {
+		t.Fatalf("expected the pothole to breach, got %+v", late)
+	}
+	metrics, err := svc.DashboardMetrics(ctx)
+	if err != nil || metrics.SLABreaches != 2 || metrics.SLABreachesByIncidentType["pothole"] != 1 {
+		t.Fatalf("unexpected metrics %+v (%v)", metrics, err)
+	}
+
+	// 7.- Atender el reporte cierra la estancia incumplida y el escalamiento no se publica al ciudadano.
+	if _, err := svc.UpdateStatus(ctx, trash.ID, StatusChange{Status: "en_proceso", Actor: "operador@example.com"}); err != nil {
+		t.Fatalf("UpdateStatus returned error: %v", err)
+	}
+	if metrics, _ := svc.DashboardMetrics(ctx); metrics.SLABreaches != 1 || metrics.SLABreachesByIncidentType["trash"] != 0 {
+		t.Fatalf("expected only the pothole breach to remain, got %+v", metrics)
+	}
+	events, _ := svc.Events(ctx, pothole.ID)
+	if last := events[len(events)-1]; last.Kind != ReportEventEscalated || last.Actor != SLAActor {
+		t.Fatalf("expected an escalation event for staff, got %+v", last)
+	}
+	status, err := svc.Lookup(ctx, pothole.ID)
+	if err != nil || len(status.History) != 1 {
+		t.Fatalf("expected the escalation to stay out of the folio history, got %+v (%v)", status.History, err)
+	}
+}
+
+func TestNewSLARejectsInvalidPolicies(t *testing.T) {
+	// 8.- Estatus inexistentes, terminales, plazos vacíos y duplicados se rechazan al cargar.
+	workflow, err := NewWorkflow(WorkflowDefinition{
+		Initial:  "en_revision",
+		Statuses: []WorkflowStatus{{ID: "en_revision"}, {ID: "cerrado", Terminal: true}},
+	})
+	if err != nil {
+		t.Fatalf("NewWorkflow returned error: %v", err)
+	}
+	invalid := [][]SLAPolicy{
+		{{IncidentTypeID: "pothole", Status: "desconocido", BusinessHours: 4}},
+		{{IncidentTypeID: "pothole", Status: "cerrado", BusinessHours: 4}},
+		{{IncidentTypeID: "pothole", Status: "en_revision"}},
+		{{IncidentTypeID: "pothole", Status: "en_revision", BusinessHours: 4}, {IncidentTypeID: "pothole", Status: "en_revision", BusinessHours: 8}},
+	}
+	for _, policies := range invalid {
+		if _, err := NewSLA(SLAConfig{Policies: policies}, workflow); err == nil {
+			t.Errorf("expected %+v to be rejected", policies)
+		}
+	}
+}
+
+func TestEscalateMailsActiveSupervisors(t *testing.T) {
+	// 9.- Sólo los supervisores activos reciben el aviso; operadores y cuentas suspendidas no.
+	mailer := newChannelMailer()
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Minute, []byte("test-secret"),
+		WithMailer(mailer), WithPasswordHasher(NewBcryptHasher(bcrypt.MinCost)))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for email, role := range map[string]string{
+		"supervisora@example.com": RoleSupervisor,
+		"suspendido@example.com":  RoleSupervisor,
+		"operador@example.com":    RoleOperator,
+	} {
+		if _, err := svc.Register(ctx, email, "ClaveSegura1"); err != nil {
+			t.Fatalf("Register returned error: %v", err)
+		}
+		if err := svc.AssignRole(ctx, email, role); err != nil {
+			t.Fatalf("AssignRole returned error: %v", err)
+		}
+	}
+	admin := Principal{Subject: "admin@example.com", Role: RoleAdmin}
+	if err := svc.DisableUser(ctx, admin, "suspendido@example.com"); err != nil {
+		t.Fatalf("DisableUser returned error: %v", err)
+	}
+	breach := SLABreach{ReportID: "F-000001", IncidentTypeID: "pothole", Status: "en_revision"}
+	if err := svc.Escalate(ctx, breach); err != nil {
+		t.Fatalf("Escalate returned error: %v", err)
+	}
+	message := mailer.wait(t)
+	if message.To != "supervisora@example.com" || !strings.Contains(message.Subject, "F-000001") {
+		t.Fatalf("unexpected escalation mail %+v", message)
+	}
+	select {
+	case extra := <-mailer.sent:
+		t.Fatalf("unexpected extra escalation mail %+v", extra)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 1.- SocialProviderConfig describe un proveedor OpenID Connect aceptado por /auth/social.
+type SocialProviderConfig struct {
+	Name         string   `json:"name"`
+	Issuers      []string `json:"issuers"`
+	ClientIDs    []string `json:"clientIds"`
+	JWKSURL      string   `json:"jwksUrl"`
+	TokenURL     string   `json:"tokenUrl,omitempty"`
+	ClientSecret string   `json:"clientSecret,omitempty"`
+	RequireNonce bool     `json:"requireNonce,omitempty"`
+}
+
+// 2.- SocialCredential agrupa lo que envía el cliente: un ID token o un código de autorización.
+type SocialCredential struct {
+	IDToken      string
+	Code         string
+	CodeVerifier string
+	RedirectURI  string
+	Nonce        string
+}
+
+// 3.- Identity vincula la cuenta local con el sujeto estable del proveedor.
+type Identity struct {
+	Provider  string
+	Subject   string
+	Email     string
+	CreatedAt time.Time
+}
+
+// 4.- IdentityRepository persiste los vínculos entre proveedores y usuarios.
+type IdentityRepository interface {
+	FindIdentity(ctx context.Context, provider, subject string) (Identity, error)
+	LinkIdentity(ctx context.Context, identity Identity) error
+}
+
+// 5.- Errores del inicio de sesión federado.
+var (
+	ErrIdentityNotFound        = errors.New("identity not found")
+	ErrInvalidIdentityToken    = errors.New("invalid identity token")
+	ErrIdentityEmailUnverified = errors.New("identity email is not verified")
+	// ErrIdentityAccountUnverified evita vincular un correo verificado a una cuenta local que nadie confirmó.
+	ErrIdentityAccountUnverified = errors.New("existing account must verify its email before linking a provider")
+)
+
+// 6.- SocialProvider verifica los ID tokens de un proveedor contra su JWKS.
+type SocialProvider struct {
+	cfg    SocialProviderConfig
+	keys   *JWKSCache
+	client *http.Client
+}
+
+// 7.- NewSocialProvider valida la configuración mínima y prepara la caché de llaves.
+func NewSocialProvider(cfg SocialProviderConfig, client *http.Client) (*SocialProvider, error) {
+	cfg.Name = strings.TrimSpace(strings.ToLower(cfg.Name))
+	if cfg.Name == "" || cfg.JWKSURL == "" || len(cfg.Issuers) == 0 || len(cfg.ClientIDs) == 0 {
+		return nil, fmt.Errorf("social provider %q requires name, issuers, clientIds and jwksUrl", cfg.Name)
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &SocialProvider{cfg: cfg, keys: NewJWKSCache(cfg.JWKSURL, client, time.Hour), client: client}, nil
+}
+
+// 8.- LoadSocialProviders lee la lista de proveedores desde un archivo JSON; los secretos admiten ${VARIABLE}.
+func LoadSocialProviders(path string) ([]SocialProviderConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read social providers: %w", err)
+	}
+	var providers []SocialProviderConfig
+	if err := json.Unmarshal(data, &providers); err != nil {
+		return nil, fmt.Errorf("decode social providers: %w", err)
+	}
+	for i := range providers {
+		providers[i].ClientSecret = os.ExpandEnv(providers[i].ClientSecret)
+	}
+	return providers, nil
+}
+
+// 9.- WithSocialLogin registra los proveedores aceptados y el repositorio de identidades.
+func WithSocialLogin(identities IdentityRepository, providers ...*SocialProvider) AuthOption {
+	return func(s *AuthService) {
+		s.identities = identities
+		s.socialProviders = make(map[string]*SocialProvider, len(providers))
+		for _, provider := range providers {
+			s.socialProviders[provider.cfg.Name] = provider
+		}
+	}
+}
+
+// idTokenClaims recoge los claims OIDC que usamos para vincular la cuenta.
+type idTokenClaims struct {
+	Email         string `json:"email"`
+	EmailVerified any    `json:"email_verified"`
+	Nonce         string `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+// 10.- emailVerified acepta el booleano estándar y la cadena "true" que emite Apple.
+func (c idTokenClaims) emailVerified() bool {
+	switch v := c.EmailVerified.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	default:
+		return false
+	}
+}
+
+// 11.- Verify comprueba firma, emisor, audiencia, vigencia y nonce del ID token.
+func (p *SocialProvider) Verify(ctx context.Context, rawToken, nonce string, now time.Time) (idTokenClaims, error) {
+	var claims idTokenClaims
+	parsed, err := jwt.ParseWithClaims(rawToken, &claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.keys.Key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+	)
+	if err != nil || !parsed.Valid {
+		return idTokenClaims{}, ErrInvalidIdentityToken
+	}
+	if !slices.Contains(p.cfg.Issuers, claims.Issuer) {
+		return idTokenClaims{}, ErrInvalidIdentityToken
+	}
+	if !slices.ContainsFunc(claims.Audience, func(aud string) bool { return slices.Contains(p.cfg.ClientIDs, aud) }) {
+		return idTokenClaims{}, ErrInvalidIdentityToken
+	}
+	if claims.Subject == "" {
+		return idTokenClaims{}, ErrInvalidIdentityToken
+	}
+	// El nonce enlaza el token con la solicitud del cliente y evita repeticiones.
+	if (nonce != "" || p.cfg.RequireNonce) && claims.Nonce != nonce {
+		return idTokenClaims{}, ErrInvalidIdentityToken
+	}
+	return claims, nil
+}
+
+// 12.- Exchange canjea un código de autorización por el ID token en el endpoint del proveedor.
+func (p *SocialProvider) Exchange(ctx context.Context, credential SocialCredential) (string, error) {
+	if p.cfg.TokenURL == "" {
+		return "", ErrUnsupportedLogin
+	}
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {credential.Code},
+		"client_id":    {p.cfg.ClientIDs[0]},
+		"redirect_uri": {credential.RedirectURI},
+	}
+	if p.cfg.ClientSecret != "" {
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+	if credential.CodeVerifier != "" {
+		form.Set("code_verifier", credential.CodeVerifier)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("exchange code: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+		return "", ErrInvalidIdentityToken
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("exchange code: unexpected status %d", resp.StatusCode)
+	}
+	var body struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode token response: %w", err)
+	}
+	if body.IDToken == "" {
+		return "", ErrInvalidIdentityToken
+	}
+	return body.IDToken, nil
+}
+
+// 13.- SocialAuthenticate verifica la credencial federada y la vincula con una cuenta local.
+func (s *AuthService) SocialAuthenticate(ctx context.Context, providerName string, credential SocialCredential) (AuthResponse, error) {
+	select {
+	case <-ctx.Done():
+		return AuthResponse{}, ctx.Err()
+	default:
+	}
+	provider, ok := s.socialProviders[strings.ToLower(providerName)]
+	if !ok || s.identities == nil {
+		return AuthResponse{}, ErrUnsupportedLogin
+	}
+	rawToken := strings.TrimSpace(credential.IDToken)
+	if rawToken == "" {
+		if strings.TrimSpace(credential.Code) == "" {
+			return AuthResponse{}, ErrInvalidIdentityToken
+		}
+		exchanged, err := provider.Exchange(ctx, credential)
+		if err != nil {
+			return AuthResponse{}, err
+		}
+		rawToken = exchanged
+	}
+	claims, err := provider.Verify(ctx, rawToken, credential.Nonce, s.now())
+	if err != nil {
+		return AuthResponse{}, err
+	}
+	user, err := s.resolveIdentity(ctx, provider.cfg.Name, claims)
+	if err != nil {
+		return AuthResponse{}, err
+	}
+	// El proveedor no sustituye al segundo factor local de las cuentas con MFA.
+	return s.completeLogin(ctx, user, "fed")
+}
+
+// 14.- resolveIdentity reutiliza el vínculo existente o lo crea a partir de un correo verificado.
+func (s *AuthService) resolveIdentity(ctx context.Context, provider string, claims idTokenClaims) (User, error) {
+	identity, err := s.identities.FindIdentity(ctx, provider, claims.Subject)
+	if err == nil {
+		return s.repo.FindByEmail(ctx, identity.Email)
+	}
+	if !errors.Is(err, ErrIdentityNotFound) {
+		return User{}, err
+	}
+	// Sin correo verificado no podemos asegurar que la cuenta local pertenezca a la misma persona.
+	email := strings.TrimSpace(strings.ToLower(claims.Email))
+	if email == "" || !claims.emailVerified() {
+		return User{}, ErrIdentityEmailUnverified
+	}
+	user, err := s.repo.FindByEmail(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		// El proveedor ya confirmó el correo, así que la cuenta nace verificada.
+		user = User{Email: email, Role: RoleCitizen, CreatedAt: s.now(), VerifiedAt: s.now(), Notifications: DefaultNotificationPreferences()}
+		if err = s.repo.Create(ctx, user); errors.Is(err, ErrEmailConflict) {
+			user, err = s.repo.FindByEmail(ctx, email)
+		}
+	}
+	if err != nil {
+		return User{}, err
+	}
+	// Una cuenta local sin confirmar pudo registrarla otra persona con este correo; vincularla le entregaría el acceso.
+	if !user.Verified() {
+		return User{}, ErrIdentityAccountUnverified
+	}
+	link := Identity{Provider: provider, Subject: claims.Subject, Email: user.Email, CreatedAt: s.now()}
+	if err := s.identities.LinkIdentity(ctx, link); err != nil {
+		return User{}, err
+	}
+	s.logger.Info().
+		Str("event", "auth.identity.linked").
+		Str("provider", provider).
+		Str("subject", user.Email).
+		Msg("social identity linked")
+	return user, nil
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 1.- memoryIdentityRepository guarda los vínculos federados en memoria.
+type memoryIdentityRepository struct {
+	mu         sync.Mutex
+	identities map[string]Identity
+}
+
+func newMemoryIdentityRepository() *memoryIdentityRepository {
+	return &memoryIdentityRepository{identities: make(map[string]Identity)}
+}
+
+func (m *memoryIdentityRepository) FindIdentity(_ context.Context, provider, subject string) (Identity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	identity, ok := m.identities[provider+"|"+subject]
+	if !ok {
+		return Identity{}, ErrIdentityNotFound
+	}
+	return identity, nil
+}
+
+func (m *memoryIdentityRepository) LinkIdentity(_ context.Context, identity Identity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.identities[identity.Provider+"|"+identity.Subject] = identity
+	return nil
+}
+
+// 2.- fakeIdentityProvider publica un JWKS y firma ID tokens como lo haría Google o Apple.
+type fakeIdentityProvider struct {
+	key       *rsa.PrivateKey
+	kid       string
+	server    *httptest.Server
+	jwksHits  atomic.Int32
+	exchanged atomic.Value
+}
+
+func newFakeIdentityProvider(t *testing.T) *fakeIdentityProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	p := &fakeIdentityProvider{key: key, kid: "kid-1"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, _ *http.Request) {
+		p.jwksHits.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": p.kid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("code") != "valid-code" || r.PostFormValue("client_secret") != "shh" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": p.exchanged.Load().(string)})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *fakeIdentityProvider) config() SocialProviderConfig {
+	return SocialProviderConfig{
+		Name:         "google",
+		Issuers:      []string{"https://accounts.example.com"},
+		ClientIDs:    []string{"citizen-app"},
+		JWKSURL:      p.server.URL + "/jwks",
+		TokenURL:     p.server.URL + "/token",
+		ClientSecret: "shh",
+	}
+}
+
+func (p *fakeIdentityProvider) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = p.kid
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		t.Fatalf("cannot sign id token: %v", err)
+	}
+	return signed
+}
+
+func baseIDClaims(subject, email string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            "https://accounts.example.com",
+		"aud":            "citizen-app",
+		"sub":            subject,
+		"email":          email,
+		"email_verified": true,
+		"nonce":          "n-123",
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(5 * time.Minute).Unix(),
+	}
+}
+
+func newSocialAuthService(t *testing.T, p *fakeIdentityProvider) (*AuthService, *fakeUserRepository) {
+	t.Helper()
+	provider, err := NewSocialProvider(p.config(), p.server.Client())
+	if err != nil {
+		t.Fatalf("NewSocialProvider returned error: %v", err)
+	}
+	repo := newFakeUserRepository()
+	svc := NewAuthService(repo, 1, time.Minute, []byte("test-secret"), WithSocialLogin(newMemoryIdentityRepository(), provider))
+	return svc, repo
+}
+
+func TestSocialAuthenticateLinksVerifiedIdentity(t *testing.T) {
+	// 3.- Un ID token válido crea la cuenta local y la vincula con el sujeto del proveedor.
+	idp := newFakeIdentityProvider(t)
+	svc, repo := newSocialAuthService(t, idp)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	resp, err := svc.SocialAuthenticate(ctx, "Google", SocialCredential{IDToken: idp.sign(t, baseIDClaims("g-1", "Vecina@Example.com")), Nonce: "n-123"})
+	if err != nil {
+		t.Fatalf("SocialAuthenticate returned error: %v", err)
+	}
+	principal, err := svc.ValidateToken(ctx, resp.Token)
+	if err != nil || principal.Subject != "vecina@example.com" || principal.Role != RoleCitizen {
+		t.Fatalf("unexpected principal %+v (%v)", principal, err)
+	}
+	if _, err := repo.FindByEmail(ctx, "vecina@example.com"); err != nil {
+		t.Fatalf("expected local account, got %v", err)
+	}
+
+	// 4.- El mismo sujeto resuelve la misma cuenta aunque cambie su correo y el JWKS sigue en caché.
+	claims := baseIDClaims("g-1", "otro@example.com")
+	delete(claims, "nonce")
+	resp, err = svc.SocialAuthenticate(ctx, "google", SocialCredential{IDToken: idp.sign(t, claims)})
+	if err != nil {
+		t.Fatalf("second SocialAuthenticate returned error: %v", err)
+	}
+	principal, _ = svc.ValidateToken(ctx, resp.Token)
+	if principal.Subject != "vecina@example.com" {
+		t.Fatalf("expected linked subject, got %s", principal.Subject)
+	}
+	if hits := idp.jwksHits.Load(); hits != 1 {
+		t.Fatalf("expected cached jwks, got %d fetches", hits)
+	}
+}
+
+func TestSocialAuthenticateDoesNotLinkUnverifiedLocalAccounts(t *testing.T) {
+	// 1.- Alguien registra el correo de la víctima con su propia contraseña y nunca lo confirma.
+	idp := newFakeIdentityProvider(t)
+	svc, repo := newSocialAuthService(t, idp)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	hashed, err := svc.hashPassword("ClaveAtacante1")
+	if err != nil {
+		t.Fatalf("hashPassword returned error: %v", err)
+	}
+	if err := repo.Create(ctx, User{Email: "victima@example.com", PasswordHash: hashed, Role: RoleCitizen, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+
+	// 2.- El inicio federado de la víctima no se vincula a esa cuenta.
+	credential := SocialCredential{IDToken: idp.sign(t, baseIDClaims("g-9", "victima@example.com")), Nonce: "n-123"}
+	if _, err := svc.SocialAuthenticate(ctx, "google", credential); !errors.Is(err, ErrIdentityAccountUnverified) {
+		t.Fatalf("expected ErrIdentityAccountUnverified, got %v", err)
+	}
+	if _, err := svc.identities.FindIdentity(ctx, "google", "g-9"); !errors.Is(err, ErrIdentityNotFound) {
+		t.Fatalf("expected no identity link, got %v", err)
+	}
+
+	// 3.- Una vez que el dueño del correo confirma la cuenta, el vínculo procede.
+	if err := repo.MarkVerified(ctx, "victima@example.com", time.Now()); err != nil {
+		t.Fatalf("MarkVerified returned error: %v", err)
+	}
+	if _, err := svc.SocialAuthenticate(ctx, "google", credential); err != nil {
+		t.Fatalf("expected link after verification, got %v", err)
+	}
+}
+
+func TestSocialAuthenticateRejectsInvalidTokens(t *testing.T) {
+	// 1.- Preparamos variantes inválidas de un token por lo demás correcto.
+	idp := newFakeIdentityProvider(t)
+	svc, _ := newSocialAuthService(t, idp)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	mutate := func(key string, value any) string {
+		claims := baseIDClaims("g-2", "vecino@example.com")
+		claims[key] = value
+		return idp.sign(t, claims)
+	}
+	cases := map[string]SocialCredential{
+		"audiencia ajena": {IDToken: mutate("aud", "otra-app"), Nonce: "n-123"},
+		"emisor ajeno":    {IDToken: mutate("iss", "https://evil.example.com"), Nonce: "n-123"},
+		"expirado":        {IDToken: mutate("exp", time.Now().Add(-time.Hour).Unix()), Nonce: "n-123"},
+		"nonce distinto":  {IDToken: mutate("nonce", "otro"), Nonce: "n-123"},
+		"firma alterada":  {IDToken: mutate("sub", "g-2") + "x", Nonce: "n-123"},
+	}
+
+	// 2.- Cada variante se rechaza como token inválido.
+	for name, credential := range cases {
+		if _, err := svc.SocialAuthenticate(ctx, "google", credential); !errors.Is(err, ErrInvalidIdentityToken) {
+			t.Fatalf("%s: expected ErrInvalidIdentityToken, got %v", name, err)
+		}
+	}
+
+	// 3.- Un correo sin verificar no puede crear ni vincular cuentas.
+	if _, err := svc.SocialAuthenticate(ctx, "google", SocialCredential{IDToken: mutate("email_verified", false), Nonce: "n-123"}); !errors.Is(err, ErrIdentityEmailUnverified) {
+		t.Fatalf("expected ErrIdentityEmailUnverified, got %v", err)
+	}
+	if _, err := svc.SocialAuthenticate(ctx, "facebook", SocialCredential{IDToken: mutate("sub", "g-2")}); !errors.Is(err, ErrUnsupportedLogin) {
+		t.Fatalf("expected ErrUnsupportedLogin, got %v", err)
+	}
+}
+
+func TestSocialAuthenticateExchangesAuthorizationCode(t *testing.T) {
+	// 1.- El proveedor devuelve el ID token al canjear el código.
+	idp := newFakeIdentityProvider(t)
+	idp.exchanged.Store(idp.sign(t, baseIDClaims("g-3", "codigo@example.com")))
+	svc, _ := newSocialAuthService(t, idp)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// 2.- Un código válido inicia sesión y uno inválido se rechaza.
+	resp, err := svc.SocialAuthenticate(ctx, "google", SocialCredential{Code: "valid-code", RedirectURI: "https://app.example.com/cb", Nonce: "n-123"})
+	if err != nil {
+		t.Fatalf("SocialAuthenticate returned error: %v", err)
+	}
+	if principal, _ := svc.ValidateToken(ctx, resp.Token); principal.Subject != "codigo@example.com" {
+		t.Fatalf("unexpected subject %q", principal.Subject)
+	}
+	if _, err := svc.SocialAuthenticate(ctx, "google", SocialCredential{Code: "bad-code"}); !errors.Is(err, ErrInvalidIdentityToken) {
+		t.Fatalf("expected ErrInvalidIdentityToken, got %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// 1.- randomToken genera un valor opaco seguro para URLs con n bytes de entropía.
+func randomToken(n int) (string, error) {
+	buf, err := randomBytes(n)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// 1.1.- randomBytes lee n bytes del generador criptográfico.
+func randomBytes(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("generate token: %w", err)
+	}
+	return buf, nil
+}
+
+// 2.- randomID produce identificadores hexadecimales para registros internos.
+func randomID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// 3.- hashToken obtiene el SHA-256 que se persiste en lugar del token en claro.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,379 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultInviteTTL        = 72 * time.Hour
+	defaultAccountStatusTTL = 30 * time.Second
+	defaultUserPageSize     = 20
+	maxUserPageSize         = 100
+)
+
+// 1.- Estados derivados de la cuenta para el listado administrativo.
+const (
+	UserStatusActive   = "active"
+	UserStatusInvited  = "invited"
+	UserStatusDisabled = "disabled"
+)
+
+// 2.- Errores de la administración de usuarios.
+var (
+	ErrAccountDisabled    = errors.New("account disabled")
+	ErrInvalidUserStatus  = errors.New("invalid user status")
+	ErrSelfManagement     = errors.New("administrators cannot change their own role or status")
+	errInviteNotAvailable = errors.New("invitation delivery is not configured")
+)
+
+// 3.- UserQuery filtra y pagina el listado; Search compara contra correo y nombre.
+type UserQuery struct {
+	Search   string
+	Role     string
+	Status   string
+	Page     int
+	PageSize int
+}
+
+// 4.- UserSummary es la vista administrativa de una cuenta.
+type UserSummary struct {
+	Email         string     `json:"email"`
+	DisplayName   string     `json:"displayName"`
+	Role          string     `json:"role"`
+	Status        string     `json:"status"`
+	EmailVerified bool       `json:"emailVerified"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	DisabledAt    *time.Time `json:"disabledAt,omitempty"`
+}
+
+// 5.- PaginatedUsers replica el formato de PaginatedReports.
+type PaginatedUsers struct {
+	Items      []UserSummary `json:"items"`
+	HasMore    bool          `json:"hasMore"`
+	Page       int           `json:"page"`
+	TotalCount int           `json:"totalCount"`
+}
+
+// Disabled indica si un administrador suspendió la cuenta.
+func (u User) Disabled() bool {
+	return !u.DisabledAt.IsZero()
+}
+
+// Status resume la cuenta: una invitación pendiente no tiene contraseña ni correo confirmado.
+func (u User) Status() string {
+	switch {
+	case u.Disabled():
+		return UserStatusDisabled
+	case u.PasswordHash == "" && !u.Verified():
+		return UserStatusInvited
+	default:
+		return UserStatusActive
+	}
+}
+
+// Summary proyecta la cuenta para el listado administrativo.
+func (u User) Summary() UserSummary {
+	summary := UserSummary{
+		Email:         u.Email,
+		DisplayName:   u.DisplayName,
+		Role:          u.Role,
+		Status:        u.Status(),
+		EmailVerified: u.Verified(),
+		CreatedAt:     u.CreatedAt,
+	}
+	if u.Disabled() {
+		disabledAt := u.DisabledAt
+		summary.DisabledAt = &disabledAt
+	}
+	return summary
+}
+
+// 6.- WithInvitations define la vigencia del enlace de invitación y la pantalla que lo recibe.
+func WithInvitations(ttl time.Duration, inviteURL string) AuthOption {
+	return func(s *AuthService) {
+		if ttl > 0 {
+			s.inviteTTL = ttl
+		}
+		s.inviteURL = strings.TrimSpace(inviteURL)
+	}
+}
+
+// 7.- ListUsers valida los filtros y acota el tamaño de página.
+func (s *AuthService) ListUsers(ctx context.Context, query UserQuery) (PaginatedUsers, error) {
+	query.Search = strings.TrimSpace(query.Search)
+	query.Role = strings.TrimSpace(strings.ToLower(query.Role))
+	query.Status = strings.TrimSpace(strings.ToLower(query.Status))
+	if query.Role != "" && !ValidRole(query.Role) {
+		return PaginatedUsers{}, ErrInvalidRole
+	}
+	switch query.Status {
+	case "", UserStatusActive, UserStatusInvited, UserStatusDisabled:
+	default:
+		return PaginatedUsers{}, ErrInvalidUserStatus
+	}
+	if query.Page < 0 {
+		query.Page = 0
+	}
+	if query.PageSize <= 0 {
+		query.PageSize = defaultUserPageSize
+	}
+	query.PageSize = min(query.PageSize, maxUserPageSize)
+	users, total, err := s.repo.List(ctx, query)
+	if err != nil {
+		return PaginatedUsers{}, err
+	}
+	items := make([]UserSummary, 0, len(users))
+	for _, user := range users {
+		items = append(items, user.Summary())
+	}
+	hasMore := (query.Page+1)*query.PageSize < total
+	return PaginatedUsers{Items: items, HasMore: hasMore, Page: query.Page, TotalCount: total}, nil
+}
+
+// 8.- InviteUser crea la cuenta sin contraseña y envía el enlace para configurarla; reinvitar reemplaza el enlace.
+func (s *AuthService) InviteUser(ctx context.Context, actor Principal, email, role string) (UserSummary, error) {
+	if s.oneTimeTokens == nil || s.mailer == nil {
+		return UserSummary{}, errInviteNotAvailable
+	}
+	normalized := strings.TrimSpace(strings.ToLower(email))
+	if normalized == "" {
+		return UserSummary{}, ErrInvalidCredentials
+	}
+	if !ValidRole(role) {
+		return UserSummary{}, ErrInvalidRole
+	}
+	user := User{Email: normalized, Role: role, CreatedAt: s.now(), Notifications: DefaultNotificationPreferences()}
+	if err := s.repo.Create(ctx, user); err != nil {
+		if !errors.Is(err, ErrEmailConflict) {
+			return UserSummary{}, err
+		}
+		// Sólo una invitación pendiente puede reenviarse; una cuenta en uso sigue en conflicto.
+		existing, findErr := s.repo.FindByEmail(ctx, normalized)
+		if findErr != nil {
+			return UserSummary{}, findErr
+		}
+		if existing.Status() != UserStatusInvited {
+			return UserSummary{}, ErrEmailConflict
+		}
+		if existing.Role != role {
+			if err := s.repo.UpdateRole(ctx, normalized, role); err != nil {
+				return UserSummary{}, err
+			}
+			existing.Role = role
+		}
+		user = existing
+	}
+	token, err := s.issueOneTimeToken(ctx, TokenPurposeInvite, normalized, s.inviteTTL)
+	if err != nil {
+		return UserSummary{}, err
+	}
+	s.enqueueMail(MailMessage{
+		To:      normalized,
+		Subject: "Te invitaron a Reportes Ciudadanos",
+		Body:    s.inviteBody(token),
+	})
+	s.logger.Info().
+		Str("event", "auth.user.invited").
+		Str("subject", normalized).
+		Str("role", role).
+		Str("actor", actor.Subject).
+		Msg("user invited")
+	return user.Summary(), nil
+}
+
+// 9.- inviteBody arma el texto con el enlace o, si no hay URL configurada, con el token en claro.
+func (s *AuthService) inviteBody(token string) string {
+	action := "Usa este código en la aplicación para elegir tu contraseña:\n\n" + token
+	if s.inviteURL != "" {
+		action = "Abre este enlace para elegir tu contraseña:\n\n" + s.inviteURL + "?token=" + url.QueryEscape(token)
+	}
+	hours := int(s.inviteTTL / time.Hour)
+	return fmt.Sprintf("Se creó una cuenta para ti en el sistema de reportes ciudadanos.\n\n%s\n\nVence en %d horas y sólo puede usarse una vez.\n", action, hours)
+}
+
+// 10.- AcceptInvitation fija la primera contraseña, confirma el correo e inicia sesión.
+func (s *AuthService) AcceptInvitation(ctx context.Context, token, password string) (AuthResponse, error) {
+	if strings.TrimSpace(password) == "" {
+		return AuthResponse{}, ErrInvalidCredentials
+	}
+	if s.oneTimeTokens == nil {
+		return AuthResponse{}, ErrInvalidOneTimeToken
+	}
+	if err := s.checkPassword(ctx, password); err != nil {
+		return AuthResponse{}, err
+	}
+	hashed, err := s.hashPassword(password)
+	if err != nil {
+		return AuthResponse{}, err
+	}
+	consumed, err := s.oneTimeTokens.Consume(ctx, TokenPurposeInvite, hashToken(strings.TrimSpace(token)), s.now())
+	if err != nil {
+		return AuthResponse{}, err
+	}
+	user, err := s.repo.FindByEmail(ctx, consumed.Subject)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return AuthResponse{}, ErrInvalidOneTimeToken
+		}
+		return AuthResponse{}, err
+	}
+	if user.Status() != UserStatusInvited {
+		return AuthResponse{}, ErrInvalidOneTimeToken
+	}
+	if err := s.repo.UpdatePassword(ctx, user.Email, hashed); err != nil {
+		return AuthResponse{}, err
+	}
+	now := s.now()
+	if err := s.repo.MarkVerified(ctx, user.Email, now); err != nil {
+		return AuthResponse{}, err
+	}
+	user.PasswordHash, user.VerifiedAt = hashed, now
+	s.logger.Info().
+		Str("event", "auth.invitation.accepted").
+		Str("subject", user.Email).
+		Msg("invitation accepted")
+	// Los roles con MFA obligatorio reciben el reto de inscripción como en cualquier login.
+	return s.completeLogin(ctx, user, "pwd")
+}
+
+// 11.- ChangeRole cambia el rol de otra cuenta y revoca sus sesiones para que ningún token conserve el rol anterior.
+func (s *AuthService) ChangeRole(ctx context.Context, actor Principal, email, role string) error {
+	normalized := strings.TrimSpace(strings.ToLower(email))
+	if normalized == actor.Subject {
+		return ErrSelfManagement
+	}
+	if err := s.AssignRole(ctx, normalized, role); err != nil {
+		return err
+	}
+	s.logger.Info().
+		Str("event", "auth.user.role_changed").
+		Str("subject", normalized).
+		Str("role", role).
+		Str("actor", actor.Subject).
+		Msg("user role changed")
+	return s.RevokeSessions(ctx, normalized)
+}
+
+// 12.- DisableUser suspende la cuenta y revoca sus sesiones para que los tokens vigentes dejen de servir.
+func (s *AuthService) DisableUser(ctx context.Context, actor Principal, email string) error {
+	normalized := strings.TrimSpace(strings.ToLower(email))
+	if normalized == actor.Subject {
+		return ErrSelfManagement
+	}
+	if err := s.repo.SetDisabled(ctx, normalized, s.now()); err != nil {
+		return err
+	}
+	s.accountStatus.put(normalized, true, s.now())
+	s.logger.Info().
+		Str("event", "auth.user.disabled").
+		Str("subject", normalized).
+		Str("actor", actor.Subject).
+		Msg("user disabled")
+	return s.RevokeSessions(ctx, normalized)
+}
+
+// 13.- EnableUser reactiva la cuenta; el usuario debe iniciar sesión de nuevo.
+func (s *AuthService) EnableUser(ctx context.Context, actor Principal, email string) error {
+	normalized := strings.TrimSpace(strings.ToLower(email))
+	if err := s.repo.SetDisabled(ctx, normalized, time.Time{}); err != nil {
+		return err
+	}
+	s.accountStatus.put(normalized, false, s.now())
+	s.logger.Info().
+		Str("event", "auth.user.enabled").
+		Str("subject", normalized).
+		Str("actor", actor.Subject).
+		Msg("user enabled")
+	return nil
+}
+
+// 14.- WithAccountStatusCache define cuánto tiempo se confía en el estado de la cuenta antes de releerlo.
+func WithAccountStatusCache(ttl time.Duration) AuthOption {
+	return func(s *AuthService) {
+		s.accountStatus = newAccountStatusCache(ttl)
+	}
+}
+
+// 15.- checkAccountStatus rechaza los tokens de cuentas suspendidas o eliminadas aunque no haya revocación configurada.
+func (s *AuthService) checkAccountStatus(ctx context.Context, subject string) error {
+	if IsAnonymousSubject(subject) {
+		return nil
+	}
+	now := s.now()
+	if disabled, ok := s.accountStatus.get(subject, now); ok {
+		if disabled {
+			return ErrAccountDisabled
+		}
+		return nil
+	}
+	user, err := s.repo.FindByEmail(ctx, subject)
+	if errors.Is(err, ErrUserNotFound) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return err
+	}
+	disabled := user.Status() == UserStatusDisabled
+	// Otras instancias ven la suspensión en cuanto vence la entrada, igual que con RevocationCache.
+	s.accountStatus.put(subject, disabled, now)
+	if disabled {
+		return ErrAccountDisabled
+	}
+	return nil
+}
+
+// accountStatusCache guarda por sujeto si la cuenta está suspendida durante ttl.
+type accountStatusCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]accountStatusEntry
+}
+
+type accountStatusEntry struct {
+	disabled bool
+	until    time.Time
+}
+
+func newAccountStatusCache(ttl time.Duration) *accountStatusCache {
+	if ttl <= 0 {
+		ttl = defaultAccountStatusTTL
+	}
+	return &accountStatusCache{ttl: ttl, entries: make(map[string]accountStatusEntry)}
+}
+
+func (c *accountStatusCache) get(subject string, now time.Time) (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[subject]
+	if !ok {
+		return false, false
+	}
+	if !entry.until.After(now) {
+		delete(c.entries, subject)
+		return false, false
+	}
+	return entry.disabled, true
+}
+
+// put reemplaza la entrada; al llegar al límite descarta las vencidas y, si no basta, todo el mapa.
+func (c *accountStatusCache) put(subject string, disabled bool, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= revocationCacheLimit {
+		for key, entry := range c.entries {
+			if !entry.until.After(now) {
+				delete(c.entries, key)
+			}
+		}
+		if len(c.entries) >= revocationCacheLimit {
+			clear(c.entries)
+		}
+	}
+	c.entries[subject] = accountStatusEntry{disabled: disabled, until: now.Add(c.ttl)}
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestInvitationCreatesStaffAccountWithSetupLink(t *testing.T) {
+	// 1.- El administrador invita a un operador; la cuenta queda pendiente sin contraseña.
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	mailer := newChannelMailer()
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Minute, []byte("test-secret"),
+		WithOneTimeTokens(newMemoryOneTimeTokenStore()), WithMailer(mailer),
+		WithInvitations(24*time.Hour, "https://panel.example.com/invite"),
+		WithEmailVerification(time.Hour, ""), WithClock(func() time.Time { return now }))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	admin := Principal{Subject: "admin@example.com", Role: RoleAdmin}
+	invited, err := svc.InviteUser(ctx, admin, " Operador@Example.com ", RoleOperator)
+	if err != nil {
+		t.Fatalf("InviteUser returned error: %v", err)
+	}
+	if invited.Email != "operador@example.com" || invited.Status != UserStatusInvited || invited.Role != RoleOperator {
+		t.Fatalf("unexpected invited user %+v", invited)
+	}
+	first := tokenFromBody(t, mailer.wait(t).Body)
+	if _, err := svc.Authenticate(ctx, "operador@example.com", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected pending account to reject login, got %v", err)
+	}
+
+	// 2.- Reinvitar cambia el rol y reemplaza el enlace anterior.
+	if _, err := svc.InviteUser(ctx, admin, "operador@example.com", RoleSupervisor); err != nil {
+		t.Fatalf("InviteUser returned error: %v", err)
+	}
+	second := tokenFromBody(t, mailer.wait(t).Body)
+	if _, err := svc.AcceptInvitation(ctx, first, "ClaveSegura1"); !errors.Is(err, ErrInvalidOneTimeToken) {
+		t.Fatalf("expected replaced token to fail, got %v", err)
+	}
+
+	// 3.- Aceptar fija la contraseña, confirma el correo y entrega tokens con el rol asignado.
+	session, err := svc.AcceptInvitation(ctx, second, "ClaveSegura1")
+	if err != nil {
+		t.Fatalf("AcceptInvitation returned error: %v", err)
+	}
+	principal, err := svc.ValidateToken(ctx, session.Token)
+	if err != nil || principal.Role != RoleSupervisor || !principal.EmailVerified {
+		t.Fatalf("unexpected principal %+v (%v)", principal, err)
+	}
+
+	// 4.- Una cuenta ya configurada no puede invitarse de nuevo.
+	if _, err := svc.InviteUser(ctx, admin, "operador@example.com", RoleOperator); !errors.Is(err, ErrEmailConflict) {
+		t.Fatalf("expected conflict for active account, got %v", err)
+	}
+}
+
+func TestDisabledUsersCannotSignIn(t *testing.T) {
+	// 1.- Registramos dos cuentas con revocación habilitada.
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	repo := newFakeUserRepository()
+	svc := NewAuthService(repo, 1, time.Minute, []byte("test-secret"),
+		WithRefreshTokens(newMemoryRefreshStore(), time.Hour), WithRevocation(newMemoryRevocationStore()),
+		WithClock(func() time.Time { return now }), WithPasswordHasher(NewBcryptHasher(bcrypt.MinCost)))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	session, err := svc.Register(ctx, "vecino@example.com", "ClaveSegura1")
+	if err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	if _, err := svc.Register(ctx, "admin@example.com", "ClaveSegura1"); err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	admin := Principal{Subject: "admin@example.com", Role: RoleAdmin}
+
+	// 2.- El administrador no puede suspenderse ni cambiarse el rol a sí mismo.
+	if err := svc.DisableUser(ctx, admin, "Admin@example.com"); !errors.Is(err, ErrSelfManagement) {
+		t.Fatalf("expected self management error, got %v", err)
+	}
+	if err := svc.ChangeRole(ctx, admin, "admin@example.com", RoleCitizen); !errors.Is(err, ErrSelfManagement) {
+		t.Fatalf("expected self management error, got %v", err)
+	}
+
+	// 3.- Suspender revoca el token vigente, la renovación y el login.
+	now = now.Add(time.Minute)
+	if err := svc.DisableUser(ctx, admin, "vecino@example.com"); err != nil {
+		t.Fatalf("DisableUser returned error: %v", err)
+	}
+	if _, err := svc.ValidateToken(ctx, session.Token); !errors.Is(err, ErrAccountDisabled) {
+		t.Fatalf("expected disabled account, got %v", err)
+	}
+	if _, err := svc.Refresh(ctx, session.RefreshToken); err == nil {
+		t.Fatalf("expected refresh to fail for disabled account")
+	}
+	if _, err := svc.Authenticate(ctx, "vecino@example.com", "ClaveSegura1"); !errors.Is(err, ErrAccountDisabled) {
+		t.Fatalf("expected disabled account, got %v", err)
+	}
+
+	// 4.- El listado distingue el estado y reactivar permite volver a entrar.
+	page, err := svc.ListUsers(ctx, UserQuery{Status: UserStatusDisabled})
+	if err != nil || page.TotalCount != 1 || page.Items[0].Email != "vecino@example.com" || page.Items[0].DisabledAt == nil {
+		t.Fatalf("unexpected disabled listing %+v (%v)", page, err)
+	}
+	if _, err := svc.ListUsers(ctx, UserQuery{Status: "deleted"}); !errors.Is(err, ErrInvalidUserStatus) {
+		t.Fatalf("expected invalid status, got %v", err)
+	}
+	now = now.Add(time.Minute)
+	if err := svc.EnableUser(ctx, admin, "vecino@example.com"); err != nil {
+		t.Fatalf("EnableUser returned error: %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, "vecino@example.com", "ClaveSegura1"); err != nil {
+		t.Fatalf("Authenticate returned error: %v", err)
+	}
+}
+
+func TestValidateTokenRejectsDisabledAccountsWithoutRevocation(t *testing.T) {
+	// 1.- Sin lista de denegación, el estado de la cuenta se consulta y se guarda treinta segundos.
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	repo := newFakeUserRepository()
+	svc := NewAuthService(repo, 1, time.Minute, []byte("test-secret"),
+		WithClock(func() time.Time { return now }), WithAccountStatusCache(30*time.Second),
+		WithPasswordHasher(NewBcryptHasher(bcrypt.MinCost)))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	citizen, err := svc.Register(ctx, "vecino@example.com", "ClaveSegura1")
+	if err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	operator, err := svc.Register(ctx, "operador@example.com", "ClaveSegura1")
+	if err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	if _, err := svc.ValidateToken(ctx, citizen.Token); err != nil {
+		t.Fatalf("ValidateToken returned error: %v", err)
+	}
+
+	// 2.- Una suspensión hecha por otra instancia se nota al vencer la caché.
+	if err := repo.SetDisabled(ctx, "vecino@example.com", now); err != nil {
+		t.Fatalf("SetDisabled returned error: %v", err)
+	}
+	if _, err := svc.ValidateToken(ctx, citizen.Token); err != nil {
+		t.Fatalf("expected the cached status within the ttl, got %v", err)
+	}
+	now = now.Add(31 * time.Second)
+	if _, err := svc.ValidateToken(ctx, citizen.Token); !errors.Is(err, ErrAccountDisabled) {
+		t.Fatalf("expected disabled account after the ttl, got %v", err)
+	}
+
+	// 3.- La suspensión local aplica de inmediato, aunque el estado activo siga en caché.
+	admin := Principal{Subject: "admin@example.com", Role: RoleAdmin}
+	if _, err := svc.ValidateToken(ctx, operator.Token); err != nil {
+		t.Fatalf("ValidateToken returned error: %v", err)
+	}
+	if err := svc.DisableUser(ctx, admin, "operador@example.com"); err != nil {
+		t.Fatalf("DisableUser returned error: %v", err)
+	}
+	if _, err := svc.ValidateToken(ctx, operator.Token); !errors.Is(err, ErrAccountDisabled) {
+		t.Fatalf("expected disabled account, got %v", err)
+	}
+}
+
+func TestChangeRoleRevokesTokensWithTheOldRole(t *testing.T) {
+	// 1.- Un administrador degradado no conserva el rol anterior en su token vigente.
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Minute, []byte("test-secret"),
+		WithRefreshTokens(newMemoryRefreshStore(), time.Hour), WithRevocation(newMemoryRevocationStore()),
+		WithClock(func() time.Time { return now }), WithPasswordHasher(NewBcryptHasher(bcrypt.MinCost)))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := svc.Register(ctx, "jefa@example.com", "ClaveSegura1"); err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	if err := svc.AssignRole(ctx, "jefa@example.com", RoleAdmin); err != nil {
+		t.Fatalf("AssignRole returned error: %v", err)
+	}
+	session, err := svc.Authenticate(ctx, "jefa@example.com", "ClaveSegura1")
+	if err != nil {
+		t.Fatalf("Authenticate returned error: %v", err)
+	}
+	now = now.Add(time.Minute)
+	if err := svc.ChangeRole(ctx, Principal{Subject: "root@example.com", Role: RoleAdmin}, "jefa@example.com", RoleCitizen); err != nil {
+		t.Fatalf("ChangeRole returned error: %v", err)
+	}
+	if _, err := svc.ValidateToken(ctx, session.Token); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("expected the admin token to be revoked, got %v", err)
+	}
+	if _, err := svc.Refresh(ctx, session.RefreshToken); err == nil {
+		t.Fatalf("expected the refresh token to be revoked")
+	}
+
+	// 2.- El siguiente inicio de sesión ya lleva el rol nuevo.
+	now = now.Add(time.Second)
+	fresh, err := svc.Authenticate(ctx, "jefa@example.com", "ClaveSegura1")
+	if err != nil {
+		t.Fatalf("Authenticate returned error: %v", err)
+	}
+	if principal, err := svc.ValidateToken(ctx, fresh.Token); err != nil || principal.Role != RoleCitizen {
+		t.Fatalf("unexpected principal %+v (%v)", principal, err)
+	}
+}
+
+func TestListUsersSearchesAndPaginates(t *testing.T) {
+	// 1.- Creamos cuentas con distintas fechas de alta, roles y nombres.
+	repo := newFakeUserRepository()
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for i, user := range []User{
+		{Email: "ana@example.com", Role: RoleOperator, DisplayName: "Ana Ruiz"},
+		{Email: "beto@example.com", Role: RoleCitizen, DisplayName: "Alberto"},
+		{Email: "carla@example.com", Role: RoleOperator, DisplayName: "Carla"},
+	} {
+		user.PasswordHash, user.CreatedAt = "$2a$hash", base.Add(time.Duration(i)*time.Hour)
+		if err := repo.Create(context.Background(), user); err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+	}
+	svc := NewAuthService(repo, 1, time.Minute, []byte("test-secret"))
+	ctx := context.Background()
+
+	// 2.- La búsqueda ignora mayúsculas y considera el nombre; el rol filtra y la página se acota.
+	page, err := svc.ListUsers(ctx, UserQuery{Search: "ALBERTO"})
+	if err != nil || page.TotalCount != 1 || page.Items[0].Email != "beto@example.com" {
+		t.Fatalf("unexpected search result %+v (%v)", page, err)
+	}
+	page, err = svc.ListUsers(ctx, UserQuery{Role: RoleOperator, PageSize: 1})
+	if err != nil || page.TotalCount != 2 || !page.HasMore || page.Items[0].Email != "carla@example.com" {
+		t.Fatalf("unexpected first page %+v (%v)", page, err)
+	}
+	page, err = svc.ListUsers(ctx, UserQuery{Role: RoleOperator, Page: 1, PageSize: 1})
+	if err != nil || page.HasMore || page.Items[0].Email != "ana@example.com" {
+		t.Fatalf("unexpected second page %+v (%v)", page, err)
+	}
+	if _, err := svc.ListUsers(ctx, UserQuery{Role: "root"}); !errors.Is(err, ErrInvalidRole) {
+		t.Fatalf("expected invalid role, got %v", err)
+	}
+}
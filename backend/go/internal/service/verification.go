@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultVerifyTTL = 48 * time.Hour
+	// verifyResendCooldown es la espera mínima entre reenvíos del correo de confirmación a una misma cuenta.
+	verifyResendCooldown = time.Minute
+)
+
+// 1.- ErrEmailUnverified distingue las acciones bloqueadas hasta confirmar el correo.
+var ErrEmailUnverified = errors.New("email not verified")
+
+// 2.- WithEmailVerification registra cuentas sin verificar y envía el token de confirmación.
+func WithEmailVerification(ttl time.Duration, verifyURL string) AuthOption {
+	return func(s *AuthService) {
+		s.emailVerification = true
+		s.verifyTTL = defaultVerifyTTL
+		if ttl > 0 {
+			s.verifyTTL = ttl
+		}
+		s.verifyURL = strings.TrimSpace(verifyURL)
+		s.verifyResendLimiter = newRateLimiter(verifyResendCooldown)
+	}
+}
+
+// 3.- WithUnverifiedPermissions limita a los permisos indicados las cuentas que aún no confirman su correo.
+func WithUnverifiedPermissions(perms ...Permission) AuthOption {
+	return func(s *AuthService) {
+		s.unverifiedPermissions = make(map[Permission]struct{}, len(perms))
+		for _, perm := range perms {
+			s.unverifiedPermissions[perm] = struct{}{}
+		}
+	}
+}
+
+// 4.- Authorize combina el permiso del rol con la restricción para correos sin verificar.
+func (s *AuthService) Authorize(principal Principal, perm Permission) error {
+	if !principal.Can(perm) {
+		return ErrForbidden
+	}
+	if principal.EmailVerified || principal.APIKeyID != "" || principal.Role == RoleAnonymous || s.unverifiedPermissions == nil {
+		return nil
+	}
+	if _, ok := s.unverifiedPermissions[perm]; !ok {
+		return ErrEmailUnverified
+	}
+	return nil
+}
+
+// 5.- VerifyEmail consume el token de confirmación y marca la cuenta como verificada.
+func (s *AuthService) VerifyEmail(ctx context.Context, token string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+	if s.oneTimeTokens == nil || strings.TrimSpace(token) == "" {
+		return ErrInvalidOneTimeToken
+	}
+	consumed, err := s.oneTimeTokens.Consume(ctx, TokenPurposeVerify, hashToken(strings.TrimSpace(token)), s.now())
+	if err != nil {
+		return err
+	}
+	if err := s.repo.MarkVerified(ctx, consumed.Subject, s.now()); err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return ErrInvalidOneTimeToken
+		}
+		return err
+	}
+	s.logger.Info().
+		Str("event", "auth.email.verified").
+		Str("subject", consumed.Subject).
+		Msg("email verified")
+	return nil
+}
+
+// 6.- ResendVerification emite un token nuevo; las cuentas ya verificadas no reciben correo.
+// Cada cuenta puede pedir un reenvío por ventana; antes de tiempo devuelve *RateLimitError.
+func (s *AuthService) ResendVerification(ctx context.Context, email string) error {
+	user, err := s.repo.FindByEmail(ctx, strings.TrimSpace(strings.ToLower(email)))
+	if err != nil {
+		return err
+	}
+	if user.Verified() {
+		return nil
+	}
+	if s.verifyResendLimiter != nil {
+		if retryAfter, ok := s.verifyResendLimiter.allow(user.Email, 1, s.now()); !ok {
+			return &RateLimitError{RetryAfter: retryAfter}
+		}
+	}
+	return s.sendVerification(ctx, user.Email)
+}
+
+// 7.- sendVerification emite el token y encola el correo de confirmación.
+func (s *AuthService) sendVerification(ctx context.Context, email string) error {
+	if !s.emailVerification || s.oneTimeTokens == nil || s.mailer == nil {
+		return errors.New("email verification delivery is not configured")
+	}
+	token, err := s.issueOneTimeToken(ctx, TokenPurposeVerify, email, s.verifyTTL)
+	if err != nil {
+		return err
+	}
+	s.enqueueMail(MailMessage{
+		To:      email,
+		Subject: "Confirma tu correo",
+		Body:    s.verifyBody(token),
+	})
+	return nil
+}
+
+// 8.- verifyBody arma el texto con el enlace o, si no hay URL configurada, con el token en claro.
+func (s *AuthService) verifyBody(token string) string {
+	action := "Usa este código en la aplicación para confirmar tu correo:\n\n" + token
+	if s.verifyURL != "" {
+		action = "Abre este enlace para confirmar tu correo:\n\n" + s.verifyURL + "?token=" + url.QueryEscape(token)
+	}
+	hours := int(s.verifyTTL / time.Hour)
+	return fmt.Sprintf("Gracias por registrarte.\n\n%s\n\nVence en %d horas. Si no creaste esta cuenta, ignora este correo.\n", action, hours)
+}
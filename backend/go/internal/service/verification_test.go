@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestEmailVerificationRestrictsUnverifiedAccounts(t *testing.T) {
+	// 1.- El registro emite un token sin verificar y envía el enlace de confirmación.
+	mailer := newChannelMailer()
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Minute, []byte("test-secret"),
+		WithOneTimeTokens(newMemoryOneTimeTokenStore()), WithMailer(mailer),
+		WithEmailVerification(time.Hour, "https://app.example.com/verify"),
+		WithUnverifiedPermissions(PermReportsSubmit), WithPasswordHasher(NewBcryptHasher(bcrypt.MinCost)))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	session, err := svc.Register(ctx, "vecino@example.com", "s3cr3t-pass")
+	if err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	principal, err := svc.ValidateToken(ctx, session.Token)
+	if err != nil || principal.EmailVerified {
+		t.Fatalf("expected unverified principal, got %+v (%v)", principal, err)
+	}
+	message := mailer.wait(t)
+	if message.To != "vecino@example.com" || !strings.Contains(message.Body, "https://app.example.com/verify?token=") {
+		t.Fatalf("unexpected verification mail: %+v", message)
+	}
+
+	// 2.- Mientras no confirme sólo conserva los permisos configurados.
+	if err := svc.Authorize(principal, PermReportsSubmit); err != nil {
+		t.Fatalf("expected submit to be allowed, got %v", err)
+	}
+	if err := svc.Authorize(principal, PermReportsRead); !errors.Is(err, ErrEmailUnverified) {
+		t.Fatalf("expected ErrEmailUnverified, got %v", err)
+	}
+	if err := svc.Authorize(principal, PermUsersManage); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected role check to take precedence, got %v", err)
+	}
+
+	// 3.- El token confirma el correo una sola vez y los tokens nuevos llevan ev=true.
+	token := tokenFromBody(t, message.Body)
+	if err := svc.VerifyEmail(ctx, token); err != nil {
+		t.Fatalf("VerifyEmail returned error: %v", err)
+	}
+	if err := svc.VerifyEmail(ctx, token); !errors.Is(err, ErrInvalidOneTimeToken) {
+		t.Fatalf("expected single-use token, got %v", err)
+	}
+	fresh, err := svc.Authenticate(ctx, "vecino@example.com", "s3cr3t-pass")
+	if err != nil {
+		t.Fatalf("Authenticate returned error: %v", err)
+	}
+	principal, _ = svc.ValidateToken(ctx, fresh.Token)
+	if !principal.EmailVerified || svc.Authorize(principal, PermReportsRead) != nil {
+		t.Fatalf("expected verified principal, got %+v", principal)
+	}
+
+	// 4.- Las cuentas ya verificadas no reciben más correos al pedir reenvío.
+	if err := svc.ResendVerification(ctx, "vecino@example.com"); err != nil {
+		t.Fatalf("ResendVerification returned error: %v", err)
+	}
+	select {
+	case extra := <-mailer.sent:
+		t.Fatalf("unexpected mail for verified account: %+v", extra)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestResendVerificationHasAPerAccountCooldown(t *testing.T) {
+	// 1.- El primer reenvío sale de inmediato y uno seguido se rechaza con el tiempo restante.
+	now := time.Now()
+	mailer := newChannelMailer()
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Minute, []byte("test-secret"),
+		WithOneTimeTokens(newMemoryOneTimeTokenStore()), WithMailer(mailer),
+		WithEmailVerification(time.Hour, ""), WithClock(func() time.Time { return now }))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for _, email := range []string{"vecino@example.com", "vecina@example.com"} {
+		if _, err := svc.Register(ctx, email, "s3cr3t-pass"); err != nil {
+			t.Fatalf("Register returned error: %v", err)
+		}
+		mailer.wait(t)
+	}
+	if err := svc.ResendVerification(ctx, "vecino@example.com"); err != nil {
+		t.Fatalf("ResendVerification returned error: %v", err)
+	}
+	mailer.wait(t)
+	now = now.Add(20 * time.Second)
+	var limited *RateLimitError
+	if err := svc.ResendVerification(ctx, "vecino@example.com"); !errors.As(err, &limited) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+	if limited.RetryAfter != verifyResendCooldown-20*time.Second {
+		t.Fatalf("unexpected retry after %s", limited.RetryAfter)
+	}
+
+	// 2.- La espera es por cuenta: otra cuenta puede pedir su reenvío.
+	if err := svc.ResendVerification(ctx, "vecina@example.com"); err != nil {
+		t.Fatalf("ResendVerification returned error: %v", err)
+	}
+	mailer.wait(t)
+
+	// 3.- Pasada la ventana, la primera cuenta vuelve a recibir su correo.
+	now = now.Add(verifyResendCooldown)
+	if err := svc.ResendVerification(ctx, "vecino@example.com"); err != nil {
+		t.Fatalf("ResendVerification returned error: %v", err)
+	}
+	mailer.wait(t)
+}
+
+func TestAccountsAreVerifiedWithoutEmailVerification(t *testing.T) {
+	// 1.- Sin la opción de verificación los registros nacen verificados.
+	svc := NewAuthService(newFakeUserRepository(), 1, time.Minute, []byte("test-secret"), WithUnverifiedPermissions())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	session, err := svc.Register(ctx, "vecino@example.com", "s3cr3t-pass")
+	if err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	principal, err := svc.ValidateToken(ctx, session.Token)
+	if err != nil || !principal.EmailVerified {
+		t.Fatalf("expected verified principal, got %+v (%v)", principal, err)
+	}
+
+	// 2.- Los tokens emitidos antes de existir el claim ev se tratan como verificados.
+	legacy, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, AccessClaims{Role: RoleCitizen, RegisteredClaims: jwt.RegisteredClaims{
+		Subject:   "vecino@example.com",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}}).SignedString([]byte("test-secret"))
+	principal, err = svc.ValidateToken(ctx, legacy)
+	if err != nil || !principal.EmailVerified {
+		t.Fatalf("expected legacy token to be verified, got %+v (%v)", principal, err)
+	}
+}
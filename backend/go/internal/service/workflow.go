@@ -0,0 +1,213 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 1.- Categorías de métricas a las que puede contribuir cada estatus.
+const (
+	MetricPending  = "pending"
+	MetricResolved = "resolved"
+	MetricCritical = "critical"
+)
+
+// 2.- WorkflowStatus describe un estatus configurable del flujo de atención.
+type WorkflowStatus struct {
+	ID       string `json:"id"`
+	Label    string `json:"label"`
+	Terminal bool   `json:"terminal"`
+	Metric   string `json:"metric,omitempty"`
+}
+
+// 3.- WorkflowTransition declara un cambio permitido y si exige comentario.
+type WorkflowTransition struct {
+	From           string `json:"from"`
+	To             string `json:"to"`
+	RequiresReason bool   `json:"requiresReason"`
+}
+
+// 4.- WorkflowDefinition es el documento JSON que cada municipio puede ajustar.
+type WorkflowDefinition struct {
+	Initial     string               `json:"initial"`
+	Statuses    []WorkflowStatus     `json:"statuses"`
+	Transitions []WorkflowTransition `json:"transitions"`
+}
+
+// 5.- Workflow indexa la definición validada para consultas rápidas.
+type Workflow struct {
+	definition  WorkflowDefinition
+	statuses    map[string]WorkflowStatus
+	transitions map[string]map[string]WorkflowTransition
+}
+
+// 6.- Errores del flujo para distinguir estatus desconocidos de transiciones inválidas.
+var (
+	ErrInvalidTransition = errors.New("invalid status transition")
+	ErrStatusConflict    = errors.New("report status changed concurrently")
+)
+
+// 7.- TransitionError detalla por qué se rechazó una transición.
+type TransitionError struct {
+	From           string
+	To             string
+	ReasonRequired bool
+}
+
+func (e *TransitionError) Error() string {
+	if e.ReasonRequired {
+		return fmt.Sprintf("transition from %s to %s requires a reason", e.From, e.To)
+	}
+	return fmt.Sprintf("transition from %s to %s is not allowed", e.From, e.To)
+}
+
+// Unwrap permite usar errors.Is(err, ErrInvalidTransition).
+func (e *TransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+// 8.- DefaultWorkflow reproduce los estatus históricos del servicio.
+func DefaultWorkflow() *Workflow {
+	workflow, err := NewWorkflow(WorkflowDefinition{
+		Initial: "en_revision",
+		Statuses: []WorkflowStatus{
+			{ID: "en_revision", Label: "En revisión", Metric: MetricPending},
+			{ID: "en_proceso", Label: "En proceso"},
+			{ID: "critico", Label: "Crítico", Metric: MetricCritical},
+			{ID: "resuelto", Label: "Resuelto", Metric: MetricResolved},
+		},
+		Transitions: []WorkflowTransition{
+			{From: "en_revision", To: "en_proceso"},
+			{From: "en_revision", To: "critico"},
+			{From: "en_revision", To: "resuelto"},
+			{From: "en_proceso", To: "critico"},
+			{From: "en_proceso", To: "resuelto"},
+			{From: "en_proceso", To: "en_revision", RequiresReason: true},
+			{From: "critico", To: "en_proceso"},
+			{From: "critico", To: "resuelto"},
+			{From: "resuelto", To: "en_revision", RequiresReason: true},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return workflow
+}
+
+// 9.- LoadWorkflow lee y valida una definición JSON desde disco.
+func LoadWorkflow(path string) (*Workflow, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read workflow: %w", err)
+	}
+	var definition WorkflowDefinition
+	if err := json.Unmarshal(raw, &definition); err != nil {
+		return nil, fmt.Errorf("decode workflow: %w", err)
+	}
+	return NewWorkflow(definition)
+}
+
+// 10.- NewWorkflow valida la coherencia de estatus, transiciones y estados terminales.
+func NewWorkflow(definition WorkflowDefinition) (*Workflow, error) {
+	w := &Workflow{
+		definition:  definition,
+		statuses:    make(map[string]WorkflowStatus, len(definition.Statuses)),
+		transitions: make(map[string]map[string]WorkflowTransition),
+	}
+	for _, status := range definition.Statuses {
+		id := strings.TrimSpace(status.ID)
+		if id == "" {
+			return nil, errors.New("workflow status id is required")
+		}
+		if _, dup := w.statuses[id]; dup {
+			return nil, fmt.Errorf("workflow status %s declared twice", id)
+		}
+		switch status.Metric {
+		case "", MetricPending, MetricResolved, MetricCritical:
+		default:
+			return nil, fmt.Errorf("workflow status %s has unknown metric %s", id, status.Metric)
+		}
+		w.statuses[id] = status
+	}
+	initial, ok := w.statuses[definition.Initial]
+	if !ok {
+		return nil, fmt.Errorf("workflow initial status %q is not declared", definition.Initial)
+	}
+	if initial.Terminal {
+		return nil, errors.New("workflow initial status cannot be terminal")
+	}
+	for _, transition := range definition.Transitions {
+		from, ok := w.statuses[transition.From]
+		if !ok {
+			return nil, fmt.Errorf("workflow transition from unknown status %s", transition.From)
+		}
+		if _, ok := w.statuses[transition.To]; !ok {
+			return nil, fmt.Errorf("workflow transition to unknown status %s", transition.To)
+		}
+		if from.Terminal {
+			return nil, fmt.Errorf("workflow status %s is terminal and cannot transition", from.ID)
+		}
+		if transition.From == transition.To {
+			return nil, fmt.Errorf("workflow transition %s loops onto itself", transition.From)
+		}
+		if w.transitions[transition.From] == nil {
+			w.transitions[transition.From] = make(map[string]WorkflowTransition)
+		}
+		w.transitions[transition.From][transition.To] = transition
+	}
+	return w, nil
+}
+
+// 11.- Definition devuelve la definición vigente para publicarla a los clientes.
+func (w *Workflow) Definition() WorkflowDefinition {
+	return w.definition
+}
+
+// 12.- Initial indica el estatus asignado a los reportes recién recibidos.
+func (w *Workflow) Initial() string {
+	return w.definition.Initial
+}
+
+// 13.- HasStatus confirma que el estatus pertenece al flujo configurado.
+func (w *Workflow) HasStatus(id string) bool {
+	_, ok := w.statuses[id]
+	return ok
+}
+
+// 14.- CheckTransition aplica las reglas de transición y comentario obligatorio.
+func (w *Workflow) CheckTransition(from, to, reason string) error {
+	if !w.HasStatus(to) {
+		return ErrInvalidStatus
+	}
+	transition, ok := w.transitions[from][to]
+	if !ok {
+		return &TransitionError{From: from, To: to}
+	}
+	if transition.RequiresReason && strings.TrimSpace(reason) == "" {
+		return &TransitionError{From: from, To: to, ReasonRequired: true}
+	}
+	return nil
+}
+
+// 15.- Metrics agrupa los conteos por estatus según la categoría configurada.
+func (w *Workflow) Metrics(counts map[string]int) AdminDashboardMetrics {
+	metrics := AdminDashboardMetrics{ByStatus: make(map[string]int, len(w.statuses))}
+	for id := range w.statuses {
+		metrics.ByStatus[id] = 0
+	}
+	for id, count := range counts {
+		metrics.ByStatus[id] = count
+		switch w.statuses[id].Metric {
+		case MetricPending:
+			metrics.PendingReports += count
+		case MetricResolved:
+			metrics.ResolvedReports += count
+		case MetricCritical:
+			metrics.CriticalIncidents += count
+		}
+	}
+	return metrics
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLoadWorkflowSupportsCustomStatuses(t *testing.T) {
+	// 1.- Cargamos la definición de ejemplo con estatus terminales adicionales.
+	workflow, err := LoadWorkflow("../../config/workflow.example.json")
+	if err != nil {
+		t.Fatalf("LoadWorkflow returned error: %v", err)
+	}
+	if !workflow.HasStatus("rechazado") || !workflow.HasStatus("duplicado") {
+		t.Fatalf("expected custom statuses to be declared")
+	}
+
+	// 2.- Las transiciones que exigen comentario fallan sin motivo.
+	err = workflow.CheckTransition("en_revision", "duplicado", " ")
+	var transitionErr *TransitionError
+	if !errors.As(err, &transitionErr) || !transitionErr.ReasonRequired {
+		t.Fatalf("expected reason-required TransitionError, got %v", err)
+	}
+	if err := workflow.CheckTransition("en_revision", "duplicado", "Mismo bache que F-2026-0000001-2"); err != nil {
+		t.Fatalf("expected transition with reason to pass, got %v", err)
+	}
+
+	// 3.- Los estatus terminales no permiten salir de ellos.
+	if err := workflow.CheckTransition("rechazado", "en_proceso", "reabrir"); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected ErrInvalidTransition from terminal status, got %v", err)
+	}
+	if err := workflow.CheckTransition("en_revision", "inexistente", ""); !errors.Is(err, ErrInvalidStatus) {
+		t.Fatalf("expected ErrInvalidStatus for unknown status, got %v", err)
+	}
+}
+
+func TestNewWorkflowRejectsTransitionsOutOfTerminalStatus(t *testing.T) {
+	// 1.- Una definición incoherente debe rechazarse al arrancar.
+	_, err := NewWorkflow(WorkflowDefinition{
+		Initial: "abierto",
+		Statuses: []WorkflowStatus{
+			{ID: "abierto"},
+			{ID: "cerrado", Terminal: true},
+		},
+		Transitions: []WorkflowTransition{
+			{From: "abierto", To: "cerrado"},
+			{From: "cerrado", To: "abierto"},
+		},
+	})
+	if err == nil {
+		t.Fatalf("expected validation error for terminal transition")
+	}
+}
+
+func TestUpdateStatusEnforcesWorkflow(t *testing.T) {
+	// 1.- Configuramos el servicio con el flujo personalizado.
+	workflow, err := LoadWorkflow("../../config/workflow.example.json")
+	if err != nil {
+		t.Fatalf("LoadWorkflow returned error: %v", err)
+	}
+	repo := newFakeReportRepository()
+	svc := NewReportService(repo, newTestFolioGenerator(), 1, 1, WithWorkflow(workflow))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	created, err := svc.Submit(ctx, "vecino@example.com", map[string]any{"incidentTypeId": "trash", "description": "Basura"})
+	if err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+
+	// 2.- Saltar directamente a resuelto no está permitido por el flujo.
+	if _, err := svc.UpdateStatus(ctx, created.ID, StatusChange{Status: "resuelto"}); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected ErrInvalidTransition, got %v", err)
+	}
+
+	// 3.- Marcar como rechazado con motivo se refleja en las métricas por estatus.
+	if _, err := svc.UpdateStatus(ctx, created.ID, StatusChange{Status: "rechazado", Reason: "Fuera del municipio"}); err != nil {
+		t.Fatalf("UpdateStatus returned error: %v", err)
+	}
+	metrics, err := svc.DashboardMetrics(ctx)
+	if err != nil {
+		t.Fatalf("DashboardMetrics returned error: %v", err)
+	}
+	if metrics.ByStatus["rechazado"] != 1 || metrics.PendingReports != 0 {
+		t.Fatalf("unexpected metrics: %+v", metrics)
+	}
+}
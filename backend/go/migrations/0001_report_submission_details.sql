@@ -0,0 +1,5 @@
+-- 0001: persiste los datos completos de la solicitud ciudadana.
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS address TEXT NOT NULL DEFAULT '';
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS evidence_urls JSONB NOT NULL DEFAULT '[]'::jsonb;
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS contact_email TEXT;
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS contact_phone TEXT;
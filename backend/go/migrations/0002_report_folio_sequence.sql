@@ -0,0 +1,2 @@
+-- 0002: secuencia durable compartida para generar folios sin colisiones.
+CREATE SEQUENCE IF NOT EXISTS report_folio_seq START WITH 1 INCREMENT BY 1;
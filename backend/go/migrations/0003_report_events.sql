@@ -0,0 +1,17 @@
+-- 0003: bitácora de eventos que respalda el historial de cada folio.
+CREATE TABLE IF NOT EXISTS report_events (
+    id BIGSERIAL PRIMARY KEY,
+    report_id TEXT NOT NULL REFERENCES reports(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    status TEXT NOT NULL,
+    actor TEXT NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS report_events_report_id_idx ON report_events (report_id, created_at);
+
+-- Los reportes previos reciben su evento de recepción para no quedar sin historial.
+INSERT INTO report_events (report_id, kind, status, created_at)
+SELECT r.id, 'submitted', r.status, r.created_at
+FROM reports r
+WHERE NOT EXISTS (SELECT 1 FROM report_events e WHERE e.report_id = r.id);
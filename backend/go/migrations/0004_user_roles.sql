@@ -0,0 +1,4 @@
+-- 0004: roles de acceso para ciudadanos y personal municipal.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'citizen';
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('citizen', 'operator', 'supervisor', 'admin'));
@@ -0,0 +1,12 @@
+-- 0006: lista de denegación de tokens de acceso y cortes por usuario.
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS revoked_tokens_expires_idx ON revoked_tokens (expires_at);
+
+CREATE TABLE IF NOT EXISTS revoked_subjects (
+    subject TEXT PRIMARY KEY,
+    revoked_before TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
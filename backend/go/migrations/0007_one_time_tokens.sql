@@ -0,0 +1,11 @@
+-- 0007: tokens de un solo uso enviados por correo (restablecimiento de contraseña).
+CREATE TABLE IF NOT EXISTS one_time_tokens (
+    id TEXT PRIMARY KEY,
+    purpose TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS one_time_tokens_subject_idx ON one_time_tokens (purpose, subject) WHERE used_at IS NULL;
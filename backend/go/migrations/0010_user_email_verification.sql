@@ -0,0 +1,3 @@
+-- 0010: estado de verificación del correo; las cuentas existentes se consideran verificadas.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS verified_at TIMESTAMPTZ DEFAULT NOW();
+ALTER TABLE users ALTER COLUMN verified_at DROP DEFAULT;
@@ -0,0 +1,15 @@
+-- 0011: secretos TOTP por usuario y códigos de recuperación de un solo uso.
+CREATE TABLE IF NOT EXISTS user_mfa (
+    email TEXT PRIMARY KEY REFERENCES users (email) ON UPDATE CASCADE ON DELETE CASCADE,
+    secret TEXT NOT NULL,
+    confirmed_at TIMESTAMPTZ,
+    last_used_step BIGINT NOT NULL DEFAULT -1,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    email TEXT NOT NULL REFERENCES users (email) ON UPDATE CASCADE ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMPTZ,
+    PRIMARY KEY (email, code_hash)
+);
@@ -0,0 +1,2 @@
+-- 0012: los hashes argon2id en formato PHC superan los 60 caracteres de bcrypt.
+ALTER TABLE users ALTER COLUMN password_hash TYPE TEXT;
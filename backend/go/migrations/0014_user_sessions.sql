@@ -0,0 +1,20 @@
+-- 0014: sesiones por dispositivo y registros de notificaciones push asociados a cada una.
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id TEXT PRIMARY KEY,
+    email TEXT NOT NULL REFERENCES users (email) ON UPDATE CASCADE ON DELETE CASCADE,
+    device_name TEXT NOT NULL DEFAULT '',
+    platform TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    last_seen_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS user_sessions_email_idx ON user_sessions (email);
+
+CREATE TABLE IF NOT EXISTS push_registrations (
+    session_id TEXT PRIMARY KEY REFERENCES user_sessions (id) ON DELETE CASCADE,
+    provider TEXT NOT NULL,
+    token TEXT NOT NULL UNIQUE,
+    updated_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,3 @@
+-- 0015: suspensión de cuentas y búsqueda del listado administrativo de usuarios.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS users_created_at_idx ON users (created_at DESC);
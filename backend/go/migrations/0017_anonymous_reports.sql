@@ -0,0 +1,3 @@
+-- 0017: reportes enviados sin cuenta y búsqueda de su autor para reclamarlos al registrarse.
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS anonymous BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE INDEX IF NOT EXISTS report_events_submitted_actor_idx ON report_events (actor) WHERE kind = 'submitted';
@@ -0,0 +1,7 @@
+-- 0018: autor de cada reporte para que el ciudadano consulte sólo los suyos.
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS reporter TEXT;
+UPDATE reports r
+SET reporter = e.actor
+FROM report_events e
+WHERE e.report_id = r.id AND e.kind = 'submitted' AND r.reporter IS NULL AND e.actor <> 'deleted-user';
+CREATE INDEX IF NOT EXISTS reports_reporter_created_idx ON reports (reporter, created_at DESC) WHERE reporter IS NOT NULL;
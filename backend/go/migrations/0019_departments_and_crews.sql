@@ -0,0 +1,25 @@
+-- 0019: dependencias, cuadrillas y la asignación vigente de cada reporte.
+CREATE TABLE IF NOT EXISTS departments (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS departments_name_idx ON departments (LOWER(name));
+
+CREATE TABLE IF NOT EXISTS crews (
+    id TEXT PRIMARY KEY,
+    department_id TEXT NOT NULL REFERENCES departments(id),
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS crews_department_name_idx ON crews (department_id, LOWER(name));
+
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS department_id TEXT REFERENCES departments(id);
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS crew_id TEXT REFERENCES crews(id);
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS assigned_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS reports_department_idx ON reports (department_id, created_at DESC) WHERE department_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS reports_crew_idx ON reports (crew_id, created_at DESC) WHERE crew_id IS NOT NULL;
+
+-- La bitácora conserva a quién se asignó cada vez, aunque después se reasigne.
+ALTER TABLE report_events ADD COLUMN IF NOT EXISTS department_id TEXT;
+ALTER TABLE report_events ADD COLUMN IF NOT EXISTS crew_id TEXT;
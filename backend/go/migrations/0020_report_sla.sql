@@ -0,0 +1,23 @@
+-- 0020: inicio de la estancia en el estatus actual e incumplimientos de SLA registrados.
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMPTZ;
+UPDATE reports r
+SET status_changed_at = COALESCE(
+    (SELECT MAX(e.created_at) FROM report_events e WHERE e.report_id = r.id AND e.kind = 'status_changed'),
+    r.created_at
+)
+WHERE r.status_changed_at IS NULL;
+ALTER TABLE reports ALTER COLUMN status_changed_at SET DEFAULT NOW();
+ALTER TABLE reports ALTER COLUMN status_changed_at SET NOT NULL;
+CREATE INDEX IF NOT EXISTS reports_status_changed_idx ON reports (status, status_changed_at);
+
+-- Una fila por estancia incumplida; un reporte que vuelve al mismo estatus abre una estancia nueva.
+CREATE TABLE IF NOT EXISTS report_sla_breaches (
+    id BIGSERIAL PRIMARY KEY,
+    report_id TEXT NOT NULL REFERENCES reports(id) ON DELETE CASCADE,
+    incident_type_id TEXT NOT NULL,
+    status TEXT NOT NULL,
+    entered_at TIMESTAMPTZ NOT NULL,
+    due_at TIMESTAMPTZ NOT NULL,
+    breached_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (report_id, status, entered_at)
+);
@@ -0,0 +1,12 @@
+-- 0021: notas internas del personal; viven fuera de report_events para no llegar al seguimiento ciudadano.
+CREATE TABLE IF NOT EXISTS report_staff_notes (
+    id TEXT PRIMARY KEY,
+    report_id TEXT NOT NULL REFERENCES reports(id) ON DELETE CASCADE,
+    author TEXT NOT NULL,
+    body TEXT NOT NULL,
+    mentions JSONB NOT NULL DEFAULT '[]'::jsonb,
+    attachments JSONB NOT NULL DEFAULT '[]'::jsonb,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    edited_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS report_staff_notes_report_idx ON report_staff_notes (report_id, created_at);
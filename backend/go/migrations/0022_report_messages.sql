@@ -0,0 +1,12 @@
+-- 0022: conversación pública entre el personal y quien reportó; separada de las notas internas.
+CREATE TABLE IF NOT EXISTS report_messages (
+    id TEXT PRIMARY KEY,
+    report_id TEXT NOT NULL REFERENCES reports(id) ON DELETE CASCADE,
+    sender TEXT NOT NULL CHECK (sender IN ('staff', 'citizen')),
+    author TEXT NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    read_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS report_messages_report_idx ON report_messages (report_id, created_at);
+CREATE INDEX IF NOT EXISTS report_messages_unread_idx ON report_messages (report_id, sender) WHERE read_at IS NULL;
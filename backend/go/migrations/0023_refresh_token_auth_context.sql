@@ -0,0 +1,3 @@
+-- 0023: cada familia de renovación conserva los métodos (amr) y la hora del inicio de sesión que la creó.
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS amr JSONB NOT NULL DEFAULT '[]'::jsonb;
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS auth_time TIMESTAMPTZ;